@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// runDLQ реализует команду "dlq": просмотр очереди недоставленных событий,
+// повторное сохранение и удаление.
+//
+//	client dlq list -config cfg.json
+//	client dlq reprocess -config cfg.json [-id ID]
+//	client dlq drop -config cfg.json -id ID
+func runDLQ(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: client dlq list|reprocess|drop [flags]")
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("dlq "+action, flag.ExitOnError)
+	configPath := fs.String("config", "config/client_config.json", "Path to client configuration file")
+	dbPath := fs.String("db", "", "Path to client database (overrides config)")
+	id := fs.String("id", "", "Only the dead letter with this event ID")
+	fs.Parse(args[1:])
+
+	repo, closeDB, err := openRepository(*configPath, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	dlq, err := openDeadLetters(repo)
+	if err != nil {
+		return err
+	}
+	letters, err := dlq.List(0)
+	if err != nil {
+		return err
+	}
+	if *id != "" {
+		var matched []repository.DeadLetter
+		for _, letter := range letters {
+			if letter.Event.ID == *id {
+				matched = append(matched, letter)
+			}
+		}
+		letters = matched
+	}
+
+	switch action {
+	case "list":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "FAILED_AT\tID\tTYPE\tATTEMPTS\tERROR")
+		for _, l := range letters {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", l.FailedAt.Format(time.RFC3339), l.Event.ID, l.Event.Type, l.Attempts, l.Error)
+		}
+		return tw.Flush()
+	case "reprocess":
+		saved, failed := 0, 0
+		for _, l := range letters {
+			if err := repo.Save(l.Event); err != nil {
+				failed++
+				l.Attempts++
+				l.Error, l.FailedAt = err.Error(), time.Now()
+				if err := dlq.Put(l); err != nil {
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "event %s: %v\n", l.Event.ID, err)
+				continue
+			}
+			if err := dlq.Remove(l.Event.ID); err != nil {
+				return err
+			}
+			saved++
+		}
+		fmt.Printf("reprocessed %d, failed %d\n", saved, failed)
+		return nil
+	case "drop":
+		if *id == "" {
+			return fmt.Errorf("dlq drop requires -id")
+		}
+		for _, l := range letters {
+			if err := dlq.Remove(l.Event.ID); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("dropped %d\n", len(letters))
+		return nil
+	default:
+		return fmt.Errorf("unknown dlq action %q", action)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/export"
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// runExport реализует команду "export": выгрузку событий клиентской БД в CSV, JSON или NDJSON.
+//
+//	client export -config cfg.json -format csv -type error -since 24h -o errors.csv
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := fs.String("config", "config/client_config.json", "Path to client configuration file")
+	dbPath := fs.String("db", "", "Path to client database (overrides config)")
+	format := fs.String("format", export.FormatNDJSON, "Output format: csv, json or ndjson")
+	output := fs.String("o", "-", "Output file (- for stdout)")
+	types := fs.String("type", "", "Comma-separated event types")
+	since := fs.String("since", "", "Only events at or after this time (RFC 3339 or duration like 1h)")
+	until := fs.String("until", "", "Only events before this time (RFC 3339 or duration like 10m)")
+	contains := fs.String("contains", "", "Only events whose message contains this substring")
+	fs.Parse(args)
+
+	filter := repository.EventFilter{Contains: *contains}
+	if *types != "" {
+		filter.Types = strings.Split(*types, ",")
+	}
+	var err error
+	if filter.Since, err = parseTimeArg(*since); err != nil {
+		return fmt.Errorf("invalid -since: %w", err)
+	}
+	if filter.Until, err = parseTimeArg(*until); err != nil {
+		return fmt.Errorf("invalid -until: %w", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	buf := bufio.NewWriter(out)
+	w, err := export.NewWriter(*format, buf)
+	if err != nil {
+		return err
+	}
+
+	repo, closeDB, err := openRepository(*configPath, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	count := 0
+	err = repo.Each(filter, func(e domain.Event) error {
+		count++
+		return w.Write(e)
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d events\n", count)
+	return nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/alert"
+	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/metrics"
+	"github.com/wrongjunior/eventsync/internal/repository"
+	"github.com/wrongjunior/eventsync/internal/service"
+	"github.com/wrongjunior/eventsync/internal/sink"
+	"log/slog"
+)
+
+// Режимы запуска нескольких клиентов.
+const (
+	// isolationShared — все клиенты используют один сервис и одно хранилище,
+	// поэтому событие, полученное несколькими клиентами, сохраняется один раз.
+	isolationShared = "shared"
+	// isolationIsolated — у каждого клиента свой сервис (кэш дедупликации,
+	// контрольная точка) и своё хранилище, как у независимых процессов.
+	isolationIsolated = "isolated"
+)
+
+// clientInstance — зависимости одного клиента.
+type clientInstance struct {
+	Service *service.ClientService
+	Outbox  *repository.SQLiteOutbox // nil — очередь исходящих сообщений выключена
+	Alerter *alert.Alerter           // nil — оповещения выключены
+	Sinks   *sink.Set                // nil — пересылка в приёмники выключена
+}
+
+// newClientInstances создаёт зависимости для cfg.NumClients клиентов: в общем
+// режиме сервис и очередь общие, в изолированном — у каждого клиента свои.
+func newClientInstances(cfg *config.ClientConfig, logger *slog.Logger, m *metrics.ClientMetrics) ([]clientInstance, error) {
+	switch cfg.Isolation {
+	case "", isolationShared:
+		shared, err := newClientInstance(cfg, logger, m)
+		if err != nil {
+			return nil, err
+		}
+		instances := make([]clientInstance, cfg.NumClients)
+		for i := range instances {
+			instances[i] = shared
+		}
+		return instances, nil
+	case isolationIsolated:
+		instances := make([]clientInstance, 0, cfg.NumClients)
+		for id := 1; id <= cfg.NumClients; id++ {
+			own := *cfg
+			own.DBPath = instanceDBPath(cfg.DBPath, id)
+			if own.OutboxPath != "" {
+				own.OutboxPath = instanceDBPath(cfg.OutboxPath, id)
+			}
+			inst, err := newClientInstance(&own, logger.With("client_id", id), m)
+			if err != nil {
+				closeInstances(instances)
+				return nil, fmt.Errorf("client %d: %w", id, err)
+			}
+			instances = append(instances, inst)
+		}
+		return instances, nil
+	default:
+		return nil, fmt.Errorf("unknown isolation mode %q", cfg.Isolation)
+	}
+}
+
+// newClientInstance создаёт сервис и, если задан outbox_path, очередь исходящих сообщений.
+func newClientInstance(cfg *config.ClientConfig, logger *slog.Logger, m *metrics.ClientMetrics) (clientInstance, error) {
+	cs, err := newClientService(cfg, logger, m)
+	if err != nil {
+		return clientInstance{}, err
+	}
+	inst := clientInstance{Service: cs}
+	if len(cfg.Alerts) > 0 {
+		alerter, err := newAlerter(cfg, logger)
+		if err != nil {
+			cs.Close()
+			return clientInstance{}, err
+		}
+		cs.OnEvent(service.AnyEventType, func(event domain.Event) error {
+			alerter.Notify(event)
+			return nil
+		})
+		inst.Alerter = alerter
+	}
+	if len(cfg.Sinks) > 0 {
+		sinks, err := newSinks(cfg, logger)
+		if err != nil {
+			inst.close()
+			return clientInstance{}, err
+		}
+		cs.OnEvent(service.AnyEventType, func(event domain.Event) error {
+			sinks.Notify(event)
+			return nil
+		})
+		inst.Sinks = sinks
+	}
+	if cfg.OutboxPath == "" {
+		return inst, nil
+	}
+	pool := dbPool(cfg)
+	if err := pool.Validate(); err != nil {
+		inst.close()
+		return clientInstance{}, err
+	}
+	db, err := sql.Open("sqlite3", cfg.OutboxPath)
+	if err != nil {
+		inst.close()
+		return clientInstance{}, err
+	}
+	pool.Apply(db)
+	inst.Outbox = repository.NewSQLiteOutbox(db)
+	if inst.Outbox.Cipher, err = loadCipher(cfg.Encryption); err != nil {
+		db.Close()
+		inst.close()
+		return clientInstance{}, err
+	}
+	if err := inst.Outbox.Init(); err != nil {
+		db.Close()
+		inst.close()
+		return clientInstance{}, fmt.Errorf("initialize outbox: %w", err)
+	}
+	return inst, nil
+}
+
+// newClientService открывает хранилище и настраивает сервис по конфигурации.
+func newClientService(cfg *config.ClientConfig, logger *slog.Logger, m *metrics.ClientMetrics) (*service.ClientService, error) {
+	repo, err := openStorage(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("initialize repository: %w", err)
+	}
+	cs := service.NewClientService(repo, logger)
+	eventTypes := cfg.EventTypes
+	if len(eventTypes) > 0 && cfg.Aggregate.Interval > 0 {
+		// Сводки приходят независимо от фильтра типов на сервере.
+		eventTypes = append(eventTypes[:len(eventTypes):len(eventTypes)], domain.EventTypeAggregate)
+	}
+	cs.SetEventTypes(eventTypes)
+	minSeverity, err := domain.ParseSeverity(cfg.MinSeverity)
+	if err != nil {
+		return nil, err
+	}
+	severities, err := domain.ParseSeverityMap(cfg.SeverityMap)
+	if err != nil {
+		return nil, err
+	}
+	cs.SetMinSeverity(minSeverity, severities)
+	if cfg.DedupCacheSize > 0 {
+		cs.SetDedupCacheSize(cfg.DedupCacheSize)
+	}
+	dedupMode, err := service.ParseDedupMode(cfg.DedupMode)
+	if err != nil {
+		return nil, err
+	}
+	cs.SetDedupMode(dedupMode)
+	if cfg.DedupWindow > 0 {
+		cs.SetDedupWindow(time.Duration(cfg.DedupWindow))
+	}
+	cs.SetSaveRetry(service.SaveRetryPolicy{
+		Attempts:       cfg.SaveRetry.Attempts,
+		InitialBackoff: time.Duration(cfg.SaveRetry.InitialBackoff),
+		MaxBackoff:     time.Duration(cfg.SaveRetry.MaxBackoff),
+	})
+	if cfg.Breaker.Threshold > 0 {
+		bufferSize := cfg.Breaker.BufferSize
+		if bufferSize <= 0 {
+			bufferSize = 1000
+		}
+		cs.EnableBreaker(service.BreakerPolicy{
+			Threshold:     cfg.Breaker.Threshold,
+			BufferSize:    bufferSize,
+			ProbeInterval: time.Duration(cfg.Breaker.ProbeInterval),
+		})
+	}
+	if cfg.DeadLetter.MaxFailures > 0 {
+		dlq, err := openDeadLetters(repo)
+		if err != nil {
+			return nil, err
+		}
+		cs.EnableDeadLetters(dlq, cfg.DeadLetter.MaxFailures)
+	}
+	if m != nil {
+		cs.SetMetrics(m)
+	}
+	if err := cs.LoadCheckpoint(); err != nil {
+		return nil, fmt.Errorf("load checkpoint: %w", err)
+	}
+	if cfg.WriteBatch.Size > 1 || cfg.WriteBatch.FlushInterval > 0 {
+		cs.EnableBatching(service.BatchPolicy{
+			Size:          cfg.WriteBatch.Size,
+			FlushInterval: time.Duration(cfg.WriteBatch.FlushInterval),
+			MaxInFlight:   cfg.WriteBatch.MaxInFlight,
+		})
+	}
+	if cfg.CausalOrder.Enabled {
+		maxWait := time.Duration(cfg.CausalOrder.MaxWait)
+		if maxWait <= 0 {
+			maxWait = 5 * time.Second
+		}
+		maxPending := cfg.CausalOrder.MaxPending
+		if maxPending <= 0 {
+			maxPending = 1000
+		}
+		cs.EnableCausalOrder(maxWait, maxPending)
+	}
+	if cfg.CRDT {
+		if _, err := cs.EnableCRDT(); err != nil {
+			cs.Close()
+			return nil, err
+		}
+	}
+	if cfg.Retention.MaxAge > 0 || cfg.Retention.MaxRows > 0 {
+		interval := time.Duration(cfg.Retention.Interval)
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		policy := service.RetentionPolicy{MaxAge: time.Duration(cfg.Retention.MaxAge), MaxRows: cfg.Retention.MaxRows}
+		if err := cs.StartPruner(policy, interval); err != nil {
+			cs.Close()
+			return nil, err
+		}
+	}
+	if cfg.Workers.Count > 0 {
+		policy, err := service.ParseOverflowPolicy(cfg.Workers.Overflow)
+		if err != nil {
+			cs.Close()
+			return nil, err
+		}
+		queueSize := cfg.Workers.QueueSize
+		if queueSize <= 0 {
+			queueSize = 1024
+		}
+		cs.EnableWorkers(cfg.Workers.Count, queueSize, policy)
+	}
+	return cs, nil
+}
+
+// instanceDBPath возвращает путь к хранилищу клиента id в изолированном
+// режиме: "client.db" -> "client-1.db".
+func instanceDBPath(path string, id int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), id, ext)
+}
+
+// closeInstances записывает буферы сервисов и закрывает очереди; общие
+// зависимости закрываются один раз.
+func closeInstances(instances []clientInstance) {
+	closed := make(map[*service.ClientService]bool)
+	for _, inst := range instances {
+		if closed[inst.Service] {
+			continue
+		}
+		closed[inst.Service] = true
+		inst.close()
+	}
+}
+
+// close записывает буфер сервиса, отправляет оставшиеся оповещения и
+// события приёмников и закрывает очередь исходящих сообщений.
+func (inst clientInstance) close() {
+	inst.Service.Close()
+	if inst.Alerter != nil {
+		inst.Alerter.Close()
+	}
+	if inst.Sinks != nil {
+		inst.Sinks.Close()
+	}
+	if inst.Outbox != nil {
+		inst.Outbox.Close()
+	}
+}
+
+// newAlerter создаёт отправку оповещений по правилам из конфигурации.
+func newAlerter(cfg *config.ClientConfig, logger *slog.Logger) (*alert.Alerter, error) {
+	severities, err := domain.ParseSeverityMap(cfg.SeverityMap)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]alert.Rule, 0, len(cfg.Alerts))
+	for _, c := range cfg.Alerts {
+		minSeverity, err := domain.ParseSeverity(c.MinSeverity)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, alert.Rule{
+			Name:         c.Name,
+			URL:          string(c.URL),
+			Types:        c.Types,
+			Topics:       c.Topics,
+			MinSeverity:  minSeverity,
+			Format:       c.Format,
+			Template:     c.Template,
+			MaxPerMinute: c.MaxPerMinute,
+		})
+	}
+	return alert.New(rules, severities, logger)
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
@@ -12,13 +13,38 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/wrongjunior/eventsync/internal/config"
-	"github.com/wrongjunior/eventsync/internal/repository"
-	"github.com/wrongjunior/eventsync/internal/service"
+	"github.com/wrongjunior/eventsync/internal/metrics"
+	"github.com/wrongjunior/eventsync/internal/systemd"
 	transportClient "github.com/wrongjunior/eventsync/internal/transport/client"
+	"github.com/wrongjunior/eventsync/internal/transport/local"
+	"github.com/wrongjunior/eventsync/internal/version"
 	"log/slog"
 )
 
+// commands — подкоманды клиента; без подкоманды клиент запускается в режиме синхронизации.
+var commands = map[string]func(args []string) error{
+	"config":  runConfig,
+	"query":   runQuery,
+	"export":  runExport,
+	"dlq":     runDLQ,
+	"record":  runRecord,
+	"replay":  runReplay,
+	"restore": runRestore,
+	"tail":    runTail,
+	"version": runVersion,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	configPath := flag.String("config", "config/client_config.json", "Path to client configuration file")
 	flag.Parse()
 
@@ -31,20 +57,43 @@ func main() {
 		Level: slog.LevelInfo,
 	}))
 
-	// Открываем подключение к БД для клиентского репозитория.
-	db, err := sql.Open("sqlite3", cfg.DBPath)
+	var clientMetrics *metrics.ClientMetrics
+	if cfg.MetricsAddr != "" {
+		registry := metrics.NewRegistry()
+		clientMetrics = metrics.NewClientMetrics(registry)
+		go serveMetrics(cfg.MetricsAddr, registry, logger)
+	}
+
+	// Инициализируем хранилища и бизнеслогику клиентов.
+	instances, err := newClientInstances(cfg, logger, clientMetrics)
 	if err != nil {
-		logger.Error("Failed to open database", "error", err)
+		logger.Error("Failed to initialize client", "error", err)
 		os.Exit(1)
 	}
-	repo := repository.NewSQLiteRepository(db)
-	if err := repo.Init(); err != nil {
-		logger.Error("Failed to initialize repository", "error", err)
-		os.Exit(1)
+
+	if cfg.TLS.InsecureSkipVerify {
+		logger.Warn("TLS certificate verification is disabled")
+	}
+	transports := make([]*transportClient.ClientTransport, cfg.NumClients)
+	for i := range transports {
+		if transports[i], err = newTransport(cfg, instances[i].Service, logger); err != nil {
+			logger.Error("Invalid connection settings", "error", err)
+			os.Exit(1)
+		}
 	}
 
-	// Инициализируем бизнеслогику клиента.
-	clientService := service.NewClientService(repo, logger)
+	if cfg.LocalAPIAddr != "" {
+		apiInstances := make([]local.Instance, len(transports))
+		for i, transport := range transports {
+			apiInstances[i] = local.Instance{ID: fmt.Sprintf("client-%d", i+1), Service: instances[i].Service, Connected: transport.Connected}
+		}
+		api := local.New(apiInstances, logger)
+		go func() {
+			if err := api.Serve(cfg.LocalAPIAddr); err != nil {
+				logger.Error("Local API server error", "error", err)
+			}
+		}()
+	}
 
 	// Создаем контекст, отменяемый сигналами ОС.
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
@@ -55,31 +104,76 @@ func main() {
 
 	// Запускаем заданное число клиентов.
 	numClients := cfg.NumClients
-	logger.Info("Starting clients", "num_clients", numClients)
+	logger.Info("Starting clients", "num_clients", numClients, "isolation", cfg.Isolation, "version", version.Get().Version)
 	for i := 0; i < numClients; i++ {
 		wg.Add(1)
 		go func(id int) {
 			defer wg.Done()
 			logger.Info("Starting client", "client_id", id)
-			transport := transportClient.NewClientTransport(cfg.ClientServerURL, clientService, logger)
+			transport := transports[id-1]
+			transport.ClientID = fmt.Sprintf("client-%d", id)
+			if outbox := instances[id-1].Outbox; outbox != nil {
+				transport.Outbox = outbox
+				transport.OutboxOwner = transport.ClientID
+			}
+			if clientMetrics != nil {
+				transport.Metrics = clientMetrics
+			}
+			transport.OnReconnectExhausted = func(e transportClient.ReconnectExhausted) {
+				logger.Error("Client gave up reconnecting", "client_id", id, "url", e.URL,
+					"attempts", e.Attempts, "error", e.LastError)
+			}
 			transport.Listen(ctx)
 			logger.Info("Client stopped", "client_id", id)
 		}(i + 1)
 	}
 
-	// Ожидаем сигнал завершения.
-	<-ctx.Done()
-	logger.Info("Shutdown signal received, waiting for clients to stop...")
-	// Ждем завершения всех клиентов (с таймаутом для graceful shutdown).
 	doneCh := make(chan struct{})
 	go func() {
 		wg.Wait()
 		close(doneCh)
 	}()
+	systemd.Ready(fmt.Sprintf("syncing with %d clients", numClients), logger)
+	go systemd.Watchdog(ctx, nil, logger)
+
+	// Ожидаем сигнал завершения либо остановки всех клиентов (например,
+	// после исчерпания попыток переподключения).
+	select {
+	case <-ctx.Done():
+	case <-doneCh:
+		logger.Info("All clients stopped")
+		closeInstances(instances)
+		return
+	}
+	logger.Info("Shutdown signal received, waiting for clients to stop...")
+	systemd.Stopping(logger)
+	// Ждем завершения всех клиентов (с таймаутом для graceful shutdown).
+	// Буферы записываются и по таймауту: события, пришедшие от ещё не
+	// остановленных клиентов, сервис уже отклоняет.
 	select {
 	case <-doneCh:
 		logger.Info("All clients stopped gracefully")
 	case <-time.After(5 * time.Second):
 		logger.Info("Timeout waiting for clients shutdown")
 	}
+	closed := make(chan struct{})
+	go func() {
+		closeInstances(instances)
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		logger.Error("Timeout flushing client storage")
+	}
+}
+
+// serveMetrics отдаёт метрики клиента по HTTP.
+func serveMetrics(addr string, registry *metrics.Registry, logger *slog.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry.Handler())
+	logger.Info("Serving metrics", "addr", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logger.Error("Metrics server error", "error", err)
+	}
 }
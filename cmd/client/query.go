@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// runQuery реализует команду "query": выборку событий из клиентской БД.
+//
+//	client query -config cfg.json -type error -since 2024-01-02T00:00:00Z -contains timeout -format json
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	configPath := fs.String("config", "config/client_config.json", "Path to client configuration file")
+	dbPath := fs.String("db", "", "Path to client database (overrides config)")
+	types := fs.String("type", "", "Comma-separated event types")
+	since := fs.String("since", "", "Only events at or after this time (RFC 3339 or duration like 1h)")
+	until := fs.String("until", "", "Only events before this time (RFC 3339 or duration like 10m)")
+	contains := fs.String("contains", "", "Only events whose message contains this substring")
+	source := fs.String("source", "", "Only events from this source, e.g. client:client-1")
+	limit := fs.Int("limit", 0, "Maximum number of events (0 = no limit)")
+	format := fs.String("format", "table", "Output format: table or json")
+	fs.Parse(args)
+
+	filter := repository.EventFilter{Contains: *contains, Source: *source, Limit: *limit}
+	if *types != "" {
+		filter.Types = strings.Split(*types, ",")
+	}
+	var err error
+	if filter.Since, err = parseTimeArg(*since); err != nil {
+		return fmt.Errorf("invalid -since: %w", err)
+	}
+	if filter.Until, err = parseTimeArg(*until); err != nil {
+		return fmt.Errorf("invalid -until: %w", err)
+	}
+
+	repo, closeDB, err := openRepository(*configPath, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	events, err := repo.Query(filter)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		return writeEventsJSON(os.Stdout, events)
+	case "table":
+		return writeEventsTable(os.Stdout, events)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+// openRepository открывает хранилище событий из конфигурации; непустой
+// dbPath заменяет путь из конфигурации, а без файла конфигурации
+// открывается БД SQLite по этому пути.
+func openRepository(configPath, dbPath string) (repository.EventRepository, func(), error) {
+	cfg, err := config.LoadClientConfig(configPath)
+	if err != nil {
+		if dbPath == "" {
+			return nil, nil, err
+		}
+		cfg = &config.ClientConfig{}
+	}
+	if dbPath != "" {
+		cfg.DBPath = dbPath
+	}
+	repo, err := openStorage(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, func() {
+		if c, ok := repo.(io.Closer); ok {
+			c.Close()
+		}
+	}, nil
+}
+
+// parseTimeArg принимает абсолютное время в RFC 3339 или длительность,
+// отсчитываемую назад от текущего момента ("1h" — час назад).
+func parseTimeArg(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func writeEventsJSON(w io.Writer, events []domain.Event) error {
+	if events == nil {
+		events = []domain.Event{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(events)
+}
+
+func writeEventsTable(w io.Writer, events []domain.Event) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIMESTAMP\tSEQ\tID\tTYPE\tSOURCE\tMESSAGE")
+	for _, e := range events {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", e.Timestamp.Format(time.RFC3339), e.Seq, e.ID, e.Type, e.Source, e.Message)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/repository"
+	"github.com/wrongjunior/eventsync/internal/service"
+	transportClient "github.com/wrongjunior/eventsync/internal/transport/client"
+	"log/slog"
+)
+
+// recordedEvent — строка файла записи потока: событие и время его получения.
+type recordedEvent struct {
+	ReceivedAt time.Time    `json:"received_at"`
+	Event      domain.Event `json:"event"`
+}
+
+// maxRecordLine ограничивает длину строки файла записи при воспроизведении.
+const maxRecordLine = 4 << 20
+
+// runRecord реализует команду "record": запись живого потока событий в
+// файл NDJSON до Ctrl+C или истечения -duration. Подключение настраивается
+// так же, как у клиента; в БД события не сохраняются.
+//
+//	client record -config cfg.json -o stream.ndjson -duration 10m
+func runRecord(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	configPath := fs.String("config", "config/client_config.json", "Path to client configuration file")
+	output := fs.String("o", "stream.ndjson", "Output file (- for stdout)")
+	duration := fs.Duration("duration", 0, "Stop recording after this long (0 = until interrupted)")
+	fs.Parse(args)
+
+	cfg, err := config.LoadClientConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	buf := bufio.NewWriter(out)
+	enc := json.NewEncoder(buf)
+
+	// Репозиторий в памяти нужен только для отсечения дубликатов при
+	// переподключении.
+	cs := service.NewClientService(repository.NewMemoryRepository(10000), logger)
+	var mu sync.Mutex
+	count := 0
+	cs.OnEvent(service.AnyEventType, func(event domain.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		count++
+		return enc.Encode(recordedEvent{ReceivedAt: time.Now().UTC(), Event: event})
+	})
+	transport, err := newTransport(cfg, cs, logger)
+	if err != nil {
+		return err
+	}
+	transport.ClientID = "recorder"
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+	fmt.Fprintln(os.Stderr, "recording, press Ctrl+C to stop")
+	transport.Listen(ctx)
+	cs.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "recorded %d events\n", count)
+	return nil
+}
+
+// runReplay реализует команду "replay": публикацию записанного потока через
+// POST /events сервера. Паузы между событиями сохраняются, делятся на -speed
+// (0 — без пауз) и ограничиваются -max-gap. Серверные номера сбрасываются;
+// с -new-ids сервер присваивает событиям новые ID, иначе клиенты, уже
+// получившие события, отсекут их как дубликаты.
+//
+//	client replay -config cfg.json -i stream.ndjson -speed 10 -max-gap 1s
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configPath := fs.String("config", "config/client_config.json", "Path to client configuration file")
+	input := fs.String("i", "stream.ndjson", "Recorded stream file (- for stdin)")
+	target := fs.String("url", "", "Publish endpoint (defaults to /events on client_server_url)")
+	speed := fs.Float64("speed", 1, "Timing factor: 2 replays twice as fast, 0 disables pauses")
+	maxGap := fs.Duration("max-gap", 0, "Upper bound for a single pause (0 = no bound)")
+	newIDs := fs.Bool("new-ids", false, "Let the server assign new event IDs")
+	fs.Parse(args)
+
+	if *speed < 0 {
+		return errors.New("-speed must not be negative")
+	}
+	cfg, err := config.LoadClientConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *target == "" {
+		if *target, err = publishURL(cfg.ClientServerURL); err != nil {
+			return err
+		}
+	}
+	client, err := publishClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64<<10), maxRecordLine)
+	var prev time.Time
+	replayed, failed := 0, 0
+	for line := 1; scanner.Scan(); line++ {
+		var rec recordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if *speed > 0 && !prev.IsZero() {
+			gap := time.Duration(float64(rec.ReceivedAt.Sub(prev)) / *speed)
+			if *maxGap > 0 && gap > *maxGap {
+				gap = *maxGap
+			}
+			select {
+			case <-time.After(gap):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		prev = rec.ReceivedAt
+
+		event := rec.Event
+		event.Seq = 0
+		if *newIDs {
+			event.ID = ""
+		}
+		if err := publishEvent(ctx, client, cfg, *target, event); err != nil {
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", line, err)
+			failed++
+			continue
+		}
+		replayed++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "replayed %d events, %d failed\n", replayed, failed)
+	return nil
+}
+
+// publishURL выводит адрес POST /events из адреса WebSocket сервера.
+func publishURL(serverURL string) (string, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	default:
+		return "", fmt.Errorf("unsupported server url scheme %q", u.Scheme)
+	}
+	u.Path, u.RawQuery = "/events", ""
+	return u.String(), nil
+}
+
+// publishClient создаёт HTTP-клиент с настройками TLS и прокси из конфигурации.
+func publishClient(cfg *config.ClientConfig) (*http.Client, error) {
+	tlsConfig, err := transportClient.NewTLSConfig(transportClient.TLSOptions{
+		CAFile:             cfg.TLS.CAFile,
+		CertFile:           cfg.TLS.CertFile,
+		KeyFile:            cfg.TLS.KeyFile,
+		ServerName:         cfg.TLS.ServerName,
+		InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if cfg.ProxyURL != "" {
+		proxyURL, err := transportClient.ParseProxyURL(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &http.Client{Transport: transport, Timeout: 10 * time.Second}, nil
+}
+
+// publishEvent публикует событие с заголовками аутентификации клиента.
+func publishEvent(ctx context.Context, client *http.Client, cfg *config.ClientConfig, target string, event domain.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = requestHeaders(cfg)
+	req.Header.Set("Content-Type", "application/json")
+	if source := tokenSource(cfg); source != nil {
+		token, err := source()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+string(cfg.APIKey))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server responded %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
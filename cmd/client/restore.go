@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// runRestore реализует команду "restore": загрузку событий из истории
+// сервера (GET /replay) за диапазон номеров или времени и их сохранение в
+// клиентскую БД, например после её повреждения. Уже сохранённые события
+// не дублируются.
+//
+//	client restore -config cfg.json -from-seq 1200 -to-seq 5000
+//	client restore -config cfg.json -since 6h -speed 0
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	configPath := fs.String("config", "config/client_config.json", "Path to client configuration file")
+	dbPath := fs.String("db", "", "Path to client database (overrides config)")
+	fromSeq := fs.Uint64("from-seq", 0, "First sequence number, inclusive")
+	toSeq := fs.Uint64("to-seq", 0, "Last sequence number, inclusive")
+	since := fs.String("since", "", "Only events at or after this time (RFC 3339 or duration like 1h)")
+	until := fs.String("until", "", "Only events before this time (RFC 3339 or duration like 10m)")
+	speed := fs.Float64("speed", 0, "Timing factor: 1 keeps the original pace, 0 disables pauses")
+	target := fs.String("url", "", "Replay endpoint (defaults to /replay on client_server_url)")
+	fs.Parse(args)
+
+	if *speed < 0 {
+		return errors.New("-speed must not be negative")
+	}
+	cfg, err := config.LoadClientConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	q := url.Values{}
+	if *fromSeq > 0 {
+		q.Set("from_seq", strconv.FormatUint(*fromSeq, 10))
+	}
+	if *toSeq > 0 {
+		q.Set("to_seq", strconv.FormatUint(*toSeq, 10))
+	}
+	from, err := parseTimeArg(*since)
+	if err != nil {
+		return fmt.Errorf("invalid -since: %w", err)
+	}
+	to, err := parseTimeArg(*until)
+	if err != nil {
+		return fmt.Errorf("invalid -until: %w", err)
+	}
+	if !from.IsZero() {
+		q.Set("from", from.UTC().Format(time.RFC3339Nano))
+	}
+	if !to.IsZero() {
+		q.Set("to", to.UTC().Format(time.RFC3339Nano))
+	}
+	if len(q) == 0 {
+		return errors.New("a range is required: -from-seq, -to-seq, -since or -until")
+	}
+	if *speed > 0 {
+		q.Set("speed", strconv.FormatFloat(*speed, 'f', -1, 64))
+	}
+	if cfg.Namespace != "" {
+		q.Set("namespace", cfg.Namespace)
+	}
+	for _, topic := range cfg.Topics {
+		q.Add("topics", topic)
+	}
+	if *target == "" {
+		if *target, err = replayURL(cfg.ClientServerURL); err != nil {
+			return err
+		}
+	}
+	client, err := publishClient(cfg)
+	if err != nil {
+		return err
+	}
+	// Поток может идти долго при -speed > 0.
+	client.Timeout = 0
+
+	repo, closeDB, err := openRepository(*configPath, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *target+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header = requestHeaders(cfg)
+	if source := tokenSource(cfg); source != nil {
+		token, err := source()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+string(cfg.APIKey))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("server responded %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), maxRecordLine)
+	restored := 0
+	for scanner.Scan() {
+		var event domain.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return err
+		}
+		if err := repo.Save(event); err != nil {
+			return fmt.Errorf("event %s: %w", event.ID, err)
+		}
+		restored++
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "restored %d of %s events\n", restored, resp.Header.Get("X-Replay-Events"))
+	return nil
+}
+
+// replayURL выводит адрес GET /replay из адреса WebSocket сервера.
+func replayURL(serverURL string) (string, error) {
+	target, err := publishURL(serverURL)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	u.Path = "/replay"
+	return u.String(), nil
+}
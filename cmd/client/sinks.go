@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/sink"
+	"log/slog"
+)
+
+// newSinks создаёт приёмники зарегистрированных типов по конфигурации и
+// запускает пересылку в них.
+func newSinks(cfg *config.ClientConfig, logger *slog.Logger) (*sink.Set, error) {
+	opts := make([]sink.Options, 0, len(cfg.Sinks))
+	closeOpened := func() {
+		for _, o := range opts {
+			o.Sink.Close()
+		}
+	}
+	for i, c := range cfg.Sinks {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("sink-%d", i+1)
+		}
+		s, err := sink.Open(c.Type, c.Options.Raw())
+		if err != nil {
+			closeOpened()
+			return nil, fmt.Errorf("sink %s: %w", name, err)
+		}
+		opts = append(opts, sink.Options{
+			Name:      name,
+			Sink:      s,
+			Types:     c.Types,
+			Topics:    c.Topics,
+			QueueSize: c.QueueSize,
+			BatchSize: c.BatchSize,
+			Retry: sink.RetryPolicy{
+				InitialBackoff: time.Duration(c.Retry.InitialBackoff),
+				MaxBackoff:     time.Duration(c.Retry.MaxBackoff),
+				MaxAttempts:    c.Retry.MaxAttempts,
+			},
+		})
+	}
+	set, err := sink.New(opts, logger)
+	if err != nil {
+		closeOpened()
+		return nil, err
+	}
+	return set, nil
+}
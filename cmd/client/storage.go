@@ -0,0 +1,110 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// openStorage открывает и инициализирует хранилище событий, выбранное в конфигурации.
+func openStorage(cfg *config.ClientConfig) (repository.EventRepository, error) {
+	if cfg.Storage != "" && cfg.Storage != "sqlite" && (cfg.Encryption != config.EncryptionConfig{}) {
+		return nil, fmt.Errorf("encryption is supported only for sqlite storage")
+	}
+	switch cfg.Storage {
+	case "", "sqlite":
+		pragmas := repository.Pragmas{
+			JournalMode: cfg.SQLite.JournalMode,
+			Synchronous: cfg.SQLite.Synchronous,
+			BusyTimeout: time.Duration(cfg.SQLite.BusyTimeout),
+			CacheSize:   cfg.SQLite.CacheSize,
+		}
+		pool := dbPool(cfg)
+		if err := pool.Validate(); err != nil {
+			return nil, err
+		}
+		db, err := sql.Open("sqlite3", pragmas.DSN(cfg.DBPath))
+		if err != nil {
+			return nil, err
+		}
+		pool.Apply(db)
+		repo := repository.NewSQLiteRepository(db)
+		repo.Pragmas = pragmas
+		if repo.Cipher, err = loadCipher(cfg.Encryption); err != nil {
+			db.Close()
+			return nil, err
+		}
+		if err := repo.Init(); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return repo, nil
+	case "bolt":
+		return repository.OpenBoltRepository(cfg.DBPath)
+	case "jsonl":
+		repo := repository.NewJSONLRepository(cfg.DBPath, cfg.JSONL.MaxFileSize, cfg.JSONL.MaxFiles)
+		if err := repo.Init(); err != nil {
+			return nil, err
+		}
+		return repo, nil
+	case "memory":
+		return repository.NewMemoryRepository(cfg.MemoryLimit), nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q", cfg.Storage)
+	}
+}
+
+// dbPool возвращает настройки пула соединений из конфигурации.
+func dbPool(cfg *config.ClientConfig) repository.Pool {
+	return repository.Pool{
+		MaxOpenConns:    cfg.DBPool.MaxOpenConns,
+		MaxIdleConns:    cfg.DBPool.MaxIdleConns,
+		ConnMaxLifetime: time.Duration(cfg.DBPool.ConnMaxLifetime),
+	}
+}
+
+// loadCipher читает ключ шифрования из переменной окружения или файла;
+// без настроек шифрования возвращает nil.
+func loadCipher(cfg config.EncryptionConfig) (*repository.FieldCipher, error) {
+	var raw string
+	switch {
+	case cfg.KeyEnv != "":
+		value, ok := os.LookupEnv(cfg.KeyEnv)
+		if !ok {
+			return nil, fmt.Errorf("encryption key variable %s is not set", cfg.KeyEnv)
+		}
+		raw = value
+	case cfg.KeyFile != "":
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read encryption key: %w", err)
+		}
+		raw = string(data)
+	default:
+		return nil, nil
+	}
+	key, err := repository.ParseKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewFieldCipher(key)
+}
+
+// openDeadLetters открывает очередь недоставленных событий в БД хранилища;
+// она поддерживается только для SQLite.
+func openDeadLetters(repo repository.EventRepository) (*repository.SQLiteDeadLetters, error) {
+	sqliteRepo, ok := repo.(*repository.SQLiteRepository)
+	if !ok {
+		return nil, fmt.Errorf("dead-letter queue is supported only for sqlite storage")
+	}
+	dlq := repository.NewSQLiteDeadLetters(sqliteRepo.DB)
+	dlq.Cipher = sqliteRepo.Cipher
+	if err := dlq.Init(); err != nil {
+		return nil, fmt.Errorf("initialize dead-letter queue: %w", err)
+	}
+	return dlq, nil
+}
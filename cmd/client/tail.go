@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/repository"
+	"github.com/wrongjunior/eventsync/internal/service"
+	"log/slog"
+)
+
+// runTail реализует команду "tail": вывод каждого полученного события
+// строкой NDJSON в stdout для конвейеров с jq, grep и другими утилитами.
+// Журнал пишется в stderr. По умолчанию события не сохраняются; с -persist
+// они проходят обычную обработку клиента и сохраняются в его хранилище, а
+// после перезапуска поток продолжается с последнего сохранённого номера.
+//
+//	client tail -config cfg.json -topics 'orders.*' | jq .data
+func runTail(args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	configPath := fs.String("config", "config/client_config.json", "Path to client configuration file")
+	persist := fs.Bool("persist", false, "Also save events to the configured storage")
+	topics := fs.String("topics", "", "Comma-separated topic patterns (overrides config)")
+	types := fs.String("types", "", "Comma-separated event types (overrides config)")
+	count := fs.Int("n", 0, "Exit after printing this many events (0 = until interrupted)")
+	fs.Parse(args)
+
+	cfg, err := config.LoadClientConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *topics != "" {
+		cfg.Topics = strings.Split(*topics, ",")
+	}
+	if *types != "" {
+		cfg.EventTypes = strings.Split(*types, ",")
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	var cs *service.ClientService
+	if *persist {
+		if cs, err = newClientService(cfg, logger, nil); err != nil {
+			return err
+		}
+	} else {
+		// Репозиторий в памяти нужен только для отсечения дубликатов при
+		// переподключении.
+		cs = service.NewClientService(repository.NewMemoryRepository(10000), logger)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Каждое событие — одна запись в stdout, поэтому читатель конвейера
+	// получает его сразу. Закрытый конвейер (например, после head) даёт
+	// ошибку записи вместо SIGPIPE, и команда завершается штатно, дописав
+	// сохраняемые события.
+	signal.Ignore(syscall.SIGPIPE)
+	enc := json.NewEncoder(os.Stdout)
+	var mu sync.Mutex
+	printed := 0
+	cs.OnEvent(service.AnyEventType, func(event domain.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := enc.Encode(event); err != nil {
+			cancel()
+			if errors.Is(err, syscall.EPIPE) {
+				return nil
+			}
+			return err
+		}
+		if printed++; *count > 0 && printed >= *count {
+			cancel()
+		}
+		return nil
+	})
+	transport, err := newTransport(cfg, cs, logger)
+	if err != nil {
+		cs.Close()
+		return err
+	}
+	transport.ClientID = "tail"
+	transport.Listen(ctx)
+	cs.Close()
+	return nil
+}
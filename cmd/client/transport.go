@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/service"
+	transportClient "github.com/wrongjunior/eventsync/internal/transport/client"
+	"log/slog"
+)
+
+// newTransport создаёт транспорт клиента с настройками подключения из
+// конфигурации: адреса, TLS, прокси, аутентификация, подписка, heartbeat и
+// переподключение. Имя клиента, Outbox и метрики задаёт вызывающий.
+func newTransport(cfg *config.ClientConfig, cs *service.ClientService, logger *slog.Logger) (*transportClient.ClientTransport, error) {
+	tlsConfig, err := transportClient.NewTLSConfig(transportClient.TLSOptions{
+		CAFile:             cfg.TLS.CAFile,
+		CertFile:           cfg.TLS.CertFile,
+		KeyFile:            cfg.TLS.KeyFile,
+		ServerName:         cfg.TLS.ServerName,
+		InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+	var proxyURL *url.URL
+	if cfg.ProxyURL != "" {
+		if proxyURL, err = transportClient.ParseProxyURL(cfg.ProxyURL); err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+	}
+	if cfg.SyncMode != "" && cfg.SyncMode != "snapshot" {
+		return nil, fmt.Errorf("invalid sync mode %q", cfg.SyncMode)
+	}
+
+	transport := transportClient.NewClientTransport(cfg.ClientServerURL, cs, logger)
+	transport.StatusInterval = time.Duration(cfg.StatusInterval)
+	transport.Snapshot = cfg.SyncMode == "snapshot"
+	transport.HeartbeatInterval = time.Duration(cfg.Heartbeat.Interval)
+	transport.HeartbeatTimeout = time.Duration(cfg.Heartbeat.Timeout)
+	if transport.HeartbeatTimeout <= 0 {
+		transport.HeartbeatTimeout = 3 * transport.HeartbeatInterval
+	}
+	transport.FailoverURLs = cfg.FailoverURLs
+	transport.TLSConfig = tlsConfig
+	transport.ProxyURL = proxyURL
+	transport.EnableCompression = cfg.Compression
+	transport.ReadBufferSize = cfg.WebSocket.ReadBufferSize
+	transport.WriteBufferSize = cfg.WebSocket.WriteBufferSize
+	if cfg.WebSocket.ReadLimit > 0 {
+		transport.ReadLimit = cfg.WebSocket.ReadLimit
+	}
+	transport.PrimaryRecheck = time.Duration(cfg.PrimaryRecheck)
+	transport.Topics = cfg.Topics
+	transport.Namespace = cfg.Namespace
+	transport.APIKey = string(cfg.APIKey)
+	transport.TokenSource = tokenSource(cfg)
+	transport.Headers = requestHeaders(cfg)
+	transport.SchemaVersions = cfg.SchemaVersions
+	transport.EventTypes = cfg.EventTypes
+	transport.MinSeverity = cfg.MinSeverity
+	transport.Aggregate = time.Duration(cfg.Aggregate.Interval)
+	transport.AggregateOnly = cfg.Aggregate.Only
+	transport.Group = cfg.Group.Name
+	transport.GroupStrategy = cfg.Group.Strategy
+	transport.Subscription = cfg.Subscription
+	transport.CatchUp = cfg.CatchUp.Enabled
+	transport.CatchUpPageSize = cfg.CatchUp.PageSize
+	transport.Reconnect = transportClient.ReconnectPolicy{
+		InitialBackoff: time.Duration(cfg.Reconnect.InitialBackoff),
+		MaxBackoff:     time.Duration(cfg.Reconnect.MaxBackoff),
+		Jitter:         cfg.Reconnect.Jitter,
+		MaxRetries:     cfg.Reconnect.MaxRetries,
+	}
+	if transport.Reconnect.MaxBackoff == 0 {
+		transport.Reconnect.MaxBackoff = transportClient.DefaultReconnectPolicy.MaxBackoff
+	}
+	return transport, nil
+}
+
+// requestHeaders возвращает дополнительные заголовки подключения из конфигурации.
+func requestHeaders(cfg *config.ClientConfig) http.Header {
+	headers := http.Header{}
+	for name, value := range cfg.Headers {
+		headers.Set(name, string(value))
+	}
+	return headers
+}
+
+// tokenSource возвращает источник bearer-токена из конфигурации; nil — токен не используется.
+func tokenSource(cfg *config.ClientConfig) transportClient.TokenSource {
+	switch {
+	case len(cfg.TokenCommand) > 0:
+		return transportClient.CommandTokenSource(cfg.TokenCommand)
+	case cfg.TokenFile != "":
+		return transportClient.FileTokenSource(cfg.TokenFile)
+	}
+	return nil
+}
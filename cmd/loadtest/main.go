@@ -0,0 +1,288 @@
+// Команда loadtest измеряет пропускную способность рассылки сервера:
+// открывает N WebSocket-подписчиков, публикует M событий в секунду через
+// POST /events и сообщает перцентили задержки доставки, потери и пропускную
+// способность.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// eventType — тип событий нагрузочного теста; подписчики получают только его.
+const eventType = "loadtest"
+
+// sentAtKey — ключ метаданных с временем публикации в наносекундах Unix.
+const sentAtKey = "loadtest.sent_at"
+
+type options struct {
+	wsURL       string
+	publishURL  string
+	apiKey      string
+	subscribers int
+	rate        int
+	duration    time.Duration
+	drain       time.Duration
+	publishers  int
+	payloadSize int
+}
+
+// stats собирает результаты подписчиков.
+type stats struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	received  atomic.Uint64
+}
+
+func (s *stats) observe(latency time.Duration) {
+	s.received.Add(1)
+	s.mu.Lock()
+	s.latencies = append(s.latencies, latency)
+	s.mu.Unlock()
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.wsURL, "url", "ws://localhost:8080/ws", "WebSocket endpoint of the server")
+	flag.StringVar(&opts.publishURL, "publish", "", "Publish endpoint; defaults to /events on the WebSocket host")
+	flag.StringVar(&opts.apiKey, "api-key", "", "API key with publish and subscribe scopes")
+	flag.IntVar(&opts.subscribers, "subscribers", 10, "Number of WebSocket subscribers")
+	flag.IntVar(&opts.rate, "rate", 100, "Events published per second")
+	flag.DurationVar(&opts.duration, "duration", 10*time.Second, "Publishing duration")
+	flag.DurationVar(&opts.drain, "drain", 2*time.Second, "Time to wait for in-flight deliveries after publishing stops")
+	flag.IntVar(&opts.publishers, "publishers", 16, "Concurrent publish requests")
+	flag.IntVar(&opts.payloadSize, "payload-size", 0, "Size of the event message in bytes")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options) error {
+	if opts.subscribers < 0 || opts.rate <= 0 || opts.publishers <= 0 {
+		return fmt.Errorf("subscribers must be non-negative, rate and publishers positive")
+	}
+	if opts.publishURL == "" {
+		u, err := publishURL(opts.wsURL)
+		if err != nil {
+			return err
+		}
+		opts.publishURL = u
+	}
+
+	st := &stats{}
+	var conns []*websocket.Conn
+	var readers sync.WaitGroup
+	for i := 0; i < opts.subscribers; i++ {
+		conn, err := dial(opts)
+		if err != nil {
+			for _, c := range conns {
+				c.Close()
+			}
+			return fmt.Errorf("subscriber %d: %w", i+1, err)
+		}
+		conns = append(conns, conn)
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			subscribe(conn, st)
+		}()
+	}
+
+	published, failed, skipped, elapsed := publish(opts)
+	time.Sleep(opts.drain)
+	for _, c := range conns {
+		c.Close()
+	}
+	readers.Wait()
+
+	report(opts, st, published, failed, skipped, elapsed)
+	return nil
+}
+
+// publishURL выводит адрес POST /events из адреса WebSocket.
+func publishURL(wsURL string) (string, error) {
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	default:
+		return "", fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+	u.Path, u.RawQuery = "/events", ""
+	return u.String(), nil
+}
+
+// dial подключает подписчика только на события нагрузочного теста.
+func dial(opts options) (*websocket.Conn, error) {
+	u, err := url.Parse(opts.wsURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("types", eventType)
+	u.RawQuery = q.Encode()
+	header := http.Header{"Sec-WebSocket-Protocol": {domain.ProtocolV1}}
+	if opts.apiKey != "" {
+		header.Set("X-API-Key", opts.apiKey)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("%w (status %s)", err, resp.Status)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// subscribe читает события до закрытия соединения и учитывает задержку
+// от публикации до получения.
+func subscribe(conn *websocket.Conn, st *stats) {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var event domain.Event
+		if json.Unmarshal(message, &event) != nil || event.Type != eventType {
+			continue
+		}
+		sentAt, err := strconv.ParseInt(event.Metadata[sentAtKey], 10, 64)
+		if err != nil {
+			continue
+		}
+		st.observe(time.Since(time.Unix(0, sentAt)))
+	}
+}
+
+// publish публикует события с заданной частотой в течение opts.duration.
+// Если все публикаторы заняты, событие пропускается и учитывается в skipped.
+func publish(opts options) (published, failed, skipped uint64, elapsed time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.duration)
+	defer cancel()
+
+	var ok, errs atomic.Uint64
+	jobs := make(chan struct{}, opts.publishers)
+	var workers sync.WaitGroup
+	client := &http.Client{Timeout: 10 * time.Second}
+	message := string(bytes.Repeat([]byte("x"), opts.payloadSize))
+	for i := 0; i < opts.publishers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for range jobs {
+				if err := post(client, opts, message); err != nil {
+					errs.Add(1)
+					continue
+				}
+				ok.Add(1)
+			}
+		}()
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(time.Second / time.Duration(opts.rate))
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+			select {
+			case jobs <- struct{}{}:
+			default:
+				skipped++
+			}
+		}
+	}
+	close(jobs)
+	workers.Wait()
+	return ok.Load(), errs.Load(), skipped, time.Since(start)
+}
+
+// post публикует одно событие с временем отправки в метаданных.
+func post(client *http.Client, opts options, message string) error {
+	event := domain.Event{
+		Type:     eventType,
+		Message:  message,
+		Metadata: map[string]string{sentAtKey: strconv.FormatInt(time.Now().UnixNano(), 10)},
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, opts.publishURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.apiKey != "" {
+		req.Header.Set("X-API-Key", opts.apiKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("publish: %s", resp.Status)
+	}
+	return nil
+}
+
+// report печатает итоги теста.
+func report(opts options, st *stats, published, failed, skipped uint64, elapsed time.Duration) {
+	received := st.received.Load()
+	expected := published * uint64(opts.subscribers)
+	var dropped uint64
+	if expected > received {
+		dropped = expected - received
+	}
+	seconds := elapsed.Seconds()
+	fmt.Printf("subscribers: %d\n", opts.subscribers)
+	fmt.Printf("published:   %d ok, %d failed, %d skipped in %s (%.1f/s)\n",
+		published, failed, skipped, elapsed.Round(time.Millisecond), float64(published)/seconds)
+	fmt.Printf("delivered:   %d of %d expected, %d dropped\n", received, expected, dropped)
+	fmt.Printf("throughput:  %.1f events/s delivered\n", float64(received)/seconds)
+
+	st.mu.Lock()
+	latencies := st.latencies
+	st.mu.Unlock()
+	if len(latencies) == 0 {
+		return
+	}
+	slices.Sort(latencies)
+	fmt.Printf("latency:     p50=%s p90=%s p99=%s max=%s\n",
+		percentile(latencies, 0.50), percentile(latencies, 0.90),
+		percentile(latencies, 0.99), latencies[len(latencies)-1])
+}
+
+// percentile возвращает перцентиль p отсортированных задержек.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted))*p+0.5) - 1
+	i = max(0, min(i, len(sorted)-1))
+	return sorted[i].Round(time.Microsecond)
+}
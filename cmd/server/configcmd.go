@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/sink"
+	"github.com/wrongjunior/eventsync/internal/source"
+)
+
+const configUsage = `usage:
+  config init [-server|-client] [-o path] [-force]
+  config validate [-server|-client] [-q] path`
+
+// runConfig реализует команду "config":
+//
+//	config init [-server|-client] [-o path] [-force]
+//	config validate [-server|-client] [-q] path
+//
+// init выводит пример конфигурации со всеми полями, значениями по умолчанию
+// и комментариями. validate загружает конфигурацию с разрешением секретов,
+// проверяет её и выводит действующие значения со скрытыми секретами, ничего
+// не запуская; при ошибках команда завершается с ненулевым кодом. Без
+// -server и -client используется конфигурация сервера.
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return errors.New(configUsage)
+	}
+	switch args[0] {
+	case "init":
+		return configInit(args[1:])
+	case "validate":
+		return configValidate(args[1:])
+	default:
+		return errors.New(configUsage)
+	}
+}
+
+// configKind разбирает флаги -server и -client; true — конфигурация сервера.
+func configKind(fs *flag.FlagSet, args []string) (bool, error) {
+	server := fs.Bool("server", false, "Use server configuration")
+	client := fs.Bool("client", false, "Use client configuration")
+	fs.Parse(args)
+	if *server && *client {
+		return false, errors.New("-server and -client are mutually exclusive")
+	}
+	return !*client, nil
+}
+
+func configInit(args []string) error {
+	fs := flag.NewFlagSet("config init", flag.ExitOnError)
+	out := fs.String("o", "", "Output file (default stdout)")
+	force := fs.Bool("force", false, "Overwrite an existing output file")
+	server, err := configKind(fs, args)
+	if err != nil {
+		return err
+	}
+	example := config.ClientExample()
+	if server {
+		example = config.ServerExample()
+	}
+	if *out == "" {
+		_, err := os.Stdout.Write(example)
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !*force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(*out, flags, 0o600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return errors.New(*out + " already exists; use -force to overwrite")
+		}
+		return err
+	}
+	if _, err := f.Write(example); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func configValidate(args []string) error {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	quiet := fs.Bool("q", false, "Do not print the effective configuration")
+	server, err := configKind(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New(configUsage)
+	}
+	path := fs.Arg(0)
+
+	var effective any
+	if server {
+		cfg, err := config.LoadServerConfig(path)
+		if err != nil {
+			return err
+		}
+		err = cfg.Validate()
+		for i, s := range cfg.Sources {
+			if s.Type != "" && !slices.Contains(source.Types(), s.Type) {
+				err = errors.Join(err, fmt.Errorf("sources[%d].type: unknown source type %q", i, s.Type))
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid configuration %s:\n%w", path, err)
+		}
+		cfg.ApplyDefaults()
+		effective = cfg
+	} else {
+		cfg, err := config.LoadClientConfig(path)
+		if err != nil {
+			return err
+		}
+		err = cfg.Validate()
+		for i, s := range cfg.Sinks {
+			if s.Type != "" && !slices.Contains(sink.Types(), s.Type) {
+				err = errors.Join(err, fmt.Errorf("sinks[%d].type: unknown sink type %q", i, s.Type))
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid configuration %s:\n%w", path, err)
+		}
+		cfg.ApplyDefaults()
+		effective = cfg
+	}
+	if *quiet {
+		return nil
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(effective)
+}
@@ -2,20 +2,58 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/wrongjunior/eventsync/internal/alert"
+	"github.com/wrongjunior/eventsync/internal/audit"
+	"github.com/wrongjunior/eventsync/internal/auth"
+	"github.com/wrongjunior/eventsync/internal/checkpoint"
 	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/durable"
+	"github.com/wrongjunior/eventsync/internal/guard"
+	"github.com/wrongjunior/eventsync/internal/history"
+	"github.com/wrongjunior/eventsync/internal/metrics"
+	"github.com/wrongjunior/eventsync/internal/netpoll"
+	"github.com/wrongjunior/eventsync/internal/quota"
+	"github.com/wrongjunior/eventsync/internal/schedule"
+	"github.com/wrongjunior/eventsync/internal/schema"
 	"github.com/wrongjunior/eventsync/internal/service"
+	"github.com/wrongjunior/eventsync/internal/systemd"
+	"github.com/wrongjunior/eventsync/internal/transform"
 	transportServer "github.com/wrongjunior/eventsync/internal/transport/server"
+	"github.com/wrongjunior/eventsync/internal/upgrade"
+	"github.com/wrongjunior/eventsync/internal/version"
 	"log/slog"
 )
 
+// commands — подкоманды сервера; без подкоманды запускается сервер.
+var commands = map[string]func(args []string) error{
+	"config":  runConfig,
+	"version": runVersion,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	configPath := flag.String("config", "config/server_config.json", "Path to server configuration file")
 	flag.Parse()
 
@@ -30,10 +68,311 @@ func main() {
 
 	// Инициализация бизнеслогики сервера.
 	eventService := service.NewEventService(logger)
-	eventService.StartEventGenerator()
+
+	routerCfg := transportServer.RouterConfig{WSPath: cfg.WSPath, AdminKey: string(cfg.AdminKey)}
+	if cfg.APIKeysPath != "" {
+		keys, err := auth.NewFileKeyStore(cfg.APIKeysPath)
+		if err != nil {
+			logger.Error("Failed to load API keys", "error", err)
+			os.Exit(1)
+		}
+		routerCfg.Keys = keys
+	}
+	if cfg.Guard != nil {
+		g, err := guard.New(guard.Policy{
+			Rate:           cfg.Guard.Rate,
+			Burst:          cfg.Guard.Burst,
+			MaxFailures:    cfg.Guard.MaxFailures,
+			Window:         time.Duration(cfg.Guard.Window),
+			BanDuration:    time.Duration(cfg.Guard.BanDuration),
+			MaxBan:         time.Duration(cfg.Guard.MaxBan),
+			Deny:           cfg.Guard.Deny,
+			Allow:          cfg.Guard.Allow,
+			TrustedProxies: cfg.Guard.TrustedProxies,
+		}, logger)
+		if err != nil {
+			logger.Error("Invalid guard configuration", "error", err)
+			os.Exit(1)
+		}
+		routerCfg.Guard = g
+	}
+	if cfg.Coalesce != nil {
+		routerCfg.Coalesce = &transportServer.CoalesceLimits{
+			MaxEvents: cfg.Coalesce.MaxEvents,
+			MaxBytes:  cfg.Coalesce.MaxBytes,
+		}
+	}
+	if cfg.WebSocket != nil {
+		routerCfg.WebSocket = &transportServer.WebSocketLimits{
+			ReadBufferSize:  cfg.WebSocket.ReadBufferSize,
+			WriteBufferSize: cfg.WebSocket.WriteBufferSize,
+			ReadLimit:       cfg.WebSocket.ReadLimit,
+		}
+	}
+	if cfg.Netpoll != nil {
+		poller, err := netpoll.New(cfg.Netpoll.Workers)
+		if err != nil {
+			logger.Error("Netpoll initialization error", "error", err)
+			os.Exit(1)
+		}
+		defer poller.Close()
+		routerCfg.Poller = poller
+		logger.Info("Serving WebSocket connections with netpoll", "workers", cfg.Netpoll.Workers)
+	}
+	if cfg.AdminOIDC != nil {
+		oidc, err := auth.NewOIDC(auth.OIDCOptions{
+			Issuer:            cfg.AdminOIDC.Issuer,
+			ClientID:          cfg.AdminOIDC.ClientID,
+			ClientSecret:      string(cfg.AdminOIDC.ClientSecret),
+			Audience:          cfg.AdminOIDC.Audience,
+			Introspect:        cfg.AdminOIDC.Introspect,
+			RolesClaim:        cfg.AdminOIDC.RolesClaim,
+			AdminRoles:        cfg.AdminOIDC.AdminRoles,
+			CacheTTL:          time.Duration(cfg.AdminOIDC.CacheTTL),
+			AllowGlobalTenant: cfg.AdminOIDC.AllowGlobalTenant,
+		})
+		if err != nil {
+			logger.Error("Failed to set up admin OIDC", "error", err)
+			os.Exit(1)
+		}
+		routerCfg.AdminOIDC = oidc
+	}
+	if cfg.JWT != nil {
+		keys, err := auth.NewKeySet(cfg.JWT.JWKS, time.Duration(cfg.JWT.RefreshInterval))
+		if err != nil {
+			logger.Error("Failed to load JWKS", "error", err)
+			os.Exit(1)
+		}
+		routerCfg.JWT = &auth.JWTVerifier{
+			Keys:              keys,
+			Issuer:            cfg.JWT.Issuer,
+			Audience:          cfg.JWT.Audience,
+			Leeway:            time.Duration(cfg.JWT.Leeway),
+			AllowGlobalTenant: cfg.JWT.AllowGlobalTenant,
+		}
+	}
+	if cfg.Quotas != nil {
+		overrides := make(map[string]quota.Limits, len(cfg.Quotas.Tenants))
+		for tenant, l := range cfg.Quotas.Tenants {
+			overrides[tenant] = quota.Limits(l)
+		}
+		routerCfg.Quotas = quota.NewManager(quota.Limits(cfg.Quotas.Default), overrides)
+	}
+	if cfg.Audit != nil {
+		auditLog, err := audit.Open(cfg.Audit.Path, cfg.Audit.Size)
+		if err != nil {
+			logger.Error("Failed to open audit log", "error", err)
+			os.Exit(1)
+		}
+		defer auditLog.Close()
+		routerCfg.Audit = auditLog
+	}
+	if cfg.Schemas != nil {
+		registry := schema.NewRegistry()
+		versions := make(map[string][]string, len(cfg.Schemas.Types)+len(cfg.Schemas.Versions))
+		for eventType, path := range cfg.Schemas.Types {
+			versions[eventType] = []string{path}
+		}
+		for eventType, paths := range cfg.Schemas.Versions {
+			versions[eventType] = paths
+		}
+		for eventType, paths := range versions {
+			for i, path := range paths {
+				raw, err := os.ReadFile(path)
+				if err == nil {
+					err = registry.RegisterVersion(eventType, i+1, raw)
+				}
+				if err != nil {
+					logger.Error("Failed to load event schema", "type", eventType, "path", path, "error", err)
+					os.Exit(1)
+				}
+			}
+		}
+		for eventType, steps := range cfg.Schemas.Downgrades {
+			for from, fields := range steps {
+				registry.RegisterDowngrade(eventType, from, schema.DropFields(fields...))
+			}
+		}
+		routerCfg.Schemas = registry
+		eventService.SetVersioning(registry)
+		if cfg.Schemas.OnInvalid == "quarantine" {
+			routerCfg.Quarantine = schema.NewQuarantine(cfg.Schemas.QuarantineSize)
+			eventService.SetValidation(registry, routerCfg.Quarantine)
+		} else {
+			eventService.SetValidation(registry, nil)
+		}
+	}
+
+	if cfg.NodeID != "" {
+		eventService.SetNodeID(cfg.NodeID)
+	}
+	resolver, err := service.ParseConflictResolver(cfg.ConflictResolution)
+	if err != nil {
+		logger.Error("Invalid conflict resolution", "error", err)
+		os.Exit(1)
+	}
+	if resolver != nil {
+		eventService.SetConflictResolver(resolver)
+	}
+	severities, err := domain.ParseSeverityMap(cfg.SeverityMap)
+	if err != nil {
+		logger.Error("Invalid severity map", "error", err)
+		os.Exit(1)
+	}
+	eventService.SetSeverityMap(severities)
+	if cfg.CRDT {
+		eventService.EnableCRDT()
+	}
+	if len(cfg.Transforms) > 0 {
+		specs := make([]transform.Spec, 0, len(cfg.Transforms))
+		for _, c := range cfg.Transforms {
+			specs = append(specs, transform.Spec(c))
+		}
+		transforms, err := transform.Build(specs, transform.DefaultEnv(cfg.NodeID))
+		if err != nil {
+			logger.Error("Invalid transforms", "error", err)
+			os.Exit(1)
+		}
+		for _, t := range transforms {
+			eventService.UseTransform(t)
+		}
+	}
+	if len(cfg.Alerts) > 0 {
+		rules, err := alertRules(cfg.Alerts)
+		if err != nil {
+			logger.Error("Invalid alerts", "error", err)
+			os.Exit(1)
+		}
+		alerter, err := alert.New(rules, severities, logger)
+		if err != nil {
+			logger.Error("Invalid alerts", "error", err)
+			os.Exit(1)
+		}
+		defer alerter.Close()
+		eventService.OnBroadcast(alerter.Notify)
+	}
+
+	var hooks upgradeHooks
+	var historyStore history.ServerEventStore
+	if cfg.History != nil {
+		store, err := openHistory(cfg.History)
+		if err != nil {
+			logger.Error("Failed to open event history", "backend", cfg.History.Backend, "error", err)
+			os.Exit(1)
+		}
+		if c, ok := store.(io.Closer); ok {
+			defer c.Close()
+		}
+		lastSeq, err := store.LastSeq()
+		if err != nil {
+			logger.Error("Failed to read event history", "error", err)
+			os.Exit(1)
+		}
+		eventService.ResumeSeq(lastSeq)
+		opts := history.RecorderOptions{MaxAge: time.Duration(cfg.History.MaxAge)}
+		if c := cfg.History.Compaction; c != nil {
+			opts.Compact, opts.CompactInterval, opts.CompactTypes = true, time.Duration(c.Interval), c.Types
+		}
+		if routerCfg.Quotas != nil {
+			opts.OnStored = routerCfg.Quotas.SyncStored
+		}
+		recorder := history.NewRecorder(store, opts, logger)
+		defer recorder.Close()
+		hooks.flush = append(hooks.flush, recorder.Flush)
+		eventService.OnBroadcast(recorder.Record)
+		routerCfg.History = store
+		historyStore = store
+		logger.Info("Event history enabled", "backend", cfg.History.Backend, "last_seq", lastSeq)
+	}
+
+	if cfg.StateSnapshot != nil {
+		if cfg.StateSnapshot.Path == "" {
+			logger.Error("Invalid state snapshot configuration", "error", "path is required")
+			os.Exit(1)
+		}
+		if err := restoreState(eventService, cfg.StateSnapshot.Path, historyStore, logger); err != nil {
+			logger.Error("Failed to restore state snapshot", "path", cfg.StateSnapshot.Path, "error", err)
+			os.Exit(1)
+		}
+		snapshots := checkpoint.Start(cfg.StateSnapshot.Path, time.Duration(cfg.StateSnapshot.Interval),
+			func() any { return eventService.State() }, logger)
+		defer snapshots.Close()
+		hooks.flush = append(hooks.flush, snapshots.Flush)
+	}
+
+	if cfg.DurableSubscriptions != nil {
+		if cfg.DurableSubscriptions.Path == "" {
+			logger.Error("Invalid durable subscriptions configuration", "error", "path is required")
+			os.Exit(1)
+		}
+		store, err := durable.Open(cfg.DurableSubscriptions.Path, cfg.DurableSubscriptions.MaxBacklog, logger)
+		if err != nil {
+			logger.Error("Failed to open durable subscriptions", "error", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+		if err := eventService.SetDurableStore(store); err != nil {
+			logger.Error("Failed to load durable subscriptions", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Durable subscriptions enabled", "path", cfg.DurableSubscriptions.Path)
+	}
+
+	// Очередь начинает доставку сразу, поэтому открывается после настройки
+	// сервиса событий.
+	if cfg.Schedule != nil {
+		if cfg.Schedule.Path == "" {
+			logger.Error("Invalid schedule configuration", "error", "path is required")
+			os.Exit(1)
+		}
+		queue, err := schedule.Open(cfg.Schedule.Path, schedule.Options{MaxDelay: time.Duration(cfg.Schedule.MaxDelay)},
+			eventService.DeliverScheduled, logger)
+		if err != nil {
+			logger.Error("Failed to open schedule queue", "error", err)
+			os.Exit(1)
+		}
+		defer queue.Close()
+		routerCfg.Schedule = queue
+		eventService.SetScheduler(queue)
+		logger.Info("Scheduled delivery enabled", "path", cfg.Schedule.Path, "pending", queue.Len())
+	}
+
+	// При активации через systemd или перезапуске сокеты уже открыты:
+	// сокет с именем "metrics" обслуживает метрики, "http" (или первый
+	// другой) — API.
+	activated, err := inheritedListeners()
+	if err != nil {
+		logger.Error("Failed to use inherited sockets", "error", err)
+		os.Exit(1)
+	}
+	var httpListener, metricsListener net.Listener
+	for _, ln := range activated {
+		switch {
+		case ln.Name == "metrics":
+			metricsListener = ln
+		case httpListener == nil || ln.Name == "http":
+			httpListener = ln
+		}
+	}
+
+	if metricsListener == nil && cfg.MetricsAddr != "" {
+		if metricsListener, err = net.Listen("tcp", cfg.MetricsAddr); err != nil {
+			logger.Error("Failed to listen", "addr", cfg.MetricsAddr, "error", err)
+			os.Exit(1)
+		}
+	}
+	var metricsServer *http.Server
+	if metricsListener != nil {
+		registry := metrics.NewRegistry()
+		routerCfg.Metrics = metrics.NewServerMetrics(registry)
+		registry.NewGaugeFunc("eventsync_server_clients", "Number of connected clients.", func() float64 {
+			return float64(len(eventService.Clients("")))
+		})
+		metricsServer = serveMetrics(metricsListener, registry, logger)
+	}
 
 	// Настройка маршрутов через chi.
-	router := transportServer.SetupRouter(eventService, logger, cfg.WSPath)
+	router := transportServer.SetupRouter(eventService, logger, routerCfg)
 	httpServer := &http.Server{
 		Addr:    cfg.ServerAddr,
 		Handler: router,
@@ -43,26 +382,178 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// Генератор и источники публикуют события, поэтому запускаются только
+	// после продолжения нумерации из истории и подключения наблюдателей.
+	eventService.StartEventGenerator()
+
+	// Источники останавливаются отдельно от сервера на время перезапуска.
+	// Процесс, запущенный на замену, завершается при ошибке источников, не
+	// сообщив о готовности, и старый процесс продолжает работу.
+	sourcesCtx, stopSources := context.WithCancel(ctx)
+	if err := startSources(sourcesCtx, cfg.Sources, eventService, logger); err != nil {
+		logger.Error("Invalid source configuration", "error", err)
+		os.Exit(1)
+	}
+	hooks.stopSources = func() { stopSources() }
+	hooks.startSources = func() error {
+		sourcesCtx, stopSources = context.WithCancel(ctx)
+		return startSources(sourcesCtx, cfg.Sources, eventService, logger)
+	}
+
+	// Сокет открывается до сообщения о готовности, чтобы systemd не
+	// считал службу готовой раньше, чем она принимает подключения.
+	if httpListener == nil {
+		if httpListener, err = net.Listen("tcp", cfg.ServerAddr); err != nil {
+			logger.Error("Failed to listen", "addr", cfg.ServerAddr, "error", err)
+			os.Exit(1)
+		}
+	} else if upgrade.Upgraded() {
+		logger.Info("Using socket inherited from the previous process", "addr", httpListener.Addr().String())
+	} else {
+		logger.Info("Using systemd socket activation", "addr", httpListener.Addr().String())
+	}
+
 	// Запускаем HTTP-сервер в отдельной горутине.
 	go func() {
-		logger.Info("Starting HTTP server", "addr", cfg.ServerAddr)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("Starting HTTP server", "addr", httpListener.Addr().String(), "version", version.Get().Version)
+		if err := httpServer.Serve(httpListener); err != nil && err != http.ErrServerClosed {
 			logger.Error("HTTP server error", "error", err)
 		}
 	}()
+	if upgrade.Upgraded() {
+		// Главным процессом службы становится новый: старый скоро завершится.
+		if _, err := systemd.Notify("MAINPID=" + strconv.Itoa(os.Getpid())); err != nil {
+			logger.Warn("Systemd main PID notification failed", "error", err)
+		}
+		if err := upgrade.Ready(); err != nil {
+			logger.Error("Failed to report readiness to the previous process", "error", err)
+		}
+	}
+	systemd.Ready("serving on "+httpListener.Addr().String(), logger)
+	go systemd.Watchdog(ctx, nil, logger)
 
-	// Ожидаем сигнала завершения.
-	<-ctx.Done()
-	logger.Info("Shutdown signal received")
+	// Ожидаем сигнала завершения; SIGUSR2 передаёт сокеты новому процессу.
+	upgrades := make(chan os.Signal, 1)
+	signal.Notify(upgrades, syscall.SIGUSR2)
+	handedOver := false
+	for !handedOver && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-upgrades:
+			listeners := []systemd.Listener{{Listener: httpListener, Name: "http"}}
+			if metricsListener != nil {
+				listeners = append(listeners, systemd.Listener{Listener: metricsListener, Name: "metrics"})
+			}
+			handedOver = handOver(cfg, eventService, listeners, hooks, logger)
+		}
+	}
+	signal.Stop(upgrades)
+	if handedOver {
+		logger.Info("Server handed over, shutting down")
+	} else {
+		logger.Info("Shutdown signal received")
+		systemd.Stopping(logger)
+	}
 
-	// Инициируем graceful shutdown HTTP-сервера.
+	// Инициируем graceful shutdown HTTP-сервера. Соединения WebSocket
+	// сервер не отслеживает: после передачи их закрывает Drain.
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		logger.Error("HTTP server shutdown error", "error", err)
 	}
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+	if handedOver {
+		// Повторный сигнал завершения отключает оставшихся клиентов сразу.
+		drainCtx, stopDrain := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		n := eventService.Drain(drainCtx, time.Duration(cfg.Upgrade.DrainPeriod), "server restarting")
+		stopDrain()
+		logger.Info("Clients drained", "clients", n)
+	}
 
 	// Завершаем работу генератора событий.
 	eventService.Shutdown()
 	logger.Info("Server stopped gracefully")
 }
+
+// serveMetrics отдаёт метрики сервера по HTTP на сокете ln и возвращает
+// запущенный сервер.
+func serveMetrics(ln net.Listener, registry *metrics.Registry, logger *slog.Logger) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry.Handler())
+	srv := &http.Server{Handler: mux}
+	logger.Info("Serving metrics", "addr", ln.Addr().String())
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error("Metrics server error", "error", err)
+		}
+	}()
+	return srv
+}
+
+// restoreState восстанавливает состояние сервера из снимка и событий
+// истории, сохранённых после него. Отсутствие снимка не является ошибкой.
+func restoreState(es *service.EventService, path string, store history.ServerEventStore, logger *slog.Logger) error {
+	var state service.State
+	if err := checkpoint.Load(path, &state); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	es.RestoreState(state)
+	var tail []domain.Event
+	if store != nil {
+		var err error
+		if tail, err = store.Range(history.Query{FromSeq: state.Seq + 1}); err != nil {
+			return err
+		}
+		es.RestoreEvents(tail)
+	}
+	logger.Info("State restored", "seq", state.Seq, "taken_at", state.TakenAt, "events", len(state.Events), "tail", len(tail))
+	return nil
+}
+
+// openHistory открывает хранилище истории событий, выбранное конфигурацией.
+func openHistory(cfg *config.HistoryConfig) (history.ServerEventStore, error) {
+	switch cfg.Backend {
+	case "", "memory":
+		return history.NewRing(cfg.Capacity), nil
+	case "sqlite", "file":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("path is required for the %s backend", cfg.Backend)
+		}
+		if cfg.Backend == "sqlite" {
+			return history.OpenSQLite(cfg.Path)
+		}
+		return history.OpenFile(cfg.Path)
+	case "postgres":
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("dsn is required for the postgres backend")
+		}
+		return history.OpenPostgres(string(cfg.DSN))
+	default:
+		return nil, fmt.Errorf("unknown backend %q", cfg.Backend)
+	}
+}
+
+// alertRules преобразует правила оповещений из конфигурации.
+func alertRules(cfg []config.AlertConfig) ([]alert.Rule, error) {
+	rules := make([]alert.Rule, 0, len(cfg))
+	for _, c := range cfg {
+		minSeverity, err := domain.ParseSeverity(c.MinSeverity)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, alert.Rule{
+			Name:         c.Name,
+			URL:          string(c.URL),
+			Types:        c.Types,
+			Topics:       c.Topics,
+			MinSeverity:  minSeverity,
+			Format:       c.Format,
+			Template:     c.Template,
+			MaxPerMinute: c.MaxPerMinute,
+		})
+	}
+	return rules, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/service"
+	"github.com/wrongjunior/eventsync/internal/source"
+	"log/slog"
+)
+
+// sourceRestartDelay — пауза перед перезапуском источника, завершившегося ошибкой.
+const sourceRestartDelay = 5 * time.Second
+
+// startSources создаёт источники зарегистрированных типов по конфигурации и
+// запускает их до отмены ctx. События источника публикуются с Source
+// "source:<имя>", если источник не указал его сам.
+func startSources(ctx context.Context, cfgs []config.SourceConfig, es *service.EventService, logger *slog.Logger) error {
+	type named struct {
+		name string
+		src  source.Source
+		log  *slog.Logger
+	}
+	sources := make([]named, 0, len(cfgs))
+	for i, c := range cfgs {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("source-%d", i+1)
+		}
+		log := logger.With("source", name)
+		src, err := source.Open(c.Type, c.Options.Raw(), log)
+		if err != nil {
+			return fmt.Errorf("source %s: %w", name, err)
+		}
+		sources = append(sources, named{name: name, src: src, log: log})
+	}
+	for _, s := range sources {
+		go runSource(ctx, s.name, s.src, es, s.log)
+	}
+	return nil
+}
+
+// runSource выполняет источник, перезапуская его после ошибки.
+func runSource(ctx context.Context, name string, src source.Source, es *service.EventService, logger *slog.Logger) {
+	publish := func(event domain.Event) error {
+		if event.Source == "" {
+			event.Source = domain.NewSource(domain.SourcePlugin, name)
+		}
+		if _, err := es.Publish(event); err != nil {
+			logger.Warn("Source event rejected", "id", event.ID, "type", event.Type, "error", err)
+			return err
+		}
+		return nil
+	}
+	logger.Info("Source started")
+	for {
+		err := src.Run(ctx, publish)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			logger.Info("Source finished")
+			return
+		}
+		logger.Error("Source failed, restarting", "retry_in", sourceRestartDelay, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(sourceRestartDelay):
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"time"
+
+	"log/slog"
+
+	"github.com/wrongjunior/eventsync/internal/config"
+	"github.com/wrongjunior/eventsync/internal/service"
+	"github.com/wrongjunior/eventsync/internal/systemd"
+	"github.com/wrongjunior/eventsync/internal/upgrade"
+)
+
+// upgradeHooks — действия сервера вокруг передачи сокетов новому процессу.
+type upgradeHooks struct {
+	stopSources  func()       // остановить источники событий
+	startSources func() error // снова запустить их, если передача не удалась
+	flush        []func()     // сохранить историю и снимок состояния
+}
+
+// handOver передаёт сокеты listeners новому процессу сервера и сообщает,
+// принял ли он работу. На время передачи публикация событий
+// приостанавливается, а история и снимок состояния сохраняются, чтобы новый
+// процесс продолжил нумерацию событий. При неудаче сервер продолжает работу.
+func handOver(cfg *config.ServerConfig, es *service.EventService, listeners []systemd.Listener, hooks upgradeHooks, logger *slog.Logger) bool {
+	// BoltDB блокирует файл на всё время работы: новый процесс не смог бы
+	// открыть его, пока жив старый.
+	if cfg.DurableSubscriptions != nil || cfg.Schedule != nil {
+		logger.Error("Upgrade is not supported with durable_subscriptions or schedule configured")
+		return false
+	}
+	logger.Info("Upgrade requested")
+	es.SetDraining(true)
+	hooks.stopSources()
+	for _, flush := range hooks.flush {
+		flush()
+	}
+	proc, err := upgrade.Spawn(listeners, time.Duration(cfg.Upgrade.ReadyTimeout), logger)
+	if err != nil {
+		logger.Error("Upgrade failed, continuing to serve", "error", err)
+		es.SetDraining(false)
+		if err := hooks.startSources(); err != nil {
+			logger.Error("Failed to restart event sources", "error", err)
+		}
+		return false
+	}
+	logger.Info("New server process is ready, draining", "pid", proc.Pid)
+	return true
+}
+
+// inheritedListeners возвращает сокеты, переданные предыдущим процессом
+// сервера при перезапуске, а без перезапуска — сокеты активации systemd.
+func inheritedListeners() ([]systemd.Listener, error) {
+	listeners, err := upgrade.Inherited()
+	if err != nil || listeners != nil {
+		return listeners, err
+	}
+	return systemd.Listeners()
+}
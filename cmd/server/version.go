@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/wrongjunior/eventsync/internal/version"
+)
+
+// runVersion реализует команду "version": вывод версии, коммита и даты
+// сборки, с -json — в формате ответа GET /version.
+func runVersion(args []string) error {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print build information as JSON")
+	fs.Parse(args)
+
+	info := version.Get()
+	if *asJSON {
+		return json.NewEncoder(os.Stdout).Encode(info)
+	}
+	_, err := fmt.Println(info)
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// repositoryFilter выбирает все события симуляции.
+var repositoryFilter = repository.EventFilter{Types: []string{eventType}}
+
+// maxListed ограничивает число номеров, перечисляемых в одном нарушении.
+const maxListed = 10
+
+// report проверяет инварианты хранилищ клиентов, печатает сводку и
+// нарушения и возвращает число нарушений:
+//   - одно событие не записывается в хранилище дважды (дедупликация
+//     клиента срабатывает и после переподключений);
+//   - в хранилище нет двух событий с одним номером;
+//   - с checkGaps — сохранены все события от первого полученного клиентом
+//     до последнего опубликованного.
+func report(clients []*simClient, lastSeq uint64, checkGaps bool) int {
+	violations := 0
+	violate := func(c *simClient, format string, args ...any) {
+		violations++
+		fmt.Printf("VIOLATION client %d: %s\n", c.id, fmt.Sprintf(format, args...))
+	}
+	fmt.Printf("%-7s %8s %8s %10s %10s %8s\n", "CLIENT", "STORED", "SAVES", "RECONNECTS", "RESTARTS", "MISSING")
+	for _, c := range clients {
+		events, err := c.repo.Query(repositoryFilter)
+		if err != nil {
+			violate(c, "query failed: %v", err)
+			continue
+		}
+
+		saves := make(map[string]int)
+		totalSaves := 0
+		for _, call := range c.repo.Calls() {
+			if call.Method == "Save" {
+				saves[call.Event.ID]++
+				totalSaves++
+			}
+		}
+		for id, n := range saves {
+			if n > 1 {
+				violate(c, "event %s written %d times", id, n)
+			}
+		}
+
+		seqs := make(map[uint64]int, len(events))
+		first := uint64(0)
+		for _, e := range events {
+			seqs[e.Seq]++
+			if first == 0 || e.Seq < first {
+				first = e.Seq
+			}
+		}
+		for seq, n := range seqs {
+			if n > 1 {
+				violate(c, "%d stored events share seq %d", n, seq)
+			}
+		}
+
+		var missing []uint64
+		if first > 0 {
+			for seq := first; seq <= lastSeq; seq++ {
+				if seqs[seq] == 0 {
+					missing = append(missing, seq)
+				}
+			}
+		}
+		if checkGaps && len(missing) > 0 {
+			listed := missing[:min(len(missing), maxListed)]
+			violate(c, "%d events missing since seq %d, e.g. %v", len(missing), first, listed)
+		}
+
+		c.mu.Lock()
+		restarts := c.restarts
+		c.mu.Unlock()
+		fmt.Printf("%-7d %8d %8d %10d %10d %8d\n", c.id, len(events), totalSaves,
+			c.metrics.Reconnects.Value(), restarts, len(missing))
+	}
+	if violations == 0 {
+		fmt.Println("all invariants hold")
+	} else {
+		fmt.Printf("%d violations\n", violations)
+	}
+	return violations
+}
@@ -0,0 +1,288 @@
+// Команда soak — длительная симуляция: сервер в процессе, клиенты за
+// прокси, который периодически обрывает соединения, отключение и возврат
+// клиентов (churn) и публикация событий с заданной частотой. В конце
+// проверяются инварианты хранилищ клиентов, нарушения печатаются, а код
+// выхода становится ненулевым.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/metrics"
+	"github.com/wrongjunior/eventsync/internal/service"
+	"github.com/wrongjunior/eventsync/internal/testutil"
+	transportClient "github.com/wrongjunior/eventsync/internal/transport/client"
+	transportServer "github.com/wrongjunior/eventsync/internal/transport/server"
+	"log/slog"
+)
+
+// eventType — тип событий, публикуемых симуляцией.
+const eventType = "soak"
+
+type options struct {
+	clients        int
+	rate           int
+	duration       time.Duration
+	drain          time.Duration
+	churnInterval  time.Duration
+	churnDowntime  time.Duration
+	flapInterval   time.Duration
+	checkGaps      bool
+	verbose        bool
+	reportInterval time.Duration
+}
+
+func main() {
+	var opts options
+	flag.IntVar(&opts.clients, "clients", 20, "Number of simulated clients")
+	flag.IntVar(&opts.rate, "rate", 50, "Events published per second")
+	flag.DurationVar(&opts.duration, "duration", time.Minute, "Simulation duration")
+	flag.DurationVar(&opts.drain, "drain", 3*time.Second, "Time for clients to catch up after publishing stops")
+	flag.DurationVar(&opts.churnInterval, "churn-interval", 2*time.Second, "How often a random client disconnects (0 = no churn)")
+	flag.DurationVar(&opts.churnDowntime, "churn-downtime", time.Second, "How long a churned client stays offline")
+	flag.DurationVar(&opts.flapInterval, "flap-interval", 10*time.Second, "How often all connections are cut (0 = no flaps)")
+	flag.BoolVar(&opts.checkGaps, "check-gaps", false, "Require every event since a client's first one to be stored (needs server-side replay)")
+	flag.BoolVar(&opts.verbose, "v", false, "Log client and server activity")
+	flag.DurationVar(&opts.reportInterval, "report-interval", 10*time.Second, "Progress report period")
+	flag.Parse()
+
+	violations, err := run(opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+	if violations > 0 {
+		os.Exit(1)
+	}
+}
+
+func run(opts options) (int, error) {
+	if opts.clients <= 0 || opts.rate <= 0 {
+		return 0, errors.New("clients and rate must be positive")
+	}
+	var handler slog.Handler = slog.NewTextHandler(io.Discard, nil)
+	if opts.verbose {
+		handler = slog.NewTextHandler(os.Stderr, nil)
+	}
+	logger := slog.New(handler)
+
+	// Сервер в процессе и прокси перед ним.
+	es := service.NewEventService(logger)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	server := &http.Server{Handler: transportServer.SetupRouter(es, logger, transportServer.RouterConfig{WSPath: "/ws"})}
+	go server.Serve(ln)
+	defer server.Close()
+	proxy, err := newFlakyProxy(ln.Addr().String())
+	if err != nil {
+		return 0, err
+	}
+	defer proxy.Close()
+	serverURL := "ws://" + proxy.Addr() + "/ws"
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	clients := make([]*simClient, opts.clients)
+	for i := range clients {
+		clients[i] = newSimClient(i+1, serverURL, logger)
+		clients[i].start()
+	}
+	defer func() {
+		for _, c := range clients {
+			c.stop()
+			c.cs.Close()
+		}
+	}()
+
+	runCtx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+	var wg sync.WaitGroup
+	var published uint64
+	var lastSeq uint64
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		published, lastSeq = publish(runCtx, es, opts.rate)
+	}()
+	var flaps, churns int
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		flaps, churns = disrupt(runCtx, opts, proxy, clients)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		progress(runCtx, opts.reportInterval, es, clients)
+	}()
+	wg.Wait()
+
+	fmt.Printf("publishing stopped, draining for %s\n", opts.drain)
+	select {
+	case <-time.After(opts.drain):
+	case <-ctx.Done():
+	}
+	fmt.Printf("published %d events (last seq %d), %d flaps, %d churns\n", published, lastSeq, flaps, churns)
+	return report(clients, lastSeq, opts.checkGaps), nil
+}
+
+// publish публикует события с частотой rate и возвращает их число и
+// последний присвоенный номер.
+func publish(ctx context.Context, es *service.EventService, rate int) (count, lastSeq uint64) {
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return count, lastSeq
+		case <-ticker.C:
+			event, err := es.Publish(domain.Event{Type: eventType, Message: fmt.Sprintf("soak %d", count+1)})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "publish error:", err)
+				continue
+			}
+			count++
+			lastSeq = event.Seq
+		}
+	}
+}
+
+// disrupt периодически обрывает соединения и отключает случайных клиентов.
+func disrupt(ctx context.Context, opts options, proxy *flakyProxy, clients []*simClient) (flaps, churns int) {
+	flapC := tick(opts.flapInterval)
+	churnC := tick(opts.churnInterval)
+	var downtime sync.WaitGroup
+	defer downtime.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return flaps, churns
+		case <-flapC:
+			flaps++
+			fmt.Printf("flap: cut %d connections\n", proxy.Flap())
+		case <-churnC:
+			c := clients[rand.Intn(len(clients))]
+			if !c.stop() {
+				continue
+			}
+			churns++
+			downtime.Add(1)
+			time.AfterFunc(opts.churnDowntime, func() {
+				defer downtime.Done()
+				c.start()
+			})
+		}
+	}
+}
+
+// progress периодически печатает состояние симуляции.
+func progress(ctx context.Context, interval time.Duration, es *service.EventService, clients []*simClient) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var stored int
+			for _, c := range clients {
+				n, _ := c.repo.Count(repositoryFilter)
+				stored += n
+			}
+			fmt.Printf("progress: %d connected, %d events stored across clients\n", len(es.Clients("")), stored)
+		}
+	}
+}
+
+// tick возвращает канал тикера либо nil, если интервал не задан.
+func tick(interval time.Duration) <-chan time.Time {
+	if interval <= 0 {
+		return nil
+	}
+	return time.NewTicker(interval).C
+}
+
+// simClient — клиент симуляции. Сервис и хранилище переживают отключения,
+// как у перезапущенного процесса с сохранённой БД.
+type simClient struct {
+	id      int
+	url     string
+	logger  *slog.Logger
+	repo    *testutil.Repository
+	cs      *service.ClientService
+	metrics *metrics.ClientMetrics
+
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	done     chan struct{}
+	restarts int
+}
+
+func newSimClient(id int, url string, logger *slog.Logger) *simClient {
+	repo := testutil.NewRepository()
+	return &simClient{
+		id:      id,
+		url:     url,
+		logger:  logger,
+		repo:    repo,
+		cs:      service.NewClientService(repo, logger),
+		metrics: metrics.NewClientMetrics(metrics.NewRegistry()),
+	}
+}
+
+// start подключает клиента.
+func (c *simClient) start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		return
+	}
+	transport := transportClient.NewClientTransport(c.url, c.cs, c.logger)
+	transport.ClientID = fmt.Sprintf("soak-%d", c.id)
+	transport.Metrics = c.metrics
+	transport.Reconnect = transportClient.ReconnectPolicy{
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Jitter:         0.2,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	c.cancel, c.done = cancel, done
+	go func() {
+		defer close(done)
+		transport.Listen(ctx)
+	}()
+}
+
+// stop отключает клиента и ждёт остановки транспорта; false — клиент уже отключён.
+func (c *simClient) stop() bool {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.cancel, c.done = nil, nil
+	if cancel != nil {
+		c.restarts++
+	}
+	c.mu.Unlock()
+	if cancel == nil {
+		return false
+	}
+	cancel()
+	<-done
+	return true
+}
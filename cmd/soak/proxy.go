@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+// flakyProxy пересылает TCP-соединения на сервер и по команде обрывает все
+// текущие соединения, имитируя сбой сети.
+type flakyProxy struct {
+	listener net.Listener
+	target   string
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// newFlakyProxy начинает принимать соединения на случайном локальном порту.
+func newFlakyProxy(target string) (*flakyProxy, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &flakyProxy{listener: ln, target: target, conns: make(map[net.Conn]struct{})}
+	go p.serve()
+	return p, nil
+}
+
+// Addr возвращает адрес прокси.
+func (p *flakyProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+func (p *flakyProxy) serve() {
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		server, err := net.Dial("tcp", p.target)
+		if err != nil {
+			client.Close()
+			continue
+		}
+		p.track(client, server)
+		go p.pipe(client, server)
+		go p.pipe(server, client)
+	}
+}
+
+func (p *flakyProxy) pipe(dst, src net.Conn) {
+	io.Copy(dst, src)
+	p.untrack(dst, src)
+}
+
+func (p *flakyProxy) track(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		p.conns[c] = struct{}{}
+	}
+}
+
+func (p *flakyProxy) untrack(conns ...net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+		delete(p.conns, c)
+	}
+}
+
+// Flap обрывает все текущие соединения и возвращает их число.
+func (p *flakyProxy) Flap() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.conns) / 2
+	for c := range p.conns {
+		c.Close()
+		delete(p.conns, c)
+	}
+	return n
+}
+
+// Close перестаёт принимать соединения и обрывает текущие.
+func (p *flakyProxy) Close() {
+	p.listener.Close()
+	p.Flap()
+}
@@ -0,0 +1,240 @@
+// Package alert отправляет уведомления о событиях, подходящих под
+// настроенные правила (например, type=error), на webhook или в Slack.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Форматы тела запроса.
+const (
+	FormatJSON  = "json"  // событие в JSON либо результат шаблона как есть
+	FormatSlack = "slack" // {"text": результат шаблона} для входящих webhook Slack
+)
+
+// defaultSlackTemplate — текст уведомления Slack без заданного шаблона.
+const defaultSlackTemplate = "[{{.Type}}] {{if .Topic}}{{.Topic}}: {{end}}{{.Message}}"
+
+// queueSize — число уведомлений, ожидающих отправки; сверх него они отбрасываются.
+const queueSize = 256
+
+// Rule описывает, какие события и куда отправлять.
+type Rule struct {
+	Name        string
+	URL         string
+	Types       []string        // типы событий; пусто — любые
+	Topics      []string        // шаблоны топиков; пусто — любые
+	MinSeverity domain.Severity // порог важности; SeverityNone — без порога
+	Format      string          // FormatJSON (по умолчанию) или FormatSlack
+	// Template — шаблон text/template над domain.Event. Для FormatJSON без
+	// шаблона отправляется событие целиком.
+	Template string
+	// MaxPerMinute ограничивает число уведомлений правила в минуту;
+	// 0 — без ограничения. Лишние уведомления отбрасываются.
+	MaxPerMinute int
+}
+
+// rule — подготовленное правило с состоянием ограничителя частоты.
+type rule struct {
+	Rule
+	tmpl *template.Template
+
+	mu         sync.Mutex
+	window     time.Time // начало текущей минуты
+	sent       int       // уведомлений в текущей минуте
+	suppressed int       // отброшено в текущей минуте
+}
+
+type delivery struct {
+	rule *rule
+	body []byte
+}
+
+// Alerter проверяет события по правилам и отправляет уведомления в
+// фоновой горутине, не задерживая обработку событий.
+type Alerter struct {
+	rules      []*rule
+	severities domain.SeverityMap
+	client     *http.Client
+	logger     *slog.Logger
+	queue      chan delivery
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// New проверяет правила и запускает отправку; severities задаёт важность
+// пользовательских типов для MinSeverity.
+func New(rules []Rule, severities domain.SeverityMap, logger *slog.Logger) (*Alerter, error) {
+	a := &Alerter{
+		severities: severities,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		logger:     logger,
+		queue:      make(chan delivery, queueSize),
+		done:       make(chan struct{}),
+	}
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("alert-%d", i+1)
+		}
+		if r.URL == "" {
+			return nil, fmt.Errorf("alert %s: url is required", r.Name)
+		}
+		for _, pattern := range r.Topics {
+			if err := domain.ValidateTopicPattern(pattern); err != nil {
+				return nil, fmt.Errorf("alert %s: %w: %q", r.Name, err, pattern)
+			}
+		}
+		text := r.Template
+		switch r.Format {
+		case "", FormatJSON:
+			r.Format = FormatJSON
+		case FormatSlack:
+			if text == "" {
+				text = defaultSlackTemplate
+			}
+		default:
+			return nil, fmt.Errorf("alert %s: unknown format %q", r.Name, r.Format)
+		}
+		prepared := &rule{Rule: r}
+		if text != "" {
+			tmpl, err := template.New(r.Name).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("alert %s: %w", r.Name, err)
+			}
+			prepared.tmpl = tmpl
+		}
+		a.rules = append(a.rules, prepared)
+	}
+	go a.run()
+	return a, nil
+}
+
+// Notify ставит в очередь уведомления по всем правилам, под которые
+// подходит событие. Не блокируется.
+func (a *Alerter) Notify(event domain.Event) {
+	for _, r := range a.rules {
+		if !r.matches(event, a.severities) || !r.allow(time.Now(), a.logger) {
+			continue
+		}
+		body, err := r.render(event)
+		if err != nil {
+			a.logger.Error("Alert render error", "alert", r.Name, "id", event.ID, "error", err)
+			continue
+		}
+		select {
+		case a.queue <- delivery{rule: r, body: body}:
+		default:
+			a.logger.Warn("Alert queue full, alert dropped", "alert", r.Name, "id", event.ID)
+		}
+	}
+}
+
+// Close отправляет уведомления из очереди и останавливает отправку.
+func (a *Alerter) Close() {
+	a.closeOnce.Do(func() { close(a.queue) })
+	<-a.done
+}
+
+func (a *Alerter) run() {
+	defer close(a.done)
+	for d := range a.queue {
+		if err := a.post(d); err != nil {
+			a.logger.Error("Alert delivery failed", "alert", d.rule.Name, "error", err)
+		}
+	}
+}
+
+func (a *Alerter) post(d delivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.rule.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+func (r *rule) matches(event domain.Event, severities domain.SeverityMap) bool {
+	if len(r.Types) > 0 {
+		found := false
+		for _, t := range r.Types {
+			if t == event.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.Topics) > 0 {
+		found := false
+		for _, pattern := range r.Topics {
+			if domain.MatchTopic(pattern, event.Topic) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return severities.Allows(event.Type, r.MinSeverity)
+}
+
+// allow применяет ограничение частоты в окне одной минуты; о числе
+// отброшенных уведомлений сообщается при смене окна.
+func (r *rule) allow(now time.Time, logger *slog.Logger) bool {
+	if r.MaxPerMinute <= 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if window := now.Truncate(time.Minute); !window.Equal(r.window) {
+		if r.suppressed > 0 {
+			logger.Warn("Alerts suppressed by rate limit", "alert", r.Name, "suppressed", r.suppressed)
+		}
+		r.window, r.sent, r.suppressed = window, 0, 0
+	}
+	if r.sent >= r.MaxPerMinute {
+		r.suppressed++
+		return false
+	}
+	r.sent++
+	return true
+}
+
+// render формирует тело запроса для события.
+func (r *rule) render(event domain.Event) ([]byte, error) {
+	if r.tmpl == nil {
+		return json.Marshal(event)
+	}
+	var text strings.Builder
+	if err := r.tmpl.Execute(&text, event); err != nil {
+		return nil, err
+	}
+	if r.Format == FormatSlack {
+		return json.Marshal(map[string]string{"text": text.String()})
+	}
+	return []byte(text.String()), nil
+}
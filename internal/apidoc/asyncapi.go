@@ -0,0 +1,83 @@
+package apidoc
+
+import "reflect"
+
+// Message — вид кадра WebSocket.
+type Message struct {
+	Name    string // значение поля kind
+	Summary string
+	Payload reflect.Type
+}
+
+// Channel — соединение WebSocket и кадры, которыми обмениваются стороны.
+type Channel struct {
+	Path         string
+	Description  string
+	Scope        string    // право ключа для подключения; пусто — без аутентификации
+	Query        []Param   // параметры запроса подключения
+	Subprotocols []string  // версии протокола из Sec-WebSocket-Protocol
+	Receive      []Message // кадры сервера клиенту
+	Send         []Message // кадры клиента серверу
+}
+
+// AsyncAPI собирает документ AsyncAPI 2.6 для канала WebSocket.
+func AsyncAPI(info Info, ch Channel, schemas *Schemas) map[string]any {
+	messages := make(map[string]any)
+	refs := func(list []Message) map[string]any {
+		oneOf := make([]any, 0, len(list))
+		for _, m := range list {
+			messages[m.Name] = map[string]any{
+				"name":    m.Name,
+				"summary": m.Summary,
+				"payload": schemas.Of(m.Payload),
+			}
+			oneOf = append(oneOf, map[string]any{"$ref": "#/components/messages/" + m.Name})
+		}
+		return map[string]any{"message": map[string]any{"oneOf": oneOf}}
+	}
+	query := make(map[string]any, len(ch.Query))
+	for _, p := range ch.Query {
+		typ := p.Type
+		if typ == "" {
+			typ = "string"
+		}
+		prop := map[string]any{"type": typ}
+		if p.Description != "" {
+			prop["description"] = p.Description
+		}
+		query[p.Name] = prop
+	}
+	// В AsyncAPI 2 subscribe — то, что получает клиент, publish — то, что он
+	// отправляет.
+	subscribe := refs(ch.Receive)
+	subscribe["summary"] = "Frames sent by the server"
+	publish := refs(ch.Send)
+	publish["summary"] = "Frames sent by the client"
+	channel := map[string]any{
+		"description": ch.Description,
+		"subscribe":   subscribe,
+		"publish":     publish,
+		"bindings": map[string]any{
+			"ws": map[string]any{
+				"method": "GET",
+				"query":  map[string]any{"type": "object", "properties": query},
+			},
+		},
+	}
+	if len(ch.Subprotocols) > 0 {
+		channel["x-subprotocols"] = ch.Subprotocols
+	}
+	if ch.Scope != "" {
+		channel["x-scope"] = ch.Scope
+	}
+	return map[string]any{
+		"asyncapi":           "2.6.0",
+		"info":               infoObject(info),
+		"defaultContentType": "application/json",
+		"channels":           map[string]any{ch.Path: channel},
+		"components": map[string]any{
+			"messages": messages,
+			"schemas":  schemas.Definitions(),
+		},
+	}
+}
@@ -0,0 +1,170 @@
+// Package apidoc формирует машинно-читаемое описание протокола — OpenAPI
+// для HTTP API и AsyncAPI для WebSocket — из Go-типов кадров и
+// зарегистрированных маршрутов, чтобы клиенты на других языках
+// генерировались по фактическому протоколу, а не по документации.
+package apidoc
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// refPrefix — путь к определениям схем в обоих документах.
+const refPrefix = "#/components/schemas/"
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Schemas строит JSON Schema Go-типов так, как их сериализует
+// encoding/json. Именованные структуры выносятся в определения и
+// подставляются ссылками, поэтому рекурсивные типы допустимы.
+type Schemas struct {
+	defs  map[string]any
+	names map[reflect.Type]string
+}
+
+// NewSchemas создаёт пустой набор определений.
+func NewSchemas() *Schemas {
+	return &Schemas{
+		defs:  make(map[string]any),
+		names: make(map[reflect.Type]string),
+	}
+}
+
+// Definitions возвращает накопленные определения для components.schemas.
+func (s *Schemas) Definitions() map[string]any {
+	return s.defs
+}
+
+// Of возвращает схему типа t; nil — любое значение.
+func (s *Schemas) Of(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]any{"type": "integer", "description": "nanoseconds"}
+	case rawMessageType:
+		return map[string]any{}
+	}
+	if t.Kind() == reflect.Pointer {
+		return s.Of(t.Elem())
+	}
+	// Собственную сериализацию нельзя вывести из устройства типа.
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return map[string]any{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": s.Of(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.Of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return map[string]any{"$ref": refPrefix + s.define(t)}
+	default:
+		return map[string]any{}
+	}
+}
+
+// define выносит именованную структуру в определения и возвращает её имя.
+func (s *Schemas) define(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := exported(t.Name())
+	if _, taken := s.defs[name]; taken {
+		// Одноимённые типы разных пакетов различаются префиксом пакета.
+		name = exported(path.Base(t.PkgPath())) + name
+	}
+	s.names[t] = name
+	s.defs[name] = nil // занимает имя на время построения рекурсивных полей
+	s.defs[name] = s.object(t)
+	return name
+}
+
+// exported делает первую букву имени заглавной.
+func exported(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// object строит схему объекта из экспортируемых полей структуры.
+func (s *Schemas) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	s.fields(t, properties, &required)
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields добавляет поля структуры, раскрывая встроенные структуры без
+// тега json, как это делает encoding/json.
+func (s *Schemas) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(ft, properties, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema := s.Of(f.Type)
+		if strings.Contains(opts, "string") {
+			schema = map[string]any{"type": "string"}
+		}
+		properties[name] = schema
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package apidoc
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Info — сведения о документе.
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+// Param — параметр запроса: "query", "path" или "header".
+type Param struct {
+	Name        string
+	In          string
+	Description string
+	Type        string // тип JSON Schema значения; пусто — "string"
+	Required    bool
+}
+
+// Response — ответ операции. Body nil — ответ без тела, если не задан
+// Media.
+type Response struct {
+	Status      int
+	Description string
+	Body        reflect.Type
+	Media       string // тип содержимого; пусто — application/json
+}
+
+// Operation — операция HTTP API.
+type Operation struct {
+	Method  string
+	Path    string // шаблон маршрута chi, например "/admin/keys/{id}"
+	Summary string
+	// Scope — право ключа, требуемое операцией; пусто — без аутентификации.
+	Scope     string
+	Params    []Param
+	Body      reflect.Type // тело запроса JSON; nil — без тела
+	BodyMedia string       // тип содержимого тела; пусто — application/json
+	Responses []Response
+}
+
+var pathParamRe = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// OpenAPI собирает документ OpenAPI 3.1 из операций. Параметры пути,
+// не описанные в Params, добавляются из шаблона маршрута. Ошибки операций
+// описываются ответом default с телом errorBody.
+func OpenAPI(info Info, ops []Operation, errorBody reflect.Type, schemas *Schemas) map[string]any {
+	paths := make(map[string]any)
+	for _, op := range ops {
+		route := pathParamRe.ReplaceAllString(op.Path, "{$1}")
+		item, _ := paths[route].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[route] = item
+		}
+		item[strings.ToLower(op.Method)] = operation(op, errorBody, schemas)
+	}
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info":    infoObject(info),
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas.Definitions(),
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+	return doc
+}
+
+func operation(op Operation, errorBody reflect.Type, schemas *Schemas) map[string]any {
+	result := map[string]any{"summary": op.Summary}
+	params := make([]any, 0, len(op.Params))
+	declared := make(map[string]bool)
+	for _, p := range op.Params {
+		declared[p.Name] = true
+		params = append(params, parameter(p))
+	}
+	for _, m := range pathParamRe.FindAllStringSubmatch(op.Path, -1) {
+		if !declared[m[1]] {
+			params = append(params, parameter(Param{Name: m[1], In: "path", Required: true}))
+		}
+	}
+	if len(params) > 0 {
+		result["parameters"] = params
+	}
+	if op.Body != nil {
+		result["requestBody"] = map[string]any{
+			"required": true,
+			"content":  content(op.BodyMedia, op.Body, schemas),
+		}
+	}
+	responses := make(map[string]any)
+	for _, r := range op.Responses {
+		resp := map[string]any{"description": r.Description}
+		if r.Description == "" {
+			resp["description"] = http.StatusText(r.Status)
+		}
+		if r.Body != nil || r.Media != "" {
+			resp["content"] = content(r.Media, r.Body, schemas)
+		}
+		responses[strconv.Itoa(r.Status)] = resp
+	}
+	if errorBody != nil {
+		responses["default"] = map[string]any{
+			"description": "Error",
+			"content":     content("", errorBody, schemas),
+		}
+	}
+	result["responses"] = responses
+	if op.Scope != "" {
+		result["security"] = []any{
+			map[string]any{"bearer": []any{}},
+			map[string]any{"apiKey": []any{}},
+		}
+		result["x-scope"] = op.Scope
+	}
+	return result
+}
+
+func parameter(p Param) map[string]any {
+	typ := p.Type
+	if typ == "" {
+		typ = "string"
+	}
+	param := map[string]any{
+		"name":   p.Name,
+		"in":     p.In,
+		"schema": map[string]any{"type": typ},
+	}
+	if p.Description != "" {
+		param["description"] = p.Description
+	}
+	if p.Required || p.In == "path" {
+		param["required"] = true
+	}
+	return param
+}
+
+func content(media string, body reflect.Type, schemas *Schemas) map[string]any {
+	if media == "" {
+		media = "application/json"
+	}
+	return map[string]any{media: map[string]any{"schema": schemas.Of(body)}}
+}
+
+func infoObject(info Info) map[string]any {
+	obj := map[string]any{"title": info.Title, "version": info.Version}
+	if info.Description != "" {
+		obj["description"] = info.Description
+	}
+	return obj
+}
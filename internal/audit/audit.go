@@ -0,0 +1,153 @@
+// Package audit ведёт журнал подключений клиентов отдельно от логов
+// приложения: подключения, отключения, ошибки аутентификации, отказы и
+// принудительные отключения.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// Kind — вид записи журнала.
+type Kind string
+
+const (
+	KindConnect     Kind = "connect"      // клиент подключился
+	KindDisconnect  Kind = "disconnect"   // клиент отключился или соединение оборвалось
+	KindEvicted     Kind = "evicted"      // сервер отключил клиента, например медленного
+	KindAuthFailure Kind = "auth_failure" // неверный ключ или недостаточно прав
+	KindRejected    Kind = "rejected"     // подключение отклонено: квота, протокол
+)
+
+// Entry — запись журнала подключений.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Kind       Kind      `json:"kind"`
+	ClientID   uint64    `json:"client_id,omitempty"`
+	Namespace  string    `json:"namespace,omitempty"`
+	KeyID      string    `json:"key_id,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	// DurationMillis и EventsDelivered заполняются при отключении.
+	DurationMillis  float64 `json:"duration_ms,omitempty"`
+	EventsDelivered uint64  `json:"events_delivered,omitempty"`
+}
+
+// Filter отбирает записи журнала; пустые поля не ограничивают выборку.
+type Filter struct {
+	Namespace string
+	Kind      Kind
+	ClientID  uint64
+	Since     time.Time
+	Limit     int // последние Limit записей; 0 — все хранимые
+}
+
+// Log хранит последние записи в памяти для административного API и, если
+// задан файл, дописывает каждую запись в него строкой JSON.
+type Log struct {
+	mu      sync.Mutex
+	entries []Entry
+	limit   int
+	file    *os.File
+}
+
+// Open открывает журнал вместимостью limit записей в памяти. Если path не
+// пуст, записи дописываются в файл, а последние из них загружаются из него
+// при открытии.
+func Open(path string, limit int) (*Log, error) {
+	if limit <= 0 {
+		limit = 1000
+	}
+	l := &Log{limit: limit}
+	if path == "" {
+		return l, nil
+	}
+	if err := l.load(path); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	l.file = f
+	return l, nil
+}
+
+// load читает последние записи из файла журнала.
+func (l *Log) load(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry Entry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue // недописанная при сбое строка
+		}
+		l.append(entry)
+	}
+	return scanner.Err()
+}
+
+// Record добавляет запись в журнал. У nil-журнала ничего не делает.
+func (l *Log) Record(entry Entry) {
+	if l == nil {
+		return
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.append(entry)
+	if l.file != nil {
+		line, _ := json.Marshal(entry)
+		l.file.Write(append(line, '\n'))
+	}
+}
+
+func (l *Log) append(entry Entry) {
+	if len(l.entries) == l.limit {
+		l.entries = l.entries[1:]
+	}
+	l.entries = append(l.entries, entry)
+}
+
+// List возвращает хранимые записи, подходящие под фильтр, от старых к новым.
+func (l *Log) List(f Filter) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := make([]Entry, 0)
+	for _, e := range l.entries {
+		if (f.Namespace != "" && e.Namespace != f.Namespace) ||
+			(f.Kind != "" && e.Kind != f.Kind) ||
+			(f.ClientID != 0 && e.ClientID != f.ClientID) ||
+			e.Time.Before(f.Since) {
+			continue
+		}
+		result = append(result, e)
+	}
+	if f.Limit > 0 && len(result) > f.Limit {
+		result = result[len(result)-f.Limit:]
+	}
+	return result
+}
+
+// Close закрывает файл журнала.
+func (l *Log) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Scope определяет разрешённую ключу операцию.
+type Scope string
+
+const (
+	ScopePublish   Scope = "publish"   // публикация событий
+	ScopeSubscribe Scope = "subscribe" // подписка на события через WebSocket
+	ScopeAdmin     Scope = "admin"     // управление ключами и административный API
+)
+
+// Ошибки аутентификации и управления ключами. ErrUnauthenticated и
+// ErrForbidden оборачивают domain.ErrUnauthorized.
+var (
+	ErrUnauthenticated = fmt.Errorf("%w: missing or invalid api key", domain.ErrUnauthorized)
+	ErrForbidden       = fmt.Errorf("%w: insufficient scope", domain.ErrUnauthorized)
+	ErrKeyNotFound     = errors.New("api key not found")
+	ErrInvalidScope    = errors.New("invalid scope")
+)
+
+// ParseScope проверяет строковое значение области доступа.
+func ParseScope(s string) (Scope, error) {
+	switch sc := Scope(s); sc {
+	case ScopePublish, ScopeSubscribe, ScopeAdmin:
+		return sc, nil
+	}
+	return "", ErrInvalidScope
+}
+
+// Principal описывает аутентифицированного владельца ключа или токена.
+type Principal struct {
+	KeyID  string
+	Tenant string // пустое значение — доступ ко всем тенантам (bootstrap-ключ администратора)
+	Scopes []Scope
+	// Topics — шаблоны топиков, в пределах которых разрешены подписка и
+	// публикация (из claim "topics" JWT); пусто — все топики.
+	Topics []string
+}
+
+// Has сообщает, выдана ли субъекту область доступа.
+func (p Principal) Has(scope Scope) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// CanSubscribe сообщает, входит ли шаблон подписки целиком в разрешённые топики.
+func (p Principal) CanSubscribe(pattern string) bool {
+	if len(p.Topics) == 0 {
+		return true
+	}
+	for _, allowed := range p.Topics {
+		if domain.PatternCovers(allowed, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanPublish сообщает, разрешена ли публикация в топик.
+func (p Principal) CanPublish(topic string) bool {
+	if len(p.Topics) == 0 {
+		return true
+	}
+	for _, allowed := range p.Topics {
+		if domain.MatchTopic(allowed, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessTenant сообщает, может ли субъект работать с указанным тенантом.
+func (p Principal) CanAccessTenant(tenant string) bool {
+	return p.Tenant == "" || p.Tenant == tenant
+}
+
+// APIKey — запись о ключе. Сам ключ не хранится, только его SHA-256.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Tenant    string     `json:"tenant"`
+	Scopes    []Scope    `json:"scopes"`
+	Hash      string     `json:"hash,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Redacted возвращает копию записи без хэша — для выдачи через API.
+func (k APIKey) Redacted() APIKey {
+	k.Hash = ""
+	return k
+}
+
+// Principal возвращает субъекта, от имени которого действует ключ.
+func (k APIKey) Principal() Principal {
+	return Principal{KeyID: k.ID, Tenant: k.Tenant, Scopes: k.Scopes}
+}
+
+// KeyStore хранит API-ключи тенантов.
+type KeyStore interface {
+	// Create выпускает новый ключ и возвращает его запись и открытое значение,
+	// которое больше нигде не сохраняется.
+	Create(tenant string, scopes []Scope) (APIKey, string, error)
+	// Revoke отзывает ключ по идентификатору.
+	Revoke(id string) error
+	// Authenticate находит действующий ключ по открытому значению.
+	Authenticate(secret string) (APIKey, error)
+	// List возвращает ключи тенанта; пустой tenant — все ключи.
+	List(tenant string) ([]APIKey, error)
+}
+
+type principalKey struct{}
+
+// WithPrincipal сохраняет субъекта в контексте запроса.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext извлекает субъекта из контекста запроса.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
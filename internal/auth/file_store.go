@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyPrefix отличает ключи eventsync от прочих секретов в логах и конфигурации.
+const keyPrefix = "esk_"
+
+// FileKeyStore хранит ключи в JSON-файле и перезаписывает его при каждом изменении.
+type FileKeyStore struct {
+	path string
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+// NewFileKeyStore загружает ключи из файла; отсутствующий файл означает пустое хранилище.
+func NewFileKeyStore(path string) (*FileKeyStore, error) {
+	s := &FileKeyStore{path: path, keys: make(map[string]APIKey)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+	return s, nil
+}
+
+// Create выпускает новый ключ вида "esk_<id>_<secret>".
+func (s *FileKeyStore) Create(tenant string, scopes []Scope) (APIKey, string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	secretPart, err := randomHex(24)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	secret := keyPrefix + id + "_" + secretPart
+	key := APIKey{
+		ID:        id,
+		Tenant:    tenant,
+		Scopes:    scopes,
+		Hash:      hashSecret(secret),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[id] = key
+	if err := s.persist(); err != nil {
+		delete(s.keys, id)
+		return APIKey{}, "", err
+	}
+	return key, secret, nil
+}
+
+// Revoke помечает ключ отозванным; запись сохраняется для аудита.
+func (s *FileKeyStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	s.keys[id] = key
+	return s.persist()
+}
+
+// Authenticate проверяет ключ за постоянное время сравнения хэшей.
+func (s *FileKeyStore) Authenticate(secret string) (APIKey, error) {
+	id, ok := parseKeyID(secret)
+	if !ok {
+		return APIKey{}, ErrUnauthenticated
+	}
+	s.mu.RLock()
+	key, ok := s.keys[id]
+	s.mu.RUnlock()
+	if !ok || key.RevokedAt != nil {
+		return APIKey{}, ErrUnauthenticated
+	}
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashSecret(secret))) != 1 {
+		return APIKey{}, ErrUnauthenticated
+	}
+	return key, nil
+}
+
+// List возвращает ключи тенанта; пустой tenant — все ключи.
+func (s *FileKeyStore) List(tenant string) ([]APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		if tenant == "" || k.Tenant == tenant {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// persist атомарно перезаписывает файл хранилища. Вызывается под s.mu.
+func (s *FileKeyStore) persist() error {
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func parseKeyID(secret string) (string, bool) {
+	rest, ok := strings.CutPrefix(secret, keyPrefix)
+	if !ok {
+		return "", false
+	}
+	id, _, ok := strings.Cut(rest, "_")
+	return id, ok && id != ""
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minReload ограничивает частоту перезагрузки набора ключей при запросах
+// с неизвестным kid.
+const minReload = 30 * time.Second
+
+// KeySet — набор открытых ключей JWKS, загружаемый из файла или по URL и
+// периодически обновляемый, чтобы подхватывать ротацию ключей.
+type KeySet struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+
+	mu       sync.Mutex
+	keys     map[string]jwk
+	loadedAt time.Time
+}
+
+// jwk — разобранный ключ JWKS.
+type jwk struct {
+	alg string // ограничение алгоритма из поля "alg"; пусто — любой подходящий
+	key crypto.PublicKey
+}
+
+// NewKeySet загружает JWKS из source — URL http(s) или пути к файлу — и
+// перечитывает его раз в refresh (0 — 10 минут).
+func NewKeySet(source string, refresh time.Duration) (*KeySet, error) {
+	if refresh <= 0 {
+		refresh = 10 * time.Minute
+	}
+	ks := &KeySet{source: source, refresh: refresh, client: &http.Client{Timeout: 10 * time.Second}}
+	if err := ks.reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// key возвращает ключ по kid; пустой kid допустим, если ключ в наборе один.
+// Устаревший набор и набор без нужного kid перезагружаются.
+func (ks *KeySet) key(kid string) (jwk, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k, ok := ks.lookup(kid)
+	age := time.Since(ks.loadedAt)
+	if age > ks.refresh || (!ok && age > minReload) {
+		if err := ks.reloadLocked(); err != nil {
+			if !ok {
+				return jwk{}, err
+			}
+			// Старый набор остаётся в силе до успешной загрузки.
+		} else {
+			k, ok = ks.lookup(kid)
+		}
+	}
+	if !ok {
+		return jwk{}, fmt.Errorf("unknown key id %q", kid)
+	}
+	return k, nil
+}
+
+func (ks *KeySet) lookup(kid string) (jwk, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			return k, true
+		}
+	}
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+func (ks *KeySet) reload() error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.reloadLocked()
+}
+
+func (ks *KeySet) reloadLocked() error {
+	raw, err := ks.fetch()
+	if err != nil {
+		return fmt.Errorf("load jwks: %w", err)
+	}
+	keys, err := parseJWKS(raw)
+	if err != nil {
+		return fmt.Errorf("load jwks: %w", err)
+	}
+	ks.keys, ks.loadedAt = keys, time.Now()
+	return nil
+}
+
+func (ks *KeySet) fetch() ([]byte, error) {
+	if !strings.HasPrefix(ks.source, "http://") && !strings.HasPrefix(ks.source, "https://") {
+		return os.ReadFile(ks.source)
+	}
+	resp, err := ks.client.Get(ks.source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded %s", ks.source, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseJWKS разбирает ключи подписи RSA и EC; ключи другого назначения и
+// неподдерживаемых типов пропускаются.
+func parseJWKS(raw []byte) (map[string]jwk, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]jwk, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var pub crypto.PublicKey
+		var err error
+		switch k.Kty {
+		case "RSA":
+			pub, err = rsaKey(k.N, k.E)
+		case "EC":
+			pub, err = ecKey(k.Crv, k.X, k.Y)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = jwk{alg: k.Alg, key: pub}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	return keys, nil
+}
+
+func rsaKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(eb)
+	if len(nb) == 0 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid rsa key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}, nil
+}
+
+func ecKey(crv, x, y string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, err
+	}
+	yb, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, err
+	}
+	pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(xb), Y: new(big.Int).SetBytes(yb)}
+	if !curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, errors.New("invalid ec key")
+	}
+	return pub, nil
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken возвращается для JWT с неверной подписью, истёкшим сроком
+// или неподходящими claims; оборачивает ErrUnauthenticated.
+var ErrInvalidToken = fmt.Errorf("%w: invalid token", ErrUnauthenticated)
+
+// JWTVerifier проверяет JWT, подписанные ключами из JWKS (RS256/384/512,
+// ES256/384/512), и строит по их claims субъекта:
+//   - "sub" — идентификатор субъекта (KeyID "jwt:<sub>");
+//   - "tenant" — пространство имён; токен без него отклоняется, если не
+//     задан AllowGlobalTenant, а с ним даёт доступ ко всем тенантам;
+//   - "scope" (строка через пробел) или "scopes" (массив) — области доступа;
+//   - "topics" — шаблоны топиков, в пределах которых разрешены подписка и публикация.
+type JWTVerifier struct {
+	Keys     *KeySet
+	Issuer   string        // ожидаемый "iss"; пусто — не проверяется
+	Audience string        // значение, которое должно быть в "aud"; пусто — не проверяется
+	Leeway   time.Duration // допуск расхождения часов для "exp" и "nbf"
+	// AllowGlobalTenant разрешает токены без "tenant" с доступом ко всем
+	// тенантам; без него такие токены отклоняются.
+	AllowGlobalTenant bool
+}
+
+// LooksLikeJWT отличает JWT (три части base64url через точку) от API-ключа.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+	Tenant    string          `json:"tenant"`
+	Scope     string          `json:"scope"`
+	Scopes    []string        `json:"scopes"`
+	Topics    []string        `json:"topics"`
+}
+
+// Verify проверяет подпись и срок действия токена и возвращает субъекта.
+// Ошибка оборачивает ErrInvalidToken.
+func (v *JWTVerifier) Verify(token string) (Principal, error) {
+	p, err := v.verify(token, time.Now())
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return p, nil
+}
+
+func (v *JWTVerifier) verify(token string, now time.Time) (Principal, error) {
+	payload, err := v.verifyPayload(token, now)
+	if err != nil {
+		return Principal{}, err
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Principal{}, fmt.Errorf("claims: %w", err)
+	}
+	if claims.Tenant == "" && !v.AllowGlobalTenant {
+		return Principal{}, errors.New("missing tenant")
+	}
+	return claims.principal(), nil
+}
+
+// verifyPayload проверяет подпись и стандартные claims токена и возвращает
+// его декодированное тело для разбора остальных claims.
+func (v *JWTVerifier) verifyPayload(token string, now time.Time) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	key, err := v.Keys.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, fmt.Errorf("algorithm %q does not match key", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	if err := verifySignature(header.Alg, key.key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("missing exp")
+	}
+	if now.After(numericDate(*claims.ExpiresAt).Add(v.Leeway)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore != nil && now.Add(v.Leeway).Before(numericDate(*claims.NotBefore)) {
+		return nil, errors.New("token not yet valid")
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if v.Audience != "" && !hasAudience(claims.Audience, v.Audience) {
+		return nil, errors.New("token not issued for this audience")
+	}
+	return payload, nil
+}
+
+// principal строит субъекта по claims; неизвестные области доступа пропускаются.
+func (c jwtClaims) principal() Principal {
+	names := c.Scopes
+	if c.Scope != "" {
+		names = append(names, strings.Fields(c.Scope)...)
+	}
+	var scopes []Scope
+	for _, name := range names {
+		if scope, err := ParseScope(name); err == nil && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return Principal{KeyID: "jwt:" + c.Subject, Tenant: c.Tenant, Scopes: scopes, Topics: c.Topics}
+}
+
+func decodeSegment(segment string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// numericDate переводит NumericDate JWT (секунды Unix, возможно дробные) во время.
+func numericDate(v float64) time.Time {
+	return time.Unix(0, int64(v*float64(time.Second)))
+}
+
+// hasAudience проверяет "aud" — строку или массив строк.
+func hasAudience(raw json.RawMessage, want string) bool {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return one == want
+	}
+	var many []string
+	return json.Unmarshal(raw, &many) == nil && slices.Contains(many, want)
+}
+
+// verifySignature проверяет подпись signed алгоритмом alg.
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %q does not match rsa key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, sig); err != nil {
+			return errors.New("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || hash.Size()*8 != ecHashBits(pub) {
+			return fmt.Errorf("algorithm %q does not match ec key", alg)
+		}
+		if len(sig) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.New("unsupported key type")
+	}
+	return nil
+}
+
+// ecHashBits возвращает размер хэша, который JWA сопоставляет кривой ключа:
+// P-256 — ES256, P-384 — ES384, P-521 — ES512.
+func ecHashBits(pub *ecdsa.PublicKey) int {
+	switch bits := pub.Curve.Params().BitSize; bits {
+	case 521:
+		return 512
+	default:
+		return bits
+	}
+}
@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCOptions задаёт проверку токенов операторов, выданных провайдером
+// OpenID Connect.
+type OIDCOptions struct {
+	Issuer       string // адрес провайдера; конфигурация берётся из /.well-known/openid-configuration
+	ClientID     string
+	ClientSecret string
+	Audience     string // ожидаемое значение "aud"; пусто — ClientID
+	// Introspect включает проверку токенов через introspection endpoint
+	// (RFC 7662) вместо локальной проверки подписи JWT; подходит для
+	// непрозрачных токенов и позволяет учитывать их отзыв.
+	Introspect bool
+	// RolesClaim — claim со списком ролей; вложенные claims задаются через
+	// точку, например "realm_access.roles". Пусто — "roles".
+	RolesClaim string
+	// AdminRoles — роли, дающие доступ к административному API;
+	// обязательны, чтобы доступ не получал любой токен провайдера.
+	AdminRoles []string
+	// CacheTTL — время кэширования результатов introspection; 0 — 1 минута.
+	CacheTTL time.Duration
+	// AllowGlobalTenant разрешает токены без claim "tenant" с доступом ко
+	// всем тенантам; без него такие токены отклоняются.
+	AllowGlobalTenant bool
+}
+
+// OIDC проверяет токены операторов: подпись по ключам провайдера либо
+// запросом к introspection endpoint. Субъект получает область admin, если
+// у него есть одна из ролей AdminRoles, и тенант из claim "tenant".
+// Результаты introspection кэшируются до CacheTTL, но не дольше срока токена.
+type OIDC struct {
+	opts          OIDCOptions
+	client        *http.Client
+	jwt           *JWTVerifier
+	introspection string
+
+	mu    sync.Mutex
+	cache map[string]cachedPrincipal
+}
+
+type cachedPrincipal struct {
+	principal Principal
+	expires   time.Time
+}
+
+// oidcDiscovery — нужные поля документа /.well-known/openid-configuration.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	JWKSURI               string `json:"jwks_uri"`
+	IntrospectionEndpoint string `json:"introspection_endpoint"`
+}
+
+// NewOIDC загружает конфигурацию провайдера и, в режиме проверки подписи,
+// его набор ключей.
+func NewOIDC(opts OIDCOptions) (*OIDC, error) {
+	if opts.Issuer == "" {
+		return nil, errors.New("oidc: issuer is required")
+	}
+	if len(opts.AdminRoles) == 0 {
+		return nil, errors.New("oidc: admin roles are required")
+	}
+	if opts.Audience == "" {
+		opts.Audience = opts.ClientID
+	}
+	if opts.RolesClaim == "" {
+		opts.RolesClaim = "roles"
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = time.Minute
+	}
+	o := &OIDC{opts: opts, client: &http.Client{Timeout: 10 * time.Second}, cache: make(map[string]cachedPrincipal)}
+
+	doc, err := o.discover()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Introspect {
+		if doc.IntrospectionEndpoint == "" {
+			return nil, errors.New("oidc: provider does not advertise introspection_endpoint")
+		}
+		if opts.ClientID == "" {
+			return nil, errors.New("oidc: client_id is required for introspection")
+		}
+		o.introspection = doc.IntrospectionEndpoint
+		return o, nil
+	}
+	if doc.JWKSURI == "" {
+		return nil, errors.New("oidc: provider does not advertise jwks_uri")
+	}
+	keys, err := NewKeySet(doc.JWKSURI, 0)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+	o.jwt = &JWTVerifier{Keys: keys, Issuer: doc.Issuer, Audience: opts.Audience, Leeway: 30 * time.Second}
+	return o, nil
+}
+
+func (o *OIDC) discover() (oidcDiscovery, error) {
+	endpoint := strings.TrimSuffix(o.opts.Issuer, "/") + "/.well-known/openid-configuration"
+	resp, err := o.client.Get(endpoint)
+	if err != nil {
+		return oidcDiscovery{}, fmt.Errorf("oidc discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return oidcDiscovery{}, fmt.Errorf("oidc discovery: %s returned %s", endpoint, resp.Status)
+	}
+	var doc oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return oidcDiscovery{}, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(o.opts.Issuer, "/") {
+		return oidcDiscovery{}, fmt.Errorf("oidc discovery: issuer mismatch: %q", doc.Issuer)
+	}
+	return doc, nil
+}
+
+// Verify проверяет токен оператора и возвращает субъекта. Недействительный
+// токен даёт ошибку, оборачивающую ErrInvalidToken; прочие ошибки — сбои
+// обращения к провайдеру.
+func (o *OIDC) Verify(ctx context.Context, token string) (Principal, error) {
+	if o.jwt != nil {
+		if !LooksLikeJWT(token) {
+			return Principal{}, fmt.Errorf("%w: not a jwt", ErrInvalidToken)
+		}
+		payload, err := o.jwt.verifyPayload(token, time.Now())
+		if err != nil {
+			return Principal{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+		}
+		var claims map[string]any
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			return Principal{}, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+		}
+		return o.principal(claims)
+	}
+
+	now := time.Now()
+	o.mu.Lock()
+	cached, ok := o.cache[token]
+	o.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.principal, nil
+	}
+	claims, err := o.introspect(ctx, token)
+	if err != nil {
+		return Principal{}, err
+	}
+	p, err := o.principal(claims)
+	if err != nil {
+		return Principal{}, err
+	}
+	expires := now.Add(o.opts.CacheTTL)
+	if exp, ok := claims["exp"].(float64); ok && numericDate(exp).Before(expires) {
+		expires = numericDate(exp)
+	}
+	o.mu.Lock()
+	for t, c := range o.cache {
+		if now.After(c.expires) {
+			delete(o.cache, t)
+		}
+	}
+	o.cache[token] = cachedPrincipal{principal: p, expires: expires}
+	o.mu.Unlock()
+	return p, nil
+}
+
+// introspect запрашивает состояние токена у провайдера (RFC 7662).
+func (o *OIDC) introspect(ctx context.Context, token string) (map[string]any, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.introspection, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.opts.ClientID), url.QueryEscape(o.opts.ClientSecret))
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc introspection: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc introspection: %s", resp.Status)
+	}
+	var claims map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("oidc introspection: %w", err)
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, fmt.Errorf("%w: token is not active", ErrInvalidToken)
+	}
+	if o.opts.Audience != "" {
+		raw, _ := json.Marshal(claims["aud"])
+		if !hasAudience(raw, o.opts.Audience) {
+			return nil, fmt.Errorf("%w: token not issued for this audience", ErrInvalidToken)
+		}
+	}
+	return claims, nil
+}
+
+// principal строит субъекта по claims токена оператора; без нужной роли
+// субъект аутентифицирован, но не получает области admin. Токен без
+// тенанта отклоняется, если не задан AllowGlobalTenant.
+func (o *OIDC) principal(claims map[string]any) (Principal, error) {
+	tenant, _ := claims["tenant"].(string)
+	if tenant == "" && !o.opts.AllowGlobalTenant {
+		return Principal{}, fmt.Errorf("%w: missing tenant", ErrInvalidToken)
+	}
+	roles := stringList(claimPath(claims, o.opts.RolesClaim))
+	admin := false
+	for _, role := range o.opts.AdminRoles {
+		if slices.Contains(roles, role) {
+			admin = true
+			break
+		}
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		subject, _ = claims["username"].(string)
+	}
+	p := Principal{KeyID: "oidc:" + subject, Tenant: tenant}
+	if admin {
+		p.Scopes = []Scope{ScopeAdmin}
+	}
+	return p, nil
+}
+
+// claimPath возвращает значение claim по пути через точку.
+func claimPath(claims map[string]any, path string) any {
+	var v any = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+	return v
+}
+
+// stringList приводит claim-массив или строку через пробел к списку строк.
+func stringList(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
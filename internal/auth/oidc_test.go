@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// introspectionProvider — провайдер OIDC, отвечающий на introspection
+// claims из tokens по значению токена.
+func introspectionProvider(t *testing.T, tokens map[string]map[string]any) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{Issuer: srv.URL, IntrospectionEndpoint: srv.URL + "/introspect"})
+	})
+	mux.HandleFunc("/introspect", func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := tokens[r.FormValue("token")]
+		if !ok {
+			claims = map[string]any{"active": false}
+		}
+		json.NewEncoder(w).Encode(claims)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOIDCIntrospection(t *testing.T) {
+	srv := introspectionProvider(t, map[string]map[string]any{
+		"admin":    {"active": true, "sub": "a", "aud": "eventsync", "tenant": "t1", "roles": []any{"ops"}},
+		"operator": {"active": true, "sub": "b", "aud": "eventsync", "tenant": "t1"},
+		"no-aud":   {"active": true, "sub": "c", "tenant": "t1", "roles": []any{"ops"}},
+		"global":   {"active": true, "sub": "d", "aud": "eventsync", "roles": []any{"ops"}},
+	})
+	opts := OIDCOptions{Issuer: srv.URL, ClientID: "eventsync", Introspect: true, AdminRoles: []string{"ops"}}
+	if _, err := NewOIDC(OIDCOptions{Issuer: srv.URL, ClientID: "eventsync", Introspect: true}); err == nil {
+		t.Fatal("NewOIDC without admin roles succeeded")
+	}
+	o, err := NewOIDC(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	p, err := o.Verify(ctx, "admin")
+	if err != nil || p.Tenant != "t1" || !p.Has(ScopeAdmin) {
+		t.Fatalf("admin = %+v, %v", p, err)
+	}
+	if p, err := o.Verify(ctx, "operator"); err != nil || p.Has(ScopeAdmin) {
+		t.Fatalf("operator without role = %+v, %v", p, err)
+	}
+	for _, token := range []string{"no-aud", "global", "unknown"} {
+		if _, err := o.Verify(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Verify(%q) = %v, want invalid token", token, err)
+		}
+	}
+
+	opts.AllowGlobalTenant = true
+	if o, err = NewOIDC(opts); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := o.Verify(ctx, "global"); err != nil || p.Tenant != "" || !p.Has(ScopeAdmin) {
+		t.Fatalf("global with AllowGlobalTenant = %+v, %v", p, err)
+	}
+}
@@ -0,0 +1,291 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// MediaType — Content-Type тела HTTP с событием в single object encoding.
+const MediaType = "avro/binary"
+
+// singleObjectMagic — маркер single object encoding; за ним следует
+// отпечаток схемы (8 байт, little-endian) и данные.
+var singleObjectMagic = [2]byte{0xc3, 0x01}
+
+// headerSize — размер заголовка single object encoding.
+const headerSize = len(singleObjectMagic) + 8
+
+var (
+	// ErrTruncated возвращается для данных, оборвавшихся посреди значения.
+	ErrTruncated = errors.New("avro: truncated data")
+	// ErrNotSingleObject возвращается для данных без маркера single object encoding.
+	ErrNotSingleObject = errors.New("avro: not a single object encoding")
+)
+
+// UnknownSchemaError возвращается для данных с отпечатком неизвестной схемы.
+type UnknownSchemaError struct {
+	Fingerprint uint64
+}
+
+func (e *UnknownSchemaError) Error() string {
+	return fmt.Sprintf("avro: unknown schema fingerprint %016x", e.Fingerprint)
+}
+
+// AppendEvent дописывает к dst событие в двоичной кодировке EventSchema,
+// без заголовка.
+func AppendEvent(dst []byte, e domain.Event) []byte {
+	dst = appendString(dst, e.ID)
+	dst = binary.AppendVarint(dst, int64(e.Seq))
+	dst = appendString(dst, e.Type)
+	dst = binary.AppendVarint(dst, int64(e.Version))
+	dst = binary.AppendVarint(dst, int64(e.Priority))
+	dst = appendString(dst, e.Namespace)
+	dst = appendString(dst, e.Topic)
+	dst = appendString(dst, e.PartitionKey)
+	dst = appendString(dst, e.CorrelationID)
+	dst = appendString(dst, e.CausationID)
+	if e.Causality == nil {
+		dst = binary.AppendVarint(dst, 0)
+	} else {
+		dst = binary.AppendVarint(dst, 1)
+		dst = appendString(dst, e.Causality.Node)
+		if len(e.Causality.Clock) > 0 {
+			dst = binary.AppendVarint(dst, int64(len(e.Causality.Clock)))
+			for node, n := range e.Causality.Clock {
+				dst = appendString(dst, node)
+				dst = binary.AppendVarint(dst, int64(n))
+			}
+		}
+		dst = binary.AppendVarint(dst, 0)
+	}
+	if len(e.Metadata) > 0 {
+		dst = binary.AppendVarint(dst, int64(len(e.Metadata)))
+		for k, v := range e.Metadata {
+			dst = appendString(dst, k)
+			dst = appendString(dst, v)
+		}
+	}
+	dst = binary.AppendVarint(dst, 0)
+	dst = appendString(dst, e.Source)
+	dst = appendString(dst, e.Message)
+	if e.Data == nil {
+		dst = binary.AppendVarint(dst, 0)
+	} else {
+		dst = binary.AppendVarint(dst, 1)
+		dst = appendString(dst, string(e.Data))
+	}
+	dst = binary.AppendVarint(dst, e.Timestamp.UnixMicro())
+	if e.DeliverAt == nil {
+		dst = binary.AppendVarint(dst, 0)
+	} else {
+		dst = binary.AppendVarint(dst, 1)
+		dst = binary.AppendVarint(dst, e.DeliverAt.UnixMicro())
+	}
+	return dst
+}
+
+// MarshalEvent кодирует событие в single object encoding с отпечатком
+// EventSchema — например, для сообщения Kafka.
+func MarshalEvent(e domain.Event) []byte {
+	return AppendEvent(appendHeader(nil, EventFingerprint), e)
+}
+
+// AppendBatch дописывает к dst пачку событий, уже закодированных
+// AppendEvent, в single object encoding с отпечатком BatchSchema.
+func AppendBatch(dst []byte, events [][]byte) []byte {
+	dst = appendHeader(dst, BatchFingerprint)
+	if len(events) > 0 {
+		size := 0
+		for _, e := range events {
+			size += len(e)
+		}
+		// Отрицательный счётчик блока сопровождается его размером в байтах,
+		// чтобы читатель мог пропустить блок, не разбирая записи.
+		dst = binary.AppendVarint(dst, -int64(len(events)))
+		dst = binary.AppendVarint(dst, int64(size))
+		for _, e := range events {
+			dst = append(dst, e...)
+		}
+	}
+	return binary.AppendVarint(dst, 0)
+}
+
+// MarshalBatch кодирует пачку событий в single object encoding с
+// отпечатком BatchSchema.
+func MarshalBatch(events []domain.Event) []byte {
+	bodies := make([][]byte, 0, len(events))
+	for _, e := range events {
+		bodies = append(bodies, AppendEvent(nil, e))
+	}
+	return AppendBatch(nil, bodies)
+}
+
+// Unmarshal разбирает single object encoding события или пачки событий,
+// выбирая схему по отпечатку.
+func Unmarshal(data []byte) ([]domain.Event, error) {
+	if len(data) < headerSize || data[0] != singleObjectMagic[0] || data[1] != singleObjectMagic[1] {
+		return nil, ErrNotSingleObject
+	}
+	r := reader{data: data[headerSize:]}
+	var events []domain.Event
+	switch fp := binary.LittleEndian.Uint64(data[2:headerSize]); fp {
+	case EventFingerprint:
+		events = []domain.Event{r.event()}
+	case BatchFingerprint:
+		for {
+			n := r.long()
+			if n == 0 || r.err != nil {
+				break
+			}
+			if n < 0 {
+				n = -n
+				r.long() // размер блока
+			}
+			for ; n > 0 && r.err == nil; n-- {
+				events = append(events, r.event())
+			}
+		}
+	default:
+		return nil, &UnknownSchemaError{Fingerprint: fp}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.data) > 0 {
+		return nil, errors.New("avro: trailing data")
+	}
+	return events, nil
+}
+
+// UnmarshalEvent разбирает одно событие в single object encoding.
+func UnmarshalEvent(data []byte) (domain.Event, error) {
+	events, err := Unmarshal(data)
+	if err != nil {
+		return domain.Event{}, err
+	}
+	if len(events) != 1 {
+		return domain.Event{}, fmt.Errorf("avro: want one event, got %d", len(events))
+	}
+	return events[0], nil
+}
+
+func appendHeader(dst []byte, fingerprint uint64) []byte {
+	dst = append(dst, singleObjectMagic[:]...)
+	return binary.LittleEndian.AppendUint64(dst, fingerprint)
+}
+
+func appendString(dst []byte, s string) []byte {
+	dst = binary.AppendVarint(dst, int64(len(s)))
+	return append(dst, s...)
+}
+
+// reader разбирает двоичную кодировку; первая ошибка запоминается, и
+// дальнейшие чтения возвращают нулевые значения.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) long() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = ErrTruncated
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) bytes() []byte {
+	n := r.long()
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > int64(len(r.data)) {
+		r.err = ErrTruncated
+		return nil
+	}
+	b := r.data[:n:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+// union читает индекс ветви объединения ["null", T] и сообщает, задано ли значение.
+func (r *reader) union() bool {
+	switch r.long() {
+	case 0:
+		return false
+	case 1:
+		return true
+	default:
+		if r.err == nil {
+			r.err = errors.New("avro: invalid union branch")
+		}
+		return false
+	}
+}
+
+// mapBlocks вызывает entry для каждой пары отображения.
+func (r *reader) mapBlocks(entry func(key string)) {
+	for r.err == nil {
+		n := r.long()
+		if n == 0 {
+			return
+		}
+		if n < 0 {
+			n = -n
+			r.long() // размер блока
+		}
+		for ; n > 0 && r.err == nil; n-- {
+			entry(r.string())
+		}
+	}
+}
+
+func (r *reader) event() domain.Event {
+	var e domain.Event
+	e.ID = r.string()
+	e.Seq = uint64(r.long())
+	e.Type = r.string()
+	e.Version = int(r.long())
+	e.Priority = int(r.long())
+	e.Namespace = r.string()
+	e.Topic = r.string()
+	e.PartitionKey = r.string()
+	e.CorrelationID = r.string()
+	e.CausationID = r.string()
+	if r.union() {
+		c := &domain.Causality{Node: r.string(), Clock: domain.VectorClock{}}
+		r.mapBlocks(func(node string) { c.Clock[node] = uint64(r.long()) })
+		e.Causality = c
+	}
+	r.mapBlocks(func(key string) {
+		if e.Metadata == nil {
+			e.Metadata = make(map[string]string)
+		}
+		e.Metadata[key] = r.string()
+	})
+	e.Source = r.string()
+	e.Message = r.string()
+	if r.union() {
+		e.Data = bytes.Clone(r.bytes())
+	}
+	e.Timestamp = time.UnixMicro(r.long()).UTC()
+	if r.union() {
+		at := time.UnixMicro(r.long()).UTC()
+		e.DeliverAt = &at
+	}
+	return e
+}
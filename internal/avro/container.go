@@ -0,0 +1,163 @@
+package avro
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// containerMagic открывает контейнерный файл Avro.
+var containerMagic = []byte{'O', 'b', 'j', 1}
+
+// fingerprintKey — ключ метаданных контейнера с отпечатком схемы событий
+// в шестнадцатеричном виде.
+const fingerprintKey = "eventsync.fingerprint"
+
+// ContainerWriter пишет события в контейнерный файл Avro (object container
+// file), который читают Hadoop, Spark и другие инструменты: схема в
+// заголовке, каждая пачка — отдельный блок без сжатия.
+type ContainerWriter struct {
+	w    io.Writer
+	sync [16]byte
+}
+
+// NewContainer начинает контейнерный файл: пишет в w заголовок со схемой
+// EventSchema и новым маркером синхронизации.
+func NewContainer(w io.Writer) (*ContainerWriter, error) {
+	c := &ContainerWriter{w: w}
+	if _, err := rand.Read(c.sync[:]); err != nil {
+		return nil, err
+	}
+	header := append([]byte(nil), containerMagic...)
+	meta := map[string]string{
+		"avro.schema":  EventSchema,
+		"avro.codec":   "null",
+		fingerprintKey: fmt.Sprintf("%016x", EventFingerprint),
+	}
+	header = binary.AppendVarint(header, int64(len(meta)))
+	for _, k := range []string{"avro.schema", "avro.codec", fingerprintKey} {
+		header = appendString(header, k)
+		header = appendString(header, meta[k])
+	}
+	header = binary.AppendVarint(header, 0)
+	header = append(header, c.sync[:]...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ResumeContainer продолжает существующий контейнерный файл: читает из r
+// его заголовок и проверяет, что файл записан с EventSchema. Блоки пишутся
+// в w, обычно открытый на дописывание тот же файл.
+func ResumeContainer(r io.Reader, w io.Writer) (*ContainerWriter, error) {
+	head := make([]byte, len(containerMagic))
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(head, containerMagic) {
+		return nil, errors.New("avro: not an object container file")
+	}
+	meta, err := readMeta(r)
+	if err != nil {
+		return nil, err
+	}
+	canonical, err := Canonical(meta["avro.schema"])
+	if err != nil {
+		return nil, fmt.Errorf("avro: container schema: %w", err)
+	}
+	if fp := Fingerprint([]byte(canonical)); fp != EventFingerprint {
+		return nil, &UnknownSchemaError{Fingerprint: fp}
+	}
+	if codec := meta["avro.codec"]; codec != "" && codec != "null" {
+		return nil, fmt.Errorf("avro: unsupported container codec %q", codec)
+	}
+	c := &ContainerWriter{w: w}
+	if _, err := io.ReadFull(r, c.sync[:]); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readMeta читает отображение метаданных заголовка контейнера.
+func readMeta(r io.Reader) (map[string]string, error) {
+	br := byteReader{r}
+	meta := make(map[string]string)
+	for {
+		n, err := binary.ReadVarint(br)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return meta, nil
+		}
+		if n < 0 {
+			n = -n
+			if _, err := binary.ReadVarint(br); err != nil {
+				return nil, err
+			}
+		}
+		for ; n > 0; n-- {
+			k, err := readString(br)
+			if err != nil {
+				return nil, err
+			}
+			v, err := readString(br)
+			if err != nil {
+				return nil, err
+			}
+			meta[k] = v
+		}
+	}
+}
+
+// maxMetaValue ограничивает значение метаданных заголовка при чтении.
+const maxMetaValue = 1 << 20
+
+func readString(br byteReader) (string, error) {
+	n, err := binary.ReadVarint(br)
+	if err != nil {
+		return "", err
+	}
+	if n < 0 || n > maxMetaValue {
+		return "", errors.New("avro: invalid container metadata")
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(br.r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// byteReader читает по байту без буферизации, чтобы не забрать данные
+// после заголовка.
+type byteReader struct{ r io.Reader }
+
+func (b byteReader) ReadByte() (byte, error) {
+	var buf [1]byte
+	_, err := io.ReadFull(b.r, buf[:])
+	return buf[0], err
+}
+
+// WriteBlock дописывает пачку событий одним блоком и одной записью, поэтому
+// блок не разрывается при ошибке посреди пачки.
+func (c *ContainerWriter) WriteBlock(events []domain.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	var data []byte
+	for _, e := range events {
+		data = AppendEvent(data, e)
+	}
+	block := binary.AppendVarint(nil, int64(len(events)))
+	block = binary.AppendVarint(block, int64(len(data)))
+	block = append(block, data...)
+	block = append(block, c.sync[:]...)
+	_, err := c.w.Write(block)
+	return err
+}
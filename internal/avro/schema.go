@@ -0,0 +1,210 @@
+// Package avro кодирует события в Avro для выгрузки в экосистемы Kafka и
+// Hadoop: двоичное представление записи, single object encoding с отпечатком
+// схемы (событие или пачка событий) и контейнерные файлы Avro.
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// EventSchema — схема записи события; поля повторяют domain.Event. Поля
+// добавляются только в конец и с default, чтобы старые читатели разбирали
+// новые данные по правилам разрешения схем.
+const EventSchema = `{
+  "type": "record",
+  "name": "Event",
+  "namespace": "eventsync.v1",
+  "doc": "eventsync event",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "seq", "type": "long"},
+    {"name": "type", "type": "string"},
+    {"name": "version", "type": "int"},
+    {"name": "priority", "type": "int"},
+    {"name": "namespace", "type": "string"},
+    {"name": "topic", "type": "string"},
+    {"name": "partition_key", "type": "string"},
+    {"name": "correlation_id", "type": "string"},
+    {"name": "causation_id", "type": "string"},
+    {"name": "causality", "default": null, "type": ["null", {
+      "type": "record",
+      "name": "Causality",
+      "fields": [
+        {"name": "node", "type": "string"},
+        {"name": "clock", "type": {"type": "map", "values": "long"}}
+      ]
+    }]},
+    {"name": "metadata", "type": {"type": "map", "values": "string"}},
+    {"name": "source", "type": "string"},
+    {"name": "message", "type": "string"},
+    {"name": "data", "doc": "JSON payload", "default": null, "type": ["null", "string"]},
+    {"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+    {"name": "deliver_at", "default": null, "type": ["null", {"type": "long", "logicalType": "timestamp-micros"}]}
+  ]
+}`
+
+// BatchSchema — схема пачки событий: массив записей EventSchema.
+const BatchSchema = `{"type": "array", "items": ` + EventSchema + `}`
+
+// Отпечатки канонических форм схем (CRC-64-AVRO), которыми помечаются
+// данные в single object encoding.
+var (
+	EventFingerprint = mustFingerprint(EventSchema)
+	BatchFingerprint = mustFingerprint(BatchSchema)
+)
+
+func mustFingerprint(schema string) uint64 {
+	canonical, err := Canonical(schema)
+	if err != nil {
+		panic("avro: " + err.Error())
+	}
+	return Fingerprint([]byte(canonical))
+}
+
+// fingerprintEmpty — начальное значение CRC-64-AVRO из спецификации.
+const fingerprintEmpty = 0xc15d213aa4d7a795
+
+var fingerprintTable = func() (t [256]uint64) {
+	for i := range t {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (fingerprintEmpty & -(fp & 1))
+		}
+		t[i] = fp
+	}
+	return t
+}()
+
+// Fingerprint возвращает 64-битный отпечаток Rabin (CRC-64-AVRO)
+// канонической формы схемы.
+func Fingerprint(canonical []byte) uint64 {
+	fp := uint64(fingerprintEmpty)
+	for _, b := range canonical {
+		fp = (fp >> 8) ^ fingerprintTable[byte(fp)^b]
+	}
+	return fp
+}
+
+// Canonical приводит схему к Parsing Canonical Form: полные имена вместо
+// namespace, только влияющие на разбор атрибуты в порядке спецификации,
+// без пробелов.
+func Canonical(schema string) (string, error) {
+	var v any
+	if err := json.Unmarshal([]byte(schema), &v); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := canonical(&b, v, ""); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// canonical дописывает каноническую форму схемы v; namespace — объемлющее
+// пространство имён для неполных имён.
+func canonical(b *strings.Builder, v any, namespace string) error {
+	switch s := v.(type) {
+	case string:
+		writeString(b, fullName(s, namespace))
+		return nil
+	case []any:
+		b.WriteByte('[')
+		for i, branch := range s {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := canonical(b, branch, namespace); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+		return nil
+	case map[string]any:
+		typ, _ := s["type"].(string)
+		switch typ {
+		case "record", "error", "enum", "fixed":
+		case "array":
+			b.WriteString(`{"type":"array","items":`)
+			if err := canonical(b, s["items"], namespace); err != nil {
+				return err
+			}
+			b.WriteByte('}')
+			return nil
+		case "map":
+			b.WriteString(`{"type":"map","values":`)
+			if err := canonical(b, s["values"], namespace); err != nil {
+				return err
+			}
+			b.WriteByte('}')
+			return nil
+		case "":
+			return fmt.Errorf("schema without type: %v", s)
+		default:
+			// Примитив в виде объекта, например с logicalType.
+			return canonical(b, typ, namespace)
+		}
+		name, _ := s["name"].(string)
+		if ns, ok := s["namespace"].(string); ok && !strings.Contains(name, ".") {
+			namespace = ns
+		}
+		name = fullName(name, namespace)
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			namespace = name[:i]
+		}
+		b.WriteString(`{"name":`)
+		writeString(b, name)
+		b.WriteString(`,"type":`)
+		writeString(b, typ)
+		switch typ {
+		case "record", "error":
+			fields, _ := s["fields"].([]any)
+			b.WriteString(`,"fields":[`)
+			for i, f := range fields {
+				field, _ := f.(map[string]any)
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteString(`{"name":`)
+				writeString(b, fmt.Sprint(field["name"]))
+				b.WriteString(`,"type":`)
+				if err := canonical(b, field["type"], namespace); err != nil {
+					return err
+				}
+				b.WriteByte('}')
+			}
+			b.WriteByte(']')
+		case "enum":
+			symbols, _ := json.Marshal(s["symbols"])
+			b.WriteString(`,"symbols":`)
+			b.Write(symbols)
+		case "fixed":
+			fmt.Fprintf(b, `,"size":%v`, s["size"])
+		}
+		b.WriteByte('}')
+		return nil
+	default:
+		return fmt.Errorf("invalid schema %v", v)
+	}
+}
+
+// primitives — имена примитивных типов, не зависящие от пространства имён.
+var primitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// fullName дополняет неполное имя типа пространством имён.
+func fullName(name, namespace string) string {
+	if primitives[name] || strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// writeString дописывает строку JSON.
+func writeString(b *strings.Builder, s string) {
+	data, _ := json.Marshal(s)
+	b.Write(data)
+}
@@ -0,0 +1,29 @@
+// Package bufpool переиспользует буферы кодирования и чтения кадров на
+// горячих путях рассылки и приёма событий.
+package bufpool
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxRetained — буферы больше этого размера не возвращаются в пул, чтобы
+// единичный крупный кадр не удерживал память навсегда.
+const maxRetained = 1 << 20
+
+var pool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// Get возвращает пустой буфер из пула.
+func Get() *bytes.Buffer {
+	return pool.Get().(*bytes.Buffer)
+}
+
+// Put возвращает буфер в пул. После Put буфер и полученные из него срезы
+// использовать нельзя.
+func Put(buf *bytes.Buffer) {
+	if buf.Cap() > maxRetained {
+		return
+	}
+	buf.Reset()
+	pool.Put(buf)
+}
@@ -0,0 +1,106 @@
+// Package checkpoint периодически сохраняет снимок состояния сервера в файл
+// JSON, чтобы после перезапуска состояние восстанавливалось из снимка и
+// короткого хвоста журнала, а не из всего журнала событий.
+package checkpoint
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultInterval — период сохранения снимка по умолчанию.
+const DefaultInterval = time.Minute
+
+// Save атомарно записывает v в файл path: сначала во временный файл рядом,
+// затем переименованием, поэтому прерванная запись не портит прежний снимок.
+func Save(path string, v any) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := json.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load читает снимок из файла path в v. Если файла нет, возвращается
+// ошибка, для которой errors.Is(err, os.ErrNotExist).
+func Load(path string, v any) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// Writer сохраняет снимок, возвращаемый take, каждые interval и при Close.
+type Writer struct {
+	path   string
+	take   func() any
+	logger *slog.Logger
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Start запускает периодическое сохранение; interval <= 0 — DefaultInterval.
+func Start(path string, interval time.Duration, take func() any, logger *slog.Logger) *Writer {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	w := &Writer{path: path, take: take, logger: logger, stop: make(chan struct{}), done: make(chan struct{})}
+	go w.run(interval)
+	return w
+}
+
+func (w *Writer) run(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.save()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *Writer) save() {
+	start := time.Now()
+	if err := Save(w.path, w.take()); err != nil {
+		w.logger.Error("State snapshot write error", "path", w.path, "error", err)
+		return
+	}
+	w.logger.Debug("State snapshot written", "path", w.path, "duration", time.Since(start))
+}
+
+// Flush сохраняет снимок немедленно, не дожидаясь периода.
+func (w *Writer) Flush() {
+	w.save()
+}
+
+// Close останавливает сохранение и записывает последний снимок.
+// Вызывается, когда рассылка уже остановлена.
+func (w *Writer) Close() {
+	w.once.Do(func() {
+		close(w.stop)
+		<-w.done
+		w.save()
+	})
+}
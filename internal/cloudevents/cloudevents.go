@@ -0,0 +1,404 @@
+// Package cloudevents переводит события eventsync в формат CloudEvents 1.0
+// и обратно: структурированный режим (JSON-объект с атрибутами и данными) и
+// двоичный режим HTTP (атрибуты в заголовках ce-*, данные в теле).
+//
+// Поля domain.Event отображаются на атрибуты так: ID — id, Source —
+// source, Type — type, Topic — subject, Timestamp — time, Data — data.
+// Остальные поля передаются расширениями: message, sequence (Seq),
+// namespace, partitionkey, correlationid, causationid, priority,
+// schemaversion (Version), deliverat и causality (JSON). Метаданные с
+// допустимыми для атрибута именами становятся расширениями, остальные
+// передаются JSON-объектом в расширении metadata; неизвестные расширения
+// входящих событий попадают в метаданные.
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// SpecVersion — поддерживаемая версия спецификации.
+const SpecVersion = "1.0"
+
+// Типы содержимого структурированного режима.
+const (
+	MediaType      = "application/cloudevents+json"
+	BatchMediaType = "application/cloudevents-batch+json"
+)
+
+// DefaultSource — атрибут source события без Source: атрибут обязателен.
+const DefaultSource = "eventsync"
+
+// headerPrefix — префикс заголовков атрибутов в двоичном режиме HTTP.
+const headerPrefix = "Ce-"
+
+// ErrInvalid возвращается для события, нарушающего спецификацию.
+var ErrInvalid = errors.New("invalid cloudevent")
+
+// Атрибуты контекста и расширения, на которые отображаются поля события.
+const (
+	attrSpecVersion     = "specversion"
+	attrID              = "id"
+	attrSource          = "source"
+	attrType            = "type"
+	attrSubject         = "subject"
+	attrTime            = "time"
+	attrDataContentType = "datacontenttype"
+	attrMessage         = "message"
+	attrSequence        = "sequence"
+	attrNamespace       = "namespace"
+	attrPartitionKey    = "partitionkey"
+	attrCorrelationID   = "correlationid"
+	attrCausationID     = "causationid"
+	attrPriority        = "priority"
+	attrSchemaVersion   = "schemaversion"
+	attrDeliverAt       = "deliverat"
+	attrCausality       = "causality"
+	attrMetadata        = "metadata"
+)
+
+// reserved — имена, которые не могут прийти из метаданных.
+var reserved = map[string]bool{
+	attrSpecVersion: true, attrID: true, attrSource: true, attrType: true, attrSubject: true,
+	attrTime: true, attrDataContentType: true, "dataschema": true, "data": true, "data_base64": true,
+	attrMessage: true, attrSequence: true, attrNamespace: true, attrPartitionKey: true,
+	attrCorrelationID: true, attrCausationID: true, attrPriority: true, attrSchemaVersion: true,
+	attrDeliverAt: true, attrCausality: true, attrMetadata: true,
+}
+
+// nameRe — допустимое имя атрибута: строчная латиница и цифры.
+var nameRe = regexp.MustCompile(`^[a-z0-9]{1,20}$`)
+
+// Event — событие CloudEvents: атрибуты в строковом виде и данные JSON.
+type Event struct {
+	Attributes map[string]string
+	Data       json.RawMessage
+}
+
+// FromDomain переводит событие eventsync в CloudEvents.
+func FromDomain(e domain.Event) (Event, error) {
+	attrs := map[string]string{
+		attrSpecVersion: SpecVersion,
+		attrID:          e.ID,
+		attrSource:      e.Source,
+		attrType:        e.Type,
+	}
+	if e.Source == "" {
+		attrs[attrSource] = DefaultSource
+	}
+	set := func(name, value string) {
+		if value != "" {
+			attrs[name] = value
+		}
+	}
+	set(attrSubject, e.Topic)
+	if !e.Timestamp.IsZero() {
+		attrs[attrTime] = e.Timestamp.Format(time.RFC3339Nano)
+	}
+	set(attrMessage, e.Message)
+	set(attrNamespace, e.Namespace)
+	set(attrPartitionKey, e.PartitionKey)
+	set(attrCorrelationID, e.CorrelationID)
+	set(attrCausationID, e.CausationID)
+	if e.Seq != 0 {
+		attrs[attrSequence] = strconv.FormatUint(e.Seq, 10)
+	}
+	if e.Priority != 0 {
+		attrs[attrPriority] = strconv.Itoa(e.Priority)
+	}
+	if e.Version != 0 {
+		attrs[attrSchemaVersion] = strconv.Itoa(e.Version)
+	}
+	if e.DeliverAt != nil {
+		attrs[attrDeliverAt] = e.DeliverAt.Format(time.RFC3339Nano)
+	}
+	if e.Causality != nil {
+		raw, err := json.Marshal(e.Causality)
+		if err != nil {
+			return Event{}, err
+		}
+		attrs[attrCausality] = string(raw)
+	}
+	var rest map[string]string
+	for k, v := range e.Metadata {
+		if nameRe.MatchString(k) && !reserved[k] {
+			attrs[k] = v
+			continue
+		}
+		if rest == nil {
+			rest = make(map[string]string)
+		}
+		rest[k] = v
+	}
+	if rest != nil {
+		raw, err := json.Marshal(rest)
+		if err != nil {
+			return Event{}, err
+		}
+		attrs[attrMetadata] = string(raw)
+	}
+	ce := Event{Attributes: attrs}
+	if len(e.Data) > 0 {
+		attrs[attrDataContentType] = "application/json"
+		ce.Data = e.Data
+	}
+	return ce, nil
+}
+
+// Domain переводит событие CloudEvents в событие eventsync.
+func (c Event) Domain() (domain.Event, error) {
+	if err := c.Validate(); err != nil {
+		return domain.Event{}, err
+	}
+	var e domain.Event
+	var err error
+	for name, value := range c.Attributes {
+		switch name {
+		case attrSpecVersion, attrDataContentType, "dataschema":
+		case attrID:
+			e.ID = value
+		case attrSource:
+			if value != DefaultSource {
+				e.Source = value
+			}
+		case attrType:
+			e.Type = value
+		case attrSubject:
+			e.Topic = value
+		case attrTime:
+			e.Timestamp, err = time.Parse(time.RFC3339Nano, value)
+		case attrMessage:
+			e.Message = value
+		case attrNamespace:
+			e.Namespace = value
+		case attrPartitionKey:
+			e.PartitionKey = value
+		case attrCorrelationID:
+			e.CorrelationID = value
+		case attrCausationID:
+			e.CausationID = value
+		case attrSequence:
+			e.Seq, err = strconv.ParseUint(value, 10, 64)
+		case attrPriority:
+			e.Priority, err = strconv.Atoi(value)
+		case attrSchemaVersion:
+			e.Version, err = strconv.Atoi(value)
+		case attrDeliverAt:
+			var at time.Time
+			at, err = time.Parse(time.RFC3339Nano, value)
+			e.DeliverAt = &at
+		case attrCausality:
+			e.Causality = new(domain.Causality)
+			err = json.Unmarshal([]byte(value), e.Causality)
+		case attrMetadata:
+			var rest map[string]string
+			if err = json.Unmarshal([]byte(value), &rest); err == nil {
+				for k, v := range rest {
+					setMetadata(&e, k, v)
+				}
+			}
+		default:
+			setMetadata(&e, name, value)
+		}
+		if err != nil {
+			return domain.Event{}, fmt.Errorf("%w: attribute %s: %w", ErrInvalid, name, err)
+		}
+	}
+	if len(c.Data) > 0 {
+		e.Data = c.Data
+	}
+	return e, nil
+}
+
+func setMetadata(e *domain.Event, key, value string) {
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
+	}
+	e.Metadata[key] = value
+}
+
+// Validate проверяет обязательные атрибуты и имена расширений.
+func (c Event) Validate() error {
+	if v := c.Attributes[attrSpecVersion]; v != SpecVersion {
+		return fmt.Errorf("%w: unsupported specversion %q", ErrInvalid, v)
+	}
+	for _, name := range []string{attrID, attrSource, attrType} {
+		if c.Attributes[name] == "" {
+			return fmt.Errorf("%w: %s is required", ErrInvalid, name)
+		}
+	}
+	for name := range c.Attributes {
+		if !nameRe.MatchString(name) {
+			return fmt.Errorf("%w: invalid attribute name %q", ErrInvalid, name)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON возвращает структурированное представление события.
+func (c Event) MarshalJSON() ([]byte, error) {
+	obj := make(map[string]any, len(c.Attributes)+1)
+	for k, v := range c.Attributes {
+		obj[k] = v
+	}
+	if len(c.Data) > 0 {
+		obj["data"] = c.Data
+	}
+	return json.Marshal(obj)
+}
+
+// UnmarshalJSON разбирает структурированное представление. Значения
+// атрибутов-чисел и логических значений сохраняются в текстовом виде,
+// data_base64 декодируется в данные.
+func (c *Event) UnmarshalJSON(data []byte) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	c.Attributes = make(map[string]string, len(obj))
+	c.Data = nil
+	for name, raw := range obj {
+		switch name {
+		case "data":
+			if !isNull(raw) {
+				c.Data = raw
+			}
+			continue
+		case "data_base64":
+			var encoded string
+			if err := json.Unmarshal(raw, &encoded); err != nil {
+				return fmt.Errorf("%w: data_base64: %w", ErrInvalid, err)
+			}
+			body, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return fmt.Errorf("%w: data_base64: %w", ErrInvalid, err)
+			}
+			c.Data = jsonData(body)
+			continue
+		}
+		if isNull(raw) {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			// Числа и логические значения.
+			s = string(raw)
+		}
+		c.Attributes[name] = s
+	}
+	return nil
+}
+
+func isNull(raw json.RawMessage) bool {
+	return strings.TrimSpace(string(raw)) == "null"
+}
+
+// jsonData возвращает тело как данные JSON; тело, не являющееся JSON,
+// передаётся строкой.
+func jsonData(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	raw, _ := json.Marshal(string(body))
+	return raw
+}
+
+// IsStructured сообщает, что запрос или ответ с заголовком Content-Type
+// содержит событие в структурированном режиме.
+func IsStructured(h http.Header) bool {
+	media, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return media == MediaType
+}
+
+// IsBinary сообщает, что заголовки несут событие в двоичном режиме.
+func IsBinary(h http.Header) bool {
+	return h.Get(headerPrefix+attrSpecVersion) != ""
+}
+
+// ReadBinary собирает событие двоичного режима из заголовков и тела.
+func ReadBinary(h http.Header, body []byte) (Event, error) {
+	c := Event{Attributes: make(map[string]string)}
+	for key, values := range h {
+		if len(values) == 0 || !strings.HasPrefix(key, headerPrefix) {
+			continue
+		}
+		value, err := unescapeHeader(values[0])
+		if err != nil {
+			return Event{}, fmt.Errorf("%w: header %s: %w", ErrInvalid, key, err)
+		}
+		c.Attributes[strings.ToLower(strings.TrimPrefix(key, headerPrefix))] = value
+	}
+	if ct := h.Get("Content-Type"); ct != "" {
+		c.Attributes[attrDataContentType] = ct
+	}
+	c.Data = jsonData(body)
+	return c, nil
+}
+
+// WriteBinary записывает атрибуты события в заголовки двоичного режима и
+// возвращает тело — данные события.
+func WriteBinary(h http.Header, c Event) []byte {
+	for name, value := range c.Attributes {
+		if name == attrDataContentType {
+			continue
+		}
+		h.Set(headerPrefix+name, escapeHeader(value))
+	}
+	if len(c.Data) > 0 {
+		h.Set("Content-Type", "application/json")
+	}
+	return c.Data
+}
+
+// escapeHeader кодирует значение заголовка по правилам привязки HTTP:
+// пробел, кавычка, процент и символы вне печатного ASCII — через %XX
+// байтов UTF-8.
+func escapeHeader(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c <= ' ' || c == '"' || c == '%' || c >= 0x7f {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// unescapeHeader декодирует значение, закодированное escapeHeader.
+func unescapeHeader(value string) (string, error) {
+	if !strings.Contains(value, "%") {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+2 >= len(value) {
+			return "", errors.New("truncated percent encoding")
+		}
+		n, err := strconv.ParseUint(value[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(byte(n))
+		i += 2
+	}
+	return b.String(), nil
+}
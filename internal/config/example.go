@@ -0,0 +1,159 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// configSource — описание структур конфигурации, из комментариев к полям
+// которого строятся примеры: так пример не расходится с кодом.
+//
+//go:embed config.go
+var configSource []byte
+
+// ServerExample возвращает пример конфигурации сервера со всеми полями,
+// значениями по умолчанию и комментариями. Необязательные разделы (nil —
+// выключено) и элементы списков закомментированы.
+func ServerExample() []byte {
+	return example(&ServerConfig{ServerAddr: ":8080", WSPath: "/ws", LogLevel: "INFO"})
+}
+
+// ClientExample возвращает пример конфигурации клиента, как ServerExample.
+func ClientExample() []byte {
+	return example(&ClientConfig{
+		ClientServerURL: "ws://localhost:8080/ws",
+		DBPath:          "client.db",
+		NumClients:      1,
+		LogLevel:        "INFO",
+	})
+}
+
+var (
+	fieldDocsOnce sync.Once
+	fieldDocs     map[string]string // "Тип.Поле" -> комментарий
+)
+
+// fieldDoc возвращает комментарий к полю структуры из config.go без
+// повторения имени поля в начале.
+func fieldDoc(typeName, field string) string {
+	fieldDocsOnce.Do(func() {
+		fieldDocs = make(map[string]string)
+		file, err := parser.ParseFile(token.NewFileSet(), "config.go", configSource, parser.ParseComments)
+		if err != nil {
+			return
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return false
+			}
+			for _, f := range st.Fields.List {
+				text := strings.TrimSpace(f.Doc.Text() + f.Comment.Text())
+				for _, name := range f.Names {
+					fieldDocs[spec.Name.Name+"."+name.Name] = strings.TrimPrefix(text, name.Name+" — ")
+				}
+			}
+			return false
+		})
+	})
+	return fieldDocs[typeName+"."+field]
+}
+
+// exampleWriter строит пример построчно с отступом в два пробела.
+type exampleWriter struct {
+	buf bytes.Buffer
+}
+
+func example(cfg any) []byte {
+	w := &exampleWriter{}
+	v := reflect.ValueOf(cfg).Elem()
+	w.line(0, false, "{", "")
+	w.fields(v, 1, false)
+	w.line(0, false, "}", "")
+	return w.buf.Bytes()
+}
+
+// line записывает строку; в выключенном разделе она закомментирована.
+func (w *exampleWriter) line(indent int, disabled bool, text, comment string) {
+	prefix := strings.Repeat("  ", indent)
+	if disabled {
+		prefix += "// "
+	}
+	w.buf.WriteString(prefix + text)
+	if comment != "" {
+		w.buf.WriteString(" // " + comment)
+	}
+	w.buf.WriteByte('\n')
+}
+
+// fields записывает поля структуры v. Многострочный комментарий пишется
+// над полем, однострочный — в конце строки.
+func (w *exampleWriter) fields(v reflect.Value, indent int, disabled bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+		doc := fieldDoc(t.Name(), f.Name)
+		inline := doc
+		if strings.Contains(doc, "\n") {
+			for _, l := range strings.Split(doc, "\n") {
+				w.line(indent, disabled, "// "+l, "")
+			}
+			inline = ""
+		}
+		w.value(v.Field(i), indent, disabled, `"`+name+`": `, inline)
+	}
+}
+
+var (
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	rawType       = reflect.TypeOf(PluginOptions{})
+)
+
+// value записывает значение поля с ключом key: вложенные структуры —
+// объектом, необязательные разделы и примеры элементов списков структур —
+// закомментированными.
+func (w *exampleWriter) value(v reflect.Value, indent int, disabled bool, key, comment string) {
+	t := v.Type()
+	switch {
+	case t == rawType:
+		w.line(indent, disabled, key+"{},", comment)
+	case t.Kind() == reflect.Struct && !t.Implements(marshalerType):
+		w.line(indent, disabled, key+"{", comment)
+		w.fields(v, indent+1, disabled)
+		w.line(indent, disabled, "},", "")
+	case t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct:
+		if v.IsNil() {
+			v, disabled = reflect.New(t.Elem()), true
+		}
+		w.value(v.Elem(), indent, disabled, key, comment)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct && v.Len() == 0:
+		w.line(indent, disabled, key+"[", comment)
+		w.value(reflect.New(t.Elem()).Elem(), indent+1, true, "", "")
+		w.line(indent, disabled, "],", "")
+	case t.Kind() == reflect.Slice && v.Len() == 0:
+		w.line(indent, disabled, key+"[],", comment)
+	case t.Kind() == reflect.Map && v.Len() == 0:
+		w.line(indent, disabled, key+"{},", comment)
+	default:
+		raw, err := json.Marshal(v.Interface())
+		if err != nil {
+			raw = []byte("null")
+		}
+		w.line(indent, disabled, key+string(raw)+",", comment)
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Secret — строковое значение конфигурации, которое может ссылаться на
+// секрет вместо того, чтобы хранить его в JSON открытым текстом:
+//   - "env:NAME" — значение переменной окружения NAME;
+//   - "file:/path" — содержимое файла без завершающего перевода строки;
+//   - "vault:path#field" — поле field секрета path из HashiCorp Vault
+//     (KV v1 или v2; без #field — поле "value"). Адрес и токен Vault берутся
+//     из VAULT_ADDR и VAULT_TOKEN, пространство имён — из VAULT_NAMESPACE.
+//
+// Остальные строки используются как есть. Ссылки разрешаются при загрузке
+// конфигурации.
+type Secret string
+
+// UnmarshalJSON разрешает ссылку на секрет.
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	value, err := resolveSecret(raw)
+	if err != nil {
+		return err
+	}
+	*s = Secret(value)
+	return nil
+}
+
+// String скрывает значение секрета при выводе в логи.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+// MarshalJSON скрывает значение секрета при выводе конфигурации.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// isSecretRef сообщает, является ли строка ссылкой на секрет.
+func isSecretRef(ref string) bool {
+	scheme, _, ok := strings.Cut(ref, ":")
+	return ok && (scheme == "env" || scheme == "file" || scheme == "vault")
+}
+
+func resolveSecret(ref string) (string, error) {
+	scheme, target, ok := strings.Cut(ref, ":")
+	if !ok {
+		return ref, nil
+	}
+	switch scheme {
+	case "env":
+		value, ok := os.LookupEnv(target)
+		if !ok {
+			return "", fmt.Errorf("secret %q: environment variable is not set", ref)
+		}
+		return value, nil
+	case "file":
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return "", fmt.Errorf("secret %q: %w", ref, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case "vault":
+		value, err := readVault(target)
+		if err != nil {
+			return "", fmt.Errorf("secret %q: %w", ref, err)
+		}
+		return value, nil
+	}
+	return ref, nil
+}
+
+// readVault читает поле секрета из Vault по HTTP API.
+func readVault(ref string) (string, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set")
+	}
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok {
+		field = "value"
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s", resp.Status)
+	}
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	data := body.Data
+	// KV v2 вкладывает значения секрета в data.data.
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, meta := data["metadata"]; meta {
+			data = nested
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %q not found", field)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %q is not a string", field)
+	}
+	return s, nil
+}
+
+// PluginOptions — параметры подключаемой интеграции (приёмника или
+// источника событий) в виде объекта JSON, который разбирает сама
+// интеграция. Строковые значения на любой глубине разрешаются как Secret,
+// поэтому ключи и адреса можно брать из окружения, файлов и Vault.
+type PluginOptions struct {
+	raw      json.RawMessage // с разрешёнными секретами
+	redacted json.RawMessage // со скрытыми значениями ссылок на секреты
+}
+
+// Raw возвращает параметры с разрешёнными секретами; nil — не заданы.
+func (o PluginOptions) Raw() json.RawMessage {
+	return o.raw
+}
+
+// UnmarshalJSON разрешает ссылки на секреты в строковых значениях.
+func (o *PluginOptions) UnmarshalJSON(data []byte) error {
+	decode := func() (any, error) {
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.UseNumber()
+		var v any
+		err := dec.Decode(&v)
+		return v, err
+	}
+	v, err := decode()
+	if err != nil {
+		return err
+	}
+	if v, err = resolveSecrets(v); err != nil {
+		return err
+	}
+	if o.raw, err = json.Marshal(v); err != nil {
+		return err
+	}
+	// Повторный разбор вместо копии: resolveSecrets изменяет значение на месте.
+	v, _ = decode()
+	o.redacted, err = json.Marshal(redactSecrets(v))
+	return err
+}
+
+// MarshalJSON записывает параметры со скрытыми значениями секретов.
+func (o PluginOptions) MarshalJSON() ([]byte, error) {
+	if o.redacted == nil {
+		return []byte("null"), nil
+	}
+	return o.redacted, nil
+}
+
+// redactSecrets заменяет ссылки на секреты во всех строках значения.
+func redactSecrets(v any) any {
+	switch t := v.(type) {
+	case string:
+		if isSecretRef(t) {
+			return Secret(t).String()
+		}
+	case map[string]any:
+		for key, item := range t {
+			t[key] = redactSecrets(item)
+		}
+	case []any:
+		for i, item := range t {
+			t[i] = redactSecrets(item)
+		}
+	}
+	return v
+}
+
+// resolveSecrets разрешает ссылки на секреты во всех строках значения.
+func resolveSecrets(v any) (any, error) {
+	var err error
+	switch t := v.(type) {
+	case string:
+		return resolveSecret(t)
+	case map[string]any:
+		for key, item := range t {
+			if t[key], err = resolveSecrets(item); err != nil {
+				return nil, err
+			}
+		}
+	case []any:
+		for i, item := range t {
+			if t[i], err = resolveSecrets(item); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
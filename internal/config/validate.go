@@ -0,0 +1,368 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// problems накапливает ошибки проверки с путём к полю.
+type problems []error
+
+func (p *problems) add(field, format string, args ...any) {
+	*p = append(*p, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+}
+
+func (p *problems) oneOf(field, value string, allowed ...string) {
+	if value != "" && !slices.Contains(allowed, value) {
+		p.add(field, "unknown value %q, want one of %s", value, strings.Join(allowed, ", "))
+	}
+}
+
+func (p *problems) required(field, value string) {
+	if value == "" {
+		p.add(field, "required")
+	}
+}
+
+func (p *problems) logLevel(field, value string) {
+	var level slog.Level
+	if value != "" && level.UnmarshalText([]byte(value)) != nil {
+		p.add(field, "unknown log level %q", value)
+	}
+}
+
+func (p *problems) severity(field, value string) {
+	if _, err := domain.ParseSeverity(value); err != nil {
+		p.add(field, "%v", err)
+	}
+}
+
+func (p *problems) severityMap(field string, rules map[string]string) {
+	if _, err := domain.ParseSeverityMap(rules); err != nil {
+		p.add(field, "%v", err)
+	}
+}
+
+func (p *problems) topics(field string, patterns []string) {
+	for i, pattern := range patterns {
+		if err := domain.ValidateTopicPattern(pattern); err != nil {
+			p.add(fmt.Sprintf("%s[%d]", field, i), "%v: %q", err, pattern)
+		}
+	}
+}
+
+func (p *problems) nonNegative(field string, value float64) {
+	if value < 0 {
+		p.add(field, "must not be negative")
+	}
+}
+
+func (p *problems) addrs(field string, values []string) {
+	for i, v := range values {
+		if _, _, err := net.ParseCIDR(v); err != nil && net.ParseIP(v) == nil {
+			p.add(fmt.Sprintf("%s[%d]", field, i), "invalid address or subnet %q", v)
+		}
+	}
+}
+
+func (p *problems) alerts(field string, alerts []AlertConfig) {
+	for i, a := range alerts {
+		f := fmt.Sprintf("%s[%d]", field, i)
+		p.required(f+".url", string(a.URL))
+		p.oneOf(f+".format", a.Format, "json", "slack")
+		p.severity(f+".min_severity", a.MinSeverity)
+		p.topics(f+".topics", a.Topics)
+		if a.MaxPerMinute < 0 {
+			p.add(f+".max_per_minute", "must not be negative")
+		}
+	}
+}
+
+func (p *problems) err() error {
+	return errors.Join(*p...)
+}
+
+// Validate проверяет согласованность конфигурации сервера, не обращаясь к
+// файлам и сети, и возвращает все найденные ошибки сразу.
+func (c *ServerConfig) Validate() error {
+	var p problems
+	if !strings.HasPrefix(c.WSPath, "/") {
+		p.add("ws_path", "must start with /")
+	}
+	p.logLevel("log_level", c.LogLevel)
+	p.oneOf("conflict_resolution", c.ConflictResolution, "lww", "server_wins")
+	p.severityMap("severity_map", c.SeverityMap)
+	if c.Schemas != nil {
+		p.oneOf("schemas.on_invalid", c.Schemas.OnInvalid, "reject", "quarantine")
+		for eventType, steps := range c.Schemas.Downgrades {
+			for version := range steps {
+				if version < 2 || version > len(c.Schemas.Versions[eventType]) {
+					p.add("schemas.downgrades."+eventType, "version %d is not a registered version above 1", version)
+				}
+			}
+		}
+	}
+	if c.JWT != nil {
+		p.required("jwt.jwks", c.JWT.JWKS)
+	}
+	if c.AdminOIDC != nil {
+		p.required("admin_oidc.issuer", c.AdminOIDC.Issuer)
+		p.required("admin_oidc.client_id", c.AdminOIDC.ClientID)
+		if c.AdminOIDC.Introspect {
+			p.required("admin_oidc.client_secret", string(c.AdminOIDC.ClientSecret))
+		}
+		if len(c.AdminOIDC.AdminRoles) == 0 {
+			p.add("admin_oidc.admin_roles", "required")
+		}
+	}
+	if g := c.Guard; g != nil {
+		p.nonNegative("guard.rate", g.Rate)
+		p.nonNegative("guard.burst", float64(g.Burst))
+		p.nonNegative("guard.max_failures", float64(g.MaxFailures))
+		p.nonNegative("guard.window", float64(g.Window))
+		p.nonNegative("guard.ban_duration", float64(g.BanDuration))
+		p.nonNegative("guard.max_ban", float64(g.MaxBan))
+		p.addrs("guard.deny", g.Deny)
+		p.addrs("guard.allow", g.Allow)
+		p.addrs("guard.trusted_proxies", g.TrustedProxies)
+	}
+	p.alerts("alerts", c.Alerts)
+	for i, t := range c.Transforms {
+		f := fmt.Sprintf("transforms[%d]", i)
+		p.required(f+".kind", t.Kind)
+		p.oneOf(f+".kind", t.Kind, "enrich", "redact", "drop", "route")
+		p.topics(f+".topics", t.Topics)
+		switch t.Kind {
+		case "redact":
+			if len(t.Fields) == 0 {
+				p.add(f+".fields", "required for redact")
+			}
+		case "route":
+			p.required(f+".topic", t.Topic)
+		}
+	}
+	if c.DurableSubscriptions != nil {
+		p.required("durable_subscriptions.path", c.DurableSubscriptions.Path)
+	}
+	if c.Schedule != nil {
+		p.required("schedule.path", c.Schedule.Path)
+	}
+	if h := c.History; h != nil {
+		p.oneOf("history.backend", h.Backend, "memory", "sqlite", "postgres", "file")
+		switch h.Backend {
+		case "sqlite", "file":
+			p.required("history.path", h.Path)
+		case "postgres":
+			p.required("history.dsn", string(h.DSN))
+		}
+	}
+	if c.StateSnapshot != nil {
+		p.required("state_snapshot.path", c.StateSnapshot.Path)
+	}
+	p.nonNegative("upgrade.drain_period", float64(c.Upgrade.DrainPeriod))
+	p.nonNegative("upgrade.ready_timeout", float64(c.Upgrade.ReadyTimeout))
+	for i, s := range c.Sources {
+		p.required(fmt.Sprintf("sources[%d].type", i), s.Type)
+	}
+	return p.err()
+}
+
+// Validate проверяет согласованность конфигурации клиента, не обращаясь к
+// файлам и сети, и возвращает все найденные ошибки сразу.
+func (c *ClientConfig) Validate() error {
+	var p problems
+	for i, raw := range append([]string{c.ClientServerURL}, c.FailoverURLs...) {
+		field := "client_server_url"
+		if i > 0 {
+			field = fmt.Sprintf("failover_urls[%d]", i-1)
+		}
+		u, err := url.Parse(raw)
+		switch {
+		case raw == "":
+			p.required(field, raw)
+		case err != nil:
+			p.add(field, "%v", err)
+		case u.Scheme != "ws" && u.Scheme != "wss":
+			p.add(field, "scheme must be ws or wss")
+		}
+	}
+	p.oneOf("storage", c.Storage, "sqlite", "bolt", "jsonl", "memory")
+	if c.Storage != "memory" {
+		p.required("db_path", c.DBPath)
+	}
+	if c.NumClients < 1 {
+		p.add("num_clients", "must be at least 1")
+	}
+	p.oneOf("isolation", c.Isolation, "shared", "isolated")
+	p.logLevel("log_level", c.LogLevel)
+	p.topics("topics", c.Topics)
+	p.severity("min_severity", c.MinSeverity)
+	p.severityMap("severity_map", c.SeverityMap)
+	p.oneOf("sync_mode", c.SyncMode, "snapshot")
+	if c.Aggregate.Interval != 0 && time.Duration(c.Aggregate.Interval) < time.Second {
+		p.add("aggregate.interval", "must be at least 1s")
+	}
+	p.oneOf("group.strategy", c.Group.Strategy, "round_robin", "key_hash")
+	p.oneOf("dedup_mode", c.DedupMode, "id", "hash", "both")
+	p.oneOf("workers.overflow", c.Workers.Overflow, "block", "drop")
+	p.oneOf("sqlite.synchronous", strings.ToUpper(c.SQLite.Synchronous), "OFF", "NORMAL", "FULL", "EXTRA")
+	p.nonNegative("memory_limit", float64(c.MemoryLimit))
+	p.nonNegative("dedup_cache_size", float64(c.DedupCacheSize))
+	p.nonNegative("dedup_window", float64(c.DedupWindow))
+	p.nonNegative("workers.count", float64(c.Workers.Count))
+	p.nonNegative("write_batch.size", float64(c.WriteBatch.Size))
+	p.nonNegative("reconnect.max_retries", float64(c.Reconnect.MaxRetries))
+	if c.Reconnect.Jitter < 0 || c.Reconnect.Jitter > 1 {
+		p.add("reconnect.jitter", "must be between 0 and 1")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		p.add("tls", "cert_file and key_file must be set together")
+	}
+	if c.ProxyURL != "" {
+		if u, err := url.Parse(c.ProxyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			p.add("proxy_url", "want http://, https:// or socks5:// URL")
+		}
+	}
+	p.alerts("alerts", c.Alerts)
+	for i, s := range c.Sinks {
+		f := fmt.Sprintf("sinks[%d]", i)
+		p.required(f+".type", s.Type)
+		p.topics(f+".topics", s.Topics)
+	}
+	return p.err()
+}
+
+// ApplyDefaults заполняет незаданные поля значениями, которые сервер
+// подставляет сам при запуске, чтобы показать действующую конфигурацию.
+// Поля, нулевое значение которых выключает возможность, не заполняются.
+func (c *ServerConfig) ApplyDefaults() {
+	setString(&c.LogLevel, "INFO")
+	if c.Schemas != nil {
+		setString(&c.Schemas.OnInvalid, "reject")
+	}
+	if c.JWT != nil {
+		setDuration(&c.JWT.RefreshInterval, 10*time.Minute)
+	}
+	if o := c.AdminOIDC; o != nil {
+		setString(&o.Audience, o.ClientID)
+		setString(&o.RolesClaim, "roles")
+		setDuration(&o.CacheTTL, time.Minute)
+	}
+	if c.Audit != nil {
+		setInt(&c.Audit.Size, 1000)
+	}
+	if g := c.Guard; g != nil {
+		setInt(&g.MaxFailures, 10)
+		setDuration(&g.Window, time.Minute)
+		setDuration(&g.BanDuration, 5*time.Minute)
+		setDuration(&g.MaxBan, 24*time.Hour)
+	}
+	defaultAlerts(c.Alerts)
+	for i := range c.Transforms {
+		if c.Transforms[i].Kind == "redact" && !c.Transforms[i].Remove {
+			setString(&c.Transforms[i].Replacement, "[REDACTED]")
+		}
+	}
+	if c.Netpoll != nil {
+		setInt(&c.Netpoll.Workers, 64)
+	}
+	if c.DurableSubscriptions != nil {
+		setInt(&c.DurableSubscriptions.MaxBacklog, 100000)
+	}
+	if h := c.History; h != nil {
+		setString(&h.Backend, "memory")
+		if h.Backend == "memory" {
+			setInt(&h.Capacity, 100000)
+		}
+		if h.Compaction != nil {
+			setDuration(&h.Compaction.Interval, time.Hour)
+		}
+	}
+	if c.StateSnapshot != nil {
+		setDuration(&c.StateSnapshot.Interval, time.Minute)
+	}
+	setDuration(&c.Upgrade.DrainPeriod, 30*time.Second)
+	setDuration(&c.Upgrade.ReadyTimeout, time.Minute)
+}
+
+// ApplyDefaults заполняет незаданные поля значениями, которые клиент
+// подставляет сам при запуске, как ServerConfig.ApplyDefaults.
+func (c *ClientConfig) ApplyDefaults() {
+	setString(&c.Storage, "sqlite")
+	setString(&c.Isolation, "shared")
+	setString(&c.LogLevel, "INFO")
+	setString(&c.Namespace, domain.DefaultNamespace)
+	setString(&c.DedupMode, "id")
+	setInt(&c.DedupCacheSize, 10000)
+	if len(c.FailoverURLs) > 0 {
+		setDuration(&c.PrimaryRecheck, 30*time.Second)
+	}
+	setDuration(&c.Reconnect.InitialBackoff, time.Second)
+	setDuration(&c.Reconnect.MaxBackoff, 30*time.Second)
+	if c.Group.Name != "" {
+		setString(&c.Group.Strategy, "round_robin")
+	}
+	if c.CausalOrder.Enabled {
+		setDuration(&c.CausalOrder.MaxWait, 5*time.Second)
+		setInt(&c.CausalOrder.MaxPending, 1000)
+	}
+	if c.Heartbeat.Interval > 0 {
+		setDuration(&c.Heartbeat.Timeout, 3*time.Duration(c.Heartbeat.Interval))
+	}
+	if c.Workers.Count > 0 {
+		setInt(&c.Workers.QueueSize, 1024)
+		setString(&c.Workers.Overflow, "block")
+	}
+	if c.SaveRetry.Attempts > 1 {
+		setDuration(&c.SaveRetry.InitialBackoff, 50*time.Millisecond)
+		setDuration(&c.SaveRetry.MaxBackoff, 2*time.Second)
+	}
+	if c.Breaker.Threshold > 0 {
+		setInt(&c.Breaker.BufferSize, 1000)
+		setDuration(&c.Breaker.ProbeInterval, time.Second)
+	}
+	if c.Retention.MaxAge > 0 || c.Retention.MaxRows > 0 {
+		setDuration(&c.Retention.Interval, time.Minute)
+	}
+	defaultAlerts(c.Alerts)
+	for i := range c.Sinks {
+		s := &c.Sinks[i]
+		setString(&s.Name, fmt.Sprintf("sink-%d", i+1))
+		setInt(&s.QueueSize, 1024)
+		setInt(&s.BatchSize, 100)
+		setDuration(&s.Retry.InitialBackoff, time.Second)
+		setDuration(&s.Retry.MaxBackoff, time.Minute)
+	}
+}
+
+func defaultAlerts(alerts []AlertConfig) {
+	for i := range alerts {
+		setString(&alerts[i].Format, "json")
+	}
+}
+
+func setString(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+func setInt(field *int, value int) {
+	if *field == 0 {
+		*field = value
+	}
+}
+
+func setDuration(field *Duration, value time.Duration) {
+	if *field == 0 {
+		*field = Duration(value)
+	}
+}
@@ -0,0 +1,167 @@
+// Package crdt материализует состояние из событий-операций над CRDT
+// (счётчики, LWW-словари, множества). Операции идемпотентны и коммутативны,
+// поэтому сервер и клиенты, получившие одни и те же события в любом порядке
+// и с повторами, приходят к одному состоянию.
+package crdt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// EventType — тип событий с операциями CRDT.
+const EventType = "crdt.op"
+
+// TopicPrefix — префикс топика событий-операций: "crdt.<объект>".
+const TopicPrefix = "crdt."
+
+// Виды CRDT.
+const (
+	KindCounter = "pncounter" // счётчик с увеличением и уменьшением
+	KindLWWMap  = "lwwmap"    // словарь, в котором побеждает последняя запись
+	KindORSet   = "orset"     // множество, в котором добавление побеждает конкурентное удаление
+)
+
+// ErrKindMismatch возвращается при операции другого вида над существующим объектом.
+var ErrKindMismatch = errors.New("crdt kind mismatch")
+
+// Op — операция над объектом CRDT, передаваемая в Data события. Набор
+// заполненных полей зависит от Kind.
+type Op struct {
+	Object string `json:"object"`
+	Kind   string `json:"kind"`
+	Node   string `json:"node,omitempty"` // узел, выполнивший операцию
+
+	// pncounter: накопленные узлом суммы увеличений и уменьшений.
+	P uint64 `json:"p,omitempty"`
+	N uint64 `json:"n,omitempty"`
+
+	// lwwmap: запись Value (или удаление) ключа Key во время TS.
+	Key     string          `json:"key,omitempty"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Deleted bool            `json:"deleted,omitempty"`
+	TS      int64           `json:"ts,omitempty"` // Unix-наносекунды
+
+	// orset: добавление элемента Add с уникальной меткой Tag либо удаление
+	// элемента Remove с наблюдавшимися метками Tags.
+	Add    string   `json:"add,omitempty"`
+	Tag    string   `json:"tag,omitempty"`
+	Remove string   `json:"remove,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// object — состояние одного объекта CRDT.
+type object interface {
+	apply(op Op) error
+	value() any
+}
+
+// State — материализованное состояние объекта.
+type State struct {
+	Object string `json:"object"`
+	Kind   string `json:"kind"`
+	Value  any    `json:"value"`
+}
+
+// Store хранит состояние объектов CRDT. Безопасен для конкурентного использования.
+type Store struct {
+	mu      sync.RWMutex
+	objects map[string]object
+	kinds   map[string]string
+}
+
+// NewStore создаёт пустое хранилище состояния.
+func NewStore() *Store {
+	return &Store{objects: make(map[string]object), kinds: make(map[string]string)}
+}
+
+// Apply применяет операцию из события. События других типов пропускаются.
+// Повторное применение той же операции не меняет состояние.
+func (s *Store) Apply(event domain.Event) error {
+	if event.Type != EventType {
+		return nil
+	}
+	op, err := DecodeOp(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[op.Object]
+	if !ok {
+		if obj, err = newObject(op.Kind); err != nil {
+			return err
+		}
+		s.objects[op.Object] = obj
+		s.kinds[op.Object] = op.Kind
+	} else if s.kinds[op.Object] != op.Kind {
+		return fmt.Errorf("%w: %s is %s, got %s", ErrKindMismatch, op.Object, s.kinds[op.Object], op.Kind)
+	}
+	return obj.apply(op)
+}
+
+// State возвращает состояние объекта; ok = false, если объекта нет.
+func (s *Store) State(name string) (State, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[name]
+	if !ok {
+		return State{}, false
+	}
+	return State{Object: name, Kind: s.kinds[name], Value: obj.value()}, true
+}
+
+// States возвращает состояние всех объектов, упорядоченное по имени.
+func (s *Store) States() []State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	states := make([]State, 0, len(s.objects))
+	for name, obj := range s.objects {
+		states = append(states, State{Object: name, Kind: s.kinds[name], Value: obj.value()})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Object < states[j].Object })
+	return states
+}
+
+// DecodeOp извлекает и проверяет операцию из события.
+func DecodeOp(event domain.Event) (Op, error) {
+	var op Op
+	if err := json.Unmarshal(event.Data, &op); err != nil {
+		return Op{}, fmt.Errorf("decode crdt op: %w", err)
+	}
+	if err := domain.ValidateTopic(TopicPrefix + op.Object); err != nil || op.Object == "" {
+		return Op{}, fmt.Errorf("invalid crdt object %q", op.Object)
+	}
+	if op.Kind == KindORSet && op.Add != "" && op.Tag == "" {
+		// ID события уникален и одинаков у всех получателей.
+		op.Tag = event.ID
+	}
+	return op, nil
+}
+
+// NewOpEvent упаковывает операцию в событие с топиком "crdt.<объект>".
+func NewOpEvent(op Op) (domain.Event, error) {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return domain.Event{}, err
+	}
+	return domain.Event{Type: EventType, Topic: TopicPrefix + op.Object, Data: data}, nil
+}
+
+func newObject(kind string) (object, error) {
+	switch kind {
+	case KindCounter:
+		return &counter{p: map[string]uint64{}, n: map[string]uint64{}}, nil
+	case KindLWWMap:
+		return &lwwMap{entries: map[string]lwwEntry{}}, nil
+	case KindORSet:
+		return &orSet{adds: map[string]map[string]struct{}{}, removed: map[string]struct{}{}}, nil
+	default:
+		return nil, fmt.Errorf("unknown crdt kind %q", kind)
+	}
+}
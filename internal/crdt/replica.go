@@ -0,0 +1,71 @@
+package crdt
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Replica формирует события-операции узла Node по текущему состоянию
+// Store. Событие нужно опубликовать; локальное состояние меняется, когда
+// операция применена к Store (например, после её получения от сервера).
+type Replica struct {
+	Node  string
+	Store *Store
+}
+
+// Add увеличивает (delta > 0) или уменьшает счётчик object.
+func (r Replica) Add(object string, delta int64) (domain.Event, error) {
+	var p, n uint64
+	r.Store.mu.RLock()
+	if c, ok := r.Store.objects[object].(*counter); ok {
+		p, n = c.totals(r.Node)
+	}
+	r.Store.mu.RUnlock()
+	if delta >= 0 {
+		p += uint64(delta)
+	} else {
+		n += uint64(-delta)
+	}
+	return NewOpEvent(Op{Object: object, Kind: KindCounter, Node: r.Node, P: p, N: n})
+}
+
+// Set записывает значение ключа словаря object.
+func (r Replica) Set(object, key string, value any) (domain.Event, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return domain.Event{}, err
+	}
+	return NewOpEvent(Op{Object: object, Kind: KindLWWMap, Node: r.Node, Key: key, Value: data, TS: time.Now().UnixNano()})
+}
+
+// Delete удаляет ключ словаря object.
+func (r Replica) Delete(object, key string) (domain.Event, error) {
+	return NewOpEvent(Op{Object: object, Kind: KindLWWMap, Node: r.Node, Key: key, Deleted: true, TS: time.Now().UnixNano()})
+}
+
+// Insert добавляет элемент в множество object.
+func (r Replica) Insert(object, elem string) (domain.Event, error) {
+	return NewOpEvent(Op{Object: object, Kind: KindORSet, Node: r.Node, Add: elem, Tag: r.Node + ":" + newTag()})
+}
+
+// Remove удаляет элемент множества object, снимая все известные реплике метки.
+func (r Replica) Remove(object, elem string) (domain.Event, error) {
+	var tags []string
+	r.Store.mu.RLock()
+	if s, ok := r.Store.objects[object].(*orSet); ok {
+		tags = s.tags(elem)
+	}
+	r.Store.mu.RUnlock()
+	return NewOpEvent(Op{Object: object, Kind: KindORSet, Node: r.Node, Remove: elem, Tags: tags})
+}
+
+// newTag генерирует уникальную метку добавления.
+func newTag() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,129 @@
+package crdt
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// counter — PN-счётчик: для каждого узла хранятся накопленные суммы
+// увеличений и уменьшений; операция поднимает их до присланных значений.
+type counter struct {
+	p, n map[string]uint64
+}
+
+func (c *counter) apply(op Op) error {
+	if op.Node == "" {
+		return errors.New("pncounter op requires node")
+	}
+	c.p[op.Node] = max(c.p[op.Node], op.P)
+	c.n[op.Node] = max(c.n[op.Node], op.N)
+	return nil
+}
+
+func (c *counter) value() any {
+	var v int64
+	for node := range c.p {
+		v += int64(c.p[node])
+	}
+	for node := range c.n {
+		v -= int64(c.n[node])
+	}
+	return v
+}
+
+// totals возвращает накопленные суммы узла.
+func (c *counter) totals(node string) (uint64, uint64) {
+	return c.p[node], c.n[node]
+}
+
+// lwwEntry — значение ключа LWW-словаря с меткой записи.
+type lwwEntry struct {
+	value   json.RawMessage
+	deleted bool
+	ts      int64
+	node    string
+}
+
+// newer сравнивает метки записи; при равном времени побеждает больший узел.
+func (e lwwEntry) newer(other lwwEntry) bool {
+	return e.ts > other.ts || e.ts == other.ts && e.node > other.node
+}
+
+// lwwMap — словарь, в котором для каждого ключа побеждает запись с
+// наибольшим временем; удаление — запись-надгробие.
+type lwwMap struct {
+	entries map[string]lwwEntry
+}
+
+func (m *lwwMap) apply(op Op) error {
+	if op.Key == "" {
+		return errors.New("lwwmap op requires key")
+	}
+	entry := lwwEntry{value: op.Value, deleted: op.Deleted, ts: op.TS, node: op.Node}
+	if cur, ok := m.entries[op.Key]; !ok || entry.newer(cur) {
+		m.entries[op.Key] = entry
+	}
+	return nil
+}
+
+func (m *lwwMap) value() any {
+	out := make(map[string]json.RawMessage)
+	for key, e := range m.entries {
+		if !e.deleted {
+			out[key] = e.value
+		}
+	}
+	return out
+}
+
+// orSet — множество с наблюдаемым удалением: элемент присутствует, пока у
+// него есть неудалённые метки добавления. Удаление снимает только метки,
+// которые видел удаляющий, поэтому конкурентное добавление сохраняется.
+type orSet struct {
+	adds    map[string]map[string]struct{} // элемент -> метки добавления
+	removed map[string]struct{}            // удалённые метки
+}
+
+func (s *orSet) apply(op Op) error {
+	switch {
+	case op.Add != "" && op.Tag != "":
+		if _, gone := s.removed[op.Tag]; gone {
+			return nil
+		}
+		if s.adds[op.Add] == nil {
+			s.adds[op.Add] = make(map[string]struct{})
+		}
+		s.adds[op.Add][op.Tag] = struct{}{}
+	case op.Remove != "":
+		for _, tag := range op.Tags {
+			s.removed[tag] = struct{}{}
+			delete(s.adds[op.Remove], tag)
+		}
+		if len(s.adds[op.Remove]) == 0 {
+			delete(s.adds, op.Remove)
+		}
+	default:
+		return errors.New("orset op requires add with tag or remove")
+	}
+	return nil
+}
+
+func (s *orSet) value() any {
+	elems := make([]string, 0, len(s.adds))
+	for elem := range s.adds {
+		elems = append(elems, elem)
+	}
+	sort.Strings(elems)
+	return elems
+}
+
+// tags возвращает наблюдаемые метки элемента.
+func (s *orSet) tags(elem string) []string {
+	tags := make([]string, 0, len(s.adds[elem]))
+	for tag := range s.adds[elem] {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
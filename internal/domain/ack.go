@@ -0,0 +1,24 @@
+package domain
+
+// FrameKindAck — значение поля kind у кадра подтверждения.
+const FrameKindAck = "ack"
+
+// Ack — кадр подтверждения, который клиент отправляет серверу после того,
+// как событие (или пачка событий) надёжно сохранено.
+type Ack struct {
+	Kind string   `json:"kind"`
+	Seq  uint64   `json:"seq,omitempty"` // наибольший подтверждаемый номер события
+	IDs  []string `json:"ids,omitempty"` // идентификаторы подтверждаемых событий
+}
+
+// NewAck формирует подтверждение для сохранённых событий.
+func NewAck(events ...Event) Ack {
+	ack := Ack{Kind: FrameKindAck, IDs: make([]string, 0, len(events))}
+	for _, e := range events {
+		ack.IDs = append(ack.IDs, e.ID)
+		if e.Seq > ack.Seq {
+			ack.Seq = e.Seq
+		}
+	}
+	return ack
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// EventTypeAggregate — тип сводных событий, которые сервер формирует для
+// подписок с агрегацией.
+const EventTypeAggregate = "aggregate.counts"
+
+// MinAggregateInterval — наименьшее окно агрегации.
+const MinAggregateInterval = time.Second
+
+// AggregateData — полезная нагрузка сводного события: сколько событий
+// каждого типа подписка получила за окно [WindowStart, WindowEnd).
+type AggregateData struct {
+	WindowStart time.Time         `json:"window_start"`
+	WindowEnd   time.Time         `json:"window_end"`
+	Interval    string            `json:"interval"` // длина окна, например "1m0s"
+	Total       uint64            `json:"total"`
+	Counts      map[string]uint64 `json:"counts"` // по типам событий
+}
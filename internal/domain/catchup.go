@@ -0,0 +1,9 @@
+package domain
+
+// EventPage — страница событий ответа GET /events?since=: события после
+// запрошенного номера в порядке номеров.
+type EventPage struct {
+	Events []Event `json:"events"`
+	// More сообщает, что после последнего события страницы есть ещё события.
+	More bool `json:"more"`
+}
@@ -0,0 +1,71 @@
+package domain
+
+// VectorClock — векторные часы: число событий, порождённых каждым узлом
+// (экземпляром сервера или клиентом), известных отправителю.
+type VectorClock map[string]uint64
+
+// Causality — причинные метаданные события: узел, породивший событие, и
+// его векторные часы в момент отправки (с учётом самого события).
+type Causality struct {
+	Node  string      `json:"node"`
+	Clock VectorClock `json:"clock"`
+}
+
+// Clone возвращает копию часов.
+func (vc VectorClock) Clone() VectorClock {
+	out := make(VectorClock, len(vc))
+	for node, n := range vc {
+		out[node] = n
+	}
+	return out
+}
+
+// Merge поднимает каждую компоненту часов до значения в other.
+func (vc VectorClock) Merge(other VectorClock) {
+	for node, n := range other {
+		if n > vc[node] {
+			vc[node] = n
+		}
+	}
+}
+
+// HappenedBefore сообщает, предшествуют ли часы vc часам other: ни одна
+// компонента vc не больше, и хотя бы одна меньше.
+func (vc VectorClock) HappenedBefore(other VectorClock) bool {
+	less := false
+	for node, n := range vc {
+		if n > other[node] {
+			return false
+		}
+		if n < other[node] {
+			less = true
+		}
+	}
+	for node, n := range other {
+		if _, ok := vc[node]; !ok && n > 0 {
+			less = true
+		}
+	}
+	return less
+}
+
+// Concurrent сообщает, что события с часами vc и other конкурентны:
+// ни одно не предшествует другому.
+func (vc VectorClock) Concurrent(other VectorClock) bool {
+	return !vc.HappenedBefore(other) && !other.HappenedBefore(vc) && !vc.Equal(other)
+}
+
+// Equal сообщает, совпадают ли часы (отсутствующая компонента равна нулю).
+func (vc VectorClock) Equal(other VectorClock) bool {
+	for node, n := range vc {
+		if other[node] != n {
+			return false
+		}
+	}
+	for node, n := range other {
+		if vc[node] != n {
+			return false
+		}
+	}
+	return true
+}
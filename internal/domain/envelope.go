@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// ErrMalformedFrame возвращается DecodeFrame для кадра, который не является
+// JSON-объектом или содержит пустое тело.
+var ErrMalformedFrame = errors.New("malformed frame")
+
+// Виды кадров протокола, помимо публикации, подтверждения и состояния.
+const (
+	FrameKindEvent     = "event"     // событие для клиента
+	FrameKindBatch     = "batch"     // несколько событий одним кадром: тело — массив событий
+	FrameKindSubscribe = "subscribe" // замена шаблонов подписки без переподключения
+	FrameKindResume    = "resume"    // номер, с которого клиент продолжает получение
+	FrameKindPing      = "ping"
+	FrameKindPong      = "pong"
+	FrameKindError     = "error" // ошибка обработки кадра получателем
+)
+
+// Envelope — конверт кадра протокола: Kind определяет тип, Payload — тело.
+// Тело служебного кадра совпадает с его плоским видом (с полем kind), тело
+// кадра "event" — событие. Получатель принимает и кадры без конверта: объект
+// с kind — плоский служебный кадр, без kind — событие. Так новые виды кадров
+// добавляются, не ломая разбор событий у старых клиентов.
+type Envelope struct {
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// NewEnvelope упаковывает тело кадра в конверт.
+func NewEnvelope(kind string, payload any) (Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return Envelope{Kind: kind, Payload: data}, nil
+}
+
+// DecodeFrame определяет вид кадра и возвращает его тело. Кадры без
+// конверта разбираются так, как описано у Envelope. Кадр null и кадр с телом
+// null отклоняются с ErrMalformedFrame, чтобы не превращаться в пустое событие.
+func DecodeFrame(message []byte) (string, json.RawMessage, error) {
+	var env Envelope
+	if err := json.Unmarshal(message, &env); err != nil {
+		return "", nil, err
+	}
+	if isNull(message) || (env.Payload != nil && isNull(env.Payload)) {
+		return "", nil, ErrMalformedFrame
+	}
+	switch {
+	case env.Kind == "":
+		return FrameKindEvent, message, nil
+	case env.Payload != nil:
+		return env.Kind, env.Payload, nil
+	default:
+		return env.Kind, message, nil
+	}
+}
+
+// isNull сообщает, что data — JSON-литерал null.
+func isNull(data []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+}
+
+// SubscribeFrame заменяет шаблоны топиков, на которые подписан клиент.
+type SubscribeFrame struct {
+	Kind   string   `json:"kind"`
+	Topics []string `json:"topics"` // пустой список — все события
+}
+
+// ResumeFrame сообщает номер последнего сохранённого клиентом события.
+type ResumeFrame struct {
+	Kind  string `json:"kind"`
+	Since uint64 `json:"since"`
+}
+
+// ErrorFrame сообщает отправителю, что его кадр не обработан.
+type ErrorFrame struct {
+	Kind  string     `json:"kind"`
+	Error FrameError `json:"error"`
+}
+
+// NewErrorFrame формирует кадр ошибки.
+func NewErrorFrame(code, message string) ErrorFrame {
+	return ErrorFrame{Kind: FrameKindError, Error: FrameError{Code: code, Message: message}}
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// frameSeeds — кадры всех версий протокола: плоские события и служебные
+// кадры ProtocolV1, кадры в конверте ProtocolV2 и пачки ProtocolV3.
+var frameSeeds = []string{
+	`{"id":"1","seq":1,"type":"info","message":"m","timestamp":"2024-01-01T00:00:00Z"}`,
+	`{"kind":"ack","id":"1","seq":1}`,
+	`{"kind":"ping","sent_at":"2024-01-01T00:00:00Z"}`,
+	`{"kind":"event","payload":{"id":"1","seq":1,"type":"info","message":"m"}}`,
+	`{"kind":"ack","payload":{"kind":"ack","id":"1","seq":1}}`,
+	`{"kind":"publish","payload":{"kind":"publish","event":{"id":"p","type":"info"}}}`,
+	`{"kind":"batch","payload":[{"id":"1","seq":1,"type":"info"},{"id":"2","seq":2,"type":"info"}]}`,
+	`{"kind":"batch","payload":[]}`,
+	`null`,
+	`{"kind":"event","payload":null}`,
+	`{"kind":5}`,
+	`[]`,
+	``,
+}
+
+func FuzzDecodeFrame(f *testing.F) {
+	for _, seed := range frameSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, message []byte) {
+		kind, payload, err := DecodeFrame(message)
+		if err != nil {
+			return
+		}
+		if kind == "" {
+			t.Fatalf("empty kind for %q", message)
+		}
+		if !json.Valid(payload) || isNull(payload) {
+			t.Fatalf("invalid payload %q for %q", payload, message)
+		}
+		// Тот же кадр в конверте разбирается в тот же вид и то же тело.
+		env, err := NewEnvelope(kind, json.RawMessage(payload))
+		if err != nil {
+			t.Fatalf("NewEnvelope: %v", err)
+		}
+		wrapped, err := json.Marshal(env)
+		if err != nil {
+			t.Fatalf("marshal envelope: %v", err)
+		}
+		kind2, payload2, err := DecodeFrame(wrapped)
+		if err != nil {
+			t.Fatalf("wrapped frame %q: %v", wrapped, err)
+		}
+		if kind2 != kind || !equalJSON(t, payload, payload2) {
+			t.Fatalf("round trip: %q %q -> %q %q", kind, payload, kind2, payload2)
+		}
+	})
+}
+
+// equalJSON сравнивает значения JSON без учёта форматирования.
+func equalJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("unmarshal %q: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("unmarshal %q: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,18 @@
+package domain
+
+import "errors"
+
+// Общие ошибки, которые сервисы, хранилища и транспорты оборачивают в свои,
+// чтобы вызывающий код различал их через errors.Is, а не по тексту.
+var (
+	// ErrDuplicateEvent — событие уже получено или сохранено ранее.
+	ErrDuplicateEvent = errors.New("duplicate event")
+	// ErrStoreUnavailable — хранилище временно недоступно (занято,
+	// заблокировано, закрыто, ошибка ввода-вывода); операцию можно повторить.
+	ErrStoreUnavailable = errors.New("store unavailable")
+	// ErrSlowClient — клиент не успевает принимать события.
+	ErrSlowClient = errors.New("slow client")
+	// ErrUnauthorized — запрос отклонён из-за отсутствующих или недостаточных
+	// учётных данных.
+	ErrUnauthorized = errors.New("unauthorized")
+)
@@ -1,11 +1,157 @@
 package domain
 
-import "time"
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+)
+
+// DefaultNamespace — пространство имён для событий и подключений, не указавших своё.
+const DefaultNamespace = "default"
+
+// ErrInvalidNamespace возвращается для некорректного имени пространства имён.
+var ErrInvalidNamespace = errors.New("invalid namespace")
+
+// ErrInvalidGroup возвращается для некорректного имени группы потребителей.
+var ErrInvalidGroup = errors.New("invalid consumer group")
+
+// ValidateGroup проверяет имя группы потребителей по тем же правилам, что и
+// имя пространства имён.
+func ValidateGroup(group string) error {
+	if !namespaceRe.MatchString(group) {
+		return ErrInvalidGroup
+	}
+	return nil
+}
+
+// ErrInvalidSubscription возвращается для некорректного имени долговременной подписки.
+var ErrInvalidSubscription = errors.New("invalid subscription name")
+
+// ValidateSubscription проверяет имя долговременной подписки по тем же
+// правилам, что и имя пространства имён.
+func ValidateSubscription(name string) error {
+	if !namespaceRe.MatchString(name) {
+		return ErrInvalidSubscription
+	}
+	return nil
+}
+
+// MaxPartitionKeyBytes — наибольшая длина ключа партиции.
+const MaxPartitionKeyBytes = 256
+
+// ErrInvalidPartitionKey возвращается для слишком длинного ключа партиции.
+var ErrInvalidPartitionKey = errors.New("invalid partition key")
+
+var namespaceRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidateNamespace проверяет имя пространства имён: латиница, цифры, "-" и "_", до 64 символов.
+func ValidateNamespace(namespace string) error {
+	if !namespaceRe.MatchString(namespace) {
+		return ErrInvalidNamespace
+	}
+	return nil
+}
 
 // Event представляет событие, генерируемое сервером и обрабатываемое клиентом.
 type Event struct {
-	ID        string    `json:"id"`
-	Type      string    `json:"type"`
-	Message   string    `json:"message"`
-	Timestamp time.Time `json:"timestamp"`
+	ID string `json:"id"`
+	// Seq — монотонный номер события, присваиваемый сервером при рассылке.
+	// По нему клиент запрашивает догрузку пропущенного после переподключения.
+	Seq       uint64 `json:"seq,omitempty"`
+	Type      string `json:"type"`
+	Version   int    `json:"version,omitempty"`   // версия схемы типа; 0 — без версии
+	Priority  int    `json:"priority,omitempty"`  // приоритет доставки; 0 — по типу события
+	Namespace string `json:"namespace,omitempty"` // пространство имён (тенант); пусто — DefaultNamespace
+	Topic     string `json:"topic,omitempty"`     // иерархический топик, например "orders.created"
+	// PartitionKey — ключ партиции, обычно ID сущности: события с одним
+	// ключом доставляются и обрабатываются в порядке номеров, даже если
+	// другие события их обгоняют по приоритету или в пуле обработки.
+	PartitionKey string `json:"partition_key,omitempty"`
+	// CorrelationID объединяет все события одной бизнес-операции,
+	// CausationID указывает ID события, непосредственно вызвавшего это.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	CausationID   string `json:"causation_id,omitempty"`
+	// Causality — векторные часы для доставки в причинном порядке; nil — без них.
+	Causality *Causality `json:"causality,omitempty"`
+	// Metadata — заголовки события (маршрутизация, трассировка и т. п.),
+	// не входящие в сообщение и полезную нагрузку.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Source — кто создал событие: "<вид>:<имя>" (см. NewSource) или
+	// значение, заданное мостом из другой системы.
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message"`
+	// Data — произвольная структурированная полезная нагрузка события (JSON).
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	// DeliverAt — время отложенной рассылки: до него сервер держит
+	// опубликованное событие в очереди; nil или прошедшее время — сразу.
+	DeliverAt *time.Time `json:"deliver_at,omitempty"`
+}
+
+// Виды источников событий, которые заполняет сервер, если издатель не
+// указал Source сам.
+const (
+	SourceGenerator = "generator" // встроенный генератор: "generator:<узел>"
+	SourceHTTP      = "http"      // POST /events: "http:<ID ключа>"
+	SourceClient    = "client"    // публикация по WebSocket: "client:<имя клиента>"
+	SourceAggregate = "aggregate" // сводное событие подписки: "aggregate:<узел>"
+	SourcePlugin    = "source"    // подключаемый источник: "source:<имя источника>"
+)
+
+// NewSource формирует значение Source "<вид>:<имя>"; без имени — только вид.
+func NewSource(kind, name string) string {
+	if name == "" {
+		return kind
+	}
+	return kind + ":" + name
+}
+
+// Уровни приоритета доставки. Допустимы и промежуточные значения.
+const (
+	PriorityLow    = 1
+	PriorityNormal = 5
+	PriorityHigh   = 10
+)
+
+// EffectivePriority возвращает приоритет доставки: явно заданный либо
+// выведенный из типа ("error" — высокий, остальные — обычный).
+func (e Event) EffectivePriority() int {
+	if e.Priority != 0 {
+		return e.Priority
+	}
+	if e.Type == "error" {
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// NamespaceOrDefault возвращает пространство имён события с учётом значения по умолчанию.
+func (e Event) NamespaceOrDefault() string {
+	if e.Namespace == "" {
+		return DefaultNamespace
+	}
+	return e.Namespace
+}
+
+// ContentHash возвращает SHA-256 (hex) от типа, сообщения и полезной нагрузки
+// события. Поля доставки (ID, Seq, время) в хэш не входят, поэтому повторно
+// отправленное под новым ID событие даёт тот же хэш. Data приводится к
+// компактному виду, чтобы пробелы в JSON не влияли на результат.
+func (e Event) ContentHash() string {
+	data := []byte(e.Data)
+	var compact bytes.Buffer
+	if len(data) > 0 && json.Compact(&compact, data) == nil {
+		data = compact.Bytes()
+	}
+	h := sha256.New()
+	h.Write([]byte(e.Type))
+	h.Write([]byte{0})
+	h.Write([]byte(e.Message))
+	h.Write([]byte{0})
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
 }
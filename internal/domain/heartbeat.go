@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// PingFrame — прикладной heartbeat. Получатель отвечает кадром "pong" с теми
+// же Nonce и SentAt и своим временем получения ReceivedAt; по ответу
+// отправитель вычисляет время оборота и расхождение часов.
+type PingFrame struct {
+	Kind       string `json:"kind"`
+	Nonce      string `json:"nonce,omitempty"`
+	SentAt     int64  `json:"sent_at,omitempty"`     // время отправки ping по часам отправителя, Unix-наносекунды
+	ReceivedAt int64  `json:"received_at,omitempty"` // время получения ping по часам ответившего, Unix-наносекунды
+}
+
+// NewPingFrame формирует ping с временем отправки now.
+func NewPingFrame(now time.Time) PingFrame {
+	return PingFrame{Kind: FrameKindPing, SentAt: now.UnixNano()}
+}
+
+// Pong формирует ответ на ping, полученный в момент now.
+func (p PingFrame) Pong(now time.Time) PingFrame {
+	return PingFrame{Kind: FrameKindPong, Nonce: p.Nonce, SentAt: p.SentAt, ReceivedAt: now.UnixNano()}
+}
+
+// Measure вычисляет по pong, полученному в момент now, время оборота и
+// смещение часов ответившего относительно локальных (положительное — его
+// часы спешат), считая, что путь в обе стороны занимает одинаковое время.
+// ok = false, если в pong нет временных меток.
+func (p PingFrame) Measure(now time.Time) (rtt, offset time.Duration, ok bool) {
+	if p.SentAt == 0 || p.ReceivedAt == 0 {
+		return 0, 0, false
+	}
+	rtt = time.Duration(now.UnixNano() - p.SentAt)
+	offset = time.Duration(p.ReceivedAt-p.SentAt) - rtt/2
+	return rtt, offset, true
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"errors"
+	"regexp"
+)
+
+// Ограничения метаданных события.
+const (
+	MaxMetadataEntries    = 32
+	MaxMetadataValueBytes = 1024
+)
+
+// ErrInvalidMetadata возвращается для метаданных, нарушающих ограничения.
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+var metadataKeyRe = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// ValidateMetadata проверяет метаданные события: не более MaxMetadataEntries
+// ключей из латиницы, цифр, ".", "-" и "_" длиной до 128 символов и значения
+// не длиннее MaxMetadataValueBytes.
+func ValidateMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxMetadataEntries {
+		return ErrInvalidMetadata
+	}
+	for key, value := range metadata {
+		if !metadataKeyRe.MatchString(key) || len(value) > MaxMetadataValueBytes {
+			return ErrInvalidMetadata
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package domain
+
+import "strings"
+
+// Версии протокола, согласуемые через подпротокол WebSocket
+// (заголовок Sec-WebSocket-Protocol).
+const (
+	// ProtocolV1 — кадры без конверта. Используется и для клиентов, не
+	// указавших подпротокол.
+	ProtocolV1 = "eventsync.v1"
+	// ProtocolV2 — кадры в конверте Envelope.
+	ProtocolV2 = "eventsync.v2"
+	// ProtocolV3 — ProtocolV2 и пачки событий FrameKindBatch, которыми
+	// сервер отправляет накопившуюся очередь отстающему клиенту.
+	ProtocolV3 = "eventsync.v3"
+)
+
+// SupportedProtocols — поддерживаемые версии протокола от новой к старой.
+var SupportedProtocols = []string{ProtocolV3, ProtocolV2, ProtocolV1}
+
+// NegotiateProtocol выбирает самую новую поддерживаемую версию из
+// предложенных клиентом подпротоколов. Клиент, не предложивший ни одной
+// версии протокола eventsync, получает ProtocolV1; ok = false, если версии
+// предложены, но ни одна не поддерживается.
+func NegotiateProtocol(offered []string) (protocol string, ok bool) {
+	versioned := false
+	for _, p := range offered {
+		if strings.HasPrefix(p, "eventsync.") {
+			versioned = true
+			break
+		}
+	}
+	if !versioned {
+		return ProtocolV1, true
+	}
+	for _, supported := range SupportedProtocols {
+		for _, p := range offered {
+			if p == supported {
+				return supported, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// Виды кадров публикации событий клиентом через WebSocket.
+const (
+	FrameKindPublish       = "publish"
+	FrameKindPublishResult = "publish_result"
+)
+
+// PublishFrame — кадр, которым клиент публикует событие через WebSocket.
+type PublishFrame struct {
+	Kind  string `json:"kind"`
+	Event Event  `json:"event"`
+}
+
+// NewPublishFrame формирует кадр публикации события.
+func NewPublishFrame(event Event) PublishFrame {
+	return PublishFrame{Kind: FrameKindPublish, Event: event}
+}
+
+// PublishResult — ответ сервера на кадр публикации.
+type PublishResult struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	Seq  uint64 `json:"seq,omitempty"` // номер, присвоенный при рассылке
+	// DeliverAt — время рассылки отложенного события; номер ему будет
+	// присвоен тогда же.
+	DeliverAt *time.Time  `json:"deliver_at,omitempty"`
+	Error     *FrameError `json:"error,omitempty"`
+}
+
+// FrameError описывает ошибку обработки кадра; коды совпадают с кодами HTTP API.
+type FrameError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity — важность события, выводимая из его типа. Порядок уровней
+// позволяет подписываться на события "не ниже warning".
+type Severity int
+
+// Уровни важности; SeverityNone — порог не задан.
+const (
+	SeverityNone Severity = iota
+	SeverityDebug
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+	SeverityCritical
+)
+
+var severityNames = map[string]Severity{
+	"debug":    SeverityDebug,
+	"info":     SeverityInfo,
+	"warning":  SeverityWarning,
+	"error":    SeverityError,
+	"critical": SeverityCritical,
+}
+
+// ParseSeverity разбирает название уровня; пустая строка — SeverityNone.
+func ParseSeverity(s string) (Severity, error) {
+	if s == "" {
+		return SeverityNone, nil
+	}
+	if sev, ok := severityNames[strings.ToLower(s)]; ok {
+		return sev, nil
+	}
+	return SeverityNone, fmt.Errorf("unknown severity %q", s)
+}
+
+func (s Severity) String() string {
+	for name, sev := range severityNames {
+		if sev == s {
+			return name
+		}
+	}
+	return "none"
+}
+
+// SeverityMap задаёт важность пользовательских типов событий. Ключ — тип
+// или шаблон "префикс*" ("payment.*" — все типы, начинающиеся с "payment.").
+type SeverityMap map[string]Severity
+
+// ParseSeverityMap разбирает правила вида тип -> название уровня.
+func ParseSeverityMap(rules map[string]string) (SeverityMap, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	m := make(SeverityMap, len(rules))
+	for eventType, name := range rules {
+		sev, err := ParseSeverity(name)
+		if err != nil {
+			return nil, fmt.Errorf("severity of %q: %w", eventType, err)
+		}
+		if sev == SeverityNone {
+			return nil, fmt.Errorf("severity of %q is empty", eventType)
+		}
+		m[eventType] = sev
+	}
+	return m, nil
+}
+
+// Of возвращает важность типа события: по точному правилу, по самому
+// длинному совпавшему шаблону, по названию уровня в самом типе ("error"),
+// иначе SeverityInfo.
+func (m SeverityMap) Of(eventType string) Severity {
+	if sev, ok := m[eventType]; ok {
+		return sev
+	}
+	best, bestLen := SeverityNone, -1
+	for pattern, sev := range m {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && len(prefix) > bestLen && strings.HasPrefix(eventType, prefix) {
+			best, bestLen = sev, len(prefix)
+		}
+	}
+	if best != SeverityNone {
+		return best
+	}
+	if sev, ok := severityNames[eventType]; ok {
+		return sev
+	}
+	return SeverityInfo
+}
+
+// Allows сообщает, проходит ли тип события порог min.
+func (m SeverityMap) Allows(eventType string, min Severity) bool {
+	return min == SeverityNone || m.Of(eventType) >= min
+}
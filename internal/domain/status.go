@@ -0,0 +1,16 @@
+package domain
+
+// FrameKindStatus — значение поля kind у кадра состояния клиента.
+const FrameKindStatus = "status"
+
+// ClientStatus — кадр, которым клиент периодически сообщает серверу
+// состояние синхронизации.
+type ClientStatus struct {
+	Kind               string `json:"kind"`
+	ClientID           string `json:"client_id,omitempty"` // имя экземпляра клиента, например "client-1"
+	LastSeq            uint64 `json:"last_seq"`            // наибольший сохранённый номер события
+	EventsReceived     uint64 `json:"events_received"`
+	DuplicatesFiltered uint64 `json:"duplicates_filtered"`
+	SaveErrors         uint64 `json:"save_errors"`
+	QueueDepth         int    `json:"queue_depth"` // события, ожидающие обработки
+}
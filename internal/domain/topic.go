@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+// Топики состоят из сегментов, разделённых точкой: "orders.created", "system.error".
+// В подписках допускаются шаблоны:
+//   - "*" совпадает ровно с одним сегментом ("orders.*" -> "orders.created");
+//   - "#" допустим только последним сегментом и совпадает с любым хвостом,
+//     включая пустой ("orders.#" -> "orders", "orders.eu.created").
+const (
+	TopicSeparator     = "."
+	TopicWildcardOne   = "*"
+	TopicWildcardMulti = "#"
+)
+
+// ErrInvalidTopic возвращается для синтаксически некорректных топиков и шаблонов.
+var ErrInvalidTopic = errors.New("invalid topic")
+
+// SplitTopic разбивает топик на сегменты.
+func SplitTopic(topic string) []string {
+	return strings.Split(topic, TopicSeparator)
+}
+
+// ValidateTopic проверяет топик события: непустые сегменты без символов шаблонов.
+func ValidateTopic(topic string) error {
+	for _, seg := range SplitTopic(topic) {
+		if seg == "" || strings.ContainsAny(seg, TopicWildcardOne+TopicWildcardMulti) {
+			return ErrInvalidTopic
+		}
+	}
+	return nil
+}
+
+// ValidateTopicPattern проверяет шаблон подписки.
+func ValidateTopicPattern(pattern string) error {
+	segs := SplitTopic(pattern)
+	for i, seg := range segs {
+		switch {
+		case seg == "":
+			return ErrInvalidTopic
+		case seg == TopicWildcardMulti && i != len(segs)-1:
+			return ErrInvalidTopic
+		case seg != TopicWildcardOne && seg != TopicWildcardMulti &&
+			strings.ContainsAny(seg, TopicWildcardOne+TopicWildcardMulti):
+			return ErrInvalidTopic
+		}
+	}
+	return nil
+}
+
+// MatchTopic сообщает, совпадает ли топик с шаблоном подписки.
+func MatchTopic(pattern, topic string) bool {
+	ps, ts := SplitTopic(pattern), SplitTopic(topic)
+	for i, p := range ps {
+		if p == TopicWildcardMulti {
+			return true
+		}
+		if i >= len(ts) {
+			return false
+		}
+		if p != TopicWildcardOne && p != ts[i] {
+			return false
+		}
+	}
+	return len(ps) == len(ts)
+}
+
+// PatternCovers сообщает, что каждый топик, подходящий под pattern,
+// подходит и под allowed, то есть подписка pattern не шире allowed.
+func PatternCovers(allowed, pattern string) bool {
+	as, ps := SplitTopic(allowed), SplitTopic(pattern)
+	for i, a := range as {
+		if a == TopicWildcardMulti {
+			return true
+		}
+		if i >= len(ps) || ps[i] == TopicWildcardMulti {
+			return false
+		}
+		if a != TopicWildcardOne && a != ps[i] {
+			return false
+		}
+	}
+	return len(as) == len(ps)
+}
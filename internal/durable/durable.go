@@ -0,0 +1,395 @@
+// Package durable хранит именованные долговременные подписки: фильтр
+// подписки, смещение (наибольший подтверждённый номер события) и журнал
+// событий, отправленных подписке, но ещё не подтверждённых ею. Процесс,
+// подключившийся под тем же именем, получает журнал и продолжает с места,
+// где остановился предыдущий, независимо от своего локального хранилища.
+package durable
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Бакеты файла. Записи подписок — по ключу "<пространство имён>\x00<имя>".
+// Журнал каждой подписки — вложенный бакет с тем же ключом в bucketBacklog
+// (номер события big-endian -> событие) и в bucketIDs (ID события -> номер).
+var (
+	bucketSubscriptions = []byte("subscriptions")
+	bucketBacklog       = []byte("backlog")
+	bucketIDs           = []byte("backlog_ids")
+	bucketMeta          = []byte("meta")
+	keyMaxSeq           = []byte("max_seq")
+)
+
+// DefaultMaxBacklog — вместимость журнала подписки по умолчанию.
+const DefaultMaxBacklog = 100000
+
+// Record — долговременная подписка.
+type Record struct {
+	Namespace   string    `json:"namespace"`
+	Name        string    `json:"name"`
+	Topics      []string  `json:"topics,omitempty"`
+	Types       []string  `json:"types,omitempty"`
+	MinSeverity string    `json:"min_severity,omitempty"`
+	Offset      uint64    `json:"offset"` // наибольший подтверждённый номер события
+	CreatedAt   time.Time `json:"created_at"`
+	// Backlog — число неподтверждённых событий в журнале; заполняется Records.
+	Backlog int `json:"backlog"`
+}
+
+// op — операция фоновой записи.
+type op struct {
+	key   string
+	event *domain.Event // добавить событие в журнал
+	ack   *domain.Ack   // удалить подтверждённые события
+	done  chan struct{} // закрывается после записи всех предыдущих операций
+}
+
+// Store — файл долговременных подписок (BoltDB). События и подтверждения
+// записываются фоновой горутиной пачками, чтобы рассылка не ждала диска.
+type Store struct {
+	db         *bolt.DB
+	maxBacklog int
+	logger     *slog.Logger
+	ops        chan op
+	stopped    chan struct{}
+	closeOnce  sync.Once
+
+	mu      sync.Mutex
+	backlog map[string]int // число событий в журналах; только горутина записи меняет
+	dropped map[string]int // вытеснено из переполненных журналов с момента запуска
+}
+
+// Open открывает (или создаёт) файл подписок; maxBacklog <= 0 —
+// DefaultMaxBacklog. При переполнении журнала вытесняются самые старые события.
+func Open(path string, maxBacklog int, logger *slog.Logger) (*Store, error) {
+	if maxBacklog <= 0 {
+		maxBacklog = DefaultMaxBacklog
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		db:         db,
+		maxBacklog: maxBacklog,
+		logger:     logger,
+		ops:        make(chan op, 4096),
+		stopped:    make(chan struct{}),
+		backlog:    make(map[string]int),
+		dropped:    make(map[string]int),
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketSubscriptions, bucketBacklog, bucketIDs, bucketMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(bucketBacklog).ForEachBucket(func(k []byte) error {
+			s.backlog[string(k)] = tx.Bucket(bucketBacklog).Bucket(k).Stats().KeyN
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
+
+func subKey(namespace, name string) string {
+	return namespace + "\x00" + name
+}
+
+// Records возвращает все подписки пространства имён; пусто — всех.
+func (s *Store) Records(namespace string) ([]Record, error) {
+	records := []Record{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSubscriptions).ForEach(func(k, v []byte) error {
+			var rec Record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if namespace == "" || rec.Namespace == namespace {
+				records = append(records, rec)
+			}
+			return nil
+		})
+	})
+	s.mu.Lock()
+	for i := range records {
+		records[i].Backlog = s.backlog[subKey(records[i].Namespace, records[i].Name)]
+	}
+	s.mu.Unlock()
+	return records, err
+}
+
+// Put сохраняет фильтр подписки и возвращает сохранённую запись; смещение
+// и время создания существующей записи сохраняются.
+func (s *Store) Put(rec Record) (Record, error) {
+	s.Flush()
+	key := []byte(subKey(rec.Namespace, rec.Name))
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketSubscriptions)
+		if raw := b.Get(key); raw != nil {
+			var cur Record
+			if err := json.Unmarshal(raw, &cur); err != nil {
+				return err
+			}
+			rec.Offset, rec.CreatedAt = cur.Offset, cur.CreatedAt
+		}
+		if rec.CreatedAt.IsZero() {
+			rec.CreatedAt = time.Now()
+		}
+		rec.Backlog = 0
+		raw, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, raw)
+	})
+	return rec, err
+}
+
+// Delete удаляет подписку и её журнал и сообщает, была ли она.
+func (s *Store) Delete(namespace, name string) (bool, error) {
+	s.Flush()
+	key := subKey(namespace, name)
+	found := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketSubscriptions).Get([]byte(key)) == nil {
+			return nil
+		}
+		found = true
+		if err := tx.Bucket(bucketSubscriptions).Delete([]byte(key)); err != nil {
+			return err
+		}
+		for _, name := range [][]byte{bucketBacklog, bucketIDs} {
+			if err := tx.Bucket(name).DeleteBucket([]byte(key)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil && found {
+		s.mu.Lock()
+		delete(s.backlog, key)
+		delete(s.dropped, key)
+		s.mu.Unlock()
+	}
+	return found, err
+}
+
+// Append добавляет событие в журнал подписки; запись асинхронная.
+func (s *Store) Append(namespace, name string, event domain.Event) {
+	s.ops <- op{key: subKey(namespace, name), event: &event}
+}
+
+// Ack удаляет из журнала подтверждённые события и поднимает смещение;
+// запись асинхронная.
+func (s *Store) Ack(namespace, name string, ack domain.Ack) {
+	s.ops <- op{key: subKey(namespace, name), ack: &ack}
+}
+
+// Flush дожидается записи всех поставленных ранее операций.
+func (s *Store) Flush() {
+	done := make(chan struct{})
+	select {
+	case s.ops <- op{done: done}:
+		<-done
+	case <-s.stopped:
+	}
+}
+
+// Backlog возвращает неподтверждённые события подписки в порядке номеров.
+func (s *Store) Backlog(namespace, name string) ([]domain.Event, error) {
+	s.Flush()
+	var events []domain.Event
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketBacklog).Bucket([]byte(subKey(namespace, name)))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var event domain.Event
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			events = append(events, event)
+			return nil
+		})
+	})
+	return events, err
+}
+
+// MaxSeq возвращает наибольший номер события, попавшего в журналы. Сервер
+// продолжает нумерацию после него, чтобы номера не повторялись после
+// перезапуска.
+func (s *Store) MaxSeq() uint64 {
+	var seq uint64
+	s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketMeta).Get(keyMaxSeq); len(v) == 8 {
+			seq = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	return seq
+}
+
+// Close записывает поставленные операции и закрывает файл. Вызывается,
+// когда рассылка уже остановлена.
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() { close(s.ops) })
+	<-s.stopped
+	return s.db.Close()
+}
+
+// run записывает операции пачками: каждая пачка — одна транзакция.
+func (s *Store) run() {
+	defer close(s.stopped)
+	for first := range s.ops {
+		batch := []op{first}
+	drain:
+		for len(batch) < 1024 {
+			select {
+			case next, ok := <-s.ops:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+		if err := s.db.Update(func(tx *bolt.Tx) error { return s.apply(tx, batch) }); err != nil {
+			s.logger.Error("Durable subscription write error", "error", err)
+		}
+		for _, o := range batch {
+			if o.done != nil {
+				close(o.done)
+			}
+		}
+	}
+}
+
+func (s *Store) apply(tx *bolt.Tx, batch []op) error {
+	var maxSeq uint64
+	for _, o := range batch {
+		switch {
+		case o.event != nil:
+			if err := s.appendEvent(tx, o.key, *o.event); err != nil {
+				return err
+			}
+			maxSeq = max(maxSeq, o.event.Seq)
+		case o.ack != nil:
+			if err := s.ackEvents(tx, o.key, *o.ack); err != nil {
+				return err
+			}
+		}
+	}
+	if maxSeq == 0 {
+		return nil
+	}
+	meta := tx.Bucket(bucketMeta)
+	if v := meta.Get(keyMaxSeq); len(v) == 8 && binary.BigEndian.Uint64(v) >= maxSeq {
+		return nil
+	}
+	return meta.Put(keyMaxSeq, binary.BigEndian.AppendUint64(nil, maxSeq))
+}
+
+func (s *Store) appendEvent(tx *bolt.Tx, key string, event domain.Event) error {
+	events, err := tx.Bucket(bucketBacklog).CreateBucketIfNotExists([]byte(key))
+	if err != nil {
+		return err
+	}
+	ids, err := tx.Bucket(bucketIDs).CreateBucketIfNotExists([]byte(key))
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	seqKey := binary.BigEndian.AppendUint64(nil, event.Seq)
+	if err := events.Put(seqKey, raw); err != nil {
+		return err
+	}
+	if err := ids.Put([]byte(event.ID), seqKey); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.backlog[key]++
+	full := s.backlog[key] > s.maxBacklog
+	s.mu.Unlock()
+	if !full {
+		return nil
+	}
+	// Журнал переполнен: вытесняется самое старое событие.
+	k, v := events.Cursor().First()
+	var oldest domain.Event
+	if err := json.Unmarshal(v, &oldest); err == nil {
+		if err := ids.Delete([]byte(oldest.ID)); err != nil {
+			return err
+		}
+	}
+	if err := events.Delete(k); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.backlog[key]--
+	s.dropped[key]++
+	dropped := s.dropped[key]
+	s.mu.Unlock()
+	if dropped == 1 || dropped%1000 == 0 {
+		s.logger.Warn("Durable subscription backlog full, oldest events dropped", "subscription", key, "dropped", dropped)
+	}
+	return nil
+}
+
+func (s *Store) ackEvents(tx *bolt.Tx, key string, ack domain.Ack) error {
+	if events, ids := tx.Bucket(bucketBacklog).Bucket([]byte(key)), tx.Bucket(bucketIDs).Bucket([]byte(key)); events != nil && ids != nil {
+		removed := 0
+		for _, id := range ack.IDs {
+			seqKey := ids.Get([]byte(id))
+			if seqKey == nil {
+				continue
+			}
+			if err := events.Delete(seqKey); err != nil {
+				return err
+			}
+			if err := ids.Delete([]byte(id)); err != nil {
+				return err
+			}
+			removed++
+		}
+		s.mu.Lock()
+		s.backlog[key] -= removed
+		s.mu.Unlock()
+	}
+	b := tx.Bucket(bucketSubscriptions)
+	raw := b.Get([]byte(key))
+	if raw == nil {
+		return nil
+	}
+	var rec Record
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return err
+	}
+	if ack.Seq <= rec.Offset {
+		return nil
+	}
+	rec.Offset = ack.Seq
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), raw)
+}
@@ -0,0 +1,116 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Поддерживаемые форматы выгрузки.
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// EventWriter последовательно записывает события в выбранном формате.
+// Close дописывает завершающие данные формата и должен вызываться всегда.
+type EventWriter interface {
+	Write(event domain.Event) error
+	Close() error
+}
+
+// NewWriter создаёт писателя событий для формата.
+func NewWriter(format string, w io.Writer) (EventWriter, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	case FormatJSON:
+		return &jsonArrayWriter{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+var csvHeader = []string{"id", "seq", "type", "timestamp", "message", "data", "correlation_id", "causation_id", "metadata", "source"}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Write(e domain.Event) error {
+	var metadata string
+	if len(e.Metadata) > 0 {
+		data, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return err
+		}
+		metadata = string(data)
+	}
+	return c.w.Write([]string{
+		e.ID,
+		strconv.FormatUint(e.Seq, 10),
+		e.Type,
+		e.Timestamp.UTC().Format(time.RFC3339Nano),
+		e.Message,
+		string(e.Data),
+		e.CorrelationID,
+		e.CausationID,
+		metadata,
+		e.Source,
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonArrayWriter пишет события единым JSON-массивом, не буферизуя выборку.
+type jsonArrayWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonArrayWriter) Write(e domain.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if j.count == 0 {
+		sep = "[\n  "
+	}
+	j.count++
+	if _, err := io.WriteString(j.w, sep); err != nil {
+		return err
+	}
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonArrayWriter) Close() error {
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(j.w, end)
+	return err
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(e domain.Event) error { return n.enc.Encode(e) }
+func (n *ndjsonWriter) Close() error               { return nil }
@@ -0,0 +1,312 @@
+// Package guard ограничивает частоту подключений с одного адреса и
+// временно блокирует адреса, с которых повторяются неудачные попытки
+// аутентификации и установки WebSocket-соединения.
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ошибки отказа в подключении, для errors.Is.
+var (
+	ErrBanned      = errors.New("address is banned")
+	ErrRateLimited = errors.New("too many handshakes")
+)
+
+// RejectedError описывает отказ: блокировку адреса или превышение частоты.
+type RejectedError struct {
+	Addr       string
+	Reason     error
+	RetryAfter time.Duration // 0 — постоянная блокировка
+}
+
+func (e *RejectedError) Error() string {
+	if e.RetryAfter == 0 {
+		return fmt.Sprintf("%s: %v", e.Addr, e.Reason)
+	}
+	return fmt.Sprintf("%s: %v, retry after %s", e.Addr, e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RejectedError) Unwrap() error { return e.Reason }
+
+// Policy задаёт ограничения; нулевые значения заменяются значениями по умолчанию,
+// кроме Rate: 0 — частота подключений не ограничивается.
+type Policy struct {
+	Rate        float64       // допустимое число подключений в секунду с адреса
+	Burst       int           // запас подключений сверх Rate; 0 — max(1, Rate)
+	MaxFailures int           // неудачных попыток за Window до блокировки; 0 — 10
+	Window      time.Duration // окно подсчёта неудач; 0 — 1 минута
+	BanDuration time.Duration // первая блокировка; повторные удваиваются; 0 — 5 минут
+	MaxBan      time.Duration // предел удвоения блокировки; 0 — 24 часа
+	Deny        []string      // адреса и подсети, запрещённые всегда
+	Allow       []string      // адреса и подсети, на которые ограничения не действуют
+	// TrustedProxies — подсети обратных прокси: для запросов от них адрес
+	// клиента берётся из X-Forwarded-For.
+	TrustedProxies []string
+}
+
+// Ban — текущая блокировка адреса.
+type Ban struct {
+	Addr     string     `json:"addr"`
+	Until    *time.Time `json:"until,omitempty"` // nil — постоянная (Deny)
+	Failures int        `json:"failures"`        // неудачных попыток в текущем окне
+	Bans     int        `json:"bans"`            // число блокировок адреса подряд
+}
+
+type state struct {
+	// Токены частоты подключений.
+	tokens   float64
+	refilled time.Time
+	// Неудачи в текущем окне и блокировка.
+	windowStart time.Time
+	failures    int
+	bannedUntil time.Time
+	bans        int
+	lastSeen    time.Time
+}
+
+// Guard учитывает подключения и неудачи по адресам клиентов.
+type Guard struct {
+	policy  Policy
+	deny    []netip.Prefix
+	allow   []netip.Prefix
+	proxies []netip.Prefix
+	logger  *slog.Logger
+
+	mu        sync.Mutex
+	addrs     map[string]*state
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New создаёт Guard с политикой policy; ошибка — при неверных подсетях.
+func New(policy Policy, logger *slog.Logger) (*Guard, error) {
+	if policy.Burst <= 0 {
+		policy.Burst = max(1, int(policy.Rate))
+	}
+	if policy.MaxFailures <= 0 {
+		policy.MaxFailures = 10
+	}
+	if policy.Window <= 0 {
+		policy.Window = time.Minute
+	}
+	if policy.BanDuration <= 0 {
+		policy.BanDuration = 5 * time.Minute
+	}
+	if policy.MaxBan <= 0 {
+		policy.MaxBan = 24 * time.Hour
+	}
+	g := &Guard{policy: policy, logger: logger, addrs: make(map[string]*state), now: time.Now}
+	var err error
+	if g.deny, err = parsePrefixes(policy.Deny); err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+	if g.allow, err = parsePrefixes(policy.Allow); err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	if g.proxies, err = parsePrefixes(policy.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted_proxies: %w", err)
+	}
+	return g, nil
+}
+
+// parsePrefixes разбирает адреса и подсети CIDR.
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientAddr возвращает адрес клиента запроса. За доверенным прокси
+// берётся ближайший к серверу недоверенный адрес из X-Forwarded-For.
+func (g *Guard) ClientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+	if !contains(g.proxies, addr) {
+		return addr.String()
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !contains(g.proxies, addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+// Check возвращает *RejectedError, если адрес заблокирован.
+func (g *Guard) Check(addr string) error {
+	return g.check(addr, false)
+}
+
+// Handshake проверяет блокировку адреса и расходует токен частоты
+// подключений. Возвращает *RejectedError при блокировке или превышении.
+func (g *Guard) Handshake(addr string) error {
+	return g.check(addr, true)
+}
+
+func (g *Guard) check(addr string, handshake bool) error {
+	ip, parseErr := netip.ParseAddr(addr)
+	if parseErr == nil {
+		if contains(g.allow, ip) {
+			return nil
+		}
+		if contains(g.deny, ip) {
+			return &RejectedError{Addr: addr, Reason: ErrBanned}
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	st := g.stateLocked(addr, now)
+	if now.Before(st.bannedUntil) {
+		return &RejectedError{Addr: addr, Reason: ErrBanned, RetryAfter: st.bannedUntil.Sub(now)}
+	}
+	if !handshake || g.policy.Rate <= 0 {
+		return nil
+	}
+	st.tokens = min(float64(g.policy.Burst), st.tokens+now.Sub(st.refilled).Seconds()*g.policy.Rate)
+	st.refilled = now
+	if st.tokens < 1 {
+		wait := time.Duration((1 - st.tokens) / g.policy.Rate * float64(time.Second))
+		return &RejectedError{Addr: addr, Reason: ErrRateLimited, RetryAfter: wait}
+	}
+	st.tokens--
+	return nil
+}
+
+// Fail учитывает неудачную попытку с адреса. Если число неудач за окно
+// достигло MaxFailures, адрес блокируется; срок каждой следующей блокировки
+// подряд удваивается.
+func (g *Guard) Fail(addr, reason string) {
+	if ip, err := netip.ParseAddr(addr); err == nil && contains(g.allow, ip) {
+		return
+	}
+	g.mu.Lock()
+	banned, bans := g.failLocked(addr)
+	g.mu.Unlock()
+	if banned > 0 {
+		g.logger.Warn("Banning address after repeated failures", "addr", addr, "duration", banned, "bans", bans, "reason", reason)
+	}
+}
+
+func (g *Guard) failLocked(addr string) (banned time.Duration, bans int) {
+	now := g.now()
+	st := g.stateLocked(addr, now)
+	if now.Sub(st.windowStart) > g.policy.Window {
+		st.windowStart = now
+		st.failures = 0
+	}
+	st.failures++
+	if st.failures < g.policy.MaxFailures || now.Before(st.bannedUntil) {
+		return 0, st.bans
+	}
+	banned = g.policy.BanDuration
+	for i := 0; i < st.bans && banned < g.policy.MaxBan; i++ {
+		banned *= 2
+	}
+	banned = min(banned, g.policy.MaxBan)
+	st.bans++
+	st.failures = 0
+	st.bannedUntil = now.Add(banned)
+	return banned, st.bans
+}
+
+// stateLocked возвращает состояние адреса, заодно удаляя давно не
+// встречавшиеся адреса без действующей блокировки.
+func (g *Guard) stateLocked(addr string, now time.Time) *state {
+	if now.Sub(g.lastSweep) > g.policy.Window {
+		g.lastSweep = now
+		for a, st := range g.addrs {
+			// Счётчик повторных блокировок сбрасывается, если адрес
+			// не встречался дольше максимальной блокировки.
+			if now.After(st.bannedUntil) && now.Sub(st.lastSeen) > max(g.policy.Window, g.policy.MaxBan) {
+				delete(g.addrs, a)
+			}
+		}
+	}
+	st, ok := g.addrs[addr]
+	if !ok {
+		st = &state{tokens: float64(g.policy.Burst), refilled: now, windowStart: now}
+		g.addrs[addr] = st
+	}
+	st.lastSeen = now
+	return st
+}
+
+// Bans возвращает действующие блокировки, включая постоянные из Deny.
+func (g *Guard) Bans() []Ban {
+	g.mu.Lock()
+	now := g.now()
+	var bans []Ban
+	for addr, st := range g.addrs {
+		if now.Before(st.bannedUntil) {
+			until := st.bannedUntil
+			bans = append(bans, Ban{Addr: addr, Until: &until, Failures: st.failures, Bans: st.bans})
+		}
+	}
+	g.mu.Unlock()
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until.Before(*bans[j].Until) })
+	for _, p := range g.deny {
+		bans = append(bans, Ban{Addr: p.String()})
+	}
+	return bans
+}
+
+// Unban снимает временную блокировку адреса и сбрасывает его счётчики.
+// Возвращает false, если адрес не был заблокирован.
+func (g *Guard) Unban(addr string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st, ok := g.addrs[addr]
+	if !ok || !g.now().Before(st.bannedUntil) {
+		return false
+	}
+	delete(g.addrs, addr)
+	return true
+}
@@ -0,0 +1,193 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// maxFileLine ограничивает длину строки файла истории.
+const maxFileLine = 4 << 20
+
+// FileStore — история в файле NDJSON: одно событие на строку, запись только
+// в конец. Range читает файл целиком, поэтому хранилище подходит для
+// демонстраций и небольших объёмов.
+type FileStore struct {
+	path string
+
+	mu      sync.Mutex
+	f       *os.File
+	lastSeq uint64
+	stored  storedBytes
+}
+
+// OpenFile открывает (или создаёт) файл истории.
+func OpenFile(path string) (*FileStore, error) {
+	s := &FileStore{path: path, stored: make(storedBytes)}
+	err := s.scan(func(event domain.Event, size int) {
+		s.lastSeq = max(s.lastSeq, event.Seq)
+		s.stored.add(event, size)
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if s.f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// scan читает события файла по порядку вместе с размером их строк.
+func (s *FileStore) scan(fn func(domain.Event, int)) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), maxFileLine)
+	for scanner.Scan() {
+		var event domain.Event
+		// Недописанная при аварии последняя строка пропускается.
+		if json.Unmarshal(scanner.Bytes(), &event) == nil {
+			fn(event, len(scanner.Bytes()))
+		}
+	}
+	return scanner.Err()
+}
+
+// Append дописывает событие в файл.
+func (s *FileStore) Append(event domain.Event) error {
+	return s.AppendBatch([]domain.Event{event})
+}
+
+// AppendBatch дописывает события в файл одной записью.
+func (s *FileStore) AppendBatch(events []domain.Event) error {
+	var buf []byte
+	sizes := make([]int, len(events))
+	for i, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+		sizes[i] = len(line)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(buf); err != nil {
+		return err
+	}
+	for i, event := range events {
+		s.lastSeq = max(s.lastSeq, event.Seq)
+		s.stored.add(event, sizes[i])
+	}
+	return nil
+}
+
+// Range возвращает подходящие под запрос события в порядке номеров.
+func (s *FileStore) Range(q Query) ([]domain.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []domain.Event
+	err := s.scan(func(event domain.Event, _ int) {
+		if q.Matches(event) {
+			events = append(events, event)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	if q.Limit > 0 && len(events) > q.Limit {
+		events = events[:q.Limit]
+	}
+	return events, nil
+}
+
+// StoredBytes возвращает объём событий в файле по пространствам имён.
+func (s *FileStore) StoredBytes() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stored.clone()
+}
+
+// LastSeq возвращает наибольший номер события в файле.
+func (s *FileStore) LastSeq() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSeq, nil
+}
+
+// Prune переписывает файл без событий с Timestamp раньше before.
+func (s *FileStore) Prune(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rewrite(func(event domain.Event) bool { return event.Timestamp.Before(before) })
+}
+
+// Compact переписывает файл, оставляя только последнее событие каждого
+// ключа партиции.
+func (s *FileStore) Compact(types []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	latest := make(latestSeqs)
+	if err := s.scan(func(event domain.Event, _ int) { latest.add(event, types) }); err != nil {
+		return 0, err
+	}
+	return s.rewrite(func(event domain.Event) bool { return latest.superseded(event, types) })
+}
+
+// rewrite переписывает файл без событий, для которых drop возвращает true;
+// вызывается под блокировкой.
+func (s *FileStore) rewrite(drop func(domain.Event) bool) (int, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".history-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	removed := 0
+	stored := make(storedBytes)
+	err = s.scan(func(event domain.Event, _ int) {
+		if drop(event) {
+			removed++
+			return
+		}
+		line, _ := json.Marshal(event)
+		w.Write(append(line, '\n'))
+		stored.add(event, len(line))
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || removed == 0 {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return 0, err
+	}
+	s.f.Close()
+	s.stored = stored
+	if s.f, err = os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// Close закрывает файл.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
@@ -0,0 +1,169 @@
+// Package history хранит разосланные сервером события, чтобы их можно было
+// повторно выдать за диапазон номеров или времени, например для
+// восстановления повреждённого хранилища клиента. Хранилище выбирается
+// конфигурацией: Ring в памяти, SQLite, PostgreSQL или файл NDJSON.
+package history
+
+import (
+	"context"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// ServerEventStore — хранилище истории событий сервера.
+type ServerEventStore interface {
+	// Append сохраняет разосланное событие.
+	Append(event domain.Event) error
+	// Range возвращает подходящие под запрос события в порядке номеров.
+	Range(q Query) ([]domain.Event, error)
+	// LastSeq возвращает наибольший сохранённый номер события; 0 — история пуста.
+	LastSeq() (uint64, error)
+	// Prune удаляет события с Timestamp раньше before и возвращает их число.
+	Prune(before time.Time) (int, error)
+}
+
+// Compactor — хранилище, умеющее уплотнение журнала: из событий с ключом
+// партиции, описывающих состояние сущности, остаётся только последнее
+// событие каждого ключа.
+type Compactor interface {
+	// Compact удаляет события с ключом партиции, для которых есть событие
+	// того же пространства имён и ключа с большим номером, и возвращает их
+	// число. types ограничивает уплотнение этими типами событий; пусто — все
+	// события с ключом.
+	Compact(types []string) (int, error)
+}
+
+// StoredBytesReporter — хранилище, знающее объём хранимых событий по
+// пространствам имён. Recorder передаёт его в RecorderOptions.OnStored
+// после каждого изменения истории, поэтому вызов должен быть дешёвым.
+type StoredBytesReporter interface {
+	StoredBytes() map[string]int64
+}
+
+// storedBytes — объём событий по пространствам имён.
+type storedBytes map[string]int64
+
+func (s storedBytes) add(event domain.Event, size int) {
+	s[event.NamespaceOrDefault()] += int64(size)
+}
+
+func (s storedBytes) clone() map[string]int64 {
+	out := make(map[string]int64, len(s))
+	for ns, n := range s {
+		if n > 0 {
+			out[ns] = n
+		}
+	}
+	return out
+}
+
+// BatchAppender — хранилище, сохраняющее пачку событий за одну операцию;
+// Recorder пользуется им, если хранилище его реализует.
+type BatchAppender interface {
+	AppendBatch(events []domain.Event) error
+}
+
+// Query отбирает события истории. Нулевые границы не ограничивают выборку.
+type Query struct {
+	Namespace string
+	FromSeq   uint64    // наименьший номер, включительно
+	ToSeq     uint64    // наибольший номер, включительно
+	Since     time.Time // Timestamp не раньше, включительно
+	Until     time.Time // Timestamp раньше, не включительно
+	Topics    []string  // шаблоны топиков; пусто — любые
+	Types     []string  // типы событий; пусто — любые
+	Limit     int       // наибольшее число событий; 0 — без ограничения
+}
+
+// Matches сообщает, подходит ли событие под запрос.
+func (q Query) Matches(event domain.Event) bool {
+	switch {
+	case q.Namespace != "" && event.NamespaceOrDefault() != q.Namespace,
+		q.FromSeq > 0 && event.Seq < q.FromSeq,
+		q.ToSeq > 0 && event.Seq > q.ToSeq,
+		!q.Since.IsZero() && event.Timestamp.Before(q.Since),
+		!q.Until.IsZero() && !event.Timestamp.Before(q.Until):
+		return false
+	}
+	if len(q.Types) > 0 && !contains(q.Types, event.Type) {
+		return false
+	}
+	if len(q.Topics) == 0 {
+		return true
+	}
+	for _, pattern := range q.Topics {
+		if domain.MatchTopic(pattern, event.Topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// entityKey — ключ уплотнения: пространство имён и ключ партиции.
+type entityKey struct {
+	namespace, partitionKey string
+}
+
+// compactable сообщает, участвует ли событие в уплотнении по types.
+func compactable(event domain.Event, types []string) bool {
+	return event.PartitionKey != "" && (len(types) == 0 || contains(types, event.Type))
+}
+
+// latestSeqs — наибольший номер события каждого ключа уплотнения.
+type latestSeqs map[entityKey]uint64
+
+// add учитывает событие, если оно участвует в уплотнении по types.
+func (l latestSeqs) add(event domain.Event, types []string) {
+	if compactable(event, types) {
+		key := entityKey{event.NamespaceOrDefault(), event.PartitionKey}
+		l[key] = max(l[key], event.Seq)
+	}
+}
+
+// superseded сообщает, вытеснено ли событие более новым событием его ключа.
+func (l latestSeqs) superseded(event domain.Event, types []string) bool {
+	return compactable(event, types) && event.Seq < l[entityKey{event.NamespaceOrDefault(), event.PartitionKey}]
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Pace передаёт события fn с паузами, равными промежуткам между их
+// Timestamp, делёнными на speed: 2 — вдвое быстрее, 0 — без пауз. maxGap,
+// если положителен, ограничивает одну паузу. Останавливается на первой
+// ошибке fn или отмене ctx и возвращает число переданных событий.
+func Pace(ctx context.Context, events []domain.Event, speed float64, maxGap time.Duration, fn func(domain.Event) error) (int, error) {
+	var prev time.Time
+	for i, event := range events {
+		if speed > 0 && !prev.IsZero() && event.Timestamp.After(prev) {
+			gap := time.Duration(float64(event.Timestamp.Sub(prev)) / speed)
+			if maxGap > 0 && gap > maxGap {
+				gap = maxGap
+			}
+			timer := time.NewTimer(gap)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return i, ctx.Err()
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if err := fn(event); err != nil {
+			return i, err
+		}
+		if event.Timestamp.After(prev) {
+			prev = event.Timestamp
+		}
+	}
+	return len(events), nil
+}
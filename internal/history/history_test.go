@@ -0,0 +1,187 @@
+package history
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// store — хранилище истории со всеми возможностями, которые проверяет тест.
+type store interface {
+	ServerEventStore
+	Compactor
+	StoredBytesReporter
+}
+
+// storeCase открывает хранилище по пути path. Постоянное хранилище
+// открывается заново после закрытия, чтобы проверить, что история
+// переживает перезапуск.
+type storeCase struct {
+	name       string
+	open       func(path string) (store, error)
+	persistent bool
+}
+
+var storeCases = []storeCase{
+	{name: "ring", open: func(string) (store, error) { return NewRing(16), nil }},
+	{name: "sqlite", open: func(path string) (store, error) { return OpenSQLite(path) }, persistent: true},
+	{name: "file", open: func(path string) (store, error) { return OpenFile(path) }, persistent: true},
+}
+
+// openStore открывает хранилище и закрывает его по завершении теста.
+func openStore(t *testing.T, tc storeCase, path string) store {
+	t.Helper()
+	s, err := tc.open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := s.(io.Closer); ok {
+		t.Cleanup(func() { c.Close() })
+	}
+	return s
+}
+
+// seqs возвращает номера событий.
+func seqs(events []domain.Event) []uint64 {
+	out := []uint64{}
+	for _, event := range events {
+		out = append(out, event.Seq)
+	}
+	return out
+}
+
+func TestStores(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []domain.Event{
+		{ID: "1", Seq: 1, Type: "created", Topic: "orders.eu", PartitionKey: "o1", Timestamp: t0},
+		{ID: "2", Seq: 2, Type: "created", Topic: "orders.us", PartitionKey: "o2", Namespace: "acme", Timestamp: t0.Add(time.Minute)},
+		{ID: "3", Seq: 3, Type: "updated", Topic: "orders.eu", PartitionKey: "o1", Timestamp: t0.Add(2 * time.Minute)},
+		{ID: "4", Seq: 4, Type: "created", Topic: "users", Timestamp: t0.Add(3 * time.Minute)},
+		{ID: "5", Seq: 5, Type: "updated", Topic: "orders.eu", PartitionKey: "o1", Timestamp: t0.Add(4 * time.Minute)},
+	}
+	for _, tc := range storeCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "history")
+			s := openStore(t, tc, path)
+			for _, event := range events[:3] {
+				if err := s.Append(event); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if batch, ok := s.(BatchAppender); ok {
+				if err := batch.AppendBatch(events[3:]); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				for _, event := range events[3:] {
+					if err := s.Append(event); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if seq, err := s.LastSeq(); err != nil || seq != 5 {
+				t.Fatalf("LastSeq = %d, %v", seq, err)
+			}
+
+			for _, q := range []struct {
+				name  string
+				query Query
+				want  []uint64
+			}{
+				{"all", Query{}, []uint64{1, 2, 3, 4, 5}},
+				{"seq range", Query{FromSeq: 2, ToSeq: 4}, []uint64{2, 3, 4}},
+				{"namespace", Query{Namespace: "acme"}, []uint64{2}},
+				{"time range", Query{Since: t0.Add(2 * time.Minute), Until: t0.Add(4 * time.Minute)}, []uint64{3, 4}},
+				{"topics and types", Query{Topics: []string{"orders.*"}, Types: []string{"updated"}}, []uint64{3, 5}},
+				{"limit", Query{FromSeq: 2, Limit: 2}, []uint64{2, 3}},
+			} {
+				got, err := s.Range(q.query)
+				if err != nil {
+					t.Fatalf("%s: %v", q.name, err)
+				}
+				if !reflect.DeepEqual(seqs(got), q.want) {
+					t.Errorf("%s: Range = %v, want %v", q.name, seqs(got), q.want)
+				}
+			}
+			if got, _ := s.Range(Query{FromSeq: 2, ToSeq: 2}); len(got) != 1 || !reflect.DeepEqual(got[0], events[1]) {
+				t.Errorf("event does not round-trip: %+v", got)
+			}
+
+			stored := s.StoredBytes()
+			if stored["acme"] <= 0 || stored[domain.DefaultNamespace] <= 0 {
+				t.Fatalf("StoredBytes = %v", stored)
+			}
+
+			// Уплотнение оставляет последнее событие ключа o1.
+			if n, err := s.Compact(nil); err != nil || n != 2 {
+				t.Fatalf("Compact = %d, %v; want 2", n, err)
+			}
+			// Хранение по возрасту удаляет события раньше границы.
+			if n, err := s.Prune(t0.Add(3 * time.Minute)); err != nil || n != 1 {
+				t.Fatalf("Prune = %d, %v; want 1", n, err)
+			}
+			if got, _ := s.Range(Query{}); !reflect.DeepEqual(seqs(got), []uint64{4, 5}) {
+				t.Fatalf("Range after compaction and pruning = %v, want [4 5]", seqs(got))
+			}
+			stored = s.StoredBytes()
+			if _, ok := stored["acme"]; ok || stored[domain.DefaultNamespace] <= 0 {
+				t.Fatalf("StoredBytes after pruning = %v", stored)
+			}
+
+			if !tc.persistent {
+				return
+			}
+			s.(io.Closer).Close()
+			s = openStore(t, tc, path)
+			if seq, err := s.LastSeq(); err != nil || seq != 5 {
+				t.Fatalf("LastSeq after reopen = %d, %v", seq, err)
+			}
+			if got, _ := s.Range(Query{}); !reflect.DeepEqual(seqs(got), []uint64{4, 5}) {
+				t.Fatalf("Range after reopen = %v, want [4 5]", seqs(got))
+			}
+			if got := s.StoredBytes(); !reflect.DeepEqual(got, stored) {
+				t.Fatalf("StoredBytes after reopen = %v, want %v", got, stored)
+			}
+		})
+	}
+}
+
+// TestRingEviction проверяет, что Ring вытесняет самые старые события и
+// вычитает их из объёма истории.
+func TestRingEviction(t *testing.T) {
+	r := NewRing(2)
+	for seq := uint64(1); seq <= 3; seq++ {
+		r.Append(domain.Event{ID: "e", Seq: seq, Namespace: "acme"})
+	}
+	got, _ := r.Range(Query{})
+	if !reflect.DeepEqual(seqs(got), []uint64{2, 3}) {
+		t.Fatalf("Range = %v, want [2 3]", seqs(got))
+	}
+	single := NewRing(1)
+	single.Append(domain.Event{ID: "e", Seq: 3, Namespace: "acme"})
+	if got, want := r.StoredBytes()["acme"], 2*single.StoredBytes()["acme"]; got != want {
+		t.Fatalf("StoredBytes = %d, want %d", got, want)
+	}
+}
+
+// TestSQLStoreDuplicateSeq проверяет, что повторное событие с тем же
+// номером не сохраняется и не учитывается в объёме истории.
+func TestSQLStoreDuplicateSeq(t *testing.T) {
+	s := openStore(t, storeCases[1], filepath.Join(t.TempDir(), "history.db"))
+	event := domain.Event{ID: "1", Seq: 1, Type: "created"}
+	s.Append(event)
+	before := s.StoredBytes()
+	if err := s.Append(event); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Range(Query{}); len(got) != 1 {
+		t.Fatalf("Range = %v", seqs(got))
+	}
+	if got := s.StoredBytes(); !reflect.DeepEqual(got, before) {
+		t.Fatalf("StoredBytes = %v, want %v", got, before)
+	}
+}
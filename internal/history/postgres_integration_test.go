@@ -0,0 +1,92 @@
+//go:build integration
+
+// Интеграционные тесты запускают настоящий PostgreSQL в контейнере
+// (нужен Docker):
+//
+//	go test -tags integration ./internal/history
+package history
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var pg struct {
+	container *postgres.PostgresContainer
+	mu        sync.Mutex
+	// databases — база на каждый путь теста: повторное открытие того же
+	// пути видит прежнюю историю, а разные тесты не мешают друг другу.
+	databases map[string]string
+}
+
+func init() {
+	storeCases = append(storeCases, storeCase{name: "postgres", open: openPostgresStore, persistent: true})
+}
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+	container, err := postgres.Run(ctx, "postgres:16-alpine",
+		postgres.WithDatabase("eventsync"),
+		postgres.WithUsername("eventsync"),
+		postgres.WithPassword("eventsync"),
+		testcontainers.WithWaitStrategy(wait.ForLog("database system is ready to accept connections").
+			WithOccurrence(2).WithStartupTimeout(time.Minute)),
+	)
+	if err != nil {
+		log.Fatalf("start postgres: %v", err)
+	}
+	pg.container = container
+	pg.databases = map[string]string{}
+	code := m.Run()
+	if err := testcontainers.TerminateContainer(container); err != nil {
+		log.Printf("terminate postgres: %v", err)
+	}
+	os.Exit(code)
+}
+
+// openPostgresStore открывает SQLStore в отдельной базе для пути path,
+// создавая её при первом открытии.
+func openPostgresStore(path string) (store, error) {
+	ctx := context.Background()
+	pg.mu.Lock()
+	name, ok := pg.databases[path]
+	if !ok {
+		name = fmt.Sprintf("history_%d", len(pg.databases)+1)
+		pg.databases[path] = name
+	}
+	pg.mu.Unlock()
+	if !ok {
+		dsn, err := pg.container.ConnectionString(ctx, "sslmode=disable")
+		if err != nil {
+			return nil, err
+		}
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.ExecContext(ctx, "CREATE DATABASE "+name)
+		db.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	host, err := pg.container.Host(ctx)
+	if err != nil {
+		return nil, err
+	}
+	port, err := pg.container.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		return nil, err
+	}
+	return OpenPostgres(fmt.Sprintf("postgres://eventsync:eventsync@%s:%s/%s?sslmode=disable", host, port.Port(), name))
+}
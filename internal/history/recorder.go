@@ -0,0 +1,179 @@
+package history
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// recordBatch — наибольшая пачка событий, сохраняемая за одну операцию.
+const recordBatch = 256
+
+// DefaultCompactInterval — период уплотнения по умолчанию.
+const DefaultCompactInterval = time.Hour
+
+// RecorderOptions задаёт обслуживание хранилища истории.
+type RecorderOptions struct {
+	// MaxAge — события старше удаляются раз в минуту; 0 — хранятся.
+	MaxAge time.Duration
+	// Compact включает уплотнение журнала по ключу партиции, если хранилище
+	// реализует Compactor.
+	Compact bool
+	// CompactInterval — период уплотнения; 0 — DefaultCompactInterval.
+	CompactInterval time.Duration
+	// CompactTypes — типы событий состояния, которые уплотняются; пусто —
+	// все события с ключом партиции.
+	CompactTypes []string
+	// OnStored получает объём истории по пространствам имён при запуске и
+	// после каждой записи, очистки и уплотнения, если хранилище реализует
+	// StoredBytesReporter; nil — не сообщается.
+	OnStored func(map[string]int64)
+}
+
+// Recorder сохраняет разосланные события в хранилище фоновой горутиной,
+// чтобы рассылка не ждала диска или сети, удаляет устаревшие события и
+// уплотняет журнал.
+type Recorder struct {
+	store   ServerEventStore
+	opts    RecorderOptions
+	logger  *slog.Logger
+	events  chan domain.Event
+	flushes chan chan struct{}
+	stopped chan struct{}
+}
+
+// NewRecorder запускает запись в store.
+func NewRecorder(store ServerEventStore, opts RecorderOptions, logger *slog.Logger) *Recorder {
+	if opts.CompactInterval <= 0 {
+		opts.CompactInterval = DefaultCompactInterval
+	}
+	r := &Recorder{
+		store:   store,
+		opts:    opts,
+		logger:  logger,
+		events:  make(chan domain.Event, 4096),
+		flushes: make(chan chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record ставит событие в очередь записи. Подходит для
+// EventService.OnBroadcast; ждёт, только если очередь заполнена.
+func (r *Recorder) Record(event domain.Event) {
+	r.events <- event
+}
+
+// Flush записывает события, уже поставленные в очередь, и возвращается,
+// когда они сохранены.
+func (r *Recorder) Flush() {
+	done := make(chan struct{})
+	select {
+	case r.flushes <- done:
+		<-done
+	case <-r.stopped:
+	}
+}
+
+// Close записывает события из очереди и останавливает запись. Вызывается,
+// когда рассылка уже остановлена; хранилище закрывает вызывающий.
+func (r *Recorder) Close() {
+	close(r.events)
+	<-r.stopped
+}
+
+func (r *Recorder) run() {
+	defer close(r.stopped)
+	var prune, compact <-chan time.Time
+	if r.opts.MaxAge > 0 {
+		ticker := time.NewTicker(min(time.Minute, r.opts.MaxAge))
+		defer ticker.Stop()
+		prune = ticker.C
+	}
+	compactor, ok := r.store.(Compactor)
+	if r.opts.Compact && ok {
+		ticker := time.NewTicker(r.opts.CompactInterval)
+		defer ticker.Stop()
+		compact = ticker.C
+	} else if r.opts.Compact {
+		r.logger.Warn("Event history store does not support compaction")
+	}
+	reporter, _ := r.store.(StoredBytesReporter)
+	report := func() {
+		if reporter != nil && r.opts.OnStored != nil {
+			r.opts.OnStored(reporter.StoredBytes())
+		}
+	}
+	report()
+	batch := make([]domain.Event, 0, recordBatch)
+	for {
+		select {
+		case event, ok := <-r.events:
+			if !ok {
+				return
+			}
+			batch = append(batch[:0], event)
+		drain:
+			for len(batch) < recordBatch {
+				select {
+				case next, ok := <-r.events:
+					if !ok {
+						break drain
+					}
+					batch = append(batch, next)
+				default:
+					break drain
+				}
+			}
+			r.save(batch)
+			report()
+		case done := <-r.flushes:
+			for len(r.events) > 0 {
+				batch = batch[:0]
+				for len(batch) < recordBatch && len(r.events) > 0 {
+					batch = append(batch, <-r.events)
+				}
+				r.save(batch)
+			}
+			report()
+			close(done)
+		case now := <-prune:
+			removed, err := r.store.Prune(now.Add(-r.opts.MaxAge))
+			if err != nil {
+				r.logger.Error("Event history prune error", "error", err)
+			} else if removed > 0 {
+				r.logger.Info("Event history pruned", "removed", removed)
+				report()
+			}
+		case <-compact:
+			start := time.Now()
+			removed, err := compactor.Compact(r.opts.CompactTypes)
+			if err != nil {
+				r.logger.Error("Event history compaction error", "error", err)
+			} else if removed > 0 {
+				r.logger.Info("Event history compacted", "removed", removed, "duration", time.Since(start))
+				report()
+			}
+		}
+	}
+}
+
+// save сохраняет пачку событий; при ошибке события теряются для истории,
+// но не для подписчиков.
+func (r *Recorder) save(batch []domain.Event) {
+	var err error
+	if b, ok := r.store.(BatchAppender); ok {
+		err = b.AppendBatch(batch)
+	} else {
+		for _, event := range batch {
+			if err = r.store.Append(event); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		r.logger.Error("Event history write error", "events", len(batch), "error", err)
+	}
+}
@@ -0,0 +1,138 @@
+package history
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// DefaultCapacity — число хранимых событий Ring по умолчанию.
+const DefaultCapacity = 100000
+
+// Ring — история в памяти фиксированной вместимости: новые события
+// вытесняют самые старые. Не переживает перезапуск сервера.
+type Ring struct {
+	mu      sync.RWMutex
+	events  []domain.Event
+	sizes   []int // размер каждого события в JSON, для учёта объёма
+	next    int   // позиция следующей записи
+	full    bool
+	lastSeq uint64
+	stored  storedBytes
+}
+
+// NewRing создаёт историю на capacity событий; capacity <= 0 — DefaultCapacity.
+func NewRing(capacity int) *Ring {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Ring{events: make([]domain.Event, capacity), sizes: make([]int, capacity), stored: make(storedBytes)}
+}
+
+// Append запоминает разосланное событие, вытесняя самое старое.
+func (r *Ring) Append(event domain.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.full {
+		r.stored.add(r.events[r.next], -r.sizes[r.next])
+	}
+	r.events[r.next], r.sizes[r.next] = event, len(body)
+	r.stored.add(event, len(body))
+	if r.next++; r.next == len(r.events) {
+		r.next, r.full = 0, true
+	}
+	r.lastSeq = max(r.lastSeq, event.Seq)
+	return nil
+}
+
+// Range возвращает подходящие под запрос события в порядке номеров.
+func (r *Ring) Range(q Query) ([]domain.Event, error) {
+	r.mu.RLock()
+	var out []domain.Event
+	events, _ := r.ordered()
+	for _, event := range events {
+		if q.Matches(event) {
+			out = append(out, event)
+		}
+	}
+	r.mu.RUnlock()
+	// Наблюдатели рассылки вызываются конкурентно, поэтому порядок записи
+	// может немного расходиться с порядком номеров.
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
+
+// ordered возвращает события и их размеры в порядке записи; вызывается
+// под блокировкой.
+func (r *Ring) ordered() ([]domain.Event, []int) {
+	if !r.full {
+		return r.events[:r.next], r.sizes[:r.next]
+	}
+	n := len(r.events)
+	return append(r.events[r.next:n:n], r.events[:r.next]...), append(r.sizes[r.next:n:n], r.sizes[:r.next]...)
+}
+
+// StoredBytes возвращает объём событий в истории по пространствам имён.
+func (r *Ring) StoredBytes() map[string]int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.stored.clone()
+}
+
+// LastSeq возвращает наибольший номер события в истории.
+func (r *Ring) LastSeq() (uint64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastSeq, nil
+}
+
+// Prune удаляет события с Timestamp раньше before.
+func (r *Ring) Prune(before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.remove(func(event domain.Event) bool { return event.Timestamp.Before(before) }), nil
+}
+
+// Compact оставляет только последнее событие каждого ключа партиции.
+func (r *Ring) Compact(types []string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	latest := make(latestSeqs)
+	events, _ := r.ordered()
+	for _, event := range events {
+		latest.add(event, types)
+	}
+	return r.remove(func(event domain.Event) bool { return latest.superseded(event, types) }), nil
+}
+
+// remove удаляет события, для которых drop возвращает true, сохраняя
+// порядок остальных; вызывается под блокировкой.
+func (r *Ring) remove(drop func(domain.Event) bool) int {
+	stored, sizes := r.ordered()
+	events, kept := make([]domain.Event, len(r.events)), make([]int, len(r.events))
+	n := 0
+	for i, event := range stored {
+		if drop(event) {
+			r.stored.add(event, -sizes[i])
+			continue
+		}
+		events[n], kept[n] = event, sizes[i]
+		n++
+	}
+	removed := len(stored) - n
+	if removed == 0 {
+		return 0
+	}
+	r.events, r.sizes, r.next, r.full = events, kept, n, false
+	return removed
+}
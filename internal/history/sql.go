@@ -0,0 +1,324 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// SQLStore — история в таблице server_events SQLite или PostgreSQL. Поля
+// для отбора хранятся в колонках, событие целиком — в JSON.
+type SQLStore struct {
+	db       *sql.DB
+	postgres bool // плейсхолдеры $1, $2, ... вместо ?
+
+	mu     sync.Mutex
+	stored storedBytes // объём по пространствам имён; пересчитывается после удаления
+}
+
+// sqlSchema подходит для обоих диалектов.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS server_events (
+		seq BIGINT PRIMARY KEY,
+		id TEXT NOT NULL,
+		namespace TEXT NOT NULL,
+		type TEXT NOT NULL,
+		topic TEXT NOT NULL,
+		partition_key TEXT NOT NULL DEFAULT '',
+		ts BIGINT NOT NULL,
+		body TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_server_events_ns_seq ON server_events (namespace, seq)`,
+	`CREATE INDEX IF NOT EXISTS idx_server_events_ts ON server_events (ts)`,
+}
+
+// sqlIndexes создаются после миграции колонок.
+var sqlIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS idx_server_events_key ON server_events (namespace, partition_key, seq)`,
+}
+
+// OpenSQLite открывает (или создаёт) историю в файле SQLite.
+func OpenSQLite(path string) (*SQLStore, error) {
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	// Один писатель исключает SQLITE_BUSY между записью и удалением.
+	db.SetMaxOpenConns(1)
+	return initSQL(db, false)
+}
+
+// OpenPostgres подключается к PostgreSQL по строке подключения dsn и
+// создаёт таблицу истории, если её нет.
+func OpenPostgres(dsn string) (*SQLStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return initSQL(db, true)
+}
+
+func initSQL(db *sql.DB, postgres bool) (*SQLStore, error) {
+	s := &SQLStore{db: db, postgres: postgres}
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLStore) init() error {
+	for _, stmt := range sqlSchema {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if err := s.migrateColumns(); err != nil {
+		return err
+	}
+	for _, stmt := range sqlIndexes {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return s.countStored()
+}
+
+// countStored пересчитывает объём событий по пространствам имён.
+func (s *SQLStore) countStored() error {
+	size := "LENGTH(CAST(body AS BLOB))"
+	if s.postgres {
+		size = "OCTET_LENGTH(body)"
+	}
+	rows, err := s.db.Query("SELECT namespace, SUM(" + size + ") FROM server_events GROUP BY namespace")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	stored := make(storedBytes)
+	for rows.Next() {
+		var ns string
+		var n int64
+		if err := rows.Scan(&ns, &n); err != nil {
+			return err
+		}
+		stored[ns] = n
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.stored = stored
+	s.mu.Unlock()
+	return nil
+}
+
+// StoredBytes возвращает объём событий в истории по пространствам имён.
+func (s *SQLStore) StoredBytes() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stored.clone()
+}
+
+// migrateColumns добавляет колонку partition_key в таблицы, созданные до её
+// появления. События, сохранённые раньше, в уплотнении не участвуют.
+func (s *SQLStore) migrateColumns() error {
+	const add = `ALTER TABLE server_events ADD COLUMN partition_key TEXT NOT NULL DEFAULT ''`
+	if s.postgres {
+		_, err := s.db.Exec(strings.Replace(add, "ADD COLUMN", "ADD COLUMN IF NOT EXISTS", 1))
+		return err
+	}
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('server_events') WHERE name = 'partition_key'`).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = s.db.Exec(add)
+	return err
+}
+
+// rebind заменяет плейсхолдеры ? на $n для PostgreSQL.
+func (s *SQLStore) rebind(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+const insertServerEvent = `INSERT INTO server_events (seq, id, namespace, type, topic, partition_key, ts, body)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (seq) DO NOTHING`
+
+// Append сохраняет событие; событие с уже сохранённым номером пропускается.
+func (s *SQLStore) Append(event domain.Event) error {
+	return s.AppendBatch([]domain.Event{event})
+}
+
+// AppendBatch сохраняет события в одной транзакции.
+func (s *SQLStore) AppendBatch(events []domain.Event) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(s.rebind(insertServerEvent))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	added := make(storedBytes)
+	for _, event := range events {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		res, err := stmt.Exec(int64(event.Seq), event.ID, event.NamespaceOrDefault(), event.Type, event.Topic,
+			event.PartitionKey, event.Timestamp.UnixNano(), string(body))
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			added.add(event, len(body))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for ns, n := range added {
+		s.stored[ns] += n
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Range возвращает подходящие под запрос события в порядке номеров. Границы
+// и типы отбираются в запросе, шаблоны топиков — после чтения.
+func (s *SQLStore) Range(q Query) ([]domain.Event, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+	if q.Namespace != "" {
+		add("namespace = ?", q.Namespace)
+	}
+	if q.FromSeq > 0 {
+		add("seq >= ?", int64(q.FromSeq))
+	}
+	if q.ToSeq > 0 {
+		add("seq <= ?", int64(q.ToSeq))
+	}
+	if !q.Since.IsZero() {
+		add("ts >= ?", q.Since.UnixNano())
+	}
+	if !q.Until.IsZero() {
+		add("ts < ?", q.Until.UnixNano())
+	}
+	if len(q.Types) > 0 {
+		where = append(where, "type IN (?"+strings.Repeat(", ?", len(q.Types)-1)+")")
+		for _, t := range q.Types {
+			args = append(args, t)
+		}
+	}
+	query := "SELECT body FROM server_events"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := s.db.Query(s.rebind(query+" ORDER BY seq"), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []domain.Event
+	for rows.Next() {
+		var body string
+		if err := rows.Scan(&body); err != nil {
+			return nil, err
+		}
+		var event domain.Event
+		if err := json.Unmarshal([]byte(body), &event); err != nil {
+			return nil, err
+		}
+		if !q.Matches(event) {
+			continue
+		}
+		events = append(events, event)
+		if q.Limit > 0 && len(events) == q.Limit {
+			break
+		}
+	}
+	return events, rows.Err()
+}
+
+// LastSeq возвращает наибольший сохранённый номер события.
+func (s *SQLStore) LastSeq() (uint64, error) {
+	var seq int64
+	err := s.db.QueryRow("SELECT COALESCE(MAX(seq), 0) FROM server_events").Scan(&seq)
+	return uint64(seq), err
+}
+
+// Prune удаляет события с Timestamp раньше before.
+func (s *SQLStore) Prune(before time.Time) (int, error) {
+	res, err := s.db.Exec(s.rebind("DELETE FROM server_events WHERE ts < ?"), before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return s.removed(res)
+}
+
+// removed возвращает число удалённых строк и пересчитывает объём истории,
+// если они были.
+func (s *SQLStore) removed(res sql.Result) (int, error) {
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return int(n), err
+	}
+	return int(n), s.countStored()
+}
+
+// Compact оставляет только последнее событие каждого ключа партиции.
+func (s *SQLStore) Compact(types []string) (int, error) {
+	var typeFilter string
+	args := make([]any, 0, 2*len(types))
+	if len(types) > 0 {
+		typeFilter = " AND type IN (?" + strings.Repeat(", ?", len(types)-1) + ")"
+		for _, t := range types {
+			args = append(args, t)
+		}
+		args = append(args, args...)
+	}
+	query := `DELETE FROM server_events WHERE partition_key <> ''` + typeFilter + ` AND seq < (
+		SELECT MAX(latest.seq) FROM server_events latest
+		WHERE latest.namespace = server_events.namespace AND latest.partition_key = server_events.partition_key` +
+		strings.ReplaceAll(typeFilter, "type", "latest.type") + `)`
+	res, err := s.db.Exec(s.rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return s.removed(res)
+}
+
+// Close закрывает подключение к БД.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
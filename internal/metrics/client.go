@@ -0,0 +1,57 @@
+package metrics
+
+// ClientMetrics — метрики клиентского процесса синхронизации.
+type ClientMetrics struct {
+	EventsReceived     *Counter
+	DuplicatesFiltered *Counter
+	SaveErrors         *Counter
+	SaveRetries        *Counter // повторные попытки записи после временных ошибок хранилища
+	Reconnects         *Counter
+	Connected          *Gauge     // число транспортов с активным соединением
+	EventLatency       *Histogram // время от Timestamp события до получения клиентом
+	// MessageBytes — размер принятых сообщений, WireBytes — байты, принятые
+	// из сети; их отношение показывает эффект сжатия permessage-deflate.
+	MessageBytes *Counter
+	WireBytes    *Counter
+	QueueDepth   *Gauge   // события в очереди пула обработки
+	QueueDropped *Counter // события, отброшенные при переполнении очереди
+	EventsPruned *Counter // события, удалённые политикой хранения
+	// EventsDeadLettered — события, перемещённые в очередь недоставленных
+	// после исчерпания попыток сохранения.
+	EventsDeadLettered *Counter
+	// BreakerOpen — 1, пока автомат защиты хранилища разомкнут;
+	// BreakerRejected — события, отклонённые при заполненном буфере автомата.
+	BreakerOpen     *Gauge
+	BreakerRejected *Counter
+	// HeartbeatRTT — время оборота прикладного ping, ClockOffset — смещение
+	// часов сервера относительно клиента по последнему pong.
+	HeartbeatRTT *Histogram
+	ClockOffset  *Gauge
+}
+
+// NewClientMetrics регистрирует метрики клиента в реестре.
+func NewClientMetrics(r *Registry) *ClientMetrics {
+	return &ClientMetrics{
+		EventsReceived:     r.NewCounter("eventsync_client_events_received_total", "Events received from the server."),
+		DuplicatesFiltered: r.NewCounter("eventsync_client_duplicates_filtered_total", "Events dropped as duplicates."),
+		SaveErrors:         r.NewCounter("eventsync_client_save_errors_total", "Events that failed to persist."),
+		SaveRetries:        r.NewCounter("eventsync_client_save_retries_total", "Save attempts retried after transient storage errors."),
+		Reconnects:         r.NewCounter("eventsync_client_reconnects_total", "Successful reconnections to the server."),
+		Connected:          r.NewGauge("eventsync_client_connected", "Number of transports currently connected."),
+		EventLatency: r.NewHistogram("eventsync_client_event_latency_seconds",
+			"End-to-end latency between event timestamp and receipt.", DefaultLatencyBuckets),
+		MessageBytes: r.NewCounter("eventsync_client_message_bytes_total", "Uncompressed size of received WebSocket messages."),
+		WireBytes:    r.NewCounter("eventsync_client_wire_bytes_total", "Bytes read from the network, including framing, compression and TLS."),
+		QueueDepth:   r.NewGauge("eventsync_client_queue_depth", "Events waiting in the processing queue."),
+		QueueDropped: r.NewCounter("eventsync_client_queue_dropped_total", "Events dropped because the processing queue was full."),
+		EventsPruned: r.NewCounter("eventsync_client_events_pruned_total", "Events deleted by the retention policy."),
+		EventsDeadLettered: r.NewCounter("eventsync_client_events_dead_lettered_total",
+			"Events moved to the dead-letter queue after repeated save failures."),
+		BreakerOpen: r.NewGauge("eventsync_client_breaker_open", "1 while the storage circuit breaker is open."),
+		BreakerRejected: r.NewCounter("eventsync_client_breaker_rejected_total",
+			"Events rejected because the circuit breaker buffer was full."),
+		HeartbeatRTT: r.NewHistogram("eventsync_client_heartbeat_rtt_seconds",
+			"Round-trip time of application-level heartbeats.", DefaultLatencyBuckets),
+		ClockOffset: r.NewGauge("eventsync_client_clock_offset_seconds", "Server clock offset relative to the client, from the last heartbeat."),
+	}
+}
@@ -0,0 +1,32 @@
+package metrics
+
+// QueueDepthBuckets — границы корзин гистограммы глубины очереди отправки.
+var QueueDepthBuckets = []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+// ServerMetrics — метрики сервера рассылки.
+type ServerMetrics struct {
+	// DeliveryLatency — время от постановки события в очередь клиента при
+	// рассылке до завершения записи в его соединение; EventAge — от Timestamp
+	// события (момента генерации) до завершения записи.
+	DeliveryLatency *Histogram
+	EventAge        *Histogram
+	// QueueDepth — глубина очереди отправки клиента после постановки события;
+	// рост верхних корзин предупреждает о медленных клиентах до их отключения.
+	QueueDepth    *Histogram
+	EventsSent    *Counter
+	EventsDropped *Counter // события, не принятые в переполненную очередь
+}
+
+// NewServerMetrics регистрирует метрики сервера в реестре.
+func NewServerMetrics(r *Registry) *ServerMetrics {
+	return &ServerMetrics{
+		DeliveryLatency: r.NewHistogram("eventsync_server_delivery_latency_seconds",
+			"Time from enqueueing an event for a client to completing the write.", DefaultLatencyBuckets),
+		EventAge: r.NewHistogram("eventsync_server_event_age_seconds",
+			"Time from event timestamp to completing the write to a client.", DefaultLatencyBuckets),
+		QueueDepth: r.NewHistogram("eventsync_server_send_queue_depth",
+			"Per-client send queue depth observed after each enqueue.", QueueDepthBuckets),
+		EventsSent:    r.NewCounter("eventsync_server_events_sent_total", "Events written to client connections."),
+		EventsDropped: r.NewCounter("eventsync_server_events_dropped_total", "Events dropped because a client send queue was full."),
+	}
+}
@@ -0,0 +1,15 @@
+// Package netpoll сообщает о готовности соединений к чтению без отдельной
+// горутины на соединение: сокеты отслеживает один реактор (epoll на Linux),
+// а обработчики готовых соединений выполняет ограниченный пул горутин.
+// Запись по-прежнему идёт через net.Conn, а читать стоит через Read: он
+// возвращает уже поступившие данные и не занимает обработчик ожиданием
+// остальных.
+package netpoll
+
+import "errors"
+
+// ErrUnsupported возвращается New на ОС без поддержки реактора.
+var ErrUnsupported = errors.New("netpoll is not supported on this platform")
+
+// DefaultWorkers — размер пула обработчиков по умолчанию.
+const DefaultWorkers = 64
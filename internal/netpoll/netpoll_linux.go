@@ -0,0 +1,242 @@
+package netpoll
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+)
+
+// Poller — реактор на epoll. Соединение отслеживается в режиме
+// EPOLLONESHOT: пока обработчик соединения выполняется, новых уведомлений о
+// нём нет, а после возврата обработчика соединение снова отслеживается,
+// если его не удалили. Поэтому обработчики одного соединения не выполняются
+// параллельно, а непрочитанные данные вызывают обработчик повторно.
+type Poller struct {
+	epfd int
+	wake [2]int // канал, запись в который прерывает EpollWait при Close
+	work chan *entry
+	done chan struct{}
+
+	mu      sync.Mutex
+	entries map[int]*entry // по дескриптору
+	gen     int32
+	closed  bool
+}
+
+type entry struct {
+	fd      int
+	gen     int32 // отличает соединение от прежнего владельца того же дескриптора
+	handler func()
+	removed bool // под Poller.mu
+}
+
+const armEvents = syscall.EPOLLIN | syscall.EPOLLRDHUP | syscall.EPOLLONESHOT
+
+// New запускает реактор с пулом из workers обработчиков; workers <= 0 —
+// DefaultWorkers.
+func New(workers int) (*Poller, error) {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("netpoll: epoll_create: %w", err)
+	}
+	p := &Poller{
+		epfd:    epfd,
+		work:    make(chan *entry, workers),
+		done:    make(chan struct{}),
+		entries: make(map[int]*entry),
+	}
+	if err := syscall.Pipe2(p.wake[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		syscall.Close(epfd)
+		return nil, fmt.Errorf("netpoll: pipe: %w", err)
+	}
+	ev := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(p.wake[0]), Pad: -1}
+	if err := syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, p.wake[0], &ev); err != nil {
+		p.closeFDs()
+		return nil, fmt.Errorf("netpoll: epoll_ctl: %w", err)
+	}
+	for range workers {
+		go p.worker()
+	}
+	go p.loop()
+	return p, nil
+}
+
+// Add начинает отслеживать conn: handler вызывается на пуле обработчиков,
+// когда в соединении появились данные или клиент его закрыл. Соединение
+// должно давать доступ к дескриптору (как *net.TCPConn).
+func (p *Poller) Add(conn net.Conn, handler func()) error {
+	fd, err := connFD(conn)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errors.New("netpoll: poller is closed")
+	}
+	p.gen++
+	e := &entry{fd: fd, gen: p.gen, handler: handler}
+	ev := syscall.EpollEvent{Events: armEvents, Fd: int32(fd), Pad: e.gen}
+	if err := syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_ADD, fd, &ev); err != nil {
+		return fmt.Errorf("netpoll: epoll_ctl: %w", err)
+	}
+	p.entries[fd] = e
+	return nil
+}
+
+// Remove прекращает отслеживать conn. Вызывается до закрытия соединения,
+// пока его дескриптор действителен; в том числе из обработчика.
+func (p *Poller) Remove(conn net.Conn) error {
+	fd, err := connFD(conn)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.entries[fd]
+	if !ok {
+		return nil
+	}
+	e.removed = true
+	delete(p.entries, fd)
+	if p.closed {
+		return nil
+	}
+	if err := syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_DEL, fd, nil); err != nil {
+		return fmt.Errorf("netpoll: epoll_ctl: %w", err)
+	}
+	return nil
+}
+
+// Len возвращает число отслеживаемых соединений.
+func (p *Poller) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// Close останавливает реактор. Соединения не закрываются; выполняющиеся
+// обработчики завершаются сами.
+func (p *Poller) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+	syscall.Write(p.wake[1], []byte{0})
+	<-p.done
+	return nil
+}
+
+// loop ждёт готовности соединений и передаёт их пулу обработчиков.
+func (p *Poller) loop() {
+	defer func() {
+		close(p.work)
+		p.closeFDs()
+		close(p.done)
+	}()
+	events := make([]syscall.EpollEvent, 256)
+	for {
+		n, err := syscall.EpollWait(p.epfd, events, -1)
+		if err != nil {
+			if errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			return
+		}
+		for _, ev := range events[:n] {
+			if int(ev.Fd) == p.wake[0] {
+				return
+			}
+			p.mu.Lock()
+			e := p.entries[int(ev.Fd)]
+			p.mu.Unlock()
+			// Событие могло остаться от закрытого соединения, дескриптор
+			// которого уже занят новым.
+			if e != nil && e.gen == ev.Pad {
+				p.work <- e
+			}
+		}
+	}
+}
+
+// worker выполняет обработчики и снова включает отслеживание соединения.
+func (p *Poller) worker() {
+	for e := range p.work {
+		e.handler()
+		p.mu.Lock()
+		if !e.removed && !p.closed {
+			ev := syscall.EpollEvent{Events: armEvents, Fd: int32(e.fd), Pad: e.gen}
+			syscall.EpollCtl(p.epfd, syscall.EPOLL_CTL_MOD, e.fd, &ev)
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (p *Poller) closeFDs() {
+	syscall.Close(p.wake[0])
+	syscall.Close(p.wake[1])
+	syscall.Close(p.epfd)
+}
+
+// Read читает из conn уже поступившие данные, не дожидаясь новых: n == 0
+// без ошибки означает, что данных пока нет. Закрытие соединения клиентом
+// возвращается как io.EOF.
+func Read(conn net.Conn, p []byte) (int, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, fmt.Errorf("netpoll: %T has no file descriptor", conn)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var (
+		n       int
+		readErr error
+	)
+	err = raw.Read(func(fd uintptr) bool {
+		for {
+			n, readErr = syscall.Read(int(fd), p)
+			if readErr != syscall.EINTR {
+				return true
+			}
+		}
+	})
+	switch {
+	case err != nil:
+		return 0, err
+	case readErr == syscall.EAGAIN:
+		return 0, nil
+	case readErr != nil:
+		return 0, readErr
+	case n == 0 && len(p) > 0:
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// connFD возвращает дескриптор соединения, не передавая владение им.
+func connFD(conn net.Conn) (int, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return -1, fmt.Errorf("netpoll: %T has no file descriptor", conn)
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+	fd := -1
+	if err := raw.Control(func(f uintptr) { fd = int(f) }); err != nil {
+		return -1, err
+	}
+	return fd, nil
+}
@@ -0,0 +1,110 @@
+package netpoll
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// tcpPair возвращает два конца TCP-соединения.
+func tcpPair(t *testing.T) (server, client net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := ln.Accept()
+		accepted <- conn
+	}()
+	client, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server = <-accepted
+	if server == nil {
+		t.Fatal("accept failed")
+	}
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return server, client
+}
+
+func TestRead(t *testing.T) {
+	server, client := tcpPair(t)
+	buf := make([]byte, 16)
+	if n, err := Read(server, buf); n != 0 || err != nil {
+		t.Fatalf("Read without data = %d, %v; want 0, nil", n, err)
+	}
+	client.Write([]byte("hello"))
+	deadline := time.Now().Add(5 * time.Second)
+	var got []byte
+	for len(got) < 5 && time.Now().Before(deadline) {
+		n, err := Read(server, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("Read = %q", got)
+	}
+	client.Close()
+	for time.Now().Before(deadline) {
+		if _, err := Read(server, buf); err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatalf("Read after close = %v, want EOF", err)
+		}
+	}
+	t.Fatal("Read did not report EOF")
+}
+
+// TestPoller проверяет, что обработчик вызывается, пока в соединении есть
+// непрочитанные данные, и не вызывается после Remove.
+func TestPoller(t *testing.T) {
+	p, err := New(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	server, client := tcpPair(t)
+	ready := make(chan struct{}, 16)
+	if err := p.Add(server, func() { ready <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	if p.Len() != 1 {
+		t.Fatalf("Len = %d, want 1", p.Len())
+	}
+	client.Write([]byte("x"))
+	// Обработчик не читает данные, поэтому вызывается повторно.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ready:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("handler call %d missing", i+1)
+		}
+	}
+	if err := p.Remove(server); err != nil {
+		t.Fatal(err)
+	}
+	if p.Len() != 0 {
+		t.Fatalf("Len after Remove = %d", p.Len())
+	}
+	// Вызовы, уже поставленные в очередь пула, могут завершиться.
+	time.Sleep(50 * time.Millisecond)
+	for len(ready) > 0 {
+		<-ready
+	}
+	client.Write([]byte("y"))
+	select {
+	case <-ready:
+		t.Fatal("handler called after Remove")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package netpoll
+
+import "net"
+
+// Poller — заглушка для ОС без epoll; New всегда возвращает ErrUnsupported.
+type Poller struct{}
+
+// New возвращает ErrUnsupported.
+func New(workers int) (*Poller, error) {
+	return nil, ErrUnsupported
+}
+
+// Add возвращает ErrUnsupported.
+func (p *Poller) Add(conn net.Conn, handler func()) error { return ErrUnsupported }
+
+// Remove ничего не делает.
+func (p *Poller) Remove(conn net.Conn) error { return nil }
+
+// Len возвращает 0.
+func (p *Poller) Len() int { return 0 }
+
+// Read возвращает ErrUnsupported.
+func Read(conn net.Conn, p []byte) (int, error) { return 0, ErrUnsupported }
+
+// Close ничего не делает.
+func (p *Poller) Close() error { return nil }
@@ -0,0 +1,147 @@
+// Package pb содержит типы, сгенерированные из proto/eventsync/v1/event.proto,
+// и их преобразование в доменные события: двоичный кодек протокола для
+// клиентов на других языках и будущего транспорта gRPC.
+package pb
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/wrongjunior/eventsync eventsync/v1/event.proto
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// MediaType — Content-Type тела HTTP с событием в protobuf.
+const MediaType = "application/x-protobuf"
+
+// ErrEmptyFrame возвращается для кадра без события и без пакета.
+var ErrEmptyFrame = errors.New("empty protobuf frame")
+
+// FromDomain переводит доменное событие в сообщение protobuf.
+func FromDomain(e domain.Event) *Event {
+	m := &Event{
+		Id:            e.ID,
+		Seq:           e.Seq,
+		Type:          e.Type,
+		Version:       int32(e.Version),
+		Priority:      int32(e.Priority),
+		Namespace:     e.Namespace,
+		Topic:         e.Topic,
+		PartitionKey:  e.PartitionKey,
+		CorrelationId: e.CorrelationID,
+		CausationId:   e.CausationID,
+		Metadata:      e.Metadata,
+		Source:        e.Source,
+		Message:       e.Message,
+		Data:          e.Data,
+	}
+	if e.Causality != nil {
+		m.Causality = &Causality{Node: e.Causality.Node, Clock: e.Causality.Clock}
+	}
+	if !e.Timestamp.IsZero() {
+		m.Timestamp = timestamppb.New(e.Timestamp)
+	}
+	if e.DeliverAt != nil {
+		m.DeliverAt = timestamppb.New(*e.DeliverAt)
+	}
+	return m
+}
+
+// Domain переводит сообщение protobuf в доменное событие.
+func (m *Event) Domain() domain.Event {
+	e := domain.Event{
+		ID:            m.GetId(),
+		Seq:           m.GetSeq(),
+		Type:          m.GetType(),
+		Version:       int(m.GetVersion()),
+		Priority:      int(m.GetPriority()),
+		Namespace:     m.GetNamespace(),
+		Topic:         m.GetTopic(),
+		PartitionKey:  m.GetPartitionKey(),
+		CorrelationID: m.GetCorrelationId(),
+		CausationID:   m.GetCausationId(),
+		Metadata:      m.GetMetadata(),
+		Source:        m.GetSource(),
+		Message:       m.GetMessage(),
+		Data:          m.GetData(),
+	}
+	if c := m.GetCausality(); c != nil {
+		e.Causality = &domain.Causality{Node: c.GetNode(), Clock: c.GetClock()}
+	}
+	if m.Timestamp != nil {
+		e.Timestamp = m.Timestamp.AsTime()
+	}
+	if m.DeliverAt != nil {
+		at := m.DeliverAt.AsTime()
+		e.DeliverAt = &at
+	}
+	return e
+}
+
+// MarshalEvent кодирует одно событие.
+func MarshalEvent(e domain.Event) ([]byte, error) {
+	return proto.Marshal(FromDomain(e))
+}
+
+// UnmarshalEvent декодирует одно событие.
+func UnmarshalEvent(b []byte) (domain.Event, error) {
+	var m Event
+	if err := proto.Unmarshal(b, &m); err != nil {
+		return domain.Event{}, err
+	}
+	return m.Domain(), nil
+}
+
+// Номера полей кадров для сборки из готовых кодировок событий.
+const (
+	frameEventField  protowire.Number = 1 // Frame.event
+	frameBatchField  protowire.Number = 2 // Frame.batch
+	batchEventsField protowire.Number = 1 // EventBatch.events
+)
+
+// AppendEventFrame дописывает к dst кадр Frame с событием, уже
+// закодированным MarshalEvent, — без повторной сериализации.
+func AppendEventFrame(dst, event []byte) []byte {
+	dst = protowire.AppendTag(dst, frameEventField, protowire.BytesType)
+	return protowire.AppendBytes(dst, event)
+}
+
+// AppendBatchFrame дописывает к dst кадр Frame с пакетом событий, уже
+// закодированных MarshalEvent.
+func AppendBatchFrame(dst []byte, events [][]byte) []byte {
+	size := 0
+	for _, e := range events {
+		size += protowire.SizeTag(batchEventsField) + protowire.SizeBytes(len(e))
+	}
+	dst = protowire.AppendTag(dst, frameBatchField, protowire.BytesType)
+	dst = protowire.AppendVarint(dst, uint64(size))
+	for _, e := range events {
+		dst = protowire.AppendTag(dst, batchEventsField, protowire.BytesType)
+		dst = protowire.AppendBytes(dst, e)
+	}
+	return dst
+}
+
+// DecodeFrame декодирует двоичный кадр WebSocket в события.
+func DecodeFrame(b []byte) ([]domain.Event, error) {
+	var f Frame
+	if err := proto.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	switch body := f.Body.(type) {
+	case *Frame_Event:
+		return []domain.Event{body.Event.Domain()}, nil
+	case *Frame_Batch:
+		events := make([]domain.Event, 0, len(body.Batch.GetEvents()))
+		for _, m := range body.Batch.GetEvents() {
+			events = append(events, m.Domain())
+		}
+		return events, nil
+	default:
+		return nil, ErrEmptyFrame
+	}
+}
@@ -0,0 +1,212 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Ресурсы, на которые распространяются квоты.
+const (
+	ResourceConnections = "connections"
+	ResourcePublishRate = "events_per_minute"
+	ResourceStoredBytes = "stored_bytes"
+)
+
+// ErrQuotaExceeded — базовая ошибка превышения квоты, для errors.Is.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ExceededError описывает, какая квота тенанта превышена.
+type ExceededError struct {
+	Tenant   string `json:"tenant"`
+	Resource string `json:"resource"`
+	Limit    int64  `json:"limit"`
+	Used     int64  `json:"used"`
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded for tenant %q: %s limit %d (used %d)", e.Tenant, e.Resource, e.Limit, e.Used)
+}
+
+// Is позволяет сравнивать ошибку с ErrQuotaExceeded.
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Limits задаёт квоты тенанта; нулевое значение поля означает отсутствие ограничения.
+type Limits struct {
+	MaxConnections     int64 `json:"max_connections"`
+	MaxEventsPerMinute int64 `json:"max_events_per_minute"`
+	MaxStoredBytes     int64 `json:"max_stored_bytes"`
+}
+
+// Usage — текущее потребление ресурсов тенантом.
+type Usage struct {
+	Tenant          string `json:"tenant"`
+	Limits          Limits `json:"limits"`
+	Connections     int64  `json:"connections"`
+	EventsPerMinute int64  `json:"events_per_minute"`
+	StoredBytes     int64  `json:"stored_bytes"`
+}
+
+type usage struct {
+	connections int64
+	windowStart time.Time
+	windowCount int64
+	storedBytes int64
+}
+
+// Manager учитывает потребление и применяет квоты по тенантам. Объём
+// хранимых данных учитывается, только если менеджер получает объём
+// истории через SyncStored: без истории сервер событий не хранит.
+type Manager struct {
+	mu        sync.Mutex
+	defaults  Limits
+	overrides map[string]Limits
+	usage     map[string]*usage
+	stored    bool // объём хранимых данных синхронизируется с историей
+	now       func() time.Time
+}
+
+// NewManager создаёт менеджер квот с лимитами по умолчанию и переопределениями для тенантов.
+func NewManager(defaults Limits, overrides map[string]Limits) *Manager {
+	if overrides == nil {
+		overrides = make(map[string]Limits)
+	}
+	return &Manager{
+		defaults:  defaults,
+		overrides: overrides,
+		usage:     make(map[string]*usage),
+		now:       time.Now,
+	}
+}
+
+// limits возвращает квоты тенанта. Вызывается под m.mu.
+func (m *Manager) limits(tenant string) Limits {
+	if l, ok := m.overrides[tenant]; ok {
+		return l
+	}
+	return m.defaults
+}
+
+// get возвращает счётчики тенанта, создавая их при необходимости. Вызывается под m.mu.
+func (m *Manager) get(tenant string) *usage {
+	u, ok := m.usage[tenant]
+	if !ok {
+		u = &usage{}
+		m.usage[tenant] = u
+	}
+	return u
+}
+
+// rollWindow сбрасывает минутное окно публикаций, если оно истекло. Вызывается под m.mu.
+func (m *Manager) rollWindow(u *usage) {
+	now := m.now()
+	if now.Sub(u.windowStart) >= time.Minute {
+		u.windowStart = now
+		u.windowCount = 0
+	}
+}
+
+// AcquireConnection резервирует подключение тенанта. Возвращённую функцию
+// нужно вызвать при закрытии соединения.
+func (m *Manager) AcquireConnection(tenant string) (release func(), err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, u := m.limits(tenant), m.get(tenant)
+	if l.MaxConnections > 0 && u.connections >= l.MaxConnections {
+		return nil, &ExceededError{Tenant: tenant, Resource: ResourceConnections, Limit: l.MaxConnections, Used: u.connections}
+	}
+	u.connections++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			u.connections--
+			m.mu.Unlock()
+		})
+	}, nil
+}
+
+// ReservePublish резервирует публикацию события размером size байт,
+// проверяя минутный лимит публикаций и объём хранимых данных. Если
+// публикация не состоялась, резерв нужно вернуть вызовом cancel, чтобы
+// отклонённые события не расходовали квоту.
+func (m *Manager) ReservePublish(tenant string, size int64) (cancel func(), err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, u := m.limits(tenant), m.get(tenant)
+	m.rollWindow(u)
+	if l.MaxEventsPerMinute > 0 && u.windowCount >= l.MaxEventsPerMinute {
+		return nil, &ExceededError{Tenant: tenant, Resource: ResourcePublishRate, Limit: l.MaxEventsPerMinute, Used: u.windowCount}
+	}
+	if !m.stored {
+		size = 0
+	}
+	if l.MaxStoredBytes > 0 && size > 0 && u.storedBytes+size > l.MaxStoredBytes {
+		return nil, &ExceededError{Tenant: tenant, Resource: ResourceStoredBytes, Limit: l.MaxStoredBytes, Used: u.storedBytes}
+	}
+	u.windowCount++
+	u.storedBytes += size
+	window := u.windowStart
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			// Публикация из истёкшего окна в новом уже не учтена.
+			if u.windowStart.Equal(window) {
+				u.windowCount = max(u.windowCount-1, 0)
+			}
+			u.storedBytes = max(u.storedBytes-size, 0)
+		})
+	}, nil
+}
+
+// SyncStored заменяет учтённый объём хранимых данных тенантов объёмом
+// истории по пространствам имён; тенанты, которых нет в stored, ничего не
+// хранят. Вызывается после записи, очистки и уплотнения истории, поэтому
+// удалённые из неё события освобождают квоту.
+func (m *Manager) SyncStored(stored map[string]int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stored = true
+	for tenant, u := range m.usage {
+		u.storedBytes = stored[tenant]
+	}
+	for tenant, size := range stored {
+		m.get(tenant).storedBytes = size
+	}
+}
+
+// Usage возвращает потребление тенанта.
+func (m *Manager) Usage(tenant string) Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snapshot(tenant)
+}
+
+// AllUsage возвращает потребление всех известных тенантов.
+func (m *Manager) AllUsage() []Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]Usage, 0, len(m.usage))
+	for tenant := range m.usage {
+		result = append(result, m.snapshot(tenant))
+	}
+	return result
+}
+
+// snapshot формирует срез потребления тенанта. Вызывается под m.mu.
+func (m *Manager) snapshot(tenant string) Usage {
+	u := m.get(tenant)
+	m.rollWindow(u)
+	return Usage{
+		Tenant:          tenant,
+		Limits:          m.limits(tenant),
+		Connections:     u.connections,
+		EventsPerMinute: u.windowCount,
+		StoredBytes:     u.storedBytes,
+	}
+}
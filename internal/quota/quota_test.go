@@ -0,0 +1,49 @@
+package quota
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestReservePublishCancel проверяет, что отменённая публикация не
+// расходует ни лимит публикаций, ни объём хранимых данных.
+func TestReservePublishCancel(t *testing.T) {
+	m := NewManager(Limits{MaxEventsPerMinute: 1, MaxStoredBytes: 100}, nil)
+	m.SyncStored(nil)
+	cancel, err := m.ReservePublish("t1", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	cancel()
+	if u := m.Usage("t1"); u.EventsPerMinute != 0 || u.StoredBytes != 0 {
+		t.Fatalf("usage after cancel = %+v", u)
+	}
+	if _, err := m.ReservePublish("t1", 60); err != nil {
+		t.Fatalf("publish after cancel: %v", err)
+	}
+	if _, err := m.ReservePublish("t1", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("second publish = %v, want quota exceeded", err)
+	}
+}
+
+// TestSyncStored проверяет, что объём хранимых данных следует за историей:
+// удалённые из неё события освобождают квоту.
+func TestSyncStored(t *testing.T) {
+	m := NewManager(Limits{MaxStoredBytes: 100}, nil)
+	if _, err := m.ReservePublish("t1", 1000); err != nil {
+		t.Fatalf("without history stored bytes are not limited: %v", err)
+	}
+	m.SyncStored(map[string]int64{"t1": 90})
+	var exceeded *ExceededError
+	if _, err := m.ReservePublish("t1", 20); !errors.As(err, &exceeded) || exceeded.Resource != ResourceStoredBytes {
+		t.Fatalf("publish over stored limit = %v", err)
+	}
+	m.SyncStored(map[string]int64{})
+	if _, err := m.ReservePublish("t1", 20); err != nil {
+		t.Fatalf("publish after history pruned: %v", err)
+	}
+	if got := m.Usage("t1").StoredBytes; got != 20 {
+		t.Fatalf("stored bytes = %d, want 20", got)
+	}
+}
@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Бакеты BoltDB: события по ID и вторичные индексы по времени и по типу.
+// Ключ индекса по времени — 8 байт времени (big-endian, наносекунды Unix) и ID,
+// индекса по типу — тип, нулевой байт, время и ID; значения индексов пусты.
+var (
+	boltEvents = []byte("events")
+	boltByTime = []byte("events_by_time")
+	boltByType = []byte("events_by_type")
+	boltMeta   = []byte("meta")
+	boltSeqKey = []byte("last_seq")
+)
+
+// BoltRepository хранит события во встроенной key-value БД BoltDB.
+// В отличие от SQLite не требует cgo, поэтому клиент с ним легко
+// собирается под другие платформы.
+type BoltRepository struct {
+	DB *bolt.DB
+}
+
+// NewBoltRepository создаёт новый экземпляр репозитория.
+func NewBoltRepository(db *bolt.DB) *BoltRepository {
+	return &BoltRepository{DB: db}
+}
+
+// OpenBoltRepository открывает (или создаёт) файл БД и инициализирует репозиторий.
+func OpenBoltRepository(path string) (*BoltRepository, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	repo := NewBoltRepository(db)
+	if err := repo.Init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// Init создаёт бакеты, если их ещё нет.
+func (repo *BoltRepository) Init() error {
+	return repo.DB.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltEvents, boltByTime, boltByType, boltMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Save сохраняет событие, если события с таким ID ещё нет.
+func (repo *BoltRepository) Save(event domain.Event) error {
+	return unavailable(repo.DB.Update(func(tx *bolt.Tx) error {
+		return putEvent(tx, event)
+	}))
+}
+
+// SaveBatch сохраняет события одной транзакцией.
+func (repo *BoltRepository) SaveBatch(events []domain.Event) error {
+	return unavailable(repo.DB.Update(func(tx *bolt.Tx) error {
+		for _, event := range events {
+			if err := putEvent(tx, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// putEvent записывает событие и его индексы; существующее событие не меняется.
+func putEvent(tx *bolt.Tx, event domain.Event) error {
+	events := tx.Bucket(boltEvents)
+	id := []byte(event.ID)
+	if events.Get(id) != nil {
+		return nil
+	}
+	event.Timestamp = event.Timestamp.UTC()
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := events.Put(id, data); err != nil {
+		return err
+	}
+	tk := timeKey(event.Timestamp, event.ID)
+	if err := tx.Bucket(boltByTime).Put(tk, nil); err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltByType).Put(append(typePrefix(event.Type), tk...), nil); err != nil {
+		return err
+	}
+	if event.Seq == 0 {
+		return nil
+	}
+	meta := tx.Bucket(boltMeta)
+	if cur := meta.Get(boltSeqKey); cur != nil && binary.BigEndian.Uint64(cur) >= event.Seq {
+		return nil
+	}
+	return meta.Put(boltSeqKey, binary.BigEndian.AppendUint64(nil, event.Seq))
+}
+
+// LastSeq возвращает наибольший сохранённый серверный номер события.
+func (repo *BoltRepository) LastSeq() (uint64, error) {
+	var seq uint64
+	err := repo.DB.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(boltMeta).Get(boltSeqKey); v != nil {
+			seq = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	return seq, err
+}
+
+// Query возвращает события, удовлетворяющие фильтру, в порядке времени.
+func (repo *BoltRepository) Query(filter EventFilter) ([]domain.Event, error) {
+	var events []domain.Event
+	err := repo.Each(filter, func(event domain.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+// Each последовательно передаёт fn события, удовлетворяющие фильтру.
+// Для выборки одного типа используется индекс по типу, иначе — по времени.
+// Ошибка fn прерывает обход.
+func (repo *BoltRepository) Each(filter EventFilter, fn func(domain.Event) error) error {
+	return repo.DB.View(func(tx *bolt.Tx) error {
+		var (
+			index  = tx.Bucket(boltByTime)
+			prefix []byte
+		)
+		if len(filter.Types) == 1 {
+			index = tx.Bucket(boltByType)
+			prefix = typePrefix(filter.Types[0])
+		}
+		start := prefix
+		if !filter.Since.IsZero() {
+			start = append(append([]byte{}, prefix...), timeKey(filter.Since, "")...)
+		}
+		var until []byte
+		if !filter.Until.IsZero() {
+			until = append(append([]byte{}, prefix...), timeKey(filter.Until, "")...)
+		}
+
+		events := tx.Bucket(boltEvents)
+		matched := 0
+		c := index.Cursor()
+		for k, _ := c.Seek(start); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			if until != nil && bytes.Compare(k, until) >= 0 {
+				break
+			}
+			data := events.Get(k[len(prefix)+8:])
+			if data == nil {
+				continue
+			}
+			var event domain.Event
+			if err := json.Unmarshal(data, &event); err != nil {
+				return err
+			}
+			if !matchFilter(filter, event) {
+				continue
+			}
+			if err := fn(event); err != nil {
+				return err
+			}
+			matched++
+			if filter.Limit > 0 && matched >= filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// Count возвращает число событий, удовлетворяющих фильтру, без учёта Limit.
+func (repo *BoltRepository) Count(filter EventFilter) (int, error) {
+	filter.Limit = 0
+	n := 0
+	err := repo.Each(filter, func(domain.Event) error {
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// DeleteOlderThan удаляет события с временем раньше cutoff вместе с их индексами.
+func (repo *BoltRepository) DeleteOlderThan(cutoff time.Time) (int, error) {
+	until := timeKey(cutoff, "")
+	var n int
+	err := repo.DB.Update(func(tx *bolt.Tx) (err error) {
+		n, err = deleteOldest(tx, func(k []byte, _ int) bool {
+			return bytes.Compare(k, until) < 0
+		})
+		return err
+	})
+	return n, err
+}
+
+// DeleteExceeding удаляет самые старые события сверх maxRows вместе с их индексами.
+func (repo *BoltRepository) DeleteExceeding(maxRows int) (int, error) {
+	var n int
+	err := repo.DB.Update(func(tx *bolt.Tx) (err error) {
+		excess := tx.Bucket(boltEvents).Stats().KeyN - maxRows
+		n, err = deleteOldest(tx, func(_ []byte, deleted int) bool {
+			return deleted < excess
+		})
+		return err
+	})
+	return n, err
+}
+
+// deleteOldest удаляет события в порядке времени вместе с их индексами, пока
+// more возвращает true для очередного ключа индекса по времени и числа уже
+// удалённых событий.
+func deleteOldest(tx *bolt.Tx, more func(key []byte, deleted int) bool) (int, error) {
+	events, byType := tx.Bucket(boltEvents), tx.Bucket(boltByType)
+	n := 0
+	c := tx.Bucket(boltByTime).Cursor()
+	// Удаление через курсор сдвигает его на следующий ключ, поэтому
+	// каждый раз читаем первый ключ бакета.
+	for k, _ := c.First(); k != nil && more(k, n); k, _ = c.First() {
+		id := k[8:]
+		if data := events.Get(id); data != nil {
+			var event domain.Event
+			if err := json.Unmarshal(data, &event); err != nil {
+				return n, err
+			}
+			if err := byType.Delete(append(typePrefix(event.Type), k...)); err != nil {
+				return n, err
+			}
+			if err := events.Delete(id); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if err := c.Delete(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Close закрывает файл БД.
+func (repo *BoltRepository) Close() error {
+	return repo.DB.Close()
+}
+
+// timeKey строит ключ индекса по времени. Время до начала эпохи Unix
+// приводится к нулю, чтобы ключи оставались упорядоченными.
+func timeKey(ts time.Time, id string) []byte {
+	var nanos uint64
+	if ts.After(time.Unix(0, 0)) {
+		nanos = uint64(ts.UnixNano())
+	}
+	key := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(id)), nanos)
+	return append(key, id...)
+}
+
+// typePrefix возвращает префикс ключей индекса по типу.
+func typePrefix(eventType string) []byte {
+	return append([]byte(eventType), 0)
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// encPrefix помечает зашифрованное значение колонки. Значения без префикса
+// читаются как есть, поэтому базу, заполненную до включения шифрования,
+// можно продолжать использовать.
+const encPrefix = "enc:v1:"
+
+// KeySize — длина ключа шифрования в байтах (AES-256).
+const KeySize = 32
+
+// FieldCipher шифрует содержимое событий (сообщение, data, метаданные,
+// источник) и сообщения очереди outbox алгоритмом AES-256-GCM.
+// Шифротекст привязан к ID события и не может быть перенесён в другую строку.
+// Хэш содержимого для дедупликации хранится как HMAC, чтобы по нему нельзя
+// было подобрать короткие сообщения.
+type FieldCipher struct {
+	aead   cipher.AEAD
+	macKey []byte
+}
+
+// NewFieldCipher создаёт шифратор с ключом длиной KeySize байт.
+func NewFieldCipher(key []byte) (*FieldCipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("eventsync content hash"))
+	return &FieldCipher{aead: aead, macKey: mac.Sum(nil)}, nil
+}
+
+// ParseKey разбирает ключ, записанный в hex (64 символа) или base64.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := hex.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("encryption key must be %d bytes encoded as hex or base64", KeySize)
+}
+
+// encrypt шифрует значение колонки события id.
+func (c *FieldCipher) encrypt(id, plaintext string) string {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err) // crypto/rand не возвращает ошибок на поддерживаемых платформах
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(id))
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed)
+}
+
+// decrypt расшифровывает значение колонки события id; значение без
+// префикса возвращается без изменений.
+func (c *FieldCipher) decrypt(id, value string) (string, error) {
+	if !strings.HasPrefix(value, encPrefix) {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value[len(encPrefix):])
+	if err != nil {
+		return "", fmt.Errorf("decrypt event %s: %w", id, err)
+	}
+	n := c.aead.NonceSize()
+	if len(sealed) < n {
+		return "", fmt.Errorf("decrypt event %s: ciphertext too short", id)
+	}
+	plain, err := c.aead.Open(nil, sealed[:n], sealed[n:], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("decrypt event %s: %w", id, err)
+	}
+	return string(plain), nil
+}
+
+// hashKey возвращает значение колонки content_hash для хэша содержимого.
+func (c *FieldCipher) hashKey(hash string) string {
+	mac := hmac.New(sha256.New, c.macKey)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// encryptData шифрует полезную нагрузку и сохраняет её строкой JSON, чтобы
+// колонка data по-прежнему проходила проверку json_valid.
+func (c *FieldCipher) encryptData(id string, data []byte) []byte {
+	encoded, _ := json.Marshal(c.encrypt(id, string(data)))
+	return encoded
+}
+
+// decryptData расшифровывает полезную нагрузку, сохранённую encryptData;
+// незашифрованная нагрузка возвращается без изменений.
+func (c *FieldCipher) decryptData(id string, data []byte) ([]byte, error) {
+	if !strings.HasPrefix(string(data), `"`+encPrefix) {
+		return data, nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("decrypt event %s: %w", id, err)
+	}
+	plain, err := c.decrypt(id, value)
+	return []byte(plain), err
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+func testCipher(t *testing.T) *FieldCipher {
+	t.Helper()
+	c, err := NewFieldCipher(bytes.Repeat([]byte{7}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "client.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// TestSQLiteEncryptsEventContent проверяет, что ни одна колонка с
+// содержимым события не попадает на диск открытым текстом, а фильтры по
+// зашифрованным колонкам продолжают работать.
+func TestSQLiteEncryptsEventContent(t *testing.T) {
+	repo := NewSQLiteRepository(openTestDB(t))
+	repo.Cipher = testCipher(t)
+	if err := repo.Init(); err != nil {
+		t.Fatal(err)
+	}
+	event := domain.Event{
+		ID: "e1", Seq: 1, Type: "order", Message: "secret-message", Data: []byte(`{"card":"secret-data"}`),
+		Metadata: map[string]string{"user": "secret-meta"}, Source: "secret-source", Timestamp: time.Now(),
+	}
+	if err := repo.Save(event); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Save(domain.Event{ID: "e2", Seq: 2, Type: "order", Source: "other", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	var message, data, metadata, source string
+	err := repo.DB.QueryRow(`SELECT message, data, metadata, source FROM events WHERE id = 'e1';`).Scan(&message, &data, &metadata, &source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range []string{message, data, metadata, source} {
+		if strings.Contains(column, "secret") {
+			t.Errorf("column stored in plaintext: %s", column)
+		}
+	}
+
+	events, err := repo.Query(EventFilter{Source: "secret-source"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Message != event.Message || string(events[0].Data) != string(event.Data) ||
+		events[0].Metadata["user"] != "secret-meta" || events[0].Source != event.Source {
+		t.Fatalf("Query by source = %+v", events)
+	}
+	if n, err := repo.Count(EventFilter{Source: "other"}); err != nil || n != 1 {
+		t.Fatalf("Count by source = %d, %v", n, err)
+	}
+}
+
+func TestSQLiteOutboxEncryption(t *testing.T) {
+	o := NewSQLiteOutbox(openTestDB(t))
+	o.Cipher = testCipher(t)
+	if err := o.Init(); err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"kind":"publish","event":{"message":"secret"}}`)
+	if err := o.Enqueue("c1", payload); err != nil {
+		t.Fatal(err)
+	}
+	var stored []byte
+	if err := o.DB.QueryRow(`SELECT payload FROM outbox;`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, []byte("secret")) {
+		t.Fatalf("outbox payload stored in plaintext: %s", stored)
+	}
+	msgs, err := o.Pending("c1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || !bytes.Equal(msgs[0].Payload, payload) {
+		t.Fatalf("Pending = %+v", msgs)
+	}
+}
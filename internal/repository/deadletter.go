@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// DeadLetter — событие, которое не удалось сохранить, с последней ошибкой
+// и числом неудачных попыток.
+type DeadLetter struct {
+	Event    domain.Event `json:"event"`
+	Error    string       `json:"error"`
+	Attempts int          `json:"attempts"`
+	FailedAt time.Time    `json:"failed_at"`
+}
+
+// SQLiteDeadLetters — таблица dead_letters в БД клиента. При заданном Cipher
+// события в ней шифруются так же, как в таблице events.
+type SQLiteDeadLetters struct {
+	DB     *sql.DB
+	Cipher *FieldCipher
+}
+
+// NewSQLiteDeadLetters создаёт очередь недоставленных событий поверх открытой БД.
+func NewSQLiteDeadLetters(db *sql.DB) *SQLiteDeadLetters {
+	return &SQLiteDeadLetters{DB: db}
+}
+
+// Init создаёт таблицу, если её ещё нет.
+func (d *SQLiteDeadLetters) Init() error {
+	_, err := d.DB.Exec(`
+        CREATE TABLE IF NOT EXISTS dead_letters (
+            id TEXT PRIMARY KEY,
+            event TEXT NOT NULL,
+            error TEXT NOT NULL,
+            attempts INTEGER NOT NULL,
+            failed_at DATETIME NOT NULL
+        );
+    `)
+	return err
+}
+
+// Put помещает событие в очередь или обновляет ошибку и число попыток
+// уже помещённого.
+func (d *SQLiteDeadLetters) Put(letter DeadLetter) error {
+	data, err := json.Marshal(letter.Event)
+	if err != nil {
+		return err
+	}
+	payload := string(data)
+	if d.Cipher != nil {
+		payload = d.Cipher.encrypt(letter.Event.ID, payload)
+	}
+	_, err = d.DB.Exec(`INSERT INTO dead_letters (id, event, error, attempts, failed_at) VALUES (?, ?, ?, ?, ?)
+        ON CONFLICT (id) DO UPDATE SET event = excluded.event, error = excluded.error,
+            attempts = excluded.attempts, failed_at = excluded.failed_at;`,
+		letter.Event.ID, payload, letter.Error, letter.Attempts, letter.FailedAt.UTC())
+	return unavailable(err)
+}
+
+// List возвращает до limit событий очереди в порядке поступления; limit <= 0 — все.
+func (d *SQLiteDeadLetters) List(limit int) ([]DeadLetter, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := d.DB.Query(`SELECT id, event, error, attempts, failed_at FROM dead_letters ORDER BY failed_at, id LIMIT ?;`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var letters []DeadLetter
+	for rows.Next() {
+		var (
+			letter      DeadLetter
+			id, payload string
+		)
+		if err := rows.Scan(&id, &payload, &letter.Error, &letter.Attempts, &letter.FailedAt); err != nil {
+			return nil, err
+		}
+		if d.Cipher != nil {
+			if payload, err = d.Cipher.decrypt(id, payload); err != nil {
+				return nil, err
+			}
+		}
+		if err := json.Unmarshal([]byte(payload), &letter.Event); err != nil {
+			return nil, fmt.Errorf("decode dead letter %s: %w", id, err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+// Remove удаляет событие из очереди.
+func (d *SQLiteDeadLetters) Remove(id string) error {
+	_, err := d.DB.Exec(`DELETE FROM dead_letters WHERE id = ?;`, id)
+	return err
+}
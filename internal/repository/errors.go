@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	bolt "go.etcd.io/bbolt"
+)
+
+// unavailable оборачивает ошибку хранилища в domain.ErrStoreUnavailable,
+// если она временная: БД занята, заблокирована, закрыта или недоступна
+// для ввода-вывода. Остальные ошибки возвращаются без изменений.
+func unavailable(err error) error {
+	if err == nil || !transient(err) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", domain.ErrStoreUnavailable, err)
+}
+
+func transient(err error) bool {
+	var se sqlite3.Error
+	if errors.As(err, &se) {
+		switch se.Code {
+		case sqlite3.ErrBusy, sqlite3.ErrLocked, sqlite3.ErrIoErr, sqlite3.ErrCantOpen, sqlite3.ErrFull:
+			return true
+		}
+		return false
+	}
+	return errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, bolt.ErrDatabaseNotOpen) ||
+		errors.Is(err, bolt.ErrTimeout)
+}
@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+const (
+	jsonlCurrent = "events.jsonl"
+	jsonlPrefix  = "events-"
+	jsonlSuffix  = ".jsonl"
+	// jsonlRotatedLayout — время ротации в имени архивного файла; имена
+	// сортируются в хронологическом порядке.
+	jsonlRotatedLayout = "20060102T150405.000000000"
+)
+
+// JSONLRepository дописывает события построчно в JSON-файлы каталога Dir:
+// текущий файл events.jsonl, архивные — events-<время ротации>.jsonl.
+// Это аудиторский журнал, который удобно просматривать grep и отправлять в
+// систему сбора логов. Журнал только дописывается, поэтому повторная
+// доставка, не отсечённая кэшем дедупликации клиента, даёт повторную строку.
+type JSONLRepository struct {
+	Dir string
+	// MaxFileSize — размер текущего файла в байтах, после которого он
+	// ротируется; 0 — без ротации.
+	MaxFileSize int64
+	// MaxFiles — число хранимых архивных файлов; старые удаляются. 0 — все.
+	MaxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	seq  uint64
+}
+
+// NewJSONLRepository создаёт репозиторий, пишущий в каталог dir.
+func NewJSONLRepository(dir string, maxFileSize int64, maxFiles int) *JSONLRepository {
+	return &JSONLRepository{Dir: dir, MaxFileSize: maxFileSize, MaxFiles: maxFiles}
+}
+
+// Init создаёт каталог, открывает текущий файл на дозапись и восстанавливает
+// наибольший номер события из уже записанных файлов.
+func (repo *JSONLRepository) Init() error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if err := os.MkdirAll(repo.Dir, 0o755); err != nil {
+		return err
+	}
+	files, err := repo.files()
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		if err := scanJSONL(name, func(event domain.Event) {
+			if event.Seq > repo.seq {
+				repo.seq = event.Seq
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	return repo.open()
+}
+
+// open открывает текущий файл на дозапись.
+func (repo *JSONLRepository) open() error {
+	f, err := os.OpenFile(filepath.Join(repo.Dir, jsonlCurrent), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	repo.file, repo.size = f, info.Size()
+	return nil
+}
+
+// Save дописывает событие и сбрасывает файл на диск.
+func (repo *JSONLRepository) Save(event domain.Event) error {
+	return repo.SaveBatch([]domain.Event{event})
+}
+
+// SaveBatch дописывает события и сбрасывает файл на диск один раз.
+func (repo *JSONLRepository) SaveBatch(events []domain.Event) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.file == nil {
+		return fmt.Errorf("jsonl repository is not initialized")
+	}
+	var buf []byte
+	for _, event := range events {
+		event.Timestamp = event.Timestamp.UTC()
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	n, err := repo.file.Write(buf)
+	repo.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if err := repo.file.Sync(); err != nil {
+		return err
+	}
+	for _, event := range events {
+		if event.Seq > repo.seq {
+			repo.seq = event.Seq
+		}
+	}
+	if repo.MaxFileSize > 0 && repo.size >= repo.MaxFileSize {
+		return repo.rotate()
+	}
+	return nil
+}
+
+// rotate переименовывает текущий файл в архивный, открывает новый и
+// удаляет архивные файлы сверх MaxFiles.
+func (repo *JSONLRepository) rotate() error {
+	if err := repo.file.Close(); err != nil {
+		return err
+	}
+	repo.file = nil
+	rotated := jsonlPrefix + time.Now().UTC().Format(jsonlRotatedLayout) + jsonlSuffix
+	if err := os.Rename(filepath.Join(repo.Dir, jsonlCurrent), filepath.Join(repo.Dir, rotated)); err != nil {
+		return err
+	}
+	if err := repo.open(); err != nil {
+		return err
+	}
+	if repo.MaxFiles <= 0 {
+		return nil
+	}
+	archived, err := filepath.Glob(filepath.Join(repo.Dir, jsonlPrefix+"*"+jsonlSuffix))
+	if err != nil {
+		return err
+	}
+	sort.Strings(archived)
+	for len(archived) > repo.MaxFiles {
+		if err := os.Remove(archived[0]); err != nil {
+			return err
+		}
+		archived = archived[1:]
+	}
+	return nil
+}
+
+// files возвращает файлы журнала от старых к новым.
+func (repo *JSONLRepository) files() ([]string, error) {
+	archived, err := filepath.Glob(filepath.Join(repo.Dir, jsonlPrefix+"*"+jsonlSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(archived)
+	current := filepath.Join(repo.Dir, jsonlCurrent)
+	if _, err := os.Stat(current); err == nil {
+		archived = append(archived, current)
+	}
+	return archived, nil
+}
+
+// LastSeq возвращает наибольший записанный серверный номер события.
+func (repo *JSONLRepository) LastSeq() (uint64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	return repo.seq, nil
+}
+
+// Query читает журнал и возвращает события, удовлетворяющие фильтру, в
+// порядке времени. Повторные строки одного события возвращаются один раз.
+func (repo *JSONLRepository) Query(filter EventFilter) ([]domain.Event, error) {
+	repo.mu.Lock()
+	files, err := repo.files()
+	if err != nil {
+		repo.mu.Unlock()
+		return nil, err
+	}
+	var events []domain.Event
+	seen := make(map[string]struct{})
+	for _, name := range files {
+		err := scanJSONL(name, func(event domain.Event) {
+			if _, dup := seen[event.ID]; dup || !matchFilter(filter, event) {
+				return
+			}
+			seen[event.ID] = struct{}{}
+			events = append(events, event)
+		})
+		if err != nil {
+			repo.mu.Unlock()
+			return nil, err
+		}
+	}
+	repo.mu.Unlock()
+
+	sortEvents(events)
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+// Each передаёт fn события, удовлетворяющие фильтру, в порядке времени.
+func (repo *JSONLRepository) Each(filter EventFilter, fn func(domain.Event) error) error {
+	events, err := repo.Query(filter)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count возвращает число событий, удовлетворяющих фильтру, без учёта Limit.
+func (repo *JSONLRepository) Count(filter EventFilter) (int, error) {
+	filter.Limit = 0
+	events, err := repo.Query(filter)
+	return len(events), err
+}
+
+// DeleteOlderThan удаляет архивные файлы, все события которых раньше cutoff.
+// Журнал только дописывается, поэтому текущий файл и архивы с более новыми
+// событиями сохраняются целиком.
+func (repo *JSONLRepository) DeleteOlderThan(cutoff time.Time) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	archived, err := filepath.Glob(filepath.Join(repo.Dir, jsonlPrefix+"*"+jsonlSuffix))
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, name := range archived {
+		n, expired := 0, true
+		err := scanJSONL(name, func(event domain.Event) {
+			n++
+			if !event.Timestamp.Before(cutoff) {
+				expired = false
+			}
+		})
+		if err != nil {
+			return deleted, err
+		}
+		if !expired {
+			continue
+		}
+		if err := os.Remove(name); err != nil {
+			return deleted, err
+		}
+		deleted += n
+	}
+	return deleted, nil
+}
+
+// Close закрывает текущий файл.
+func (repo *JSONLRepository) Close() error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.file == nil {
+		return nil
+	}
+	err := repo.file.Close()
+	repo.file = nil
+	return err
+}
+
+// scanJSONL передаёт fn события из файла журнала. Недописанная последняя
+// строка (например, после аварийной остановки) пропускается.
+func scanJSONL(name string, fn func(domain.Event)) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var event domain.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			continue
+		}
+		fn(event)
+	}
+	return sc.Err()
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// MemoryRepository хранит события в памяти процесса. Подходит для тестов,
+// эфемерных потребителей и замеров производительности: данные теряются
+// при остановке клиента.
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	limit  int // максимальное число событий; 0 — без ограничения
+	events map[string]domain.Event
+	order  []string // ID в порядке сохранения, для вытеснения самых старых
+	seq    uint64
+}
+
+// NewMemoryRepository создаёт репозиторий в памяти. При limit > 0 хранится не
+// больше limit событий: при переполнении вытесняются сохранённые раньше всех.
+func NewMemoryRepository(limit int) *MemoryRepository {
+	return &MemoryRepository{limit: limit, events: make(map[string]domain.Event)}
+}
+
+// Init ничего не делает: репозиторий готов к работе сразу после создания.
+func (repo *MemoryRepository) Init() error {
+	return nil
+}
+
+// Save сохраняет событие, если события с таким ID ещё нет.
+func (repo *MemoryRepository) Save(event domain.Event) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.put(event)
+	return nil
+}
+
+// SaveBatch сохраняет события под одной блокировкой.
+func (repo *MemoryRepository) SaveBatch(events []domain.Event) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for _, event := range events {
+		repo.put(event)
+	}
+	return nil
+}
+
+// Replace сохраняет событие, заменяя прежнее с тем же ID; заменённое
+// событие переносится в конец порядка хранения.
+func (repo *MemoryRepository) Replace(event domain.Event) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if _, ok := repo.events[event.ID]; ok {
+		delete(repo.events, event.ID)
+		for i, id := range repo.order {
+			if id == event.ID {
+				repo.order = append(repo.order[:i], repo.order[i+1:]...)
+				break
+			}
+		}
+	}
+	repo.put(event)
+	return nil
+}
+
+func (repo *MemoryRepository) put(event domain.Event) {
+	if _, ok := repo.events[event.ID]; ok {
+		return
+	}
+	event.Timestamp = event.Timestamp.UTC()
+	repo.events[event.ID] = event
+	repo.order = append(repo.order, event.ID)
+	if event.Seq > repo.seq {
+		repo.seq = event.Seq
+	}
+	if repo.limit > 0 && len(repo.order) > repo.limit {
+		evicted := len(repo.order) - repo.limit
+		for _, id := range repo.order[:evicted] {
+			delete(repo.events, id)
+		}
+		repo.order = append(repo.order[:0], repo.order[evicted:]...)
+	}
+}
+
+// LastSeq возвращает наибольший сохранённый серверный номер события,
+// включая вытесненные события.
+func (repo *MemoryRepository) LastSeq() (uint64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	return repo.seq, nil
+}
+
+// Query возвращает события, удовлетворяющие фильтру, в порядке времени.
+func (repo *MemoryRepository) Query(filter EventFilter) ([]domain.Event, error) {
+	repo.mu.RLock()
+	var events []domain.Event
+	for _, event := range repo.events {
+		if matchFilter(filter, event) {
+			events = append(events, event)
+		}
+	}
+	repo.mu.RUnlock()
+
+	sortEvents(events)
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+// Each передаёт fn события, удовлетворяющие фильтру. Выборка делается
+// снимком, поэтому fn может обращаться к репозиторию.
+func (repo *MemoryRepository) Each(filter EventFilter, fn func(domain.Event) error) error {
+	events, err := repo.Query(filter)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count возвращает число событий, удовлетворяющих фильтру, без учёта Limit.
+func (repo *MemoryRepository) Count(filter EventFilter) (int, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	n := 0
+	for _, event := range repo.events {
+		if matchFilter(filter, event) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// DeleteOlderThan удаляет события с временем раньше cutoff.
+func (repo *MemoryRepository) DeleteOlderThan(cutoff time.Time) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	kept := repo.order[:0]
+	n := 0
+	for _, id := range repo.order {
+		if repo.events[id].Timestamp.Before(cutoff) {
+			delete(repo.events, id)
+			n++
+			continue
+		}
+		kept = append(kept, id)
+	}
+	repo.order = kept
+	return n, nil
+}
+
+// DeleteExceeding удаляет самые старые по времени события сверх maxRows.
+func (repo *MemoryRepository) DeleteExceeding(maxRows int) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	excess := len(repo.order) - maxRows
+	if excess <= 0 {
+		return 0, nil
+	}
+	events := make([]domain.Event, 0, len(repo.order))
+	for _, id := range repo.order {
+		events = append(events, repo.events[id])
+	}
+	sortEvents(events)
+	for _, event := range events[:excess] {
+		delete(repo.events, event.ID)
+	}
+	kept := repo.order[:0]
+	for _, id := range repo.order {
+		if _, ok := repo.events[id]; ok {
+			kept = append(kept, id)
+		}
+	}
+	repo.order = kept
+	return excess, nil
+}
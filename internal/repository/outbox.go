@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"database/sql"
+)
+
+// OutboxMessage — сообщение клиента серверу, ожидающее отправки.
+type OutboxMessage struct {
+	ID      int64
+	Payload []byte
+}
+
+// SQLiteOutbox — очередь исходящих сообщений клиента (подтверждений и
+// публикуемых событий) в таблице SQLite. Сообщения, которые не удалось
+// отправить без соединения, переживают перезапуск и отправляются по порядку
+// после переподключения. Owner разделяет очереди клиентов, использующих
+// одну БД. При заданном Cipher сообщения шифруются так же, как события.
+type SQLiteOutbox struct {
+	DB     *sql.DB
+	Cipher *FieldCipher
+}
+
+// NewSQLiteOutbox создаёт очередь поверх открытой БД.
+func NewSQLiteOutbox(db *sql.DB) *SQLiteOutbox {
+	return &SQLiteOutbox{DB: db}
+}
+
+// Init создаёт таблицу очереди, если её ещё нет.
+func (o *SQLiteOutbox) Init() error {
+	_, err := o.DB.Exec(`
+        CREATE TABLE IF NOT EXISTS outbox (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            owner TEXT NOT NULL,
+            payload BLOB NOT NULL,
+            created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX IF NOT EXISTS idx_outbox_owner ON outbox (owner, id);
+    `)
+	return err
+}
+
+// Enqueue добавляет сообщение в конец очереди owner.
+func (o *SQLiteOutbox) Enqueue(owner string, payload []byte) error {
+	if o.Cipher != nil {
+		// Номер сообщения до вставки неизвестен, поэтому шифротекст
+		// привязан к очереди владельца.
+		payload = []byte(o.Cipher.encrypt(outboxAD(owner), string(payload)))
+	}
+	_, err := o.DB.Exec(`INSERT INTO outbox (owner, payload) VALUES (?, ?);`, owner, payload)
+	return err
+}
+
+// Pending возвращает до limit первых сообщений очереди owner в порядке добавления.
+func (o *SQLiteOutbox) Pending(owner string, limit int) ([]OutboxMessage, error) {
+	rows, err := o.DB.Query(`SELECT id, payload FROM outbox WHERE owner = ? ORDER BY id LIMIT ?;`, owner, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var msgs []OutboxMessage
+	for rows.Next() {
+		var m OutboxMessage
+		if err := rows.Scan(&m.ID, &m.Payload); err != nil {
+			return nil, err
+		}
+		if o.Cipher != nil {
+			plain, err := o.Cipher.decrypt(outboxAD(owner), string(m.Payload))
+			if err != nil {
+				return nil, err
+			}
+			m.Payload = []byte(plain)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// outboxAD — дополнительные данные шифрования сообщений очереди owner.
+func outboxAD(owner string) string {
+	return "outbox:" + owner
+}
+
+// Remove удаляет отправленное сообщение.
+func (o *SQLiteOutbox) Remove(id int64) error {
+	_, err := o.DB.Exec(`DELETE FROM outbox WHERE id = ?;`, id)
+	return err
+}
+
+// Close закрывает БД очереди.
+func (o *SQLiteOutbox) Close() error {
+	return o.DB.Close()
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Pool задаёт пул соединений sql.DB. Нулевые значения оставляют настройки
+// database/sql по умолчанию: число открытых соединений не ограничено,
+// простаивающих хранится два, время жизни соединения не ограничено.
+type Pool struct {
+	MaxOpenConns    int           // предел открытых соединений
+	MaxIdleConns    int           // простаивающих соединений в пуле; <0 — не хранить
+	ConnMaxLifetime time.Duration // время, после которого соединение закрывается
+}
+
+// Validate проверяет допустимость значений.
+func (p Pool) Validate() error {
+	if p.MaxOpenConns < 0 {
+		return fmt.Errorf("invalid max_open_conns %d", p.MaxOpenConns)
+	}
+	if p.ConnMaxLifetime < 0 {
+		return fmt.Errorf("invalid conn_max_lifetime %s", p.ConnMaxLifetime)
+	}
+	return nil
+}
+
+// Apply применяет настройки к db. Простаивающих соединений не становится
+// больше MaxOpenConns: database/sql ограничивает их сам.
+func (p Pool) Apply(db *sql.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(max(p.MaxIdleConns, 0))
+	}
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+}
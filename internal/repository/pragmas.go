@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pragmas задаёт настройки SQLite, применяемые при инициализации
+// репозитория. Нулевые значения оставляют настройку SQLite по умолчанию.
+type Pragmas struct {
+	JournalMode string        // например, "WAL"
+	Synchronous string        // "OFF", "NORMAL", "FULL" или "EXTRA"
+	BusyTimeout time.Duration // сколько ждать снятия блокировки вместо SQLITE_BUSY
+	CacheSize   int           // PRAGMA cache_size: >0 — страниц, <0 — КиБ
+}
+
+var (
+	journalModes = map[string]bool{"DELETE": true, "TRUNCATE": true, "PERSIST": true, "MEMORY": true, "WAL": true, "OFF": true}
+	syncLevels   = map[string]bool{"OFF": true, "NORMAL": true, "FULL": true, "EXTRA": true}
+)
+
+// Validate проверяет допустимость значений.
+func (p Pragmas) Validate() error {
+	if p.JournalMode != "" && !journalModes[strings.ToUpper(p.JournalMode)] {
+		return fmt.Errorf("invalid journal_mode %q", p.JournalMode)
+	}
+	if p.Synchronous != "" && !syncLevels[strings.ToUpper(p.Synchronous)] {
+		return fmt.Errorf("invalid synchronous %q", p.Synchronous)
+	}
+	if p.BusyTimeout < 0 {
+		return fmt.Errorf("invalid busy_timeout %s", p.BusyTimeout)
+	}
+	return nil
+}
+
+// statements возвращает PRAGMA-запросы для заданных настроек.
+func (p Pragmas) statements() []string {
+	var stmts []string
+	if p.JournalMode != "" {
+		stmts = append(stmts, "PRAGMA journal_mode = "+strings.ToUpper(p.JournalMode)+";")
+	}
+	if p.Synchronous != "" {
+		stmts = append(stmts, "PRAGMA synchronous = "+strings.ToUpper(p.Synchronous)+";")
+	}
+	if p.BusyTimeout > 0 {
+		stmts = append(stmts, fmt.Sprintf("PRAGMA busy_timeout = %d;", p.BusyTimeout.Milliseconds()))
+	}
+	if p.CacheSize != 0 {
+		stmts = append(stmts, fmt.Sprintf("PRAGMA cache_size = %d;", p.CacheSize))
+	}
+	return stmts
+}
+
+// DSN дополняет путь к БД параметрами драйвера go-sqlite3. journal_mode
+// хранится в файле БД, а остальные настройки действуют на одно соединение:
+// чтобы они применялись ко всем соединениям пула, БД открывается с этим DSN.
+func (p Pragmas) DSN(path string) string {
+	params := url.Values{}
+	if p.JournalMode != "" {
+		params.Set("_journal_mode", strings.ToUpper(p.JournalMode))
+	}
+	if p.Synchronous != "" {
+		params.Set("_synchronous", strings.ToUpper(p.Synchronous))
+	}
+	if p.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(p.BusyTimeout.Milliseconds(), 10))
+	}
+	if p.CacheSize != 0 {
+		params.Set("_cache_size", strconv.Itoa(p.CacheSize))
+	}
+	if len(params) == 0 {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + params.Encode()
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// EventRepository определяет интерфейс хранилища событий клиента.
+type EventRepository interface {
+	Init() error
+	// Save сохраняет событие; повторное сохранение того же ID не является ошибкой.
+	// Временная недоступность хранилища сообщается ошибкой, обёрнутой в
+	// domain.ErrStoreUnavailable.
+	Save(event domain.Event) error
+	// Query возвращает события, удовлетворяющие фильтру, в порядке времени.
+	Query(filter EventFilter) ([]domain.Event, error)
+	// Each передаёт fn события, удовлетворяющие фильтру, в порядке времени;
+	// ошибка fn прерывает обход.
+	Each(filter EventFilter, fn func(domain.Event) error) error
+	// Count возвращает число событий, удовлетворяющих фильтру (без учёта Limit).
+	Count(filter EventFilter) (int, error)
+	// DeleteOlderThan удаляет события с временем раньше cutoff и возвращает их число.
+	DeleteOlderThan(cutoff time.Time) (int, error)
+	// LastSeq возвращает наибольший сохранённый серверный номер события; 0 — нет таких событий.
+	LastSeq() (uint64, error)
+}
+
+// ContentHashIndex реализуют хранилища, индексирующие события по
+// domain.Event.ContentHash. Через него клиент отсекает дубликаты по
+// содержимому и после вытеснения из кэша или перезапуска.
+type ContentHashIndex interface {
+	HasContentHash(hash string) (bool, error)
+}
+
+// EventReplacer реализуют хранилища, умеющие заменять сохранённое событие
+// событием с тем же ID (используется окном дедупликации клиента).
+type EventReplacer interface {
+	Replace(event domain.Event) error
+}
+
+// RowLimiter реализуют хранилища, умеющие ограничивать число хранимых событий.
+type RowLimiter interface {
+	// DeleteExceeding удаляет самые старые события сверх maxRows и
+	// возвращает их число.
+	DeleteExceeding(maxRows int) (int, error)
+}
+
+// EventFilter задаёт условия выборки сохранённых событий. Нулевые поля не ограничивают выборку.
+type EventFilter struct {
+	Types    []string  // типы событий
+	Since    time.Time // не раньше (включительно)
+	Until    time.Time // раньше (не включительно)
+	Contains string    // подстрока сообщения
+	Source   string    // источник события (точное совпадение)
+	Limit    int       // максимальное число событий; 0 — без ограничения
+}
+
+// matchFilter проверяет условия фильтра, не покрытые индексом.
+func matchFilter(filter EventFilter, event domain.Event) bool {
+	if len(filter.Types) > 0 {
+		found := false
+		for _, t := range filter.Types {
+			if t == event.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !filter.Since.IsZero() && event.Timestamp.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && !event.Timestamp.Before(filter.Until) {
+		return false
+	}
+	if filter.Source != "" && event.Source != filter.Source {
+		return false
+	}
+	if filter.Contains != "" && !strings.Contains(event.Message, filter.Contains) {
+		return false
+	}
+	return true
+}
+
+// sortEvents упорядочивает события по времени, а при равном времени — по ID,
+// как ORDER BY timestamp, id в SQLite.
+func sortEvents(events []domain.Event) {
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Timestamp.Equal(events[j].Timestamp) {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		}
+		return events[i].ID < events[j].ID
+	})
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// repoCase открывает хранилище событий по пути path для общего набора
+// проверок EventRepository.
+type repoCase struct {
+	name string
+	open func(t *testing.T, path string) EventRepository
+	// journal — журнал только дописывается: повторное сохранение добавляет
+	// событие ещё раз, а удаляются лишь целые архивные файлы (JSONL).
+	journal bool
+}
+
+var repoCases = []repoCase{
+	{name: "memory", open: func(*testing.T, string) EventRepository { return NewMemoryRepository(0) }},
+	{name: "sqlite", open: func(t *testing.T, path string) EventRepository {
+		return NewSQLiteRepository(openTestDB(t))
+	}},
+	{name: "sqlite encrypted", open: func(t *testing.T, path string) EventRepository {
+		repo := NewSQLiteRepository(openTestDB(t))
+		repo.Cipher = testCipher(t)
+		return repo
+	}},
+	{name: "bolt", open: func(t *testing.T, path string) EventRepository {
+		repo, err := OpenBoltRepository(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	}},
+	{name: "jsonl", open: func(t *testing.T, path string) EventRepository {
+		return NewJSONLRepository(path, 0, 0)
+	}, journal: true},
+}
+
+// ids возвращает ID событий по порядку.
+func ids(events []domain.Event) []string {
+	out := []string{}
+	for _, event := range events {
+		out = append(out, event.ID)
+	}
+	return out
+}
+
+// TestEventRepositories проверяет все реализации EventRepository одним
+// набором: сохранение, фильтры, порядок, LastSeq и удаление по возрасту.
+func TestEventRepositories(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []domain.Event{
+		{ID: "e1", Seq: 1, Type: "info", Message: "disk almost full", Source: "node-1", Timestamp: t0},
+		{ID: "e2", Seq: 2, Type: "error", Message: "disk full", Source: "node-2", Timestamp: t0.Add(time.Minute),
+			Metadata: map[string]string{"trace": "t2"}, CorrelationID: "c1"},
+		{ID: "e3", Seq: 3, Type: "info", Message: "ok", Source: "node-1", Timestamp: t0.Add(2 * time.Minute),
+			Data: []byte(`{"free":10}`)},
+	}
+	for _, tc := range repoCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := tc.open(t, filepath.Join(t.TempDir(), "events"))
+			if c, ok := repo.(io.Closer); ok {
+				t.Cleanup(func() { c.Close() })
+			}
+			if err := repo.Init(); err != nil {
+				t.Fatal(err)
+			}
+			for _, event := range events {
+				if err := repo.Save(event); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !tc.journal {
+				if err := repo.Save(events[0]); err != nil {
+					t.Fatalf("repeated Save: %v", err)
+				}
+			}
+			if seq, err := repo.LastSeq(); err != nil || seq != 3 {
+				t.Fatalf("LastSeq = %d, %v", seq, err)
+			}
+
+			all, err := repo.Query(EventFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ids(all), []string{"e1", "e2", "e3"}) {
+				t.Fatalf("Query = %v", ids(all))
+			}
+			for i, got := range all {
+				want := events[i]
+				if got.Message != want.Message || string(got.Data) != string(want.Data) || got.Source != want.Source ||
+					!reflect.DeepEqual(got.Metadata, want.Metadata) || got.CorrelationID != want.CorrelationID ||
+					!got.Timestamp.Equal(want.Timestamp) || got.Seq != want.Seq || got.Type != want.Type {
+					t.Errorf("event %s does not round-trip: %+v", want.ID, got)
+				}
+			}
+
+			for _, q := range []struct {
+				name   string
+				filter EventFilter
+				want   []string
+			}{
+				{"types", EventFilter{Types: []string{"info"}}, []string{"e1", "e3"}},
+				{"since", EventFilter{Since: t0.Add(time.Minute)}, []string{"e2", "e3"}},
+				{"until", EventFilter{Until: t0.Add(time.Minute)}, []string{"e1"}},
+				{"contains", EventFilter{Contains: "disk"}, []string{"e1", "e2"}},
+				{"source", EventFilter{Source: "node-1"}, []string{"e1", "e3"}},
+				{"limit", EventFilter{Limit: 2}, []string{"e1", "e2"}},
+				{"combined", EventFilter{Types: []string{"info"}, Contains: "disk", Source: "node-1"}, []string{"e1"}},
+			} {
+				got, err := repo.Query(q.filter)
+				if err != nil {
+					t.Fatalf("%s: %v", q.name, err)
+				}
+				if !reflect.DeepEqual(ids(got), q.want) {
+					t.Errorf("%s: Query = %v, want %v", q.name, ids(got), q.want)
+				}
+			}
+			if n, err := repo.Count(EventFilter{Types: []string{"info"}, Limit: 1}); err != nil || n != 2 {
+				t.Errorf("Count ignores Limit: %d, %v; want 2", n, err)
+			}
+
+			stop := errors.New("stop")
+			calls := 0
+			err = repo.Each(EventFilter{}, func(domain.Event) error {
+				calls++
+				return stop
+			})
+			if !errors.Is(err, stop) || calls != 1 {
+				t.Errorf("Each after fn error = %v with %d calls", err, calls)
+			}
+
+			deleted, kept := 1, []string{"e2", "e3"}
+			if tc.journal {
+				// Текущий файл журнала не удаляется.
+				deleted, kept = 0, []string{"e1", "e2", "e3"}
+			}
+			if n, err := repo.DeleteOlderThan(t0.Add(time.Minute)); err != nil || n != deleted {
+				t.Fatalf("DeleteOlderThan = %d, %v; want %d", n, err, deleted)
+			}
+			if got, _ := repo.Query(EventFilter{}); !reflect.DeepEqual(ids(got), kept) {
+				t.Fatalf("Query after DeleteOlderThan = %v", ids(got))
+			}
+		})
+	}
+}
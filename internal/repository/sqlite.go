@@ -2,19 +2,23 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/wrongjunior/eventsync/internal/domain"
 )
 
-// EventRepository определяет интерфейс для сохранения событий.
-type EventRepository interface {
-	Init() error
-	Save(event domain.Event) error
-}
-
 // SQLiteRepository реализует репозиторий на базе SQLite.
 type SQLiteRepository struct {
 	DB *sql.DB
+	// Pragmas применяются в Init до создания таблиц.
+	Pragmas Pragmas
+	// Cipher, если задан, шифрует сообщение и полезную нагрузку событий.
+	// Поиск по подстроке сообщения тогда выполняется после расшифровки.
+	Cipher *FieldCipher
 }
 
 // NewSQLiteRepository создаёт новый экземпляр репозитория.
@@ -22,8 +26,38 @@ func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
 	return &SQLiteRepository{DB: db}
 }
 
-// Init создаёт таблицу для хранения событий, если её ещё нет.
+// eventColumns — колонки, добавленные после первой версии схемы таблицы events.
+// Init добавляет недостающие колонки в существующие базы.
+var eventColumns = []struct{ name, decl string }{
+	{"data", "TEXT CHECK (data IS NULL OR json_valid(data))"},
+	{"correlation_id", "TEXT"},
+	{"causation_id", "TEXT"},
+	{"seq", "INTEGER"},
+	{"content_hash", "TEXT"},
+	{"metadata", "TEXT CHECK (metadata IS NULL OR json_valid(metadata))"},
+	{"source", "TEXT"},
+}
+
+// eventIndexes создаются после миграции колонок.
+var eventIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS idx_events_correlation_id ON events (correlation_id);`,
+	`CREATE INDEX IF NOT EXISTS idx_events_causation_id ON events (causation_id);`,
+	`CREATE INDEX IF NOT EXISTS idx_events_seq ON events (seq);`,
+	`CREATE INDEX IF NOT EXISTS idx_events_content_hash ON events (content_hash);`,
+	`CREATE INDEX IF NOT EXISTS idx_events_source ON events (source);`,
+}
+
+// Init создаёт таблицу для хранения событий, если её ещё нет, и
+// дополняет старые таблицы новыми колонками.
 func (repo *SQLiteRepository) Init() error {
+	if err := repo.Pragmas.Validate(); err != nil {
+		return err
+	}
+	for _, stmt := range repo.Pragmas.statements() {
+		if _, err := repo.DB.Exec(stmt); err != nil {
+			return fmt.Errorf("apply pragma: %w", err)
+		}
+	}
 	query := `
         CREATE TABLE IF NOT EXISTS events (
             id TEXT PRIMARY KEY,
@@ -32,13 +66,397 @@ func (repo *SQLiteRepository) Init() error {
             timestamp DATETIME
         );
     `
-	_, err := repo.DB.Exec(query)
-	return err
+	if _, err := repo.DB.Exec(query); err != nil {
+		return err
+	}
+	if err := repo.migrateColumns(); err != nil {
+		return err
+	}
+	for _, idx := range eventIndexes {
+		if _, err := repo.DB.Exec(idx); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// Save сохраняет событие, если такого события ещё нет.
+// migrateColumns добавляет отсутствующие колонки из eventColumns.
+func (repo *SQLiteRepository) migrateColumns() error {
+	rows, err := repo.DB.Query(`PRAGMA table_info(events);`)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, ctype      string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, col := range eventColumns {
+		if existing[col.name] {
+			continue
+		}
+		if _, err := repo.DB.Exec(fmt.Sprintf(`ALTER TABLE events ADD COLUMN %s %s;`, col.name, col.decl)); err != nil {
+			return fmt.Errorf("add column %s: %w", col.name, err)
+		}
+	}
+	return nil
+}
+
+// insertEvent — запрос сохранения события, общий для Save и SaveBatch.
+const insertEvent = `INSERT OR IGNORE INTO events (id, seq, type, message, data, correlation_id, causation_id, timestamp, content_hash, metadata, source)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+// replaceEvent — запрос Replace: прежнее событие с тем же ID удаляется.
+const replaceEvent = `INSERT OR REPLACE INTO events (id, seq, type, message, data, correlation_id, causation_id, timestamp, content_hash, metadata, source)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+
+// Save сохраняет событие, если такого события ещё нет. Время хранится в UTC,
+// чтобы строковое сравнение в SQLite совпадало с хронологическим. Временные
+// ошибки (БД занята или заблокирована) оборачиваются в domain.ErrStoreUnavailable.
 func (repo *SQLiteRepository) Save(event domain.Event) error {
-	query := `INSERT OR IGNORE INTO events (id, type, message, timestamp) VALUES (?, ?, ?, ?);`
-	_, err := repo.DB.Exec(query, event.ID, event.Type, event.Message, event.Timestamp)
+	_, err := repo.DB.Exec(insertEvent, repo.insertArgs(event)...)
+	return unavailable(err)
+}
+
+// Replace сохраняет событие, заменяя прежнее с тем же ID.
+func (repo *SQLiteRepository) Replace(event domain.Event) error {
+	_, err := repo.DB.Exec(replaceEvent, repo.insertArgs(event)...)
+	return unavailable(err)
+}
+
+// SaveBatch сохраняет события одной транзакцией: либо все, либо ни одного.
+func (repo *SQLiteRepository) SaveBatch(events []domain.Event) error {
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return unavailable(err)
+	}
+	stmt, err := tx.Prepare(insertEvent)
+	if err != nil {
+		tx.Rollback()
+		return unavailable(err)
+	}
+	defer stmt.Close()
+	for _, event := range events {
+		if _, err := stmt.Exec(repo.insertArgs(event)...); err != nil {
+			tx.Rollback()
+			return unavailable(fmt.Errorf("save event %s: %w", event.ID, err))
+		}
+	}
+	return unavailable(tx.Commit())
+}
+
+// insertArgs возвращает аргументы insertEvent для события, при заданном
+// Cipher — с зашифрованным содержимым: сообщением, полезной нагрузкой,
+// метаданными и источником. Открытыми остаются ID, номер, тип, время и
+// идентификаторы корреляции, по которым строятся индексы.
+func (repo *SQLiteRepository) insertArgs(event domain.Event) []any {
+	message, data, hash := event.Message, event.Data, event.ContentHash()
+	metadata, source := nullableMetadata(event.Metadata), event.Source
+	if repo.Cipher != nil {
+		message = repo.Cipher.encrypt(event.ID, message)
+		if len(data) > 0 {
+			data = repo.Cipher.encryptData(event.ID, data)
+		}
+		if s, ok := metadata.(string); ok {
+			metadata = string(repo.Cipher.encryptData(event.ID, []byte(s)))
+		}
+		if source != "" {
+			source = repo.Cipher.encrypt(event.ID, source)
+		}
+		hash = repo.Cipher.hashKey(hash)
+	}
+	return []any{event.ID, nullableSeq(event.Seq), event.Type, message, nullableJSON(data),
+		nullableString(event.CorrelationID), nullableString(event.CausationID), event.Timestamp.UTC(), hash, metadata, nullableString(source)}
+}
+
+// LastSeq возвращает наибольший сохранённый серверный номер события; 0 — нет событий с номером.
+func (repo *SQLiteRepository) LastSeq() (uint64, error) {
+	var seq sql.NullInt64
+	if err := repo.DB.QueryRow(`SELECT MAX(seq) FROM events;`).Scan(&seq); err != nil {
+		return 0, err
+	}
+	return uint64(seq.Int64), nil
+}
+
+// HasContentHash проверяет, сохранено ли событие с таким хэшем содержимого.
+// События, сохранённые до появления колонки content_hash, не учитываются.
+func (repo *SQLiteRepository) HasContentHash(hash string) (bool, error) {
+	if repo.Cipher != nil {
+		hash = repo.Cipher.hashKey(hash)
+	}
+	var exists bool
+	err := repo.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM events WHERE content_hash = ?);`, hash).Scan(&exists)
+	return exists, err
+}
+
+// selectEvents — общий список колонок для чтения событий, согласованный со scanEvent.
+const selectEvents = `SELECT id, seq, type, message, data, correlation_id, causation_id, timestamp, metadata, source FROM events`
+
+// Query возвращает события, удовлетворяющие фильтру, в порядке времени.
+func (repo *SQLiteRepository) Query(filter EventFilter) ([]domain.Event, error) {
+	var events []domain.Event
+	err := repo.Each(filter, func(event domain.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+// Each последовательно передаёт fn события, удовлетворяющие фильтру, не
+// загружая всю выборку в память. Ошибка fn прерывает обход.
+func (repo *SQLiteRepository) Each(filter EventFilter, fn func(domain.Event) error) error {
+	if !repo.decryptedFilter(filter) {
+		query, args := filterQuery(filter)
+		return repo.eachEvent(query, args, fn)
+	}
+	// Сообщения и источники зашифрованы: подстроку, источник и Limit
+	// проверяем после расшифровки.
+	contains, source, limit := filter.Contains, filter.Source, filter.Limit
+	filter.Contains, filter.Source, filter.Limit = "", "", 0
+	query, args := filterQuery(filter)
+	matched := 0
+	err := repo.eachEvent(query, args, func(event domain.Event) error {
+		if !strings.Contains(event.Message, contains) || (source != "" && event.Source != source) {
+			return nil
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+		matched++
+		if limit > 0 && matched >= limit {
+			return errStopEach
+		}
+		return nil
+	})
+	if errors.Is(err, errStopEach) {
+		return nil
+	}
 	return err
 }
+
+// decryptedFilter сообщает, что фильтр проверяет зашифрованные колонки и
+// не может быть выполнен запросом SQL.
+func (repo *SQLiteRepository) decryptedFilter(filter EventFilter) bool {
+	return repo.Cipher != nil && (filter.Contains != "" || filter.Source != "")
+}
+
+// errStopEach прерывает обход в Each после набора Limit событий.
+var errStopEach = errors.New("stop iteration")
+
+// Count возвращает число событий, удовлетворяющих фильтру, без учёта Limit.
+func (repo *SQLiteRepository) Count(filter EventFilter) (int, error) {
+	if repo.decryptedFilter(filter) {
+		filter.Limit = 0
+		n := 0
+		err := repo.Each(filter, func(domain.Event) error {
+			n++
+			return nil
+		})
+		return n, err
+	}
+	where, args := filterWhere(filter)
+	var n int
+	err := repo.DB.QueryRow(`SELECT COUNT(*) FROM events`+where+`;`, args...).Scan(&n)
+	return n, err
+}
+
+// DeleteOlderThan удаляет события с временем раньше cutoff.
+func (repo *SQLiteRepository) DeleteOlderThan(cutoff time.Time) (int, error) {
+	res, err := repo.DB.Exec(`DELETE FROM events WHERE timestamp < ?;`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// DeleteExceeding удаляет самые старые события сверх maxRows.
+func (repo *SQLiteRepository) DeleteExceeding(maxRows int) (int, error) {
+	res, err := repo.DB.Exec(`DELETE FROM events WHERE id IN (
+            SELECT id FROM events ORDER BY timestamp DESC, id DESC LIMIT -1 OFFSET ?
+        );`, maxRows)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Close закрывает соединение с БД.
+func (repo *SQLiteRepository) Close() error {
+	return repo.DB.Close()
+}
+
+// filterQuery строит запрос выборки событий по фильтру.
+func filterQuery(filter EventFilter) (string, []any) {
+	where, args := filterWhere(filter)
+	query := selectEvents + where + " ORDER BY timestamp, id"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+	return query + ";", args
+}
+
+// filterWhere строит условие WHERE по фильтру без учёта Limit.
+func filterWhere(filter EventFilter) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	if len(filter.Types) > 0 {
+		conds = append(conds, "type IN (?"+strings.Repeat(", ?", len(filter.Types)-1)+")")
+		for _, t := range filter.Types {
+			args = append(args, t)
+		}
+	}
+	if !filter.Since.IsZero() {
+		conds = append(conds, "timestamp >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		conds = append(conds, "timestamp < ?")
+		args = append(args, filter.Until.UTC())
+	}
+	if filter.Source != "" {
+		conds = append(conds, "source = ?")
+		args = append(args, filter.Source)
+	}
+	if filter.Contains != "" {
+		conds = append(conds, "instr(message, ?) > 0")
+		args = append(args, filter.Contains)
+	}
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// FindByCorrelationID возвращает все события с указанным CorrelationID в порядке времени.
+func (repo *SQLiteRepository) FindByCorrelationID(correlationID string) ([]domain.Event, error) {
+	return repo.queryEvents(selectEvents+` WHERE correlation_id = ? ORDER BY timestamp, id;`, correlationID)
+}
+
+// FindCausedBy возвращает события, непосредственно вызванные событием eventID.
+func (repo *SQLiteRepository) FindCausedBy(eventID string) ([]domain.Event, error) {
+	return repo.queryEvents(selectEvents+` WHERE causation_id = ? ORDER BY timestamp, id;`, eventID)
+}
+
+// queryEvents выполняет запрос, возвращающий колонки selectEvents.
+func (repo *SQLiteRepository) queryEvents(query string, args ...any) ([]domain.Event, error) {
+	var events []domain.Event
+	err := repo.eachEvent(query, args, func(event domain.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	return events, err
+}
+
+// eachEvent выполняет запрос, возвращающий колонки selectEvents, и передаёт события fn.
+func (repo *SQLiteRepository) eachEvent(query string, args []any, fn func(domain.Event) error) error {
+	rows, err := repo.DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		event, err := repo.scanEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// scanEvent читает строку с колонками selectEvents, расшифровывая
+// содержимое при заданном Cipher.
+func (repo *SQLiteRepository) scanEvent(rows *sql.Rows) (domain.Event, error) {
+	var (
+		event                  domain.Event
+		seq                    sql.NullInt64
+		message, data          sql.NullString
+		correlation, causation sql.NullString
+		metadata, source       sql.NullString
+	)
+	if err := rows.Scan(&event.ID, &seq, &event.Type, &message, &data, &correlation, &causation, &event.Timestamp, &metadata, &source); err != nil {
+		return domain.Event{}, err
+	}
+	event.Seq = uint64(seq.Int64)
+	event.Message = message.String
+	if data.Valid {
+		event.Data = []byte(data.String)
+	}
+	event.CorrelationID = correlation.String
+	event.CausationID = causation.String
+	event.Source = source.String
+	rawMetadata := []byte(metadata.String)
+	if repo.Cipher != nil {
+		var err error
+		if event.Message, err = repo.Cipher.decrypt(event.ID, event.Message); err != nil {
+			return domain.Event{}, err
+		}
+		if event.Data, err = repo.Cipher.decryptData(event.ID, event.Data); err != nil {
+			return domain.Event{}, err
+		}
+		if event.Source, err = repo.Cipher.decrypt(event.ID, event.Source); err != nil {
+			return domain.Event{}, err
+		}
+		if rawMetadata, err = repo.Cipher.decryptData(event.ID, rawMetadata); err != nil {
+			return domain.Event{}, err
+		}
+	}
+	if metadata.Valid {
+		if err := json.Unmarshal(rawMetadata, &event.Metadata); err != nil {
+			return domain.Event{}, fmt.Errorf("decode metadata of event %s: %w", event.ID, err)
+		}
+	}
+	return event, nil
+}
+
+// nullableJSON сохраняет пустую полезную нагрузку как NULL.
+func nullableJSON(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
+
+// nullableMetadata сохраняет метаданные объектом JSON, пустые — как NULL.
+func nullableMetadata(metadata map[string]string) any {
+	if len(metadata) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(metadata)
+	return string(data)
+}
+
+// nullableSeq сохраняет отсутствующий номер как NULL.
+func nullableSeq(seq uint64) any {
+	if seq == 0 {
+		return nil
+	}
+	return int64(seq)
+}
+
+// nullableString сохраняет пустую строку как NULL.
+func nullableString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
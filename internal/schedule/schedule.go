@@ -0,0 +1,262 @@
+// Package schedule хранит события с отложенной доставкой (deliver_at) до
+// наступления их времени. Очередь лежит в файле BoltDB и переживает
+// перезапуск сервера: после запуска события, время которых прошло,
+// рассылаются сразу.
+package schedule
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Бакеты очереди. Ключ событий — 8 байт времени доставки (big-endian,
+// наносекунды Unix) и ID, поэтому курсор идёт в порядке доставки. Индекс
+// по ID — пространство имён, нулевой байт и ID события -> ключ события.
+var (
+	bucketEvents = []byte("scheduled")
+	bucketByID   = []byte("scheduled_by_id")
+)
+
+// ErrTooFar возвращается Schedule для события, время доставки которого
+// дальше MaxDelay.
+var ErrTooFar = errors.New("deliver_at is too far in the future")
+
+// Options задаёт очередь отложенных событий.
+type Options struct {
+	// MaxDelay — наибольшая задержка доставки относительно момента
+	// публикации; 0 — без ограничения.
+	MaxDelay time.Duration
+}
+
+// Queue — очередь отложенных событий. Событие рассылается функцией deliver
+// не раньше своего DeliverAt и удаляется из очереди после рассылки, поэтому
+// при сбое между ними оно будет разослано повторно с тем же ID.
+type Queue struct {
+	db      *bolt.DB
+	opts    Options
+	deliver func(domain.Event) error
+	logger  *slog.Logger
+
+	wake     chan struct{} // в очередь добавлено событие
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// Open открывает (или создаёт) файл очереди и запускает доставку событий
+// функцией deliver. Ошибка deliver записывается в журнал, а событие
+// удаляется из очереди: повтор не исправит отклонённое событие.
+func Open(path string, opts Options, deliver func(domain.Event) error, logger *slog.Logger) (*Queue, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketEvents, bucketByID} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	q := &Queue{
+		db:      db,
+		opts:    opts,
+		deliver: deliver,
+		logger:  logger,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go q.run()
+	return q, nil
+}
+
+// Schedule ставит событие в очередь до его DeliverAt. Событие с тем же ID в
+// том же пространстве имён заменяется: его доставка переносится.
+func (q *Queue) Schedule(event domain.Event) error {
+	if event.DeliverAt == nil {
+		return errors.New("event has no deliver_at")
+	}
+	if q.opts.MaxDelay > 0 && time.Until(*event.DeliverAt) > q.opts.MaxDelay {
+		return fmt.Errorf("%w: maximum delay is %s", ErrTooFar, q.opts.MaxDelay)
+	}
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	key := eventKey(*event.DeliverAt, event.ID)
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		events, byID := tx.Bucket(bucketEvents), tx.Bucket(bucketByID)
+		idKey := idKey(event.NamespaceOrDefault(), event.ID)
+		if old := byID.Get(idKey); old != nil {
+			if err := events.Delete(old); err != nil {
+				return err
+			}
+		}
+		if err := events.Put(key, raw); err != nil {
+			return err
+		}
+		return byID.Put(idKey, key)
+	})
+	if err != nil {
+		return err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// List возвращает ожидающие события пространства имён в порядке доставки;
+// пустое значение — события всех пространств имён.
+func (q *Queue) List(namespace string) ([]domain.Event, error) {
+	result := []domain.Event{}
+	err := q.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketEvents).ForEach(func(_, raw []byte) error {
+			var event domain.Event
+			if err := json.Unmarshal(raw, &event); err != nil {
+				return err
+			}
+			if namespace == "" || event.NamespaceOrDefault() == namespace {
+				result = append(result, event)
+			}
+			return nil
+		})
+	})
+	return result, err
+}
+
+// Cancel удаляет событие из очереди и сообщает, было ли оно в ней.
+func (q *Queue) Cancel(namespace, id string) (bool, error) {
+	found := false
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		byID := tx.Bucket(bucketByID)
+		idKey := idKey(namespace, id)
+		key := byID.Get(idKey)
+		if key == nil {
+			return nil
+		}
+		found = true
+		if err := tx.Bucket(bucketEvents).Delete(key); err != nil {
+			return err
+		}
+		return byID.Delete(idKey)
+	})
+	return found, err
+}
+
+// Len возвращает число ожидающих событий.
+func (q *Queue) Len() int {
+	n := 0
+	q.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketEvents).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+// Close останавливает доставку и закрывает файл очереди. Ожидающие события
+// остаются в нём до следующего запуска.
+func (q *Queue) Close() error {
+	q.stopOnce.Do(func() { close(q.stop) })
+	<-q.stopped
+	return q.db.Close()
+}
+
+// run рассылает наступившие события и спит до времени следующего.
+func (q *Queue) run() {
+	defer close(q.stopped)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-q.wake:
+		case <-q.stop:
+			return
+		}
+		next, err := q.deliverDue()
+		if err != nil {
+			q.logger.Error("Scheduled delivery error", "error", err)
+			next = time.Now().Add(time.Second)
+		}
+		timer.Stop()
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// deliverDue рассылает события, время которых наступило, и возвращает время
+// следующего ожидающего события; нулевое — очередь пуста.
+func (q *Queue) deliverDue() (time.Time, error) {
+	for {
+		var (
+			key   []byte
+			event domain.Event
+			next  time.Time
+		)
+		err := q.db.View(func(tx *bolt.Tx) error {
+			k, raw := tx.Bucket(bucketEvents).Cursor().First()
+			if k == nil {
+				return nil
+			}
+			if at := keyTime(k); at.After(time.Now()) {
+				next = at
+				return nil
+			}
+			key = bytes.Clone(k)
+			return json.Unmarshal(raw, &event)
+		})
+		if err != nil || key == nil {
+			return next, err
+		}
+		if err := q.deliver(event); err != nil {
+			q.logger.Warn("Scheduled event rejected", "id", event.ID, "namespace", event.NamespaceOrDefault(), "error", err)
+		}
+		err = q.db.Update(func(tx *bolt.Tx) error {
+			if err := tx.Bucket(bucketEvents).Delete(key); err != nil {
+				return err
+			}
+			byID := tx.Bucket(bucketByID)
+			idKey := idKey(event.NamespaceOrDefault(), event.ID)
+			// Индекс мог уже указывать на перенесённое событие с тем же ID.
+			if bytes.Equal(byID.Get(idKey), key) {
+				return byID.Delete(idKey)
+			}
+			return nil
+		})
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+}
+
+func eventKey(at time.Time, id string) []byte {
+	key := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(at.UnixNano()))
+	return append(key, id...)
+}
+
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key[:8])))
+}
+
+func idKey(namespace, id string) []byte {
+	return []byte(namespace + "\x00" + id)
+}
@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// QuarantinedEvent — событие, не прошедшее проверку, с причиной отказа.
+type QuarantinedEvent struct {
+	Event  domain.Event `json:"event"`
+	Reason string       `json:"reason"`
+	At     time.Time    `json:"at"`
+}
+
+// Quarantine — ограниченный по размеру буфер последних отклонённых событий.
+// При переполнении вытесняются самые старые записи.
+type Quarantine struct {
+	mu    sync.Mutex
+	items []QuarantinedEvent
+	limit int
+}
+
+// NewQuarantine создаёт карантин вместимостью limit записей.
+func NewQuarantine(limit int) *Quarantine {
+	if limit <= 0 {
+		limit = 1000
+	}
+	return &Quarantine{limit: limit}
+}
+
+// Put помещает событие в карантин.
+func (q *Quarantine) Put(event domain.Event, reason error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == q.limit {
+		q.items = q.items[1:]
+	}
+	q.items = append(q.items, QuarantinedEvent{Event: event, Reason: reason.Error(), At: time.Now()})
+}
+
+// List возвращает события из карантина пространства имён; пустое значение — все.
+func (q *Quarantine) List(namespace string) []QuarantinedEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	result := make([]QuarantinedEvent, 0, len(q.items))
+	for _, item := range q.items {
+		if namespace == "" || item.Event.NamespaceOrDefault() == namespace {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,235 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Ошибки реестра схем.
+var (
+	ErrUnknownVersion   = errors.New("unknown schema version")
+	ErrCannotDowngrade  = errors.New("cannot downgrade event")
+	ErrVersionConflict  = errors.New("schema version already registered")
+	ErrNonSequentialVer = errors.New("schema versions must be registered sequentially")
+)
+
+// DowngradeFunc преобразует событие версии from в версию from-1.
+type DowngradeFunc func(event domain.Event) (domain.Event, error)
+
+type typeSchemas struct {
+	versions   []*Schema         // versions[i] — схема версии i+1
+	raw        []json.RawMessage // исходные тексты схем
+	downgrades map[int]DowngradeFunc
+}
+
+// Registry хранит версии JSON Schema по типам событий. События типов без
+// зарегистрированной схемы считаются корректными.
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]*typeSchemas
+}
+
+// NewRegistry создаёт пустой реестр схем.
+func NewRegistry() *Registry {
+	return &Registry{types: make(map[string]*typeSchemas)}
+}
+
+// Register компилирует схему и добавляет её следующей версией типа.
+// Возвращает присвоенный номер версии.
+func (r *Registry) Register(eventType string, raw []byte) (int, error) {
+	s, err := Compile(raw)
+	if err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ts := r.get(eventType)
+	ts.versions = append(ts.versions, s)
+	ts.raw = append(ts.raw, append(json.RawMessage(nil), raw...))
+	return len(ts.versions), nil
+}
+
+// RegisterVersion регистрирует схему под явным номером версии. Версии
+// добавляются строго по порядку, начиная с 1.
+func (r *Registry) RegisterVersion(eventType string, version int, raw []byte) error {
+	s, err := Compile(raw)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ts := r.get(eventType)
+	switch {
+	case version <= len(ts.versions):
+		return fmt.Errorf("%w: %s v%d", ErrVersionConflict, eventType, version)
+	case version != len(ts.versions)+1:
+		return fmt.Errorf("%w: %s v%d after v%d", ErrNonSequentialVer, eventType, version, len(ts.versions))
+	}
+	ts.versions = append(ts.versions, s)
+	ts.raw = append(ts.raw, append(json.RawMessage(nil), raw...))
+	return nil
+}
+
+// RegisterDowngrade задаёт преобразование события типа из версии from в from-1.
+func (r *Registry) RegisterDowngrade(eventType string, from int, fn DowngradeFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(eventType).downgrades[from] = fn
+}
+
+// DropFields возвращает преобразование, удаляющее из объекта data события
+// поля, которых нет в предыдущей версии схемы.
+func DropFields(fields ...string) DowngradeFunc {
+	return func(event domain.Event) (domain.Event, error) {
+		if len(event.Data) == 0 {
+			return event, nil
+		}
+		var data map[string]json.RawMessage
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return domain.Event{}, fmt.Errorf("data is not an object: %w", err)
+		}
+		for _, field := range fields {
+			delete(data, field)
+		}
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return domain.Event{}, err
+		}
+		event.Data = raw
+		return event, nil
+	}
+}
+
+// get возвращает схемы типа, создавая запись при необходимости. Вызывается под r.mu.
+func (r *Registry) get(eventType string) *typeSchemas {
+	ts, ok := r.types[eventType]
+	if !ok {
+		ts = &typeSchemas{downgrades: make(map[int]DowngradeFunc)}
+		r.types[eventType] = ts
+	}
+	return ts
+}
+
+// Types возвращает зарегистрированные типы и номера их версий.
+func (r *Registry) Types() map[string][]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make(map[string][]int, len(r.types))
+	for t, ts := range r.types {
+		if len(ts.versions) == 0 {
+			continue
+		}
+		versions := make([]int, len(ts.versions))
+		for i := range ts.versions {
+			versions[i] = i + 1
+		}
+		result[t] = versions
+	}
+	return result
+}
+
+// Raw возвращает исходный текст схемы; version 0 — последняя версия.
+func (r *Registry) Raw(eventType string, version int) (json.RawMessage, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ts, ok := r.types[eventType]
+	if !ok || len(ts.raw) == 0 {
+		return nil, false
+	}
+	if version == 0 {
+		version = len(ts.raw)
+	}
+	if version < 1 || version > len(ts.raw) {
+		return nil, false
+	}
+	return ts.raw[version-1], true
+}
+
+// LatestVersion возвращает последнюю версию схемы типа; 0 — схем нет.
+func (r *Registry) LatestVersion(eventType string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if ts, ok := r.types[eventType]; ok {
+		return len(ts.versions)
+	}
+	return 0
+}
+
+// schemaFor возвращает схему версии события; version 0 — последняя.
+func (r *Registry) schemaFor(eventType string, version int) (*Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ts, ok := r.types[eventType]
+	if !ok || len(ts.versions) == 0 {
+		return nil, nil
+	}
+	if version == 0 {
+		version = len(ts.versions)
+	}
+	if version < 1 || version > len(ts.versions) {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownVersion, eventType, version)
+	}
+	return ts.versions[version-1], nil
+}
+
+// Validate проверяет событие целиком (в его JSON-представлении) по схеме
+// его типа и версии.
+func (r *Registry) Validate(event domain.Event) error {
+	s, err := r.schemaFor(event.Type, event.Version)
+	if err != nil || s == nil {
+		return err
+	}
+	doc, err := toDocument(event)
+	if err != nil {
+		return err
+	}
+	return s.Validate(doc)
+}
+
+// Downgrade понижает версию события до target, последовательно применяя
+// зарегистрированные преобразования. Если для шага преобразование не задано,
+// событие переносится без изменений, когда оно уже соответствует схеме
+// предыдущей версии; иначе возвращается ErrCannotDowngrade.
+func (r *Registry) Downgrade(event domain.Event, target int) (domain.Event, error) {
+	for event.Version > target {
+		from := event.Version
+		r.mu.RLock()
+		var fn DowngradeFunc
+		if ts, ok := r.types[event.Type]; ok {
+			fn = ts.downgrades[from]
+		}
+		r.mu.RUnlock()
+
+		next := event
+		if fn != nil {
+			var err error
+			if next, err = fn(event); err != nil {
+				return domain.Event{}, fmt.Errorf("%w: %s v%d: %w", ErrCannotDowngrade, event.Type, from, err)
+			}
+		}
+		next.Version = from - 1
+		if next.Version > 0 {
+			if err := r.Validate(next); err != nil {
+				return domain.Event{}, fmt.Errorf("%w: %s v%d: %w", ErrCannotDowngrade, event.Type, from, err)
+			}
+		}
+		event = next
+	}
+	return event, nil
+}
+
+func toDocument(event domain.Event) (any, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Aggregation — сводные события подписки: каждые Interval клиент получает
+// событие domain.EventTypeAggregate с числом полученных им событий по типам.
+// Окна выровнены по кратным Interval от начала эпохи, первое окно начинается
+// с регистрации клиента.
+type Aggregation struct {
+	Interval time.Duration
+	// Only — отправлять только сводки, без самих событий.
+	Only bool
+}
+
+// aggregator считает события клиента в текущем окне и по таймеру
+// отправляет сводку. Собственной горутины у него нет.
+type aggregator struct {
+	client   *Client
+	interval time.Duration
+	source   string
+
+	mu      sync.Mutex
+	start   time.Time
+	counts  map[string]uint64
+	total   uint64
+	timer   *time.Timer
+	stopped bool
+}
+
+func newAggregator(client *Client, interval time.Duration, source string) *aggregator {
+	a := &aggregator{
+		client:   client,
+		interval: interval,
+		source:   source,
+		start:    time.Now(),
+		counts:   make(map[string]uint64),
+	}
+	a.mu.Lock()
+	a.timer = time.AfterFunc(a.untilWindowEnd(a.start), a.flush)
+	a.mu.Unlock()
+	return a
+}
+
+// untilWindowEnd возвращает время до конца окна, содержащего t.
+func (a *aggregator) untilWindowEnd(t time.Time) time.Duration {
+	return t.Truncate(a.interval).Add(a.interval).Sub(t)
+}
+
+// add учитывает событие в текущем окне.
+func (a *aggregator) add(eventType string) {
+	a.mu.Lock()
+	a.counts[eventType]++
+	a.total++
+	a.mu.Unlock()
+}
+
+// flush закрывает окно, отправляет сводку клиенту и начинает следующее окно.
+func (a *aggregator) flush() {
+	now := time.Now()
+	a.mu.Lock()
+	if a.stopped {
+		a.mu.Unlock()
+		return
+	}
+	data := domain.AggregateData{
+		WindowStart: a.start,
+		WindowEnd:   now,
+		Interval:    a.interval.String(),
+		Total:       a.total,
+		Counts:      a.counts,
+	}
+	a.start, a.counts, a.total = now, make(map[string]uint64), 0
+	a.timer.Reset(a.untilWindowEnd(now))
+	a.mu.Unlock()
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	a.client.Notifier.Notify(domain.Event{
+		ID:        newEventID(),
+		Type:      domain.EventTypeAggregate,
+		Namespace: a.client.namespace(),
+		Source:    a.source,
+		Message:   strconv.FormatUint(data.Total, 10) + " events in " + data.Interval,
+		Data:      raw,
+		Timestamp: now,
+	})
+}
+
+// stop останавливает отправку сводок; незавершённое окно отбрасывается.
+func (a *aggregator) stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stopped = true
+	a.timer.Stop()
+}
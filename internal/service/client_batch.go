@@ -0,0 +1,165 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// batchSaver реализуется репозиториями, умеющими сохранять несколько
+// событий одной транзакцией.
+type batchSaver interface {
+	SaveBatch(events []domain.Event) error
+}
+
+// BatchPolicy задаёт пакетную запись событий.
+type BatchPolicy struct {
+	// Size — событий в пачке, при наборе которых она записывается, не
+	// дожидаясь FlushInterval; 0 или 1 — только по FlushInterval и MaxInFlight.
+	Size int
+	// FlushInterval — наибольшая задержка записи первого события пачки.
+	FlushInterval time.Duration
+	// MaxInFlight — наибольшее число принятых, но ещё не записанных событий,
+	// то есть событий, которые придётся получить заново после сбоя. При
+	// достижении предела приём событий ждёт записи.
+	MaxInFlight int
+}
+
+// defaultMaxInFlight — предел незаписанных событий для пакетной записи
+// только по времени.
+const defaultMaxInFlight = 1000
+
+// withDefaults подставляет значения по умолчанию: FlushInterval — 100ms,
+// MaxInFlight — два размера пачки, Size не больше MaxInFlight.
+func (p BatchPolicy) withDefaults() BatchPolicy {
+	if p.FlushInterval <= 0 {
+		p.FlushInterval = 100 * time.Millisecond
+	}
+	if p.MaxInFlight <= 0 {
+		p.MaxInFlight = defaultMaxInFlight
+		if p.Size > 1 {
+			p.MaxInFlight = 2 * p.Size
+		}
+	}
+	if p.Size <= 1 || p.Size > p.MaxInFlight {
+		p.Size = p.MaxInFlight
+	}
+	return p
+}
+
+// saveRequest — событие, ожидающее записи, и обработчик результата.
+type saveRequest struct {
+	event domain.Event
+	done  func(error)
+}
+
+// batchWriter накапливает события и записывает их пачками: при наборе
+// Size событий либо по истечении FlushInterval с момента первого события в
+// буфере. Пока пачка записывается, следующая продолжает накапливаться, и если
+// к концу записи она уже созрела, то уходит сразу, вбирая всё пришедшее.
+// Принятые и ещё не записанные события ограничены MaxInFlight.
+type batchWriter struct {
+	save     func([]domain.Event) error
+	policy   BatchPolicy
+	slots    chan struct{} // свободные места среди незаписанных событий
+	requests chan saveRequest
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func newBatchWriter(save func([]domain.Event) error, policy BatchPolicy) *batchWriter {
+	bw := &batchWriter{
+		save:     save,
+		policy:   policy,
+		slots:    make(chan struct{}, policy.MaxInFlight),
+		requests: make(chan saveRequest),
+		stopped:  make(chan struct{}),
+	}
+	go bw.run()
+	return bw
+}
+
+// enqueue ставит событие в буфер; done вызывается после записи пачки.
+// Если незаписанных событий MaxInFlight, enqueue ждёт записи.
+func (bw *batchWriter) enqueue(event domain.Event, done func(error)) {
+	bw.slots <- struct{}{}
+	bw.requests <- saveRequest{event: event, done: done}
+}
+
+// close записывает накопленные события и останавливает writer.
+// Вызывающий гарантирует, что новых enqueue после close не будет.
+func (bw *batchWriter) close() {
+	bw.stopOnce.Do(func() { close(bw.requests) })
+	<-bw.stopped
+}
+
+func (bw *batchWriter) run() {
+	defer close(bw.stopped)
+	var (
+		pending  []saveRequest
+		timer    *time.Timer
+		timeout  <-chan time.Time
+		due      bool          // накопленную пачку пора записать
+		saved    chan struct{} // закрывается по окончании записи пачки; nil — запись не идёт
+		requests = bw.requests
+	)
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+		due = false
+		if len(pending) == 0 {
+			return
+		}
+		batch, done := pending, make(chan struct{})
+		pending, saved = nil, done
+		go func() {
+			bw.write(batch)
+			close(done)
+		}()
+	}
+	for {
+		select {
+		case req, ok := <-requests:
+			if !ok {
+				// После close записывается всё накопленное.
+				requests, due = nil, true
+				break
+			}
+			pending = append(pending, req)
+			if len(pending) >= bw.policy.Size {
+				due = true
+			} else if timer == nil {
+				timer = time.NewTimer(bw.policy.FlushInterval)
+				timeout = timer.C
+			}
+		case <-timeout:
+			timer, timeout = nil, nil
+			due = true
+		case <-saved:
+			saved = nil
+		}
+		if due && saved == nil {
+			flush()
+		}
+		if requests == nil && saved == nil && len(pending) == 0 {
+			return
+		}
+	}
+}
+
+// write сохраняет пачку, сообщает результат каждому событию и освобождает
+// их места среди незаписанных.
+func (bw *batchWriter) write(batch []saveRequest) {
+	events := make([]domain.Event, len(batch))
+	for i, req := range batch {
+		events[i] = req.event
+	}
+	err := bw.save(events)
+	for _, req := range batch {
+		<-bw.slots
+		req.done(err)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// ErrBreakerOpen возвращается для события, отклонённого при разомкнутом
+// автомате защиты хранилища и заполненном буфере.
+var ErrBreakerOpen = fmt.Errorf("%w: circuit breaker open", domain.ErrStoreUnavailable)
+
+// BreakerPolicy задаёт автомат защиты хранилища.
+type BreakerPolicy struct {
+	Threshold     int           // подряд неудачных записей до размыкания
+	BufferSize    int           // событий в памяти, пока автомат разомкнут
+	ProbeInterval time.Duration // период проверки хранилища
+}
+
+// breaker — автомат защиты хранилища. После Threshold временных ошибок
+// записи подряд он размыкается: события больше не идут в хранилище, а
+// ждут в буфере (их подтверждение откладывается), пока периодическая
+// проверка не покажет, что хранилище снова доступно. Тогда буфер
+// записывается по порядку и автомат замыкается.
+type breaker struct {
+	cs     *ClientService
+	policy BreakerPolicy
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	buffer   []saveRequest
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// EnableBreaker включает автомат защиты хранилища. Временными считаются
+// ошибки, которые повторяет политика SetSaveRetry. Вызывается до начала
+// обработки событий.
+func (cs *ClientService) EnableBreaker(policy BreakerPolicy) {
+	policy.Threshold = max(policy.Threshold, 1)
+	policy.BufferSize = max(policy.BufferSize, 1)
+	if policy.ProbeInterval <= 0 {
+		policy.ProbeInterval = time.Second
+	}
+	cs.breaker = &breaker{cs: cs, policy: policy}
+	cs.logger.Info("Storage circuit breaker enabled", "threshold", policy.Threshold,
+		"buffer_size", policy.BufferSize, "probe_interval", policy.ProbeInterval)
+}
+
+// hold принимает событие при разомкнутом автомате: ставит в буфер или
+// отклоняет, если буфер заполнен. Возвращает false, если автомат замкнут
+// и событие нужно записать обычным путём.
+func (b *breaker) hold(event domain.Event, done func(error)) bool {
+	b.mu.Lock()
+	if !b.open {
+		b.mu.Unlock()
+		return false
+	}
+	if len(b.buffer) >= b.policy.BufferSize {
+		b.mu.Unlock()
+		b.cs.metrics.BreakerRejected.Inc()
+		done(ErrBreakerOpen)
+		return true
+	}
+	b.buffer = append(b.buffer, saveRequest{event: event, done: done})
+	b.mu.Unlock()
+	return true
+}
+
+// record учитывает результат записи и размыкает автомат после Threshold
+// временных ошибок подряд.
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil || !b.cs.retryPolicy().retryable(err) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.open || b.failures < b.policy.Threshold {
+		return
+	}
+	b.open = true
+	b.stop, b.done = make(chan struct{}), make(chan struct{})
+	b.cs.metrics.BreakerOpen.Set(1)
+	b.cs.logger.Warn("Storage circuit breaker opened", "failures", b.failures, "error", err)
+	go b.probe(b.stop, b.done)
+}
+
+// probe периодически проверяет хранилище записью первого события буфера
+// (или чтением, если буфер пуст) и при успехе замыкает автомат.
+func (b *breaker) probe(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(b.policy.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		b.mu.Lock()
+		var first *saveRequest
+		if len(b.buffer) > 0 {
+			first = &b.buffer[0]
+		}
+		b.mu.Unlock()
+		var err error
+		if first != nil {
+			err = b.cs.repo.Save(first.event)
+		} else {
+			_, err = b.cs.repo.LastSeq()
+		}
+		if err != nil {
+			b.cs.logger.Debug("Storage probe failed", "error", err)
+			continue
+		}
+		b.close(first != nil)
+		return
+	}
+}
+
+// close замыкает автомат и записывает буфер обычным путём; при
+// firstSaved первое событие уже записано проверкой.
+func (b *breaker) close(firstSaved bool) {
+	b.mu.Lock()
+	pending := b.buffer
+	b.buffer, b.open, b.failures = nil, false, 0
+	b.mu.Unlock()
+	b.cs.metrics.BreakerOpen.Set(0)
+	b.cs.logger.Info("Storage circuit breaker closed", "buffered", len(pending))
+	for i, req := range pending {
+		if i == 0 && firstSaved {
+			req.done(nil)
+			continue
+		}
+		b.cs.write(req.event, req.done)
+	}
+}
+
+// shutdown останавливает проверку и пытается записать буфер.
+func (b *breaker) shutdown() {
+	b.mu.Lock()
+	stop, done := b.stop, b.done
+	b.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+	b.mu.Lock()
+	pending := b.buffer
+	b.buffer, b.open, b.stop = nil, false, nil
+	b.mu.Unlock()
+	for _, req := range pending {
+		req.done(b.cs.retrySave(func() error { return b.cs.repo.Save(req.event) }))
+	}
+}
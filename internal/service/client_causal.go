@@ -0,0 +1,184 @@
+package service
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// causalItem — событие, ожидающее доставки своих причин.
+type causalItem struct {
+	event domain.Event
+	done  func(error)
+	at    time.Time
+}
+
+// causalBuffer задерживает события с причинными метаданными, пока не
+// обработаны события, от которых они зависят. Событие узла n с часами c
+// доставляется, когда от n обработано c[n]-1 событий, а от остальных узлов —
+// не меньше c[k]. Узел, от которого ещё ничего не получено, принимается с
+// текущего значения: клиент, подключившийся позже, не ждёт всю историю.
+type causalBuffer struct {
+	mu         sync.Mutex
+	delivered  domain.VectorClock
+	pending    []causalItem
+	maxWait    time.Duration
+	maxPending int
+	deliver    func(domain.Event, func(error))
+	timer      *time.Timer
+	logger     *slog.Logger
+}
+
+// EnableCausalOrder включает доставку событий в причинном порядке: событие,
+// причины которого ещё не получены, ждёт их не дольше maxWait; в буфере
+// хранится не больше maxPending событий. По истечении ожидания или при
+// переполнении событие обрабатывается без недостающих причин. При пуле
+// обработки с несколькими обработчиками порядок сохранения не гарантируется.
+// Вызывается до начала обработки событий.
+func (cs *ClientService) EnableCausalOrder(maxWait time.Duration, maxPending int) {
+	cs.causal = &causalBuffer{
+		delivered:  domain.VectorClock{},
+		maxWait:    maxWait,
+		maxPending: maxPending,
+		deliver:    cs.dispatch,
+		logger:     cs.logger,
+	}
+	cs.logger.Info("Causal ordering enabled", "max_wait", maxWait, "max_pending", maxPending)
+}
+
+// StampCausality добавляет к публикуемому клиентом событию причинные
+// метаданные узла node: событие следует за всеми уже обработанными.
+// Без EnableCausalOrder событие возвращается без изменений.
+func (cs *ClientService) StampCausality(node string, event domain.Event) domain.Event {
+	if cs.causal == nil {
+		return event
+	}
+	b := cs.causal
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.delivered[node]++
+	event.Causality = &domain.Causality{Node: node, Clock: b.delivered.Clone()}
+	return event
+}
+
+// add принимает событие и доставляет все события, ставшие готовыми.
+// Доставка идёт под блокировкой буфера, чтобы сохранить порядок.
+func (b *causalBuffer) add(event domain.Event, done func(error)) {
+	if event.Causality == nil {
+		b.deliver(event, done)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stale(event) || b.ready(event) {
+		b.advance(event)
+		b.deliver(event, done)
+		b.drain()
+		return
+	}
+	b.pending = append(b.pending, causalItem{event: event, done: done, at: time.Now()})
+	if len(b.pending) > b.maxPending {
+		b.force("causal buffer full")
+	}
+	b.schedule()
+}
+
+// stale сообщает, что событие от своего узла уже учтено (повторная доставка).
+func (b *causalBuffer) stale(event domain.Event) bool {
+	c := event.Causality
+	n, ok := b.delivered[c.Node]
+	return ok && c.Clock[c.Node] <= n
+}
+
+// ready сообщает, что все причины события обработаны.
+func (b *causalBuffer) ready(event domain.Event) bool {
+	c := event.Causality
+	for node, n := range c.Clock {
+		have, ok := b.delivered[node]
+		if !ok {
+			continue
+		}
+		if node == c.Node {
+			if n != have+1 {
+				return false
+			}
+		} else if n > have {
+			return false
+		}
+	}
+	return true
+}
+
+// advance учитывает доставку события: узел события получает его номер,
+// ещё не встречавшиеся узлы — значения из часов события.
+func (b *causalBuffer) advance(event domain.Event) {
+	c := event.Causality
+	for node, n := range c.Clock {
+		if _, ok := b.delivered[node]; !ok || node == c.Node {
+			if n > b.delivered[node] {
+				b.delivered[node] = n
+			}
+		}
+	}
+}
+
+// drain доставляет ожидающие события, пока находятся готовые.
+func (b *causalBuffer) drain() {
+	for progress := true; progress; {
+		progress = false
+		for i, item := range b.pending {
+			if b.stale(item.event) || b.ready(item.event) {
+				b.pending = append(b.pending[:i], b.pending[i+1:]...)
+				b.advance(item.event)
+				b.deliver(item.event, item.done)
+				progress = true
+				break
+			}
+		}
+	}
+}
+
+// force доставляет самое старое ожидающее событие, не дожидаясь причин.
+func (b *causalBuffer) force(reason string) {
+	item := b.pending[0]
+	b.pending = b.pending[1:]
+	b.logger.Warn("Delivering event without its causes", "id", item.event.ID, "reason", reason,
+		"waited", time.Since(item.at))
+	b.delivered.Merge(item.event.Causality.Clock)
+	b.deliver(item.event, item.done)
+	b.drain()
+}
+
+// schedule взводит таймер на истечение ожидания самого старого события.
+func (b *causalBuffer) schedule() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+	b.timer = time.AfterFunc(time.Until(b.pending[0].at.Add(b.maxWait)), b.expire)
+}
+
+// expire доставляет события, ожидание которых истекло.
+func (b *causalBuffer) expire() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for len(b.pending) > 0 && time.Since(b.pending[0].at) >= b.maxWait {
+		b.force("causal wait timeout")
+	}
+	b.schedule()
+}
+
+// flush доставляет все ожидающие события; вызывается при остановке сервиса.
+func (b *causalBuffer) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for len(b.pending) > 0 {
+		b.force("shutdown")
+	}
+	b.schedule()
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/wrongjunior/eventsync/internal/crdt"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// EnableCRDT восстанавливает состояние CRDT из сохранённых событий-операций
+// и поддерживает его по мере сохранения новых. Вызывается до начала
+// обработки событий. Удалённые политикой хранения операции в состояние
+// при следующем запуске не попадут.
+func (cs *ClientService) EnableCRDT() (*crdt.Store, error) {
+	store := crdt.NewStore()
+	err := cs.repo.Each(repository.EventFilter{Types: []string{crdt.EventType}}, func(event domain.Event) error {
+		if err := store.Apply(event); err != nil {
+			cs.logger.Warn("Skipping invalid CRDT op", "id", event.ID, "error", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("restore crdt state: %w", err)
+	}
+	cs.OnEvent(crdt.EventType, store.Apply)
+	cs.logger.Info("CRDT state restored", "objects", len(store.States()))
+	return store, nil
+}
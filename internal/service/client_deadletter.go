@@ -0,0 +1,73 @@
+package service
+
+import (
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// DeadLetterQueue принимает события, которые не удалось сохранить.
+type DeadLetterQueue interface {
+	Put(letter repository.DeadLetter) error
+}
+
+// maxTrackedFailures ограничивает число событий, для которых считаются
+// неудачные попытки сохранения.
+const maxTrackedFailures = 10000
+
+// EnableDeadLetters включает очередь недоставленных событий: событие, не
+// сохранённое maxFailures раз подряд (в том числе при повторных доставках),
+// помещается в dlq и подтверждается серверу, чтобы не блокировать поток.
+// Вызывается до начала обработки событий.
+func (cs *ClientService) EnableDeadLetters(dlq DeadLetterQueue, maxFailures int) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.dlq = dlq
+	cs.dlqMaxFailures = max(maxFailures, 1)
+	cs.failures = make(map[string]int)
+}
+
+// deadLetter учитывает неудачное сохранение события и при исчерпании
+// попыток помещает его в очередь. Возвращает true, если событие помещено
+// в очередь и считается обработанным.
+func (cs *ClientService) deadLetter(event domain.Event, cause error) bool {
+	cs.mu.Lock()
+	if cs.dlq == nil {
+		cs.mu.Unlock()
+		return false
+	}
+	attempts := cs.failures[event.ID] + 1
+	if attempts < cs.dlqMaxFailures {
+		if _, tracked := cs.failures[event.ID]; !tracked && len(cs.failures) >= maxTrackedFailures {
+			for id := range cs.failures {
+				delete(cs.failures, id)
+				break
+			}
+		}
+		cs.failures[event.ID] = attempts
+		cs.mu.Unlock()
+		return false
+	}
+	delete(cs.failures, event.ID)
+	dlq := cs.dlq
+	cs.mu.Unlock()
+
+	letter := repository.DeadLetter{Event: event, Error: cause.Error(), Attempts: attempts, FailedAt: time.Now()}
+	if err := dlq.Put(letter); err != nil {
+		cs.logger.Error("Error moving event to dead-letter queue", "id", event.ID, "error", err)
+		return false
+	}
+	cs.metrics.EventsDeadLettered.Inc()
+	cs.logger.Warn("Event moved to dead-letter queue", "id", event.ID, "attempts", attempts, "error", cause)
+	return true
+}
+
+// saveSucceeded сбрасывает счётчик неудачных попыток события.
+func (cs *ClientService) saveSucceeded(id string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.failures != nil {
+		delete(cs.failures, id)
+	}
+}
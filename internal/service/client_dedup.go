@@ -0,0 +1,98 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// DedupMode определяет, по какому признаку событие считается дубликатом.
+type DedupMode int
+
+const (
+	// DedupByID — дубликаты определяются по ID события (по умолчанию).
+	DedupByID DedupMode = iota
+	// DedupByHash — по хэшу типа, сообщения и полезной нагрузки. Подходит
+	// для источников, повторно отправляющих то же событие под новым ID.
+	DedupByHash
+	// DedupByBoth — событие отбрасывается при совпадении ID или хэша.
+	DedupByBoth
+)
+
+// ParseDedupMode разбирает название режима: "id" (по умолчанию), "hash" или "both".
+func ParseDedupMode(s string) (DedupMode, error) {
+	switch s {
+	case "", "id":
+		return DedupByID, nil
+	case "hash":
+		return DedupByHash, nil
+	case "both":
+		return DedupByBoth, nil
+	default:
+		return 0, fmt.Errorf("unknown dedup mode %q", s)
+	}
+}
+
+// hashKeyPrefix отделяет хэши от ID в общем кэше дедупликации.
+const hashKeyPrefix = "#"
+
+// SetDedupMode задаёт режим дедупликации. В режимах с хэшем хранилище,
+// реализующее repository.ContentHashIndex, проверяется при промахе кэша.
+// Хранилище по-прежнему не сохраняет второе событие с тем же ID.
+func (cs *ClientService) SetDedupMode(mode DedupMode) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.dedupMode = mode
+}
+
+// SetDedupWindow ограничивает дедупликацию окном: событие с уже
+// встречавшимся ID (или хэшем) отбрасывается, только если прежнее получено
+// не раньше window назад. Нужно для источников, законно повторяющих ID через
+// дни. Хранилище, реализующее repository.EventReplacer, заменяет прежнюю
+// копию новой, минуя пакетную запись; остальные хранилища по-прежнему не
+// сохраняют второе событие с тем же ID. Проверка хэша в хранилище в этом
+// режиме не выполняется: хранилище не знает, когда хэш был получен.
+// 0 — без ограничения по времени; уже накопленные ключи сбрасываются.
+func (cs *ClientService) SetDedupWindow(window time.Duration) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.dedupWindow = window
+	cs.receivedIDs = newLRUSet(cs.receivedIDs.capacity, window)
+}
+
+// windowed сообщает, включено ли окно дедупликации.
+func (cs *ClientService) windowed() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.dedupWindow > 0
+}
+
+// dedupKeys возвращает ключи кэша дедупликации для события и хэш
+// содержимого (пустой в режиме DedupByID). Вызывается под cs.mu.
+func (cs *ClientService) dedupKeys(event domain.Event) (keys []string, hash string) {
+	if cs.dedupMode != DedupByHash {
+		keys = append(keys, event.ID)
+	}
+	if cs.dedupMode != DedupByID {
+		hash = event.ContentHash()
+		keys = append(keys, hashKeyPrefix+hash)
+	}
+	return keys, hash
+}
+
+// storedHash проверяет хэш содержимого в хранилище. Ошибка чтения не
+// мешает сохранению: событие лишь не считается дубликатом.
+func (cs *ClientService) storedHash(event domain.Event, hash string) bool {
+	index, ok := cs.repo.(repository.ContentHashIndex)
+	if !ok || hash == "" {
+		return false
+	}
+	found, err := index.HasContentHash(hash)
+	if err != nil {
+		cs.logger.Warn("Content hash lookup failed", "id", event.ID, "error", err)
+		return false
+	}
+	return found
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// AnyEventType — тип для регистрации обработчика всех событий.
+const AnyEventType = "*"
+
+// ErrSkipEvent возвращается обработчиком BeforeSave, чтобы событие не
+// сохранялось. В отличие от прочих ошибок, пропуск считается успешной
+// обработкой и подтверждается серверу.
+var ErrSkipEvent = errors.New("skip event")
+
+// ErrEventRejected оборачивает ошибку обработчика BeforeSave или middleware,
+// отклонивших событие. Отклонённое событие не сохраняется и не
+// подтверждается, но считается обработанным: оно не удерживает LastSeq,
+// и после переподключения сервер не присылает его повторно.
+var ErrEventRejected = errors.New("event rejected")
+
+// EventHandler — пользовательский обработчик события.
+type EventHandler func(event domain.Event) error
+
+// clientHooks хранит обработчики по типам событий.
+type clientHooks struct {
+	before map[string][]EventHandler
+	after  map[string][]EventHandler
+}
+
+// BeforeSave регистрирует обработчик, вызываемый после фильтрации дубликатов
+// и до сохранения. Ошибка обработчика отменяет сохранение события.
+func (cs *ClientService) BeforeSave(eventType string, fn EventHandler) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.hooks.before == nil {
+		cs.hooks.before = make(map[string][]EventHandler)
+	}
+	cs.hooks.before[eventType] = append(cs.hooks.before[eventType], fn)
+}
+
+// OnEvent регистрирует обработчик, вызываемый после успешного сохранения
+// события. Ошибки таких обработчиков только логируются.
+func (cs *ClientService) OnEvent(eventType string, fn EventHandler) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.hooks.after == nil {
+		cs.hooks.after = make(map[string][]EventHandler)
+	}
+	cs.hooks.after[eventType] = append(cs.hooks.after[eventType], fn)
+}
+
+// handlersFor возвращает обработчики типа события и обработчики всех типов.
+// Вызывается под cs.mu; возвращает копию, чтобы вызывать её без блокировки.
+func handlersFor(m map[string][]EventHandler, eventType string) []EventHandler {
+	specific, all := m[eventType], m[AnyEventType]
+	if len(specific)+len(all) == 0 {
+		return nil
+	}
+	result := make([]EventHandler, 0, len(specific)+len(all))
+	result = append(result, all...)
+	return append(result, specific...)
+}
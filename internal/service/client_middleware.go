@@ -0,0 +1,75 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Next передаёт событие следующему звену конвейера обработки.
+type Next func(event domain.Event) error
+
+// Middleware — звено конвейера обработки события клиентом. Оно может
+// изменить событие (обогащение), отклонить его ошибкой (валидация), не
+// вызвать next (фильтрация) или замерить обработку (метрики). Последнее
+// звено — стандартная обработка: дедупликация, BeforeSave, сохранение, OnEvent.
+//
+// Ошибка middleware означает, что событие не обработано и не подтверждается
+// серверу; событие, отброшенное без вызова next, считается обработанным.
+// Для дубликата next возвращает domain.ErrDuplicateEvent — это успех.
+// При пакетной записи или пуле обработки next возвращает управление до
+// сохранения события, и ошибка записи не возвращается из next.
+type Middleware func(event domain.Event, next Next) error
+
+// Use добавляет middleware в конец конвейера: первое добавленное вызывается первым.
+func (cs *ClientService) Use(mw ...Middleware) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.middleware = append(cs.middleware, mw...)
+}
+
+// runMiddleware проводит событие через конвейер middleware и стандартную
+// обработку. done вызывается ровно один раз.
+func (cs *ClientService) runMiddleware(chain []Middleware, event domain.Event, done func(error)) {
+	handled := false
+	var next func(i int) Next
+	next = func(i int) Next {
+		return func(event domain.Event) error {
+			if handled {
+				return nil
+			}
+			if i == len(chain) {
+				handled = true
+				errCh := make(chan error, 1)
+				cs.handle(event, func(err error) {
+					// Синхронная обработка сообщает результат сразу,
+					// асинхронная — позже, уже из другой горутины.
+					select {
+					case errCh <- err:
+					default:
+					}
+					done(err)
+				})
+				select {
+				case err := <-errCh:
+					return err
+				default:
+					return nil
+				}
+			}
+			return chain[i](event, next(i+1))
+		}
+	}
+	err := next(0)(event)
+	if handled {
+		if err != nil {
+			cs.logger.Error("Middleware error after event was handled", "id", event.ID, "error", err)
+		}
+		return
+	}
+	if err != nil {
+		cs.logger.Error("Event rejected by middleware", "id", event.ID, "error", err)
+		err = fmt.Errorf("%w: %w", ErrEventRejected, err)
+	}
+	done(err)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// RetentionPolicy ограничивает объём клиентского хранилища. Нулевые поля
+// не ограничивают хранение.
+type RetentionPolicy struct {
+	MaxAge  time.Duration // события старше удаляются
+	MaxRows int           // сверх этого числа удаляются самые старые события
+}
+
+// Prune однократно применяет политику хранения и возвращает число удалённых
+// событий. MaxRows требует хранилища, реализующего repository.RowLimiter.
+func (cs *ClientService) Prune(policy RetentionPolicy) (int, error) {
+	deleted := 0
+	if policy.MaxAge > 0 {
+		n, err := cs.repo.DeleteOlderThan(time.Now().Add(-policy.MaxAge))
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	if policy.MaxRows > 0 {
+		limiter, ok := cs.repo.(repository.RowLimiter)
+		if !ok {
+			return deleted, fmt.Errorf("storage does not support max_rows retention")
+		}
+		n, err := limiter.DeleteExceeding(policy.MaxRows)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	cs.metrics.EventsPruned.Add(uint64(deleted))
+	return deleted, nil
+}
+
+// StartPruner запускает фоновое применение политики хранения каждые
+// interval; первый проход выполняется сразу. Остановка — в Close.
+// Вызывается до начала обработки событий.
+func (cs *ClientService) StartPruner(policy RetentionPolicy, interval time.Duration) error {
+	if policy.MaxRows > 0 {
+		if _, ok := cs.repo.(repository.RowLimiter); !ok {
+			return fmt.Errorf("storage does not support max_rows retention")
+		}
+	}
+	cs.pruneStop = make(chan struct{})
+	cs.pruneDone = make(chan struct{})
+	go func() {
+		defer close(cs.pruneDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			n, err := cs.Prune(policy)
+			if err != nil {
+				cs.logger.Error("Error pruning events", "error", err)
+			} else if n > 0 {
+				cs.logger.Info("Pruned events", "deleted", n)
+			}
+			select {
+			case <-cs.pruneStop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	cs.logger.Info("Retention enabled", "max_age", policy.MaxAge, "max_rows", policy.MaxRows, "interval", interval)
+	return nil
+}
+
+// stopPruner останавливает фоновое удаление, дожидаясь текущего прохода.
+func (cs *ClientService) stopPruner() {
+	if cs.pruneStop == nil {
+		return
+	}
+	close(cs.pruneStop)
+	<-cs.pruneDone
+	cs.pruneStop = nil
+}
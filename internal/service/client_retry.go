@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// SaveRetryPolicy задаёт повтор сохранения события при временных ошибках
+// хранилища с экспоненциально растущей задержкой.
+type SaveRetryPolicy struct {
+	Attempts       int           // всего попыток, включая первую; <= 1 — без повторов
+	InitialBackoff time.Duration // задержка перед второй попыткой; 0 — 50ms
+	MaxBackoff     time.Duration // верхняя граница задержки; 0 — 2s
+	// Retryable классифицирует ошибку как временную; nil — повторяются
+	// ошибки, обёрнутые в domain.ErrStoreUnavailable.
+	Retryable func(error) bool
+}
+
+// backoff возвращает задержку после attempt-й неудачной попытки (attempt >= 1).
+func (p SaveRetryPolicy) backoff(attempt int) time.Duration {
+	initial, maxBackoff := p.InitialBackoff, p.MaxBackoff
+	if initial <= 0 {
+		initial = 50 * time.Millisecond
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = 2 * time.Second
+	}
+	d := initial
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+func (p SaveRetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return errors.Is(err, domain.ErrStoreUnavailable)
+}
+
+// SetSaveRetry задаёт политику повтора записи событий в хранилище, в том
+// числе пакетной. Повторы выполняются в обрабатывающей горутине, поэтому
+// следующие события ждут их завершения. Вызывается до начала обработки событий.
+func (cs *ClientService) SetSaveRetry(policy SaveRetryPolicy) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.retry = policy
+}
+
+// retrySave выполняет запись save, повторяя её по политике при временных
+// ошибках; возвращает последнюю ошибку.
+func (cs *ClientService) retrySave(save func() error) error {
+	policy := cs.retryPolicy()
+	err := save()
+	for attempt := 1; err != nil && attempt < policy.Attempts && policy.retryable(err); attempt++ {
+		delay := policy.backoff(attempt)
+		cs.metrics.SaveRetries.Inc()
+		cs.logger.Warn("Retrying save", "attempt", attempt+1, "delay", delay, "error", err)
+		time.Sleep(delay)
+		err = save()
+	}
+	return err
+}
+
+// retryPolicy возвращает текущую политику повтора записи.
+func (cs *ClientService) retryPolicy() SaveRetryPolicy {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.retry
+}
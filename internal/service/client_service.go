@@ -1,19 +1,56 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/metrics"
 	"github.com/wrongjunior/eventsync/internal/repository"
 	"log/slog"
 )
 
+// ErrServiceClosed возвращается обработкой событий, поступивших после Close.
+var ErrServiceClosed = errors.New("client service is closed")
+
 // ClientService реализует бизнеслогику клиента: фильтрация дубликатов и сохранение событий.
 type ClientService struct {
-	repo        repository.EventRepository
-	logger      *slog.Logger
-	mu          sync.Mutex
-	receivedIDs map[string]struct{}
+	repo   repository.EventRepository
+	logger *slog.Logger
+	mu     sync.Mutex
+	// receivedIDs — ограниченный кэш недавно полученных ID (и хэшей
+	// содержимого, см. dedupMode). Он лишь снижает
+	// число обращений к БД: корректность обеспечивает INSERT OR IGNORE.
+	receivedIDs    *lruSet
+	dedupMode      DedupMode
+	dedupWindow    time.Duration       // окно дедупликации; 0 — без ограничения по времени
+	lastSeq        uint64              // наибольший сохранённый серверный номер события
+	inflight       map[uint64]int      // номера событий, обработка которых не завершена
+	unsaved        map[uint64]struct{} // номера событий, не сохранённых из-за ошибки
+	eventTypes     map[string]struct{} // сохраняемые типы событий; nil — все
+	minSeverity    domain.Severity     // порог важности сохраняемых событий
+	severities     domain.SeverityMap
+	hooks          clientHooks
+	metrics        *metrics.ClientMetrics
+	batch          *batchWriter // буфер пакетной записи; nil — запись по одному событию
+	workers        *workerPool  // пул обработки; nil — обработка в вызывающей горутине
+	middleware     []Middleware
+	causal         *causalBuffer   // буфер причинного порядка; nil — события обрабатываются по получении
+	retry          SaveRetryPolicy // повтор записи при временных ошибках хранилища
+	breaker        *breaker        // автомат защиты хранилища; nil — выключен
+	dlq            DeadLetterQueue // очередь несохранённых событий; nil — выключена
+	dlqMaxFailures int
+	failures       map[string]int // неудачные попытки сохранения по ID события
+	pruneStop      chan struct{}  // остановка фонового удаления; nil — не запущено
+	pruneDone      chan struct{}
+	stats          struct{ received, duplicates, saveErrors atomic.Uint64 } // для отчёта о состоянии
+	// closeMu удерживается на чтение, пока событие передаётся в обработку,
+	// чтобы Close не закрыл очереди под ещё работающим транспортом.
+	closeMu sync.RWMutex
+	closed  bool
 }
 
 // NewClientService создаёт новый экземпляр клиентского сервиса.
@@ -21,21 +58,347 @@ func NewClientService(repo repository.EventRepository, logger *slog.Logger) *Cli
 	return &ClientService{
 		repo:        repo,
 		logger:      logger,
-		receivedIDs: make(map[string]struct{}),
+		receivedIDs: newLRUSet(DefaultDedupCacheSize, 0),
+		metrics:     &metrics.ClientMetrics{},
 	}
 }
 
-// ProcessEvent фильтрует дубли и сохраняет событие.
-func (cs *ClientService) ProcessEvent(event domain.Event) {
+// SetDedupCacheSize задаёт вместимость кэша дедупликации; уже накопленные ID сбрасываются.
+func (cs *ClientService) SetDedupCacheSize(size int) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.receivedIDs = newLRUSet(size, cs.dedupWindow)
+}
+
+// SetMetrics подключает метрики клиента.
+func (cs *ClientService) SetMetrics(m *metrics.ClientMetrics) {
+	cs.metrics = m
+}
+
+// SetEventTypes ограничивает сохраняемые события указанными типами.
+// Пустой список снимает ограничение.
+func (cs *ClientService) SetEventTypes(types []string) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
-	if _, exists := cs.receivedIDs[event.ID]; exists {
-		cs.logger.Info("Duplicate event filtered", "id", event.ID)
+	if len(types) == 0 {
+		cs.eventTypes = nil
+		return
+	}
+	cs.eventTypes = make(map[string]struct{}, len(types))
+	for _, t := range types {
+		cs.eventTypes[t] = struct{}{}
+	}
+}
+
+// SetMinSeverity ограничивает сохраняемые события порогом важности min;
+// важность пользовательских типов задаёт severities.
+func (cs *ClientService) SetMinSeverity(min domain.Severity, severities domain.SeverityMap) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.minSeverity, cs.severities = min, severities
+}
+
+// EnableBatching включает пакетную запись: события накапливаются и
+// сохраняются одной транзакцией (с одним fsync) при наборе policy.Size штук
+// либо через policy.FlushInterval после первого события в буфере.
+// Репозиторий без SaveBatch сохраняет пачку поштучно. Вызывается до начала
+// обработки событий.
+func (cs *ClientService) EnableBatching(policy BatchPolicy) {
+	policy = policy.withDefaults()
+	save := func(events []domain.Event) error {
+		for _, event := range events {
+			if err := cs.repo.Save(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if bs, ok := cs.repo.(batchSaver); ok {
+		save = bs.SaveBatch
+	}
+	cs.batch = newBatchWriter(func(events []domain.Event) error {
+		return cs.retrySave(func() error { return save(events) })
+	}, policy)
+	cs.logger.Info("Batched writes enabled", "batch_size", policy.Size,
+		"flush_interval", policy.FlushInterval, "max_in_flight", policy.MaxInFlight)
+}
+
+// Close дожидается обработки событий из очереди пула и записывает события,
+// накопленные в буфере пакетной записи. События, поступившие после Close,
+// отклоняются с ErrServiceClosed, поэтому Close можно вызывать, не дожидаясь
+// остановки транспорта.
+func (cs *ClientService) Close() {
+	cs.closeMu.Lock()
+	defer cs.closeMu.Unlock()
+	if cs.closed {
+		return
+	}
+	cs.closed = true
+	cs.stopPruner()
+	if cs.causal != nil {
+		cs.causal.flush()
+	}
+	if cs.workers != nil {
+		cs.workers.stop()
+	}
+	if cs.breaker != nil {
+		cs.breaker.shutdown()
+	}
+	if cs.batch != nil {
+		cs.batch.close()
+	}
+}
+
+// ProcessEvent фильтрует дубли, вызывает обработчики и сохраняет событие.
+// Ошибка означает, что событие не сохранено и подтверждать его серверу нельзя,
+// кроме domain.ErrDuplicateEvent: дубликат уже сохранён ранее и подтверждается
+// как успешно обработанный. Недоступность хранилища сообщается ошибкой,
+// обёрнутой в domain.ErrStoreUnavailable. При пакетной записи
+// ProcessEvent ждёт записи всей пачки; транспорту следует использовать
+// ProcessEventAsync.
+func (cs *ClientService) ProcessEvent(event domain.Event) error {
+	errCh := make(chan error, 1)
+	cs.ProcessEventAsync(event, func(err error) { errCh <- err })
+	return <-errCh
+}
+
+// ProcessEventAsync обрабатывает событие как ProcessEvent, но не ждёт записи:
+// done вызывается после сохранения события (возможно, из другой горутины)
+// с тем же результатом, который вернул бы ProcessEvent. При включённом пуле
+// обработчиков событие ставится в его очередь, при причинном порядке —
+// может ждать своих причин.
+func (cs *ClientService) ProcessEventAsync(event domain.Event, done func(error)) {
+	cs.closeMu.RLock()
+	defer cs.closeMu.RUnlock()
+	if cs.closed {
+		done(ErrServiceClosed)
+		return
+	}
+	done = cs.track(event.Seq, done)
+	if cs.causal != nil {
+		cs.causal.add(event, done)
 		return
 	}
-	cs.receivedIDs[event.ID] = struct{}{}
+	cs.dispatch(event, done)
+}
+
+// dispatch передаёт событие пулу обработки или обрабатывает его сразу.
+func (cs *ClientService) dispatch(event domain.Event, done func(error)) {
+	if cs.workers != nil {
+		cs.enqueue(event, done)
+		return
+	}
+	cs.processEvent(event, done)
+}
+
+// processEvent проводит событие через middleware, если они заданы, и стандартную обработку.
+func (cs *ClientService) processEvent(event domain.Event, done func(error)) {
+	cs.mu.Lock()
+	chain := cs.middleware
+	cs.mu.Unlock()
+	if len(chain) == 0 {
+		cs.handle(event, done)
+		return
+	}
+	cs.runMiddleware(chain, event, done)
+}
+
+// handle — стандартная обработка события: фильтры, обработчики, сохранение.
+func (cs *ClientService) handle(event domain.Event, done func(error)) {
+	cs.metrics.EventsReceived.Inc()
+	cs.stats.received.Add(1)
+	if !event.Timestamp.IsZero() {
+		cs.metrics.EventLatency.Observe(time.Since(event.Timestamp).Seconds())
+	}
+	cs.mu.Lock()
+	if cs.eventTypes != nil {
+		if _, ok := cs.eventTypes[event.Type]; !ok {
+			cs.mu.Unlock()
+			cs.logger.Debug("Event type filtered", "id", event.ID, "type", event.Type)
+			done(nil)
+			return
+		}
+	}
+	if !cs.severities.Allows(event.Type, cs.minSeverity) {
+		cs.mu.Unlock()
+		cs.logger.Debug("Event below severity threshold", "id", event.ID, "type", event.Type)
+		done(nil)
+		return
+	}
+	keys, hash := cs.dedupKeys(event)
+	for _, key := range keys {
+		if cs.receivedIDs.contains(key) {
+			cs.mu.Unlock()
+			cs.duplicate(event, done)
+			return
+		}
+	}
+	for _, key := range keys {
+		cs.receivedIDs.add(key)
+	}
+	before := handlersFor(cs.hooks.before, event.Type)
+	after := handlersFor(cs.hooks.after, event.Type)
+	window := cs.dedupWindow
+	cs.mu.Unlock()
+
+	if window == 0 && cs.storedHash(event, hash) {
+		cs.duplicate(event, done)
+		return
+	}
+
+	// Обработчики вызываются без блокировки: им разрешено обращаться к сервису.
 	cs.logger.Info("Processing event", "event", event)
-	if err := cs.repo.Save(event); err != nil {
-		cs.logger.Error("Error saving event", "error", err)
+	for _, fn := range before {
+		if err := fn(event); err != nil {
+			if errors.Is(err, ErrSkipEvent) {
+				done(nil)
+				return
+			}
+			cs.forget(keys...)
+			cs.logger.Error("Event rejected by handler", "id", event.ID, "error", err)
+			done(fmt.Errorf("%w: %w", ErrEventRejected, err))
+			return
+		}
+	}
+
+	saved := func(err error) {
+		if err != nil {
+			cs.forget(keys...)
+			cs.metrics.SaveErrors.Inc()
+			cs.stats.saveErrors.Add(1)
+			cs.logger.Error("Error saving event", "id", event.ID, "error", err)
+			if cs.deadLetter(event, err) {
+				done(nil)
+				return
+			}
+			done(err)
+			return
+		}
+		cs.saveSucceeded(event.ID)
+		cs.mu.Lock()
+		if event.Seq > cs.lastSeq {
+			cs.lastSeq = event.Seq
+		}
+		cs.mu.Unlock()
+
+		for _, fn := range after {
+			if err := fn(event); err != nil {
+				cs.logger.Error("Event handler error", "id", event.ID, "type", event.Type, "error", err)
+			}
+		}
+		done(nil)
+	}
+	if cs.breaker != nil {
+		if cs.breaker.hold(event, saved) {
+			return
+		}
+		record := saved
+		saved = func(err error) {
+			cs.breaker.record(err)
+			record(err)
+		}
+	}
+	cs.write(event, saved)
+}
+
+// write записывает событие в хранилище — через буфер пакетной записи или
+// сразу с повтором по политике — и вызывает done с результатом.
+func (cs *ClientService) write(event domain.Event, done func(error)) {
+	if replacer, ok := cs.repo.(repository.EventReplacer); ok && cs.windowed() {
+		done(cs.retrySave(func() error { return replacer.Replace(event) }))
+		return
+	}
+	if cs.batch != nil {
+		cs.batch.enqueue(event, done)
+		return
+	}
+	done(cs.retrySave(func() error { return cs.repo.Save(event) }))
+}
+
+// duplicate учитывает отброшенный дубликат. Он уже сохранён ранее, поэтому
+// ошибка domain.ErrDuplicateEvent означает успешную обработку.
+func (cs *ClientService) duplicate(event domain.Event, done func(error)) {
+	cs.metrics.DuplicatesFiltered.Inc()
+	cs.stats.duplicates.Add(1)
+	cs.logger.Info("Duplicate event filtered", "id", event.ID)
+	done(fmt.Errorf("%w: %s", domain.ErrDuplicateEvent, event.ID))
+}
+
+// forget снимает отметки о получении события, чтобы повторная доставка
+// после неудачной обработки не была отброшена как дубликат.
+func (cs *ClientService) forget(keys ...string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for _, key := range keys {
+		cs.receivedIDs.remove(key)
+	}
+}
+
+// LoadCheckpoint восстанавливает номер последнего сохранённого события из
+// репозитория, чтобы после перезапуска продолжить с места остановки.
+func (cs *ClientService) LoadCheckpoint() error {
+	seq, err := cs.repo.LastSeq()
+	if err != nil {
+		return err
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if seq > cs.lastSeq {
+		cs.lastSeq = seq
+	}
+	cs.logger.Info("Checkpoint loaded", "last_seq", cs.lastSeq)
+	return nil
+}
+
+// Query возвращает сохранённые события, удовлетворяющие фильтру.
+func (cs *ClientService) Query(filter repository.EventFilter) ([]domain.Event, error) {
+	return cs.repo.Query(filter)
+}
+
+// LastSeq возвращает номер, с которого транспорт просит сервер продолжить
+// рассылку: все полученные события с номерами не больше него сохранены.
+// Пул обработчиков и приоритетная доставка завершают запись не по порядку,
+// поэтому это не наибольший сохранённый номер, а номер перед самым ранним
+// событием, которое ещё обрабатывается или не сохранено из-за ошибки.
+func (cs *ClientService) LastSeq() uint64 {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	seq := cs.lastSeq
+	for pending := range cs.inflight {
+		seq = min(seq, pending-1)
+	}
+	for pending := range cs.unsaved {
+		seq = min(seq, pending-1)
+	}
+	return seq
+}
+
+// track отмечает событие с номером seq как обрабатываемое до вызова done.
+// Событие, которое не удалось сохранить, удерживает LastSeq перед собой,
+// пока не будет сохранено при повторной доставке. Отклонённое обработчиком
+// событие (ErrEventRejected) обработано и LastSeq не удерживает.
+func (cs *ClientService) track(seq uint64, done func(error)) func(error) {
+	if seq == 0 {
+		return done
+	}
+	cs.mu.Lock()
+	if cs.inflight == nil {
+		cs.inflight = make(map[uint64]int)
+		cs.unsaved = make(map[uint64]struct{})
+	}
+	cs.inflight[seq]++
+	cs.mu.Unlock()
+	return func(err error) {
+		cs.mu.Lock()
+		if cs.inflight[seq]--; cs.inflight[seq] <= 0 {
+			delete(cs.inflight, seq)
+		}
+		if err != nil && !errors.Is(err, domain.ErrDuplicateEvent) && !errors.Is(err, ErrEventRejected) {
+			cs.unsaved[seq] = struct{}{}
+		} else {
+			delete(cs.unsaved, seq)
+		}
+		cs.mu.Unlock()
+		done(err)
 	}
 }
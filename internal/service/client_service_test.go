@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// TestLastSeqWatermark проверяет, что LastSeq не обгоняет событие, запись
+// которого ещё не завершена или не удалась, даже если более поздние
+// события уже сохранены, а отклонённое обработчиком событие его не держит.
+func TestLastSeqWatermark(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	failed := errors.New("rejected")
+	repo := &failingRepository{EventRepository: repository.NewMemoryRepository(0), fail: "broken"}
+	cs := NewClientService(repo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	cs.BeforeSave(AnyEventType, func(event domain.Event) error {
+		switch event.ID {
+		case "slow":
+			close(started)
+			<-release
+		case "bad":
+			return failed
+		}
+		return nil
+	})
+
+	slow := make(chan error, 1)
+	go cs.ProcessEventAsync(domain.Event{ID: "slow", Type: "t", Seq: 1}, func(err error) { slow <- err })
+	<-started
+	for seq, id := range map[uint64]string{2: "a", 3: "b"} {
+		if err := cs.ProcessEvent(domain.Event{ID: id, Type: "t", Seq: seq}); err != nil {
+			t.Fatalf("ProcessEvent(%d): %v", seq, err)
+		}
+	}
+	if got := cs.LastSeq(); got != 0 {
+		t.Fatalf("LastSeq with seq 1 in flight = %d, want 0", got)
+	}
+	close(release)
+	if err := <-slow; err != nil {
+		t.Fatalf("ProcessEvent(1): %v", err)
+	}
+	if got := cs.LastSeq(); got != 3 {
+		t.Fatalf("LastSeq = %d, want 3", got)
+	}
+
+	if err := cs.ProcessEvent(domain.Event{ID: "broken", Type: "t", Seq: 4}); err == nil {
+		t.Fatal("ProcessEvent(4) with failing store succeeded")
+	}
+	if err := cs.ProcessEvent(domain.Event{ID: "c", Type: "t", Seq: 5}); err != nil {
+		t.Fatalf("ProcessEvent(5): %v", err)
+	}
+	if got := cs.LastSeq(); got != 3 {
+		t.Fatalf("LastSeq with seq 4 unsaved = %d, want 3", got)
+	}
+	if err := cs.ProcessEvent(domain.Event{ID: "d", Type: "t", Seq: 4}); err != nil {
+		t.Fatalf("ProcessEvent(4) redelivery: %v", err)
+	}
+	if got := cs.LastSeq(); got != 5 {
+		t.Fatalf("LastSeq = %d, want 5", got)
+	}
+
+	err := cs.ProcessEvent(domain.Event{ID: "bad", Type: "t", Seq: 6})
+	if !errors.Is(err, failed) || !errors.Is(err, ErrEventRejected) {
+		t.Fatalf("ProcessEvent(6) = %v, want rejected %v", err, failed)
+	}
+	if err := cs.ProcessEvent(domain.Event{ID: "e", Type: "t", Seq: 7}); err != nil {
+		t.Fatalf("ProcessEvent(7): %v", err)
+	}
+	if got := cs.LastSeq(); got != 7 {
+		t.Fatalf("LastSeq after rejected seq 6 = %d, want 7", got)
+	}
+}
+
+// failingRepository не сохраняет событие с ID fail.
+type failingRepository struct {
+	repository.EventRepository
+	fail string
+}
+
+func (r *failingRepository) Save(event domain.Event) error {
+	if event.ID == r.fail {
+		return errors.New("disk full")
+	}
+	return r.EventRepository.Save(event)
+}
+
+// TestCloseWhileProcessing проверяет, что Close можно вызвать, пока
+// транспорт ещё передаёт события: принятые события записываются, а
+// поступившие после Close отклоняются без паники.
+func TestCloseWhileProcessing(t *testing.T) {
+	repo := repository.NewMemoryRepository(0)
+	cs := NewClientService(repo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	cs.EnableWorkers(4, 16, OverflowBlock)
+	cs.EnableBatching(BatchPolicy{Size: 8, FlushInterval: time.Hour})
+
+	results := make(chan error, 1000)
+	go func() {
+		for i := 1; i <= cap(results); i++ {
+			cs.ProcessEventAsync(domain.Event{ID: fmt.Sprint(i), Type: "t", Seq: uint64(i)}, func(err error) { results <- err })
+		}
+	}()
+	for len(results) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cs.Close()
+
+	var saved int
+	for i := 0; i < cap(results); i++ {
+		switch err := <-results; {
+		case err == nil:
+			saved++
+		case !errors.Is(err, ErrServiceClosed):
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	events, err := repo.Query(repository.EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != saved {
+		t.Fatalf("stored %d events, %d reported saved", len(events), saved)
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// clientStatus — последнее состояние, сообщённое клиентом, и время его получения.
+type clientStatus struct {
+	status     domain.ClientStatus
+	receivedAt time.Time
+}
+
+// heartbeat — результат измерения по ответу клиента на прикладной ping.
+type heartbeat struct {
+	rtt, offset time.Duration
+	at          time.Time
+}
+
+// RecordHeartbeat сохраняет время оборота и смещение часов клиента
+// относительно сервера, измеренные по ответу на прикладной ping.
+func (c *Client) RecordHeartbeat(rtt, offset time.Duration) {
+	c.heartbeat.Store(&heartbeat{rtt: rtt, offset: offset, at: time.Now()})
+}
+
+// ClientInfo — снимок состояния подключённого клиента для административного API.
+type ClientInfo struct {
+	ID           uint64               `json:"id"`
+	Namespace    string               `json:"namespace"`
+	Topics       []string             `json:"topics"`
+	Group        string               `json:"group,omitempty"` // группа потребителей; пусто — вне группы
+	RemoteAddr   string               `json:"remote_addr,omitempty"`
+	Protocol     string               `json:"protocol,omitempty"`
+	ConnectedAt  time.Time            `json:"connected_at"`
+	DeliveredSeq uint64               `json:"delivered_seq"`
+	AckedSeq     uint64               `json:"acked_seq"`
+	Lag          uint64               `json:"lag"`
+	Status       *domain.ClientStatus `json:"status,omitempty"` // nil — клиент не сообщал состояние
+	StatusAt     *time.Time           `json:"status_at,omitempty"`
+	// RTTMillis и ClockOffsetMillis — последнее измерение прикладного
+	// heartbeat; смещение положительно, если часы клиента спешат.
+	RTTMillis         *float64   `json:"rtt_ms,omitempty"`
+	ClockOffsetMillis *float64   `json:"clock_offset_ms,omitempty"`
+	HeartbeatAt       *time.Time `json:"heartbeat_at,omitempty"`
+}
+
+// clientIDs выдаёт номера подключений для административного API.
+var clientIDs atomic.Uint64
+
+// sourceName возвращает Source для событий, опубликованных клиентом: имя
+// из его отчёта о состоянии, а до первого отчёта — номер подключения.
+func (c *Client) sourceName() string {
+	name := "conn-" + strconv.FormatUint(c.id, 10)
+	if st := c.status.Load(); st != nil && st.status.ClientID != "" {
+		name = st.status.ClientID
+	}
+	return domain.NewSource(domain.SourceClient, name)
+}
+
+// ID возвращает номер подключения, присвоенный при регистрации.
+func (c *Client) ID() uint64 {
+	return c.id
+}
+
+// ConnectedAt возвращает время регистрации клиента.
+func (c *Client) ConnectedAt() time.Time {
+	return c.connectedAt
+}
+
+// ReportStatus сохраняет состояние, сообщённое клиентом.
+func (s *EventService) ReportStatus(client *Client, status domain.ClientStatus) {
+	client.status.Store(&clientStatus{status: status, receivedAt: time.Now()})
+}
+
+// Clients возвращает снимок состояния подключённых клиентов пространства
+// имён namespace; пустая строка — всех пространств имён.
+func (s *EventService) Clients(namespace string) []ClientInfo {
+	infos := []ClientInfo{}
+	for _, sh := range s.shardsSnapshot(namespace) {
+		sh.mu.RLock()
+		for client := range sh.clients {
+			infos = append(infos, clientInfo(client))
+		}
+		sh.mu.RUnlock()
+	}
+	return infos
+}
+
+// clientInfo снимает состояние клиента; вызывается под блокировкой его
+// сегмента реестра, так как Subscribe меняет подписку.
+func clientInfo(client *Client) ClientInfo {
+	info := ClientInfo{
+		ID:           client.id,
+		Namespace:    client.namespace(),
+		Topics:       client.patterns(),
+		RemoteAddr:   client.RemoteAddr,
+		Protocol:     client.Protocol,
+		ConnectedAt:  client.connectedAt,
+		DeliveredSeq: client.deliveredSeq.Load(),
+		AckedSeq:     client.ackedSeq.Load(),
+		Lag:          client.Lag(),
+	}
+	if client.Group != nil {
+		info.Group = client.Group.Name
+	}
+	if st := client.status.Load(); st != nil {
+		status, at := st.status, st.receivedAt
+		info.Status, info.StatusAt = &status, &at
+	}
+	if hb := client.heartbeat.Load(); hb != nil {
+		rtt, offset, at := millis(hb.rtt), millis(hb.offset), hb.at
+		info.RTTMillis, info.ClockOffsetMillis, info.HeartbeatAt = &rtt, &offset, &at
+	}
+	return info
+}
+
+// Status возвращает состояние синхронизации клиента для отчёта серверу.
+func (cs *ClientService) Status() domain.ClientStatus {
+	status := domain.ClientStatus{
+		Kind:               domain.FrameKindStatus,
+		LastSeq:            cs.LastSeq(),
+		EventsReceived:     cs.stats.received.Load(),
+		DuplicatesFiltered: cs.stats.duplicates.Load(),
+		SaveErrors:         cs.stats.saveErrors.Load(),
+	}
+	if cs.workers != nil {
+		status.QueueDepth = cs.workers.depth()
+	}
+	return status
+}
+
+// millis переводит длительность в миллисекунды.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
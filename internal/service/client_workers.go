@@ -0,0 +1,142 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// ErrQueueFull возвращается обработчику события, отброшенного из-за
+// переполнения очереди обработки; такое событие не сохраняется и не подтверждается.
+var ErrQueueFull = errors.New("processing queue full")
+
+// OverflowPolicy определяет поведение при переполнении очереди обработки.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock приостанавливает приём событий до освобождения места.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop отбрасывает новое событие, не блокируя цикл чтения.
+	OverflowDrop
+)
+
+// ParseOverflowPolicy разбирает название политики: "block" (по умолчанию) или "drop".
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch s {
+	case "", "block":
+		return OverflowBlock, nil
+	case "drop":
+		return OverflowDrop, nil
+	default:
+		return 0, fmt.Errorf("unknown overflow policy %q", s)
+	}
+}
+
+// workItem — событие, ожидающее обработки пулом.
+type workItem struct {
+	event domain.Event
+	done  func(error)
+}
+
+// workerPool обрабатывает события в нескольких горутинах, чтобы медленное
+// хранилище не останавливало цикл чтения транспорта. События без ключа
+// партиции берёт любой свободный обработчик из общей очереди, а события с
+// ключом попадают в очередь обработчика, выбранного по ключу, и
+// обрабатываются им по одному в порядке получения.
+type workerPool struct {
+	queue  chan workItem   // события без ключа партиции
+	keyed  []chan workItem // события с ключом: очередь каждого обработчика
+	policy OverflowPolicy
+	wg     sync.WaitGroup
+}
+
+// EnableWorkers переносит обработку событий в пул из workers горутин с
+// очередью на queueSize событий без ключа партиции; очередь каждого
+// обработчика для событий с ключом вмещает queueSize/workers событий. События
+// без ключа могут сохраняться не в порядке получения, события одного ключа —
+// всегда по порядку. Вызывается до начала обработки событий.
+func (cs *ClientService) EnableWorkers(workers, queueSize int, policy OverflowPolicy) {
+	workers = max(workers, 1)
+	pool := &workerPool{
+		queue:  make(chan workItem, queueSize),
+		keyed:  make([]chan workItem, workers),
+		policy: policy,
+	}
+	for i := range pool.keyed {
+		pool.keyed[i] = make(chan workItem, max(queueSize/workers, 1))
+		pool.wg.Add(1)
+		go func(keyed chan workItem) {
+			defer pool.wg.Done()
+			shared := pool.queue
+			for shared != nil || keyed != nil {
+				var (
+					item workItem
+					ok   bool
+				)
+				select {
+				case item, ok = <-shared:
+					if !ok {
+						shared = nil
+						continue
+					}
+				case item, ok = <-keyed:
+					if !ok {
+						keyed = nil
+						continue
+					}
+				}
+				cs.metrics.QueueDepth.Set(float64(pool.depth()))
+				cs.processEvent(item.event, item.done)
+			}
+		}(pool.keyed[i])
+	}
+	cs.workers = pool
+	cs.logger.Info("Event worker pool enabled", "workers", workers, "queue_size", queueSize)
+}
+
+// enqueue ставит событие в очередь пула согласно политике переполнения.
+func (cs *ClientService) enqueue(event domain.Event, done func(error)) {
+	item := workItem{event: event, done: done}
+	queue := cs.workers.queueFor(event)
+	if cs.workers.policy == OverflowDrop {
+		select {
+		case queue <- item:
+		default:
+			cs.metrics.QueueDropped.Inc()
+			cs.logger.Warn("Processing queue full, event dropped", "id", event.ID)
+			done(ErrQueueFull)
+			return
+		}
+	} else {
+		queue <- item
+	}
+	cs.metrics.QueueDepth.Set(float64(cs.workers.depth()))
+}
+
+// queueFor возвращает очередь события: общую или обработчика его ключа партиции.
+func (p *workerPool) queueFor(event domain.Event) chan workItem {
+	if event.PartitionKey == "" {
+		return p.queue
+	}
+	return p.keyed[partitionIndex(event.NamespaceOrDefault(), event.PartitionKey, len(p.keyed))]
+}
+
+// depth возвращает число событий, ожидающих обработки.
+func (p *workerPool) depth() int {
+	n := len(p.queue)
+	for _, keyed := range p.keyed {
+		n += len(keyed)
+	}
+	return n
+}
+
+// stop дожидается обработки событий, уже стоящих в очереди.
+func (p *workerPool) stop() {
+	close(p.queue)
+	for _, keyed := range p.keyed {
+		close(keyed)
+	}
+	p.wg.Wait()
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// ErrConflict возвращается публикацией, проигравшей разрешение конфликта.
+var ErrConflict = errors.New("conflicting update rejected")
+
+// ConflictResolver разрешает конфликт публикуемого события incoming с
+// текущей версией current той же сущности — последним событием того же
+// типа в том же топике и пространстве имён. Возвращает событие, которое
+// будет разослано, либо ошибку, отклоняющую публикацию.
+type ConflictResolver interface {
+	Resolve(current, incoming domain.Event) (domain.Event, error)
+}
+
+// MergeFunc — пользовательская функция слияния версий сущности.
+type MergeFunc func(current, incoming domain.Event) (domain.Event, error)
+
+// Resolve вызывает f.
+func (f MergeFunc) Resolve(current, incoming domain.Event) (domain.Event, error) {
+	return f(current, incoming)
+}
+
+// LastWriterWins принимает версию с более поздним Timestamp; при равном
+// времени побеждает больший ID, чтобы все узлы выбрали одно и то же.
+type LastWriterWins struct{}
+
+// Resolve отклоняет incoming, если она старше current.
+func (LastWriterWins) Resolve(current, incoming domain.Event) (domain.Event, error) {
+	if incoming.Timestamp.After(current.Timestamp) ||
+		incoming.Timestamp.Equal(current.Timestamp) && incoming.ID > current.ID {
+		return incoming, nil
+	}
+	return domain.Event{}, fmt.Errorf("%w: newer version %s exists", ErrConflict, current.ID)
+}
+
+// ServerWins сохраняет версию, уже принятую сервером: конфликтующее
+// обновление отклоняется. Клиент должен обновлять сущность, зная её
+// текущую версию (по причинным метаданным), и повторить попытку.
+type ServerWins struct{}
+
+// Resolve всегда отклоняет incoming.
+func (ServerWins) Resolve(current, _ domain.Event) (domain.Event, error) {
+	return domain.Event{}, fmt.Errorf("%w: current version is %s", ErrConflict, current.ID)
+}
+
+// ParseConflictResolver возвращает встроенную стратегию по названию:
+// "lww" или "server_wins"; пустая строка — без разрешения конфликтов.
+func ParseConflictResolver(name string) (ConflictResolver, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "lww":
+		return LastWriterWins{}, nil
+	case "server_wins":
+		return ServerWins{}, nil
+	default:
+		return nil, fmt.Errorf("unknown conflict resolution %q", name)
+	}
+}
+
+// SetConflictResolver включает разрешение конфликтов для публикаций
+// (через HTTP и WebSocket). Обновление конфликтует с текущей версией
+// сущности, если не следует за ней причинно: у него нет причинных
+// метаданных или его векторные часы не покрывают часы текущей версии.
+// Вызывается до запуска сервера.
+func (s *EventService) SetConflictResolver(r ConflictResolver) {
+	s.resolver = r
+}
+
+// resolveConflict применяет стратегию к публикуемому событию. Проверка и
+// запись принятой версии идут под блокировкой ключа сущности, поэтому
+// параллельное обновление той же сущности сравнивается уже с ней, даже
+// если она ещё не разослана; публикации разных сущностей не ждут друг друга.
+func (s *EventService) resolveConflict(event domain.Event) (domain.Event, error) {
+	key := snapshotKey{namespace: event.Namespace, eventType: event.Type, topic: event.Topic}
+	mu := &s.resolveLocks[partitionIndex(key.namespace, key.eventType+"\x00"+key.topic, len(s.resolveLocks))]
+	mu.Lock()
+	defer mu.Unlock()
+	current, ok := s.currentVersion(key)
+	if ok && !descends(event, current) {
+		resolved, err := s.resolver.Resolve(current, event)
+		if err != nil {
+			s.logger.Info("Conflicting update rejected", "id", event.ID, "current", current.ID, "error", err)
+			return domain.Event{}, err
+		}
+		if resolved.ID != event.ID {
+			s.logger.Info("Conflict resolved", "id", event.ID, "current", current.ID, "result", resolved.ID)
+		}
+		event = resolved
+	}
+	s.snapMu.Lock()
+	if s.versions == nil {
+		s.versions = make(map[snapshotKey]domain.Event)
+	}
+	s.versions[key] = event
+	s.snapMu.Unlock()
+	return event, nil
+}
+
+// currentVersion возвращает текущую версию сущности: последнюю принятую
+// разрешением конфликтов, а до первой такой — последнюю из снимка.
+func (s *EventService) currentVersion(key snapshotKey) (domain.Event, bool) {
+	s.snapMu.Lock()
+	defer s.snapMu.Unlock()
+	if current, ok := s.versions[key]; ok {
+		return current, true
+	}
+	current, ok := s.snapshot[key]
+	return current, ok
+}
+
+// descends сообщает, что событие причинно следует за current.
+func descends(event, current domain.Event) bool {
+	if event.Causality == nil || current.Causality == nil {
+		return false
+	}
+	ec, cc := event.Causality.Clock, current.Causality.Clock
+	return cc.Equal(ec) || cc.HappenedBefore(ec)
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"github.com/wrongjunior/eventsync/internal/crdt"
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// EnableCRDT включает материализацию состояния CRDT: операции из
+// публикуемых событий типа crdt.EventType применяются к состоянию
+// пространства имён, а некорректные операции отклоняются.
+func (s *EventService) EnableCRDT() {
+	s.crdtMu.Lock()
+	defer s.crdtMu.Unlock()
+	if s.crdtStores == nil {
+		s.crdtStores = make(map[string]*crdt.Store)
+	}
+}
+
+// CRDTStore возвращает состояние CRDT пространства имён; nil, если
+// материализация выключена или операций ещё не было.
+func (s *EventService) CRDTStore(namespace string) *crdt.Store {
+	s.crdtMu.Lock()
+	defer s.crdtMu.Unlock()
+	return s.crdtStores[namespace]
+}
+
+// applyCRDT применяет операцию события к состоянию его пространства имён.
+func (s *EventService) applyCRDT(event domain.Event) error {
+	if event.Type != crdt.EventType {
+		return nil
+	}
+	s.crdtMu.Lock()
+	if s.crdtStores == nil {
+		s.crdtMu.Unlock()
+		return nil
+	}
+	store, ok := s.crdtStores[event.Namespace]
+	if !ok {
+		store = crdt.NewStore()
+		s.crdtStores[event.Namespace] = store
+	}
+	s.crdtMu.Unlock()
+	return store.Apply(event)
+}
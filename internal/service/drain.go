@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrDraining возвращается публикацией, пока сервер передаёт работу новому
+// процессу: событие нужно опубликовать повторно, уже в новом процессе.
+var ErrDraining = errors.New("server is draining, retry the request")
+
+// SetDraining включает или выключает приём публикаций. Пока он выключен,
+// сервис не присваивает новых номеров событий, поэтому новый процесс,
+// восстановивший состояние, продолжает нумерацию без пересечений.
+func (s *EventService) SetDraining(draining bool) {
+	s.draining.Store(draining)
+}
+
+// drainLinger ограничивает ожидание отключения клиентов после Drain.
+const drainLinger = 5 * time.Second
+
+// Disconnecter — Notifier, умеющий закрыть соединение клиента с указанием
+// причины: отправив уже поставленные в очередь события, транспорт
+// сообщает клиенту, что сервер уходит, и клиент переподключается.
+type Disconnecter interface {
+	Disconnect(reason string)
+}
+
+// Drain отключает подключённых клиентов, равномерно распределяя отключения
+// по period, чтобы тысячи подписчиков переподключались к новому процессу не
+// одновременно. При отмене ctx оставшиеся клиенты отключаются сразу.
+// Клиенты, уведомитель которых не реализует Disconnecter, не отключаются.
+// Возвращает число отключённых клиентов.
+func (s *EventService) Drain(ctx context.Context, period time.Duration, reason string) int {
+	var targets []Disconnecter
+	for _, sh := range s.shardsSnapshot("") {
+		sh.mu.RLock()
+		for client := range sh.clients {
+			if d, ok := client.Notifier.(Disconnecter); ok {
+				targets = append(targets, d)
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	if len(targets) == 0 {
+		return 0
+	}
+	s.logger.Info("Draining clients", "clients", len(targets), "period", period)
+	step := period / time.Duration(len(targets))
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	for i, d := range targets {
+		d.Disconnect(reason)
+		if step <= 0 || i == len(targets)-1 || ctx.Err() != nil {
+			continue
+		}
+		timer.Reset(step)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	// Кадры закрытия отправляются асинхронно: ждём, пока клиенты отключатся,
+	// но не дольше drainLinger.
+	timer.Reset(drainLinger)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for len(s.Clients("")) > 0 {
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			return len(targets)
+		case <-ctx.Done():
+			return len(targets)
+		}
+	}
+	return len(targets)
+}
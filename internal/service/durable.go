@@ -0,0 +1,196 @@
+package service
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/durable"
+)
+
+var (
+	// ErrDurableDisabled возвращается для именованной подписки, если
+	// долговременные подписки не включены.
+	ErrDurableDisabled = errors.New("durable subscriptions are not enabled")
+	// ErrSubscriptionInUse возвращается, если подписку уже держит другое
+	// подключение.
+	ErrSubscriptionInUse = errors.New("subscription is already in use")
+)
+
+// DurableSubscription — долговременная подписка для административного API.
+type DurableSubscription struct {
+	durable.Record
+	Connected bool `json:"connected"` // подписку держит подключение
+}
+
+// durableSub — долговременная подписка в памяти: по её фильтру события
+// попадают в журнал, пока подписчик подключён и пока его нет.
+type durableSub struct {
+	filter *Client // шаблоны топиков, типы и порог важности; nil — пока не задан
+	active bool    // подписку держит подключение; под durableMu
+}
+
+// SetDurableStore включает долговременные подписки и загружает сохранённые.
+// Нумерация событий продолжается после последнего номера в журналах.
+// Вызывается до запуска сервера.
+func (s *EventService) SetDurableStore(store *durable.Store) error {
+	records, err := store.Records("")
+	if err != nil {
+		return err
+	}
+	s.durableMu.Lock()
+	defer s.durableMu.Unlock()
+	s.durableStore = store
+	s.durables = make(map[string]map[string]*durableSub)
+	for _, rec := range records {
+		s.durableSubs(rec.Namespace)[rec.Name] = &durableSub{filter: durableFilter(rec)}
+	}
+	s.ResumeSeq(store.MaxSeq())
+	return nil
+}
+
+// durableSubs возвращает подписки пространства имён, создавая набор при
+// необходимости; вызывается под durableMu на запись.
+func (s *EventService) durableSubs(namespace string) map[string]*durableSub {
+	subs := s.durables[namespace]
+	if subs == nil {
+		subs = make(map[string]*durableSub)
+		s.durables[namespace] = subs
+	}
+	return subs
+}
+
+// durableFilter восстанавливает фильтр подписки из записи.
+func durableFilter(rec durable.Record) *Client {
+	filter := &Client{Namespace: rec.Namespace, Topics: rec.Topics}
+	if len(rec.Types) > 0 {
+		filter.EventTypes = make(map[string]struct{}, len(rec.Types))
+		for _, t := range rec.Types {
+			filter.EventTypes[t] = struct{}{}
+		}
+	}
+	filter.MinSeverity, _ = domain.ParseSeverity(rec.MinSeverity)
+	return filter
+}
+
+// AcquireDurable занимает подписку name для подключения, создавая её при
+// необходимости. Подписку держит одно подключение; release освобождает её.
+func (s *EventService) AcquireDurable(namespace, name string) (release func(), err error) {
+	s.durableMu.Lock()
+	defer s.durableMu.Unlock()
+	if s.durableStore == nil {
+		return nil, ErrDurableDisabled
+	}
+	subs := s.durableSubs(namespace)
+	sub := subs[name]
+	if sub == nil {
+		sub = &durableSub{}
+		subs[name] = sub
+	} else if sub.active {
+		return nil, ErrSubscriptionInUse
+	}
+	sub.active = true
+	return func() {
+		s.durableMu.Lock()
+		sub.active = false
+		s.durableMu.Unlock()
+	}, nil
+}
+
+// attachDurable сохраняет фильтр подписки клиента и отправляет ему журнал
+// неподтверждённых событий. Вызывается из Register под блокировкой сегмента
+// реестра: рассылки пространства имён ждут её, поэтому новые события идут
+// за журналом без пропусков и повторов.
+func (s *EventService) attachDurable(client *Client) {
+	namespace := client.namespace()
+	rec := durable.Record{Namespace: namespace, Name: client.Subscription, Topics: client.Topics}
+	for t := range client.EventTypes {
+		rec.Types = append(rec.Types, t)
+	}
+	slices.Sort(rec.Types)
+	if client.MinSeverity != domain.SeverityNone {
+		rec.MinSeverity = client.MinSeverity.String()
+	}
+	rec, err := s.durableStore.Put(rec)
+	if err != nil {
+		s.logger.Error("Failed to save durable subscription", "subscription", client.Subscription, "error", err)
+		return
+	}
+	s.durableMu.Lock()
+	if sub := s.durables[namespace][client.Subscription]; sub != nil {
+		sub.filter = durableFilter(rec)
+	}
+	s.durableMu.Unlock()
+	storeMax(&client.ackedSeq, rec.Offset)
+	client.ResumeFrom = max(client.ResumeFrom, rec.Offset)
+
+	backlog, err := s.durableStore.Backlog(namespace, client.Subscription)
+	if err != nil {
+		s.logger.Error("Failed to read durable subscription backlog", "subscription", client.Subscription, "error", err)
+		return
+	}
+	downgraded := make(map[int]*domain.Event)
+	encodings := make(map[int]*Encodings)
+	for _, event := range backlog {
+		clear(downgraded)
+		clear(encodings)
+		s.deliver(client, event, downgraded, encodings)
+	}
+	s.logger.Info("Durable subscription attached", "client_id", client.id, "subscription", client.Subscription,
+		"offset", rec.Offset, "backlog", len(backlog))
+}
+
+// appendDurable добавляет событие в журналы подходящих долговременных
+// подписок, кроме подписки клиента skip. Вызывается под блокировкой
+// сегмента реестра на чтение.
+func (s *EventService) appendDurable(event domain.Event, skip *Client) {
+	s.durableMu.RLock()
+	defer s.durableMu.RUnlock()
+	for name, sub := range s.durables[event.Namespace] {
+		if sub.filter == nil || (skip != nil && skip.Subscription == name) {
+			continue
+		}
+		if sub.filter.wants(event.Type, s.severities) && matchesAny(sub.filter.patterns(), event.Topic) {
+			s.durableStore.Append(event.Namespace, name, event)
+		}
+	}
+}
+
+// DurableSubscriptions возвращает долговременные подписки пространства
+// имён; пусто — всех.
+func (s *EventService) DurableSubscriptions(namespace string) ([]DurableSubscription, error) {
+	s.durableMu.RLock()
+	store := s.durableStore
+	s.durableMu.RUnlock()
+	if store == nil {
+		return nil, ErrDurableDisabled
+	}
+	records, err := store.Records(namespace)
+	if err != nil {
+		return nil, err
+	}
+	subs := make([]DurableSubscription, 0, len(records))
+	s.durableMu.RLock()
+	for _, rec := range records {
+		sub := s.durables[rec.Namespace][rec.Name]
+		subs = append(subs, DurableSubscription{Record: rec, Connected: sub != nil && sub.active})
+	}
+	s.durableMu.RUnlock()
+	return subs, nil
+}
+
+// DeleteDurable удаляет отключённую подписку и её журнал и сообщает, была
+// ли она.
+func (s *EventService) DeleteDurable(namespace, name string) (bool, error) {
+	s.durableMu.Lock()
+	defer s.durableMu.Unlock()
+	if s.durableStore == nil {
+		return false, ErrDurableDisabled
+	}
+	sub := s.durables[namespace][name]
+	if sub != nil && sub.active {
+		return false, ErrSubscriptionInUse
+	}
+	delete(s.durables[namespace], name)
+	return s.durableStore.Delete(namespace, name)
+}
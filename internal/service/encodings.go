@@ -0,0 +1,58 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// SharedNotifier — Notifier, которому рассылка передаёт вместе с событием
+// кэш его кодировок, общий для всех получателей этой версии события, чтобы
+// транспорт сериализовал событие один раз на рассылку, а не на каждого клиента.
+type SharedNotifier interface {
+	NotifyShared(event domain.Event, encodings *Encodings)
+}
+
+// Encodings — кэш закодированных представлений одного события, например
+// подготовленных WebSocket-кадров с конвертом и без.
+type Encodings struct {
+	mu     sync.Mutex
+	values map[any]encoding
+}
+
+type encoding struct {
+	value any
+	err   error
+}
+
+// Load возвращает представление по ключу key, вычисляя его функцией build
+// только при первом обращении; ошибка build тоже запоминается.
+func (e *Encodings) Load(key any, build func() (any, error)) (any, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if enc, ok := e.values[key]; ok {
+		return enc.value, enc.err
+	}
+	if e.values == nil {
+		e.values = make(map[any]encoding)
+	}
+	value, err := build()
+	e.values[key] = encoding{value: value, err: err}
+	return value, err
+}
+
+// notify передаёт событие клиенту. Получатели одной версии события делят
+// кэш его кодировок из encodings.
+func notify(client *Client, event domain.Event, encodings map[int]*Encodings) {
+	shared, ok := client.Notifier.(SharedNotifier)
+	if !ok {
+		client.Notifier.Notify(event)
+		return
+	}
+	enc := encodings[event.Version]
+	if enc == nil {
+		enc = &Encodings{}
+		encodings[event.Version] = enc
+	}
+	shared.NotifyShared(event, enc)
+}
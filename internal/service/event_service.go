@@ -2,12 +2,21 @@ package service
 
 import (
 	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"math/rand"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/wrongjunior/eventsync/internal/crdt"
 	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/durable"
 	"log/slog"
 )
 
@@ -16,55 +25,565 @@ type Notifier interface {
 	Notify(event domain.Event)
 }
 
+// EventValidator проверяет события, поступающие извне.
+type EventValidator interface {
+	Validate(event domain.Event) error
+}
+
+// EventQuarantine принимает события, не прошедшие проверку.
+type EventQuarantine interface {
+	Put(event domain.Event, reason error)
+}
+
+// SchemaVersioner сообщает текущие версии схем и понижает версию события
+// для клиентов, которые понимают только старые схемы.
+type SchemaVersioner interface {
+	LatestVersion(eventType string) int
+	Downgrade(event domain.Event, target int) (domain.Event, error)
+}
+
+// EventScheduler хранит события с отложенной доставкой до их DeliverAt.
+type EventScheduler interface {
+	Schedule(event domain.Event) error
+}
+
+// ErrEventQuarantined возвращается Publish, если событие отправлено в карантин.
+var ErrEventQuarantined = errors.New("event quarantined")
+
+// ErrSchedulingDisabled возвращается Publish для события с будущим
+// DeliverAt, если отложенная доставка не включена.
+var ErrSchedulingDisabled = errors.New("scheduled delivery is not enabled")
+
 // Client представляет абстрактного клиента (обёртка над Notifier).
 type Client struct {
 	Notifier Notifier
+	// Topics — шаблоны топиков, на которые подписан клиент. Пустой список
+	// означает подписку на все события ("#").
+	Topics []string
+	// Namespace — пространство имён, к которому привязано подключение.
+	// Клиент получает события только своего пространства имён.
+	Namespace string
+	// Versions — максимальные версии схем по типам событий, которые понимает
+	// клиент. События более новых версий понижаются перед отправкой.
+	Versions map[string]int
+	// EventTypes — типы событий, которые нужны клиенту; nil — все типы.
+	EventTypes map[string]struct{}
+	// MinSeverity — минимальная важность нужных клиенту событий;
+	// domain.SeverityNone — без порога.
+	MinSeverity domain.Severity
+	// ResumeFrom — номер последнего события, сохранённого клиентом до
+	// переподключения; 0 — клиент начинает с текущего момента.
+	ResumeFrom uint64
+	// RemoteAddr — адрес клиента для административного API.
+	RemoteAddr string
+	// Protocol — согласованная версия протокола, например domain.ProtocolV2.
+	Protocol string
+	// Snapshot запрашивает при регистрации уплотнённый снимок — последнее
+	// событие каждого типа и топика, — после которого идут новые события.
+	Snapshot bool
+	// Aggregate включает сводные события о полученных клиентом событиях;
+	// nil — без сводок.
+	Aggregate *Aggregation
+	// Group — группа потребителей, в которой клиент делит события с
+	// другими участниками; nil — клиент получает все подходящие события.
+	Group *GroupMembership
+	// Subscription — имя долговременной подписки: сервер хранит её смещение
+	// и неподтверждённые события, пока подписчик отключён; пусто — обычная
+	// подписка. Подписку нужно занять через AcquireDurable.
+	Subscription string
+
+	aggregator   *aggregator    // считает события для сводок; nil — без агрегации
+	group        *consumerGroup // состояние группы потребителей; nil — вне группы
+	id           uint64         // номер подключения, присваиваемый при регистрации
+	connectedAt  time.Time
+	status       atomic.Pointer[clientStatus] // последнее состояние, сообщённое клиентом
+	heartbeat    atomic.Pointer[heartbeat]    // последнее измерение по прикладному heartbeat
+	deliveredSeq atomic.Uint64                // наибольший номер, переданный клиенту
+	ackedSeq     atomic.Uint64                // наибольший номер, подтверждённый клиентом
+	acked        atomic.Uint64                // число подтверждённых событий
+}
+
+// wants сообщает, нужен ли клиенту тип события с учётом порога важности.
+func (c *Client) wants(eventType string, severities domain.SeverityMap) bool {
+	if !severities.Allows(eventType, c.MinSeverity) {
+		return false
+	}
+	if c.EventTypes == nil {
+		return true
+	}
+	_, ok := c.EventTypes[eventType]
+	return ok
+}
+
+// Lag возвращает разницу между последним отправленным и последним
+// подтверждённым клиентом номером события.
+func (c *Client) Lag() uint64 {
+	delivered, acked := c.deliveredSeq.Load(), c.ackedSeq.Load()
+	if acked >= delivered {
+		return 0
+	}
+	return delivered - acked
+}
+
+// AckedSeq возвращает наибольший подтверждённый клиентом номер события.
+func (c *Client) AckedSeq() uint64 {
+	return c.ackedSeq.Load()
+}
+
+// namespace возвращает пространство имён клиента с учётом значения по умолчанию.
+func (c *Client) namespace() string {
+	if c.Namespace == "" {
+		return domain.DefaultNamespace
+	}
+	return c.Namespace
+}
+
+// patterns возвращает шаблоны подписки клиента с учётом значения по умолчанию.
+func (c *Client) patterns() []string {
+	if len(c.Topics) == 0 {
+		return []string{domain.TopicWildcardMulti}
+	}
+	return c.Topics
 }
 
 // EventService реализует бизнеслогку сервера: регистрация клиентов, генерация и рассылка событий.
 type EventService struct {
-	mu      sync.RWMutex
-	clients map[*Client]struct{}
-	logger  *slog.Logger
-	ctx     context.Context
-	cancel  context.CancelFunc
+	mu     sync.RWMutex               // защищает shards
+	shards map[string]*namespaceShard // клиенты и индексы подписок по пространствам имён
+	logger *slog.Logger
+
+	validator  EventValidator
+	quarantine EventQuarantine
+	versioner  SchemaVersioner
+	scheduler  EventScheduler     // очередь отложенных событий; nil — выключена
+	severities domain.SeverityMap // важность пользовательских типов для порогов клиентов
+
+	seq      atomic.Uint64 // последний присвоенный номер события
+	draining atomic.Bool   // публикации отклоняются, см. SetDraining
+	// partitions упорядочивают рассылку событий одного ключа партиции:
+	// номер присваивается и событие ставится в очереди клиентов под одной
+	// блокировкой, поэтому порядок в очередях совпадает с порядком номеров.
+	partitions [64]sync.Mutex
+
+	nodeID  string // узел в причинных метаданных; пусто — события не помечаются
+	clockMu sync.Mutex
+	clock   domain.VectorClock // векторные часы узла
+
+	resolver ConflictResolver // разрешение конфликтов публикаций; nil — выключено
+	// resolveLocks упорядочивают разрешение конфликтов одной сущности:
+	// проверка и запись принятой версии идут под блокировкой её ключа,
+	// а рассылка — уже без неё.
+	resolveLocks [64]sync.Mutex
+
+	snapMu   sync.Mutex
+	snapshot map[snapshotKey]domain.Event // последние события для снимка
+	versions map[snapshotKey]domain.Event // версии, принятые разрешением конфликтов
+
+	crdtMu     sync.Mutex
+	crdtStores map[string]*crdt.Store // состояние CRDT по пространствам имён; nil — выключено
+
+	durableMu    sync.RWMutex                      // берётся после блокировки сегмента реестра
+	durableStore *durable.Store                    // долговременные подписки; nil — выключены
+	durables     map[string]map[string]*durableSub // подписки по пространствам имён и именам
+
+	observers  []func(domain.Event) // вызываются для каждого разосланного события
+	transforms []Transform          // конвейер преобразования перед рассылкой
+
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewEventService создаёт новый экземпляр сервиса.
 func NewEventService(logger *slog.Logger) *EventService {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &EventService{
-		clients: make(map[*Client]struct{}),
-		logger:  logger,
-		ctx:     ctx,
-		cancel:  cancel,
+		shards: make(map[string]*namespaceShard),
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
 // Register добавляет клиента для получения уведомлений.
 func (s *EventService) Register(client *Client) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.clients[client] = struct{}{}
-	s.logger.Info("Client registered")
+	sh := s.lockShard(client.namespace(), true)
+	defer sh.unlock(true)
+	client.id = clientIDs.Add(1)
+	client.connectedAt = time.Now()
+	sh.clients[client] = struct{}{}
+	for _, pattern := range client.patterns() {
+		sh.index.add(pattern, client)
+	}
+	if client.Group != nil {
+		s.joinGroup(sh, client)
+	}
+	if client.Aggregate != nil {
+		client.aggregator = newAggregator(client, client.Aggregate.Interval, domain.NewSource(domain.SourceAggregate, s.nodeID))
+	}
+	s.logger.Info("Client registered", "namespace", client.namespace(), "topics", client.patterns(), "protocol", client.Protocol,
+		"resume_from", client.ResumeFrom, "current_seq", s.seq.Load())
+	if client.Snapshot {
+		s.sendSnapshot(client)
+	}
+	if client.Subscription != "" && s.durableStore != nil {
+		s.attachDurable(client)
+	}
 }
 
 // Unregister удаляет клиента.
 func (s *EventService) Unregister(client *Client) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	delete(s.clients, client)
+	sh := s.lockShard(client.namespace(), true)
+	delete(sh.clients, client)
+	for _, pattern := range client.patterns() {
+		sh.index.remove(pattern, client)
+	}
+	if client.Group != nil {
+		s.leaveGroup(sh, client)
+	}
+	empty := len(sh.clients) == 0
+	sh.unlock(true)
+	if client.aggregator != nil {
+		client.aggregator.stop()
+	}
+	if empty {
+		s.pruneShard(client.namespace(), sh)
+	}
 	s.logger.Info("Client unregistered")
 }
 
-// Broadcast рассылает событие всем зарегистрированным клиентам.
+// Subscribe заменяет шаблоны подписки зарегистрированного клиента.
+// Шаблоны должны быть проверены вызывающим.
+func (s *EventService) Subscribe(client *Client, topics []string) {
+	sh := s.lockShard(client.namespace(), true)
+	defer sh.unlock(true)
+	for _, pattern := range client.patterns() {
+		sh.index.remove(pattern, client)
+	}
+	client.Topics = topics
+	for _, pattern := range client.patterns() {
+		sh.index.add(pattern, client)
+	}
+	s.logger.Info("Client subscription changed", "client_id", client.id, "topics", client.patterns())
+}
+
+// Resume принимает номер последнего сохранённого клиентом события, как
+// параметр since при подключении.
+func (s *EventService) Resume(client *Client, since uint64) {
+	sh := s.lockShard(client.namespace(), true)
+	client.ResumeFrom = since
+	sh.unlock(true)
+	s.logger.Info("Client resume requested", "client_id", client.id, "resume_from", since, "current_seq", s.seq.Load())
+}
+
+// Broadcast рассылает событие клиентам его пространства имён, подписанным на его топик.
+// Событию без номера присваивается следующий Seq. Событие проходит конвейер
+// UseTransform.
 func (s *EventService) Broadcast(event domain.Event) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	for client := range s.clients {
-		client.Notifier.Notify(event)
+	if event, ok := s.transform(event); ok {
+		s.broadcast(event, nil)
+	}
+}
+
+// Replay повторно рассылает событие из истории подписчикам его пространства
+// имён с исходным номером. Конвейер UseTransform и функции OnBroadcast не
+// вызываются: событие уже прошло их при первой рассылке.
+func (s *EventService) Replay(event domain.Event) {
+	s.fanout(event, nil)
+	s.logger.Debug("Event replayed", "id", event.ID, "seq", event.Seq)
+}
+
+// broadcast рассылает событие всем подходящим клиентам, кроме skip, и
+// возвращает его с присвоенным номером.
+func (s *EventService) broadcast(event domain.Event, skip *Client) domain.Event {
+	event = s.fanout(event, skip)
+	for _, fn := range s.observers {
+		fn(event)
 	}
 	s.logger.Info("Event broadcast", "event", event)
+	return event
+}
+
+// fanout присваивает событию номер, если его нет, и доставляет его
+// подходящим клиентам, кроме skip.
+func (s *EventService) fanout(event domain.Event, skip *Client) domain.Event {
+	event.Namespace = event.NamespaceOrDefault()
+	if event.PartitionKey != "" {
+		mu := &s.partitions[partitionIndex(event.Namespace, event.PartitionKey, len(s.partitions))]
+		mu.Lock()
+		defer mu.Unlock()
+	}
+	if event.Seq == 0 {
+		event.Seq = s.seq.Add(1)
+	}
+	s.stampCausality(&event)
+	downgraded := make(map[int]*domain.Event)
+	encodings := make(map[int]*Encodings)
+	var groups map[*consumerGroup][]*Client
+	for client := range s.recipients(event, skip) {
+		if client == skip || !client.wants(event.Type, s.severities) {
+			continue
+		}
+		if client.group != nil {
+			if groups == nil {
+				groups = make(map[*consumerGroup][]*Client)
+			}
+			groups[client.group] = append(groups[client.group], client)
+			continue
+		}
+		s.deliver(client, event, downgraded, encodings)
+	}
+	// Каждой группе событие доставляется одному участнику; если понизить
+	// событие для него нельзя, оно достаётся следующему.
+	for _, members := range groups {
+		for _, client := range groupOrder(event, members) {
+			if s.deliver(client, event, downgraded, encodings) {
+				break
+			}
+		}
+	}
+	return event
+}
+
+// partitionIndex распределяет ключи партиций пространства имён по n сегментам.
+func partitionIndex(namespace, key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(namespace))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// deliver отправляет событие клиенту в понятной ему версии и сообщает,
+// получил ли он его.
+func (s *EventService) deliver(client *Client, event domain.Event, downgraded map[int]*domain.Event, encodings map[int]*Encodings) bool {
+	out, ok := s.eventFor(client, event, downgraded)
+	if !ok {
+		return false
+	}
+	if client.aggregator != nil {
+		client.aggregator.add(out.Type)
+		if client.Aggregate.Only {
+			return true
+		}
+	}
+	notify(client, out, encodings)
+	storeMax(&client.deliveredSeq, out.Seq)
+	return true
+}
+
+// OnBroadcast регистрирует функцию, вызываемую для каждого разосланного
+// события, например для отправки оповещений. Функция не должна блокироваться.
+// Вызывается до запуска сервера.
+func (s *EventService) OnBroadcast(fn func(domain.Event)) {
+	s.observers = append(s.observers, fn)
+}
+
+// SetNodeID включает причинные метаданные: события без них, рассылаемые
+// сервером, помечаются векторными часами узла node, а часы событий,
+// опубликованных клиентами, учитываются в часах узла. Имя узла должно быть
+// уникально среди экземпляров сервера.
+func (s *EventService) SetNodeID(node string) {
+	s.clockMu.Lock()
+	defer s.clockMu.Unlock()
+	s.nodeID = node
+	s.clock = domain.VectorClock{}
+}
+
+// stampCausality помечает событие часами узла либо учитывает его часы.
+func (s *EventService) stampCausality(event *domain.Event) {
+	s.clockMu.Lock()
+	defer s.clockMu.Unlock()
+	if s.nodeID == "" {
+		return
+	}
+	if event.Causality != nil {
+		s.clock.Merge(event.Causality.Clock)
+		return
+	}
+	s.clock[s.nodeID]++
+	event.Causality = &domain.Causality{Node: s.nodeID, Clock: s.clock.Clone()}
+}
+
+// SetValidation включает проверку публикуемых событий. Если quarantine не nil,
+// некорректные события сохраняются в нём вместо простого отклонения.
+func (s *EventService) SetValidation(v EventValidator, quarantine EventQuarantine) {
+	s.validator = v
+	s.quarantine = quarantine
+}
+
+// SetSeverityMap задаёт важность пользовательских типов событий, по
+// которой применяется порог Client.MinSeverity. Вызывается до запуска сервера.
+func (s *EventService) SetSeverityMap(m domain.SeverityMap) {
+	s.severities = m
+}
+
+// SetVersioning включает версионирование схем: публикуемые события без версии
+// получают последнюю версию типа, а клиенты со старыми версиями получают
+// понижённые события.
+func (s *EventService) SetVersioning(v SchemaVersioner) {
+	s.versioner = v
+}
+
+// ResumeSeq продолжает нумерацию событий после seq, если она ещё не дошла
+// до него, чтобы номера не повторялись после перезапуска сервера с
+// сохранёнными событиями. Вызывается до запуска сервера.
+func (s *EventService) ResumeSeq(seq uint64) {
+	storeMax(&s.seq, seq)
+}
+
+// SetScheduler включает отложенную доставку: события с будущим DeliverAt
+// передаются scheduler, который рассылает их через DeliverScheduled.
+// Вызывается до запуска сервера.
+func (s *EventService) SetScheduler(scheduler EventScheduler) {
+	s.scheduler = scheduler
+}
+
+// Publish проверяет событие, опубликованное извне, дополняет недостающие
+// поля (ID, время) и рассылает его подписчикам.
+func (s *EventService) Publish(event domain.Event) (domain.Event, error) {
+	return s.PublishFrom(nil, event)
+}
+
+// PublishFrom публикует событие, полученное от подключённого клиента origin:
+// событие проверяется как в Publish и рассылается остальным подписчикам,
+// но не возвращается отправителю. Конвейер преобразования применяется до
+// проверки схемы, поэтому проверяется и рассылается одно и то же событие.
+// Отброшенное конвейером событие возвращается без номера и без ошибки.
+// Событие с будущим DeliverAt проверяется сразу, а рассылается в
+// назначенное время, в том числе отправителю.
+func (s *EventService) PublishFrom(origin *Client, event domain.Event) (domain.Event, error) {
+	if s.draining.Load() {
+		return domain.Event{}, ErrDraining
+	}
+	if event.Topic != "" {
+		if err := domain.ValidateTopic(event.Topic); err != nil {
+			return domain.Event{}, err
+		}
+	}
+	event.Namespace = event.NamespaceOrDefault()
+	if err := domain.ValidateNamespace(event.Namespace); err != nil {
+		return domain.Event{}, err
+	}
+	if err := domain.ValidateMetadata(event.Metadata); err != nil {
+		return domain.Event{}, err
+	}
+	if len(event.PartitionKey) > domain.MaxPartitionKeyBytes {
+		return domain.Event{}, domain.ErrInvalidPartitionKey
+	}
+	if event.ID == "" {
+		event.ID = newEventID()
+	}
+	if event.Source == "" && origin != nil {
+		event.Source = origin.sourceName()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	event, ok := s.transform(event)
+	if !ok {
+		return event, nil
+	}
+	if s.versioner != nil && event.Version == 0 {
+		event.Version = s.versioner.LatestVersion(event.Type)
+	}
+	if s.validator != nil {
+		if err := s.validator.Validate(event); err != nil {
+			if s.quarantine != nil {
+				s.quarantine.Put(event, err)
+				s.logger.Warn("Event quarantined", "id", event.ID, "type", event.Type, "error", err)
+				return event, fmt.Errorf("%w: %w", ErrEventQuarantined, err)
+			}
+			return domain.Event{}, err
+		}
+	}
+	if event.DeliverAt != nil && event.DeliverAt.After(time.Now()) {
+		if s.scheduler == nil {
+			return domain.Event{}, ErrSchedulingDisabled
+		}
+		if err := s.scheduler.Schedule(event); err != nil {
+			return domain.Event{}, err
+		}
+		s.logger.Info("Event scheduled", "id", event.ID, "namespace", event.Namespace, "deliver_at", *event.DeliverAt)
+		return event, nil
+	}
+	return s.dispatch(origin, event)
+}
+
+// DeliverScheduled рассылает отложенное событие, время которого наступило.
+func (s *EventService) DeliverScheduled(event domain.Event) error {
+	_, err := s.dispatch(nil, event)
+	return err
+}
+
+// dispatch разрешает конфликт проверенного и преобразованного события,
+// применяет операцию CRDT и рассылает событие всем подписчикам, кроме origin.
+func (s *EventService) dispatch(origin *Client, event domain.Event) (domain.Event, error) {
+	if s.resolver != nil && event.Type != crdt.EventType { // операции CRDT сливаются сами
+		resolved, err := s.resolveConflict(event)
+		if err != nil {
+			return domain.Event{}, err
+		}
+		if resolved.ID != event.ID {
+			// Результат слияния нужен и отправителю.
+			origin = nil
+		}
+		event = resolved
+	}
+	if err := s.applyCRDT(event); err != nil {
+		return domain.Event{}, err
+	}
+	return s.broadcast(event, origin), nil
+}
+
+// newEventID генерирует случайный идентификатор для опубликованных событий.
+func newEventID() string {
+	b := make([]byte, 16)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Acknowledge учитывает подтверждение сохранения событий клиентом.
+func (s *EventService) Acknowledge(client *Client, ack domain.Ack) {
+	storeMax(&client.ackedSeq, ack.Seq)
+	client.acked.Add(uint64(len(ack.IDs)))
+	if client.Subscription != "" && s.durableStore != nil {
+		s.durableStore.Ack(client.namespace(), client.Subscription, ack)
+	}
+	s.logger.Debug("Ack received", "seq", ack.Seq, "events", len(ack.IDs), "lag", client.Lag())
+}
+
+// storeMax атомарно увеличивает v до n, если n больше.
+func storeMax(v *atomic.Uint64, n uint64) {
+	for {
+		cur := v.Load()
+		if n <= cur || v.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
+
+// eventFor возвращает событие в версии, понятной клиенту. Результаты
+// понижения кэшируются в рамках одной рассылки; nil в кэше означает, что
+// понизить событие нельзя и клиенту оно не отправляется.
+func (s *EventService) eventFor(client *Client, event domain.Event, cache map[int]*domain.Event) (domain.Event, bool) {
+	target, ok := client.Versions[event.Type]
+	if !ok || s.versioner == nil || event.Version <= target {
+		return event, true
+	}
+	if cached, ok := cache[target]; ok {
+		if cached == nil {
+			return domain.Event{}, false
+		}
+		return *cached, true
+	}
+	out, err := s.versioner.Downgrade(event, target)
+	if err != nil {
+		s.logger.Warn("Event skipped for client with older schema", "id", event.ID, "target_version", target, "error", err)
+		cache[target] = nil
+		return domain.Event{}, false
+	}
+	cache[target] = &out
+	return out, true
 }
 
 // StartEventGenerator запускает генерацию событий каждые 5 секунд.
@@ -77,11 +596,20 @@ func (s *EventService) StartEventGenerator() {
 		for {
 			select {
 			case <-ticker.C:
+				if s.draining.Load() {
+					continue
+				}
 				evtType := eventTypes[rand.Intn(len(eventTypes))]
 				event := domain.Event{
-					ID:        strconv.Itoa(counter),
+					// Счётчик начинается заново в каждом процессе, поэтому ID
+					// случайный: иначе после перезапуска клиенты отбросили бы
+					// новые события как дубликаты.
+					ID:        newEventID(),
 					Type:      evtType,
+					Topic:     "system." + evtType,
 					Message:   "Событие номер " + strconv.Itoa(counter),
+					Data:      json.RawMessage(`{"counter":` + strconv.Itoa(counter) + `}`),
+					Source:    domain.NewSource(domain.SourceGenerator, s.nodeID),
 					Timestamp: time.Now(),
 				}
 				s.logger.Info("Event generated", "event", event)
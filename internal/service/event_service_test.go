@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// countingNotifier считает события, не удерживая их, чтобы замер показывал
+// стоимость самой рассылки.
+type countingNotifier struct {
+	n atomic.Uint64
+}
+
+func (c *countingNotifier) Notify(domain.Event) {
+	c.n.Add(1)
+}
+
+// BenchmarkBroadcast замеряет рассылку события подписчикам на все топики
+// при разном числе зарегистрированных клиентов.
+func BenchmarkBroadcast(b *testing.B) {
+	for _, clients := range []int{10, 1000, 10000} {
+		b.Run(fmt.Sprintf("clients=%d", clients), func(b *testing.B) {
+			es := NewEventService(slog.New(slog.NewTextHandler(io.Discard, nil)))
+			defer es.Shutdown()
+			for i := 0; i < clients; i++ {
+				es.Register(&Client{Notifier: &countingNotifier{}})
+			}
+			event := domain.Event{ID: "bench", Type: "info", Topic: "bench.broadcast", Message: "x"}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				event.Seq = 0
+				es.Broadcast(event)
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(clients), "ns/client")
+		})
+	}
+}
+
+// validatorFunc позволяет передать функцию как EventValidator.
+type validatorFunc func(domain.Event) error
+
+func (f validatorFunc) Validate(event domain.Event) error { return f(event) }
+
+// TestPublishValidatesTransformedEvent проверяет, что схема проверяет
+// событие после конвейера преобразования: поле, удалённое конвейером, не
+// мешает публикации, а поле, добавленное им, проверяется.
+func TestPublishValidatesTransformedEvent(t *testing.T) {
+	es := NewEventService(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer es.Shutdown()
+	errSecret := errors.New("data must not contain a secret")
+	es.SetValidation(validatorFunc(func(event domain.Event) error {
+		if bytes.Contains(event.Data, []byte("secret")) {
+			return errSecret
+		}
+		return nil
+	}), nil)
+	es.UseTransform(func(event domain.Event) (domain.Event, bool) {
+		switch event.Type {
+		case "redacted":
+			event.Data = []byte(`{"password":"***"}`)
+		case "enriched":
+			event.Data = []byte(`{"token":"secret"}`)
+		}
+		return event, true
+	})
+
+	event, err := es.Publish(domain.Event{Type: "redacted", Data: []byte(`{"password":"secret"}`)})
+	if err != nil {
+		t.Fatalf("transformed event rejected: %v", err)
+	}
+	if event.Seq == 0 || string(event.Data) != `{"password":"***"}` {
+		t.Fatalf("Publish = %+v", event)
+	}
+	if _, err := es.Publish(domain.Event{Type: "enriched", Data: []byte(`{}`)}); !errors.Is(err, errSecret) {
+		t.Fatalf("Publish of invalid transformed event = %v, want %v", err, errSecret)
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"sync/atomic"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Стратегии выбора участника группы потребителей.
+const (
+	// GroupRoundRobin — участники получают события по очереди.
+	GroupRoundRobin = "round_robin"
+	// GroupKeyHash — событие получает участник, выбранный по хэшу ключа
+	// партиции, поэтому события одного ключа идут одному участнику, пока
+	// состав группы не меняется. События без ключа распределяются по очереди.
+	GroupKeyHash = "key_hash"
+)
+
+// ParseGroupStrategy проверяет стратегию группы; пусто — GroupRoundRobin.
+func ParseGroupStrategy(s string) (string, error) {
+	switch s {
+	case "", GroupRoundRobin:
+		return GroupRoundRobin, nil
+	case GroupKeyHash:
+		return GroupKeyHash, nil
+	default:
+		return "", fmt.Errorf("unknown group strategy %q", s)
+	}
+}
+
+// GroupMembership — участие подключения в именованной группе потребителей:
+// каждое событие, подходящее под подписки участников, получает только один
+// из них. Группы существуют в пределах пространства имён.
+type GroupMembership struct {
+	Name     string
+	Strategy string // GroupRoundRobin или GroupKeyHash; пусто — стратегия группы
+}
+
+// consumerGroup — общее состояние участников группы в сегменте реестра.
+type consumerGroup struct {
+	strategy atomic.Value  // string; пусто — GroupRoundRobin
+	members  int           // под блокировкой сегмента
+	next     atomic.Uint64 // счётчик очереди для GroupRoundRobin
+}
+
+// joinGroup добавляет клиента в его группу; вызывается под блокировкой
+// сегмента на запись. Стратегию группы задаёт первый участник, указавший её
+// явно; участники без стратегии принимают стратегию группы.
+func (s *EventService) joinGroup(sh *namespaceShard, client *Client) {
+	if sh.groups == nil {
+		sh.groups = make(map[string]*consumerGroup)
+	}
+	g := sh.groups[client.Group.Name]
+	if g == nil {
+		g = &consumerGroup{}
+		g.strategy.Store("")
+		sh.groups[client.Group.Name] = g
+	}
+	if requested := client.Group.Strategy; requested != "" {
+		if current := g.strategy.Load().(string); current == "" {
+			g.strategy.Store(requested)
+		} else if current != requested {
+			s.logger.Warn("Consumer group strategy mismatch, using the group's strategy",
+				"group", client.Group.Name, "requested", requested, "strategy", current)
+		}
+	}
+	g.members++
+	client.group = g
+}
+
+// leaveGroup исключает клиента из группы; вызывается под блокировкой
+// сегмента на запись.
+func (s *EventService) leaveGroup(sh *namespaceShard, client *Client) {
+	g := sh.groups[client.Group.Name]
+	if g == nil || g != client.group {
+		return
+	}
+	if g.members--; g.members == 0 {
+		delete(sh.groups, client.Group.Name)
+	}
+}
+
+// groupOrder возвращает участников группы, подходящих для события, в
+// порядке попытки доставки: выбранный стратегией, затем следующие за ним.
+func groupOrder(event domain.Event, members []*Client) []*Client {
+	slices.SortFunc(members, func(a, b *Client) int {
+		switch {
+		case a.id < b.id:
+			return -1
+		case a.id > b.id:
+			return 1
+		}
+		return 0
+	})
+	g := members[0].group
+	var start int
+	if g.strategy.Load() == GroupKeyHash && event.PartitionKey != "" {
+		start = partitionIndex(event.Namespace, event.PartitionKey, len(members))
+	} else {
+		start = int(g.next.Add(1) % uint64(len(members)))
+	}
+	return append(members[start:len(members):len(members)], members[:start]...)
+}
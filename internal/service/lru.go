@@ -0,0 +1,77 @@
+package service
+
+import (
+	"container/list"
+	"time"
+)
+
+// DefaultDedupCacheSize — вместимость кэша дедупликации по умолчанию.
+const DefaultDedupCacheSize = 10000
+
+// lruSet — множество строк ограниченного размера с вытеснением давно не
+// встречавшихся элементов. При ненулевом window ключ, добавленный раньше
+// window назад, считается отсутствующим. Не потокобезопасно.
+type lruSet struct {
+	capacity int
+	window   time.Duration
+	order    *list.List // от недавних к давним
+	items    map[string]*list.Element
+}
+
+// lruEntry — ключ и время его последнего добавления.
+type lruEntry struct {
+	key  string
+	seen time.Time
+}
+
+func newLRUSet(capacity int, window time.Duration) *lruSet {
+	if capacity <= 0 {
+		capacity = DefaultDedupCacheSize
+	}
+	return &lruSet{capacity: capacity, window: window, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// contains проверяет наличие ключа и отмечает его как недавно использованный.
+// Ключ с истёкшим окном удаляется.
+func (s *lruSet) contains(key string) bool {
+	el, ok := s.items[key]
+	if !ok {
+		return false
+	}
+	if s.window > 0 && time.Since(el.Value.(*lruEntry).seen) > s.window {
+		s.order.Remove(el)
+		delete(s.items, key)
+		return false
+	}
+	s.order.MoveToFront(el)
+	return true
+}
+
+// add добавляет ключ, вытесняя самый давний при переполнении. Для уже
+// имеющегося ключа окно отсчитывается заново.
+func (s *lruSet) add(key string) {
+	if el, ok := s.items[key]; ok {
+		el.Value.(*lruEntry).seen = time.Now()
+		s.order.MoveToFront(el)
+		return
+	}
+	s.items[key] = s.order.PushFront(&lruEntry{key: key, seen: time.Now()})
+	if s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// remove удаляет ключ.
+func (s *lruSet) remove(key string) {
+	if el, ok := s.items[key]; ok {
+		s.order.Remove(el)
+		delete(s.items, key)
+	}
+}
+
+// len возвращает число ключей.
+func (s *lruSet) len() int {
+	return s.order.Len()
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// namespaceShard — часть реестра клиентов одного пространства имён со своей
+// блокировкой: подключения и рассылки разных тенантов не ждут друг друга.
+// Рассылка держит блокировку на чтение только пока запоминает событие для
+// снимка и выбирает получателей, а уведомляет их уже без неё, поэтому
+// Register и Unregister не ждут отправки события тысячам клиентов.
+type namespaceShard struct {
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	index   *topicIndex
+	groups  map[string]*consumerGroup // группы потребителей по именам
+	removed bool                      // сегмент исключён из реестра; см. EventService.lockShard
+}
+
+func newNamespaceShard() *namespaceShard {
+	return &namespaceShard{clients: make(map[*Client]struct{}), index: newTopicIndex()}
+}
+
+func (sh *namespaceShard) lock(write bool) {
+	if write {
+		sh.mu.Lock()
+	} else {
+		sh.mu.RLock()
+	}
+}
+
+func (sh *namespaceShard) unlock(write bool) {
+	if write {
+		sh.mu.Unlock()
+	} else {
+		sh.mu.RUnlock()
+	}
+}
+
+// lockShard возвращает заблокированный сегмент пространства имён, создавая
+// его при необходимости. Сегмент, удалённый из реестра, пока вызывающий
+// ждал блокировку, пропускается, чтобы клиент не попал в потерянный сегмент.
+func (s *EventService) lockShard(namespace string, write bool) *namespaceShard {
+	for {
+		s.mu.RLock()
+		sh := s.shards[namespace]
+		s.mu.RUnlock()
+		if sh == nil {
+			s.mu.Lock()
+			if sh = s.shards[namespace]; sh == nil {
+				sh = newNamespaceShard()
+				s.shards[namespace] = sh
+			}
+			s.mu.Unlock()
+		}
+		sh.lock(write)
+		if !sh.removed {
+			return sh
+		}
+		sh.unlock(write)
+	}
+}
+
+// pruneShard удаляет опустевший сегмент из реестра.
+func (s *EventService) pruneShard(namespace string, sh *namespaceShard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.removed || len(sh.clients) > 0 || !sh.index.root.empty() {
+		return
+	}
+	sh.removed = true
+	delete(s.shards, namespace)
+}
+
+// recipients запоминает событие для снимка и журналов долговременных
+// подписок (кроме подписки skip) и выбирает клиентов, подписанных на его
+// топик, атомарно относительно регистрации в пространстве имён: клиент
+// получает событие либо в снимке или журнале, либо в рассылке. Возвращаемое
+// множество принадлежит вызывающему и обходится без блокировки.
+func (s *EventService) recipients(event domain.Event, skip *Client) map[*Client]struct{} {
+	sh := s.lockShard(event.Namespace, false)
+	s.remember(event)
+	if s.durableStore != nil {
+		s.appendDurable(event, skip)
+	}
+	matched := sh.index.match(event.Topic)
+	empty := len(sh.clients) == 0
+	sh.unlock(false)
+	if empty {
+		s.pruneShard(event.Namespace, sh)
+	}
+	return matched
+}
+
+// shardsSnapshot возвращает сегменты реестра: одного пространства имён или
+// всех, если namespace пусто.
+func (s *EventService) shardsSnapshot(namespace string) []*namespaceShard {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if namespace != "" {
+		if sh, ok := s.shards[namespace]; ok {
+			return []*namespaceShard{sh}
+		}
+		return nil
+	}
+	shards := make([]*namespaceShard, 0, len(s.shards))
+	for _, sh := range s.shards {
+		shards = append(shards, sh)
+	}
+	return shards
+}
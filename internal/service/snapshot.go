@@ -0,0 +1,116 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// snapshotKey — ключ уплотнения: в снимке хранится последнее событие
+// каждого типа в каждом топике пространства имён.
+type snapshotKey struct {
+	namespace, eventType, topic string
+}
+
+// remember запоминает событие как последнее для его ключа уплотнения.
+func (s *EventService) remember(event domain.Event) {
+	key := snapshotKey{namespace: event.Namespace, eventType: event.Type, topic: event.Topic}
+	s.snapMu.Lock()
+	defer s.snapMu.Unlock()
+	if s.snapshot == nil {
+		s.snapshot = make(map[snapshotKey]domain.Event)
+	}
+	if cur, ok := s.snapshot[key]; !ok || cur.Seq < event.Seq {
+		s.snapshot[key] = event
+	}
+}
+
+// Snapshot возвращает уплотнённое состояние пространства имён — последние
+// события каждого типа и топика — в порядке номеров.
+func (s *EventService) Snapshot(namespace string) []domain.Event {
+	s.snapMu.Lock()
+	var events []domain.Event
+	for key, event := range s.snapshot {
+		if key.namespace == namespace {
+			events = append(events, event)
+		}
+	}
+	s.snapMu.Unlock()
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	return events
+}
+
+// State — состояние сервера для сохранения на диск: последний присвоенный
+// номер события и уплотнённое состояние всех пространств имён.
+type State struct {
+	Seq     uint64         `json:"seq"`
+	TakenAt time.Time      `json:"taken_at"`
+	Events  []domain.Event `json:"events"` // последние события каждого типа и топика
+}
+
+// State возвращает текущее состояние сервера. События, которым номер уже
+// присвоен, но которые ещё не разосланы, в состояние могут не попасть.
+func (s *EventService) State() State {
+	state := State{TakenAt: time.Now()}
+	s.snapMu.Lock()
+	state.Seq = s.seq.Load()
+	state.Events = make([]domain.Event, 0, len(s.snapshot))
+	for _, event := range s.snapshot {
+		state.Events = append(state.Events, event)
+	}
+	s.snapMu.Unlock()
+	sort.Slice(state.Events, func(i, j int) bool { return state.Events[i].Seq < state.Events[j].Seq })
+	return state
+}
+
+// RestoreState восстанавливает уплотнённое состояние из снимка и
+// продолжает нумерацию после его номера. Вызывается до запуска сервера.
+func (s *EventService) RestoreState(state State) {
+	s.ResumeSeq(state.Seq)
+	s.RestoreEvents(state.Events)
+}
+
+// RestoreEvents учитывает в уплотнённом состоянии события журнала,
+// сохранённые после снимка, не рассылая их. Вызывается до запуска сервера.
+func (s *EventService) RestoreEvents(events []domain.Event) {
+	for _, event := range events {
+		event.Namespace = event.NamespaceOrDefault()
+		s.remember(event)
+		s.ResumeSeq(event.Seq)
+	}
+}
+
+// sendSnapshot отправляет клиенту события снимка, подходящие под его
+// подписку. Вызывается из Register под блокировкой сегмента реестра:
+// рассылки пространства имён ждут её, поэтому дельты следуют за снимком без
+// пропусков.
+func (s *EventService) sendSnapshot(client *Client) {
+	patterns := client.patterns()
+	downgraded := make(map[int]*domain.Event)
+	sent := 0
+	for _, event := range s.Snapshot(client.namespace()) {
+		if !client.wants(event.Type, s.severities) || !matchesAny(patterns, event.Topic) {
+			continue
+		}
+		clear(downgraded)
+		out, ok := s.eventFor(client, event, downgraded)
+		if !ok {
+			continue
+		}
+		client.Notifier.Notify(out)
+		storeMax(&client.deliveredSeq, out.Seq)
+		sent++
+	}
+	s.logger.Info("Snapshot sent", "client_id", client.id, "events", sent)
+}
+
+// matchesAny сообщает, совпадает ли топик хотя бы с одним шаблоном.
+func matchesAny(patterns []string, topic string) bool {
+	for _, pattern := range patterns {
+		if domain.MatchTopic(pattern, topic) {
+			return true
+		}
+	}
+	return false
+}
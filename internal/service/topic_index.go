@@ -0,0 +1,107 @@
+package service
+
+import "github.com/wrongjunior/eventsync/internal/domain"
+
+// topicNode — узел префиксного дерева подписок. Дочерние узлы индексируются
+// сегментом топика; сегмент "*" хранится как обычный ключ, а подписчики на "#"
+// хранятся отдельно, так как совпадают с любым хвостом.
+type topicNode struct {
+	children map[string]*topicNode
+	exact    map[*Client]struct{}
+	multi    map[*Client]struct{}
+}
+
+func newTopicNode() *topicNode {
+	return &topicNode{
+		children: make(map[string]*topicNode),
+		exact:    make(map[*Client]struct{}),
+		multi:    make(map[*Client]struct{}),
+	}
+}
+
+func (n *topicNode) empty() bool {
+	return len(n.children) == 0 && len(n.exact) == 0 && len(n.multi) == 0
+}
+
+// topicIndex индексирует подписки по сегментам топика, чтобы рассылка
+// обходила только совпадающие ветви, а не проверяла каждого клиента.
+// Не потокобезопасен: защищается блокировкой сегмента реестра namespaceShard.
+type topicIndex struct {
+	root *topicNode
+}
+
+func newTopicIndex() *topicIndex {
+	return &topicIndex{root: newTopicNode()}
+}
+
+// add регистрирует подписку клиента по шаблону.
+func (idx *topicIndex) add(pattern string, client *Client) {
+	node := idx.root
+	for _, seg := range domain.SplitTopic(pattern) {
+		if seg == domain.TopicWildcardMulti {
+			node.multi[client] = struct{}{}
+			return
+		}
+		child, ok := node.children[seg]
+		if !ok {
+			child = newTopicNode()
+			node.children[seg] = child
+		}
+		node = child
+	}
+	node.exact[client] = struct{}{}
+}
+
+// remove удаляет подписку клиента и подчищает опустевшие ветви.
+func (idx *topicIndex) remove(pattern string, client *Client) {
+	idx.removeFrom(idx.root, domain.SplitTopic(pattern), client)
+}
+
+func (idx *topicIndex) removeFrom(node *topicNode, segs []string, client *Client) {
+	if len(segs) == 0 {
+		delete(node.exact, client)
+		return
+	}
+	if segs[0] == domain.TopicWildcardMulti {
+		delete(node.multi, client)
+		return
+	}
+	child, ok := node.children[segs[0]]
+	if !ok {
+		return
+	}
+	idx.removeFrom(child, segs[1:], client)
+	if child.empty() {
+		delete(node.children, segs[0])
+	}
+}
+
+// match возвращает множество клиентов, подписанных на топик.
+// Событие без топика получают только подписчики на "#".
+func (idx *topicIndex) match(topic string) map[*Client]struct{} {
+	result := make(map[*Client]struct{})
+	var segs []string
+	if topic != "" {
+		segs = domain.SplitTopic(topic)
+	}
+	idx.collect(idx.root, segs, result)
+	return result
+}
+
+func (idx *topicIndex) collect(node *topicNode, segs []string, result map[*Client]struct{}) {
+	for c := range node.multi {
+		result[c] = struct{}{}
+	}
+	if len(segs) == 0 {
+		for c := range node.exact {
+			result[c] = struct{}{}
+		}
+		return
+	}
+	if child, ok := node.children[segs[0]]; ok {
+		idx.collect(child, segs[1:], result)
+	}
+	if child, ok := node.children[domain.TopicWildcardOne]; ok {
+		idx.collect(child, segs[1:], result)
+	}
+}
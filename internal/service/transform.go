@@ -0,0 +1,30 @@
+package service
+
+import "github.com/wrongjunior/eventsync/internal/domain"
+
+// Transform — звено конвейера преобразования событий на сервере перед
+// рассылкой: обогащение (имя узла, тенант), маскирование полей, смена
+// топика. Возвращает изменённое событие либо false, если событие не нужно
+// рассылать. Событие передаётся по значению, но Data и Metadata звено
+// должно копировать, а не изменять на месте: их разделяют копии события.
+type Transform func(event domain.Event) (domain.Event, bool)
+
+// UseTransform добавляет звенья в конец конвейера: первое добавленное
+// вызывается первым. Конвейер применяется к опубликованным событиям до
+// проверки схемы (к отложенным — в момент публикации) и к событиям генератора.
+// Вызывается до запуска сервера.
+func (s *EventService) UseTransform(t ...Transform) {
+	s.transforms = append(s.transforms, t...)
+}
+
+// transform проводит событие через конвейер; false — событие отброшено.
+func (s *EventService) transform(event domain.Event) (domain.Event, bool) {
+	for _, t := range s.transforms {
+		var ok bool
+		if event, ok = t(event); !ok {
+			s.logger.Info("Event dropped by transform", "id", event.ID, "type", event.Type, "topic", event.Topic)
+			return event, false
+		}
+	}
+	return event, true
+}
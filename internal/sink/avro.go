@@ -0,0 +1,51 @@
+package sink
+
+import (
+	"context"
+	"os"
+
+	"github.com/wrongjunior/eventsync/internal/avro"
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// AvroFileSink дописывает события в контейнерный файл Avro: каждая пачка —
+// отдельный блок, схема — в заголовке файла.
+type AvroFileSink struct {
+	f *os.File
+	c *avro.ContainerWriter
+}
+
+// OpenAvroFile открывает контейнерный файл path для дописывания; новый
+// или пустой файл начинается заголовком.
+func OpenAvroFile(path string) (*AvroFileSink, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var c *avro.ContainerWriter
+	if info.Size() == 0 {
+		c, err = avro.NewContainer(f)
+	} else {
+		c, err = avro.ResumeContainer(f, f)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &AvroFileSink{f: f, c: c}, nil
+}
+
+// Write дописывает пачку одним блоком.
+func (s *AvroFileSink) Write(_ context.Context, events []domain.Event) error {
+	return s.c.WriteBlock(events)
+}
+
+// Close закрывает файл.
+func (s *AvroFileSink) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,68 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+func init() {
+	Register("file", func(options json.RawMessage) (Sink, error) {
+		var opts struct {
+			Path   string `json:"path"`   // файл событий
+			Format string `json:"format"` // "ndjson" (по умолчанию) или "avro"
+		}
+		if err := decodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		if opts.Path == "" {
+			return nil, errors.New("path is required")
+		}
+		switch opts.Format {
+		case "", "ndjson":
+			return OpenFile(opts.Path)
+		case "avro":
+			return OpenAvroFile(opts.Path)
+		default:
+			return nil, fmt.Errorf(`unknown format %q: want "ndjson" or "avro"`, opts.Format)
+		}
+	})
+}
+
+// FileSink дописывает события в файл строками NDJSON.
+type FileSink struct {
+	f *os.File
+}
+
+// OpenFile открывает (или создаёт) файл path для дописывания.
+func OpenFile(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f}, nil
+}
+
+// Write дописывает пачку одной записью, поэтому строки разных процессов,
+// пишущих в один файл, не перемешиваются.
+func (s *FileSink) Write(_ context.Context, events []domain.Event) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := enc.Encode(event); err != nil {
+			return Permanent(err)
+		}
+	}
+	_, err := s.f.Write(buf.Bytes())
+	return err
+}
+
+// Close закрывает файл.
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
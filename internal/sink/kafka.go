@@ -0,0 +1,103 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/wrongjunior/eventsync/internal/avro"
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+func init() {
+	Register("kafka", func(options json.RawMessage) (Sink, error) {
+		var opts struct {
+			Brokers []string `json:"brokers"` // адреса брокеров "host:port"
+			Topic   string   `json:"topic"`   // топик Kafka
+			Format  string   `json:"format"`  // "json" (по умолчанию) или "avro"
+		}
+		if err := decodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		s, err := NewKafka(opts.Brokers, opts.Topic)
+		if err != nil {
+			return nil, err
+		}
+		switch opts.Format {
+		case "", "json":
+		case "avro":
+			s.Avro = true
+		default:
+			return nil, fmt.Errorf(`unknown format %q: want "json" or "avro"`, opts.Format)
+		}
+		return s, nil
+	})
+}
+
+// KafkaSink публикует события в топик Kafka. Ключ сообщения — ключ
+// партиции события, а без него ID, поэтому события одной сущности
+// попадают в одну партицию Kafka и сохраняют порядок.
+type KafkaSink struct {
+	w *kafka.Writer
+	// Avro включает кодирование сообщений в Avro single object encoding с
+	// отпечатком схемы вместо JSON; заголовок content-type — avro/binary.
+	Avro bool
+}
+
+// NewKafka создаёт приёмник для топика topic на брокерах brokers.
+func NewKafka(brokers []string, topic string) (*KafkaSink, error) {
+	if len(brokers) == 0 || topic == "" {
+		return nil, errors.New("kafka sink requires brokers and topic")
+	}
+	return &KafkaSink{w: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		// Повторами управляет политика приёмника.
+		MaxAttempts:  1,
+		BatchTimeout: 10 * time.Millisecond,
+	}}, nil
+}
+
+// Write публикует пачку одним запросом.
+func (s *KafkaSink) Write(ctx context.Context, events []domain.Event) error {
+	msgs := make([]kafka.Message, 0, len(events))
+	for _, event := range events {
+		value, contentType, err := s.encode(event)
+		if err != nil {
+			return Permanent(err)
+		}
+		key := event.PartitionKey
+		if key == "" {
+			key = event.ID
+		}
+		msgs = append(msgs, kafka.Message{
+			Key:   []byte(key),
+			Value: value,
+			Headers: []kafka.Header{
+				{Key: "type", Value: []byte(event.Type)},
+				{Key: "topic", Value: []byte(event.Topic)},
+				{Key: "content-type", Value: []byte(contentType)},
+			},
+		})
+	}
+	return s.w.WriteMessages(ctx, msgs...)
+}
+
+// encode кодирует значение сообщения и возвращает его Content-Type.
+func (s *KafkaSink) encode(event domain.Event) ([]byte, string, error) {
+	if s.Avro {
+		return avro.MarshalEvent(event), avro.MediaType, nil
+	}
+	value, err := json.Marshal(event)
+	return value, "application/json", err
+}
+
+// Close дописывает буфер и закрывает соединения с брокерами.
+func (s *KafkaSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,70 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory создаёт приёмник из параметров options — объекта JSON из секции
+// "options" конфигурации.
+type Factory func(options json.RawMessage) (Sink, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register делает приёмник доступным в конфигурации под типом typ.
+// Вызывается из init пакета интеграции; повторная регистрация типа —
+// ошибка программы.
+func Register(typ string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("sink: Register factory is nil")
+	}
+	if _, dup := registry[typ]; dup {
+		panic("sink: Register called twice for type " + typ)
+	}
+	registry[typ] = factory
+}
+
+// Open создаёт приёмник зарегистрированного типа typ.
+func Open(typ string, options json.RawMessage) (Sink, error) {
+	registryMu.RLock()
+	factory, ok := registry[typ]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown sink type %q (registered: %v)", typ, Types())
+	}
+	return factory(options)
+}
+
+// Types возвращает зарегистрированные типы приёмников по алфавиту.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(registry))
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// decodeOptions разбирает параметры приёмника; неизвестные поля — ошибка,
+// чтобы опечатка в конфигурации не проходила молча.
+func decodeOptions(options json.RawMessage, v any) error {
+	if len(options) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(options))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+func init() {
+	Register("eventsync", func(options json.RawMessage) (Sink, error) {
+		var opts struct {
+			URL     string            `json:"url"`     // адрес сервера-получателя
+			APIKey  string            `json:"api_key"` // ключ с правом публикации
+			Headers map[string]string `json:"headers"` // дополнительные заголовки
+		}
+		if err := decodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		if opts.URL == "" {
+			return nil, errors.New("url is required")
+		}
+		return NewServer(opts.URL, opts.APIKey, headerOf(opts.Headers))
+	})
+}
+
+// ServerSink публикует события на другой сервер eventsync через
+// POST /events. ID событий сохраняются, серверные номера сбрасываются:
+// сервер-получатель нумерует события сам.
+type ServerSink struct {
+	URL     string // адрес POST /events
+	Headers http.Header
+	client  *http.Client
+}
+
+// NewServer создаёт приёмник для сервера serverURL (http(s):// или
+// ws(s)://; путь заменяется на /events) с API-ключом apiKey.
+func NewServer(serverURL, apiKey string, headers http.Header) (*ServerSink, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("unsupported server url scheme %q", u.Scheme)
+	}
+	u.Path, u.RawQuery = "/events", ""
+	headers = headers.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	if apiKey != "" {
+		headers.Set("Authorization", "Bearer "+apiKey)
+	}
+	return &ServerSink{URL: u.String(), Headers: headers, client: &http.Client{Timeout: requestTimeout}}, nil
+}
+
+// Write публикует события пачки по одному. При ошибке пачка повторяется
+// целиком; уже опубликованные события сервер-получатель отсечёт по ID.
+func (s *ServerSink) Write(ctx context.Context, events []domain.Event) error {
+	for _, event := range events {
+		event.Seq = 0
+		body, err := json.Marshal(event)
+		if err != nil {
+			return Permanent(err)
+		}
+		if err := post(ctx, s.client, s.URL, s.Headers, body, "server"); err != nil {
+			return fmt.Errorf("event %s: %w", event.ID, err)
+		}
+	}
+	return nil
+}
+
+// Close ничего не делает.
+func (s *ServerSink) Close() error {
+	return nil
+}
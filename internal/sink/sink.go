@@ -0,0 +1,241 @@
+// Package sink пересылает сохранённые клиентом события во внешние
+// приёмники: файл, webhook, топик Kafka или другой сервер eventsync.
+// Каждый приёмник работает в своей горутине со своей очередью и политикой
+// повторов, поэтому недоступный приёмник не задерживает ни обработку
+// событий, ни остальные приёмники.
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Значения по умолчанию.
+const (
+	DefaultQueueSize = 1024
+	DefaultBatchSize = 100
+	// closeTimeout ограничивает дописывание очередей при Close.
+	closeTimeout = 10 * time.Second
+)
+
+// Sink — приёмник событий. Write вызывается из одной горутины; ошибка,
+// обёрнутая Permanent, не повторяется.
+type Sink interface {
+	Write(ctx context.Context, events []domain.Event) error
+	Close() error
+}
+
+// permanentError — ошибка, которую повтор не исправит (например, 4xx).
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent помечает ошибку записи как неповторяемую.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// RetryPolicy задаёт повторы записи пачки с экспоненциальной задержкой.
+type RetryPolicy struct {
+	InitialBackoff time.Duration // задержка перед второй попыткой; 0 — 1s
+	MaxBackoff     time.Duration // верхняя граница задержки; 0 — 1m
+	// MaxAttempts — число попыток записи пачки; 0 — без ограничения, пока
+	// приёмник не закрыт. Пачка, не записанная за все попытки, отбрасывается.
+	MaxAttempts int
+}
+
+// backoff возвращает задержку после attempt-й неудачной попытки с
+// разбросом ±20%, чтобы приёмники не повторяли запросы синхронно.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	initial, maxBackoff := p.InitialBackoff, p.MaxBackoff
+	if initial <= 0 {
+		initial = time.Second
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = time.Minute
+	}
+	maxBackoff = max(maxBackoff, initial)
+	d := initial
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxBackoff)
+	return d + time.Duration((rand.Float64()*0.4-0.2)*float64(d))
+}
+
+// Options — приёмник и правила пересылки в него.
+type Options struct {
+	Name      string
+	Sink      Sink
+	Types     []string // типы событий; пусто — любые
+	Topics    []string // шаблоны топиков; пусто — любые
+	QueueSize int      // события, ожидающие записи; 0 — DefaultQueueSize
+	BatchSize int      // наибольшая пачка одной записи; 0 — DefaultBatchSize
+	Retry     RetryPolicy
+}
+
+// forwarder пересылает события в один приёмник.
+type forwarder struct {
+	Options
+	queue  chan domain.Event
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	logger *slog.Logger
+}
+
+// Set рассылает события по приёмникам.
+type Set struct {
+	forwarders []*forwarder
+	closeOnce  sync.Once
+}
+
+// New проверяет правила и запускает пересылку в приёмники.
+func New(sinks []Options, logger *slog.Logger) (*Set, error) {
+	s := &Set{}
+	for i, opts := range sinks {
+		if opts.Name == "" {
+			opts.Name = fmt.Sprintf("sink-%d", i+1)
+		}
+		for _, pattern := range opts.Topics {
+			if err := domain.ValidateTopicPattern(pattern); err != nil {
+				return nil, fmt.Errorf("sink %s: %w: %q", opts.Name, err, pattern)
+			}
+		}
+		if opts.QueueSize <= 0 {
+			opts.QueueSize = DefaultQueueSize
+		}
+		if opts.BatchSize <= 0 {
+			opts.BatchSize = DefaultBatchSize
+		}
+		f := &forwarder{
+			Options: opts,
+			queue:   make(chan domain.Event, opts.QueueSize),
+			done:    make(chan struct{}),
+			logger:  logger.With("sink", opts.Name),
+		}
+		f.ctx, f.cancel = context.WithCancel(context.Background())
+		s.forwarders = append(s.forwarders, f)
+	}
+	for _, f := range s.forwarders {
+		go f.run()
+	}
+	return s, nil
+}
+
+// Notify ставит событие в очереди подходящих приёмников. Не блокируется:
+// при переполненной очереди событие для этого приёмника отбрасывается.
+func (s *Set) Notify(event domain.Event) {
+	for _, f := range s.forwarders {
+		if !f.matches(event) {
+			continue
+		}
+		select {
+		case f.queue <- event:
+		default:
+			f.logger.Warn("Sink queue full, event dropped", "id", event.ID)
+		}
+	}
+}
+
+// Close дописывает очереди не дольше closeTimeout, прерывает повторы и
+// закрывает приёмники.
+func (s *Set) Close() {
+	s.closeOnce.Do(func() {
+		for _, f := range s.forwarders {
+			close(f.queue)
+		}
+		deadline := time.NewTimer(closeTimeout)
+		defer deadline.Stop()
+		for _, f := range s.forwarders {
+			select {
+			case <-f.done:
+			case <-deadline.C:
+			}
+		}
+		for _, f := range s.forwarders {
+			f.cancel()
+			<-f.done
+			if err := f.Sink.Close(); err != nil {
+				f.logger.Error("Sink close error", "error", err)
+			}
+		}
+	})
+}
+
+func (f *forwarder) matches(event domain.Event) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == event.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Topics) > 0 {
+		for _, pattern := range f.Topics {
+			if domain.MatchTopic(pattern, event.Topic) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// run собирает из очереди пачки до BatchSize событий и записывает их.
+func (f *forwarder) run() {
+	defer close(f.done)
+	for event := range f.queue {
+		batch := []domain.Event{event}
+	fill:
+		for len(batch) < f.BatchSize {
+			select {
+			case next, ok := <-f.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+		f.deliver(batch)
+	}
+}
+
+// deliver записывает пачку, повторяя по политике приёмника.
+func (f *forwarder) deliver(batch []domain.Event) {
+	for attempt := 1; ; attempt++ {
+		err := f.Sink.Write(f.ctx, batch)
+		if err == nil {
+			return
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) || f.ctx.Err() != nil ||
+			(f.Retry.MaxAttempts > 0 && attempt >= f.Retry.MaxAttempts) {
+			f.logger.Error("Sink write failed, events dropped", "events", len(batch), "attempts", attempt, "error", err)
+			return
+		}
+		delay := f.Retry.backoff(attempt)
+		f.logger.Warn("Sink write failed, retrying", "events", len(batch), "attempt", attempt, "retry_in", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-f.ctx.Done():
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// requestTimeout ограничивает один HTTP-запрос приёмника.
+const requestTimeout = 10 * time.Second
+
+func init() {
+	Register("webhook", func(options json.RawMessage) (Sink, error) {
+		var opts struct {
+			URL     string            `json:"url"`
+			Headers map[string]string `json:"headers"` // дополнительные заголовки
+		}
+		if err := decodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		if opts.URL == "" {
+			return nil, errors.New("url is required")
+		}
+		return NewWebhook(opts.URL, headerOf(opts.Headers)), nil
+	})
+}
+
+// headerOf переводит заголовки из параметров приёмника в http.Header.
+func headerOf(headers map[string]string) http.Header {
+	h := http.Header{}
+	for name, value := range headers {
+		h.Set(name, value)
+	}
+	return h
+}
+
+// WebhookSink отправляет пачку событий массивом JSON в POST-запросе.
+type WebhookSink struct {
+	URL     string
+	Headers http.Header
+	client  *http.Client
+}
+
+// NewWebhook создаёт приёмник для адреса url с дополнительными заголовками.
+func NewWebhook(url string, headers http.Header) *WebhookSink {
+	return &WebhookSink{URL: url, Headers: headers, client: &http.Client{Timeout: requestTimeout}}
+}
+
+// Write отправляет пачку; ответ 2xx — успех.
+func (s *WebhookSink) Write(ctx context.Context, events []domain.Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return Permanent(err)
+	}
+	return post(ctx, s.client, s.URL, s.Headers, body, "webhook")
+}
+
+// Close ничего не делает.
+func (s *WebhookSink) Close() error {
+	return nil
+}
+
+// post отправляет тело JSON и проверяет ответ. Ответы 4xx, кроме 408 и
+// 429, повтором не исправить, поэтому они неповторяемые.
+func post(ctx context.Context, client *http.Client, url string, headers http.Header, body []byte, peer string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s responded %s: %s", peer, resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
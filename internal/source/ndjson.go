@@ -0,0 +1,119 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// defaultPollInterval — период проверки файла на новые строки в режиме follow.
+const defaultPollInterval = time.Second
+
+func init() {
+	Register("ndjson", func(options json.RawMessage, logger *slog.Logger) (Source, error) {
+		var opts struct {
+			Path   string `json:"path"`   // файл NDJSON с событиями
+			Follow bool   `json:"follow"` // после конца файла ждать новых строк, как tail -f
+			// PollInterval — период проверки файла в режиме follow; 0 — 1s.
+			PollInterval string `json:"poll_interval"`
+		}
+		if err := decodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		if opts.Path == "" {
+			return nil, errors.New("path is required")
+		}
+		src := &NDJSON{Path: opts.Path, Follow: opts.Follow, PollInterval: defaultPollInterval, Logger: logger}
+		if opts.PollInterval != "" {
+			d, err := time.ParseDuration(opts.PollInterval)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid poll_interval %q", opts.PollInterval)
+			}
+			src.PollInterval = d
+		}
+		return src, nil
+	})
+}
+
+// NDJSON публикует события из файла, по одному событию JSON в строке.
+// Строки, которые не удалось разобрать, пропускаются с записью в журнал.
+type NDJSON struct {
+	Path         string
+	Follow       bool
+	PollInterval time.Duration
+	Logger       *slog.Logger
+}
+
+// Run читает файл с начала. В режиме Follow после конца файла ждёт новых
+// строк; укороченный файл (ротация) читается снова с начала.
+func (s *NDJSON) Run(ctx context.Context, publish func(domain.Event) error) error {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var offset int64
+	var partial []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		offset += int64(len(line))
+		if errors.Is(err, io.EOF) {
+			// Незавершённая строка дописывается; дочитаем её позже.
+			partial = append(partial, line...)
+			if !s.Follow {
+				if len(partial) > 0 {
+					s.publishLine(partial, publish)
+				}
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(s.PollInterval):
+			}
+			if info, err := f.Stat(); err == nil && info.Size() < offset {
+				if _, err := f.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				r.Reset(f)
+				offset, partial = 0, nil
+			}
+			continue
+		}
+		if len(partial) > 0 {
+			line = append(partial, line...)
+			partial = nil
+		}
+		s.publishLine(line, publish)
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// publishLine разбирает строку и публикует событие; пустые строки
+// пропускаются.
+func (s *NDJSON) publishLine(line []byte, publish func(domain.Event) error) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return
+	}
+	var event domain.Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		s.Logger.Warn("Malformed source line skipped", "path", s.Path, "error", err)
+		return
+	}
+	publish(event)
+}
@@ -0,0 +1,87 @@
+// Package source описывает подключаемые источники событий: интеграция
+// читает события из внешней системы, а сервер публикует их, как если бы
+// они пришли через POST /events. Новая интеграция регистрируется в init
+// своего пакета и включается в конфигурации по типу, без изменения кода
+// сервиса.
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Source — источник событий.
+type Source interface {
+	// Run передаёт publish события источника, пока ctx не отменён или
+	// источник не исчерпан. Ошибка publish означает, что событие не
+	// опубликовано; продолжать ли чтение, решает источник.
+	Run(ctx context.Context, publish func(domain.Event) error) error
+}
+
+// Factory создаёт источник из параметров options — объекта JSON из секции
+// "options" конфигурации; logger — журнал с именем источника.
+type Factory func(options json.RawMessage, logger *slog.Logger) (Source, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register делает источник доступным в конфигурации под типом typ.
+// Вызывается из init пакета интеграции; повторная регистрация типа —
+// ошибка программы.
+func Register(typ string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("source: Register factory is nil")
+	}
+	if _, dup := registry[typ]; dup {
+		panic("source: Register called twice for type " + typ)
+	}
+	registry[typ] = factory
+}
+
+// Open создаёт источник зарегистрированного типа typ.
+func Open(typ string, options json.RawMessage, logger *slog.Logger) (Source, error) {
+	registryMu.RLock()
+	factory, ok := registry[typ]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown source type %q (registered: %v)", typ, Types())
+	}
+	return factory(options, logger)
+}
+
+// Types возвращает зарегистрированные типы источников по алфавиту.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(registry))
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// decodeOptions разбирает параметры источника; неизвестные поля — ошибка,
+// чтобы опечатка в конфигурации не проходила молча.
+func decodeOptions(options json.RawMessage, v any) error {
+	if len(options) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(options))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
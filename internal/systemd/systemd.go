@@ -0,0 +1,152 @@
+// Package systemd реализует протокол уведомлений sd_notify (Type=notify и
+// WatchdogSec) и получение сокетов при активации через systemd без
+// зависимости от libsystemd. Вне systemd все функции ничего не делают.
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"log/slog"
+)
+
+// Состояния для Notify.
+const (
+	StateReady    = "READY=1"
+	StateStopping = "STOPPING=1"
+	StateWatchdog = "WATCHDOG=1"
+)
+
+// listenFDsStart — номер первого переданного systemd дескриптора.
+const listenFDsStart = 3
+
+// Notify отправляет состояние менеджеру служб через $NOTIFY_SOCKET и
+// сообщает, было ли оно отправлено; без переменной возвращает false.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// Имя, начинающееся с @, — абстрактный сокет Linux.
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Ready сообщает о готовности службы с кратким описанием состояния.
+func Ready(status string, logger *slog.Logger) {
+	state := StateReady
+	if status != "" {
+		state += "\nSTATUS=" + status
+	}
+	if _, err := Notify(state); err != nil {
+		logger.Warn("Systemd readiness notification failed", "error", err)
+	}
+}
+
+// Stopping сообщает о начале остановки службы.
+func Stopping(logger *slog.Logger) {
+	if _, err := Notify(StateStopping); err != nil {
+		logger.Warn("Systemd stopping notification failed", "error", err)
+	}
+}
+
+// WatchdogInterval возвращает период WatchdogSec= службы; false — watchdog
+// для этого процесса не включён.
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// Watchdog отправляет WATCHDOG=1 вдвое чаще периода WatchdogSec=, пока
+// healthy возвращает true (nil — всегда) и ctx не отменён. Без watchdog
+// сразу возвращается.
+func Watchdog(ctx context.Context, healthy func() bool, logger *slog.Logger) {
+	interval, ok := WatchdogInterval()
+	if !ok {
+		return
+	}
+	logger.Info("Systemd watchdog enabled", "interval", interval)
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if healthy != nil && !healthy() {
+				logger.Warn("Skipping systemd watchdog ping: service unhealthy")
+				continue
+			}
+			if _, err := Notify(StateWatchdog); err != nil {
+				logger.Warn("Systemd watchdog ping failed", "error", err)
+			}
+		}
+	}
+}
+
+// Listener — сокет, переданный systemd, с именем из FileDescriptorName=.
+type Listener struct {
+	net.Listener
+	Name string
+}
+
+// Listeners возвращает сокеты, переданные systemd при активации
+// (LISTEN_FDS), в порядке дескрипторов с именами из LISTEN_FDNAMES.
+// Переменные активации удаляются из окружения, чтобы их не унаследовали
+// дочерние процессы. Без активации возвращает nil.
+func Listeners() ([]Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	listeners := make([]Listener, 0, n)
+	for i := 0; i < n; i++ {
+		fd := listenFDsStart + i
+		syscall.CloseOnExec(fd)
+		name := ""
+		if i < len(names) && names[i] != "unknown" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, Listener{Listener: ln, Name: name})
+	}
+	return listeners, nil
+}
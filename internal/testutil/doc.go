@@ -0,0 +1,4 @@
+// Package testutil содержит поддельные реализации основных интерфейсов
+// (service.Notifier, repository.EventRepository и источника событий клиента)
+// с записью вызовов, чтобы тесты и нагрузочные стенды не писали их заново.
+package testutil
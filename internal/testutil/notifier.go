@@ -0,0 +1,70 @@
+package testutil
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Notifier реализует service.Notifier и запоминает полученные события.
+type Notifier struct {
+	mu     sync.Mutex
+	events []domain.Event
+	signal chan struct{} // закрывается и пересоздаётся при каждом Notify
+}
+
+// NewNotifier создаёт пустой Notifier.
+func NewNotifier() *Notifier {
+	return &Notifier{signal: make(chan struct{})}
+}
+
+// Notify запоминает событие.
+func (n *Notifier) Notify(event domain.Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, event)
+	close(n.signal)
+	n.signal = make(chan struct{})
+}
+
+// Events возвращает копию полученных событий в порядке получения.
+func (n *Notifier) Events() []domain.Event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]domain.Event(nil), n.events...)
+}
+
+// Count возвращает число полученных событий.
+func (n *Notifier) Count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.events)
+}
+
+// Reset забывает полученные события.
+func (n *Notifier) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = nil
+}
+
+// Wait ждёт, пока получено не меньше count событий, и сообщает, дождался ли
+// до истечения timeout.
+func (n *Notifier) Wait(count int, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		n.mu.Lock()
+		got, signal := len(n.events), n.signal
+		n.mu.Unlock()
+		if got >= count {
+			return true
+		}
+		select {
+		case <-signal:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package testutil
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// Call — записанный вызов Repository.
+type Call struct {
+	Method string
+	Event  domain.Event // для Save; иначе пусто
+}
+
+// Repository реализует repository.EventRepository поверх
+// repository.MemoryRepository, записывает вызовы и позволяет подставлять
+// ошибки сохранения.
+type Repository struct {
+	*repository.MemoryRepository
+
+	mu        sync.Mutex
+	calls     []Call
+	saveErr   error
+	failSaves int // оставшиеся неудачные Save; < 0 — до ClearFailures
+}
+
+// NewRepository создаёт пустой Repository.
+func NewRepository() *Repository {
+	return &Repository{MemoryRepository: repository.NewMemoryRepository(0)}
+}
+
+// FailSaves заставляет следующие times вызовов Save вернуть err; times <= 0 —
+// все вызовы до ClearFailures. Для временной недоступности хранилища err
+// оборачивают в domain.ErrStoreUnavailable.
+func (r *Repository) FailSaves(err error, times int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saveErr = err
+	r.failSaves = times
+	if times <= 0 {
+		r.failSaves = -1
+	}
+}
+
+// ClearFailures отменяет FailSaves.
+func (r *Repository) ClearFailures() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saveErr, r.failSaves = nil, 0
+}
+
+// Save записывает вызов и сохраняет событие либо возвращает подставленную ошибку.
+func (r *Repository) Save(event domain.Event) error {
+	r.mu.Lock()
+	r.calls = append(r.calls, Call{Method: "Save", Event: event})
+	if r.failSaves != 0 {
+		if r.failSaves > 0 {
+			r.failSaves--
+		}
+		err := r.saveErr
+		r.mu.Unlock()
+		return err
+	}
+	r.mu.Unlock()
+	return r.MemoryRepository.Save(event)
+}
+
+// SaveBatch сохраняет события по одному через Save, чтобы подставленные
+// ошибки действовали и при пакетной записи.
+func (r *Repository) SaveBatch(events []domain.Event) error {
+	for _, event := range events {
+		if err := r.Save(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Init записывает вызов.
+func (r *Repository) Init() error {
+	r.record("Init")
+	return r.MemoryRepository.Init()
+}
+
+// Query записывает вызов и выполняет выборку.
+func (r *Repository) Query(filter repository.EventFilter) ([]domain.Event, error) {
+	r.record("Query")
+	return r.MemoryRepository.Query(filter)
+}
+
+// Each записывает вызов и обходит события.
+func (r *Repository) Each(filter repository.EventFilter, fn func(domain.Event) error) error {
+	r.record("Each")
+	return r.MemoryRepository.Each(filter, fn)
+}
+
+// Count записывает вызов и считает события.
+func (r *Repository) Count(filter repository.EventFilter) (int, error) {
+	r.record("Count")
+	return r.MemoryRepository.Count(filter)
+}
+
+// DeleteOlderThan записывает вызов и удаляет события.
+func (r *Repository) DeleteOlderThan(cutoff time.Time) (int, error) {
+	r.record("DeleteOlderThan")
+	return r.MemoryRepository.DeleteOlderThan(cutoff)
+}
+
+// LastSeq записывает вызов и возвращает наибольший номер.
+func (r *Repository) LastSeq() (uint64, error) {
+	r.record("LastSeq")
+	return r.MemoryRepository.LastSeq()
+}
+
+// Calls возвращает копию записанных вызовов.
+func (r *Repository) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// CallCount возвращает число вызовов метода method.
+func (r *Repository) CallCount(method string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, c := range r.calls {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Repository) record(method string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, Call{Method: method})
+}
@@ -0,0 +1,89 @@
+package testutil
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/service"
+)
+
+// Result — итог обработки события, доставленного EventSource.
+type Result struct {
+	Event domain.Event
+	Err   error
+	Acked bool // клиент подтвердил бы событие серверу
+}
+
+// EventSource заменяет клиенту сервер: передаёт события в
+// service.ClientService так же, как транспорт, и записывает, какие из них
+// были бы подтверждены.
+type EventSource struct {
+	cs  *service.ClientService
+	seq atomic.Uint64
+
+	mu      sync.Mutex
+	results []Result
+	pending sync.WaitGroup
+}
+
+// NewEventSource создаёт источник событий для cs.
+func NewEventSource(cs *service.ClientService) *EventSource {
+	return &EventSource{cs: cs}
+}
+
+// Event создаёт событие с очередным номером, ID и текущим временем, как
+// если бы его разослал сервер.
+func (s *EventSource) Event(eventType, message string) domain.Event {
+	seq := s.seq.Add(1)
+	return domain.Event{
+		ID:        "event-" + strconv.FormatUint(seq, 10),
+		Seq:       seq,
+		Type:      eventType,
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// Deliver передаёт события клиенту, не дожидаясь их обработки.
+func (s *EventSource) Deliver(events ...domain.Event) {
+	for _, event := range events {
+		s.pending.Add(1)
+		s.cs.ProcessEventAsync(event, func(err error) {
+			defer s.pending.Done()
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			acked := err == nil || errors.Is(err, domain.ErrDuplicateEvent)
+			s.results = append(s.results, Result{Event: event, Err: err, Acked: acked})
+		})
+	}
+}
+
+// Wait ждёт обработки всех доставленных событий. При пакетной записи
+// события завершаются после сброса пачки.
+func (s *EventSource) Wait() {
+	s.pending.Wait()
+}
+
+// Results возвращает итоги обработки в порядке завершения.
+func (s *EventSource) Results() []Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Result(nil), s.results...)
+}
+
+// Acked возвращает ID подтверждённых событий в порядке завершения.
+func (s *EventSource) Acked() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, r := range s.results {
+		if r.Acked {
+			ids = append(ids, r.Event.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,291 @@
+// Package transform собирает из конфигурации звенья конвейера
+// преобразования событий на сервере: обогащение метаданными, маскирование
+// полей, отбрасывание и смену топика.
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Виды встроенных преобразований.
+const (
+	KindEnrich = "enrich" // дописывает метаданные
+	KindRedact = "redact" // маскирует или удаляет поля
+	KindDrop   = "drop"   // отбрасывает событие
+	KindRoute  = "route"  // заменяет топик события
+)
+
+// Redacted — значение, которым KindRedact заменяет поле без явной замены.
+const Redacted = "[REDACTED]"
+
+// Func — звено конвейера: изменённое событие либо false, если событие не
+// нужно рассылать. Совместимо с service.Transform.
+type Func = func(event domain.Event) (domain.Event, bool)
+
+// Spec описывает одно преобразование. Types и Topics отбирают события, к
+// которым оно применяется; пустые — ко всем.
+type Spec struct {
+	Kind   string
+	Types  []string // типы событий
+	Topics []string // шаблоны топиков
+	// Metadata — для KindEnrich: ключ -> значение. В значении подставляются
+	// ${hostname}, ${node}, ${namespace}, ${type}, ${topic} и ${source}.
+	Metadata map[string]string
+	// Fields — для KindRedact: "message", "metadata.<ключ>" или
+	// "data.<путь>", где путь — ключи вложенных объектов через точку.
+	Fields []string
+	// Replacement — для KindRedact: замена значения; пусто — Redacted.
+	// Remove удаляет поля вместо замены.
+	Replacement string
+	Remove      bool
+	// Topic — для KindRoute: новый топик события.
+	Topic string
+}
+
+// Env — значения для подстановки в KindEnrich, не зависящие от события.
+type Env struct {
+	Hostname string
+	Node     string // имя экземпляра сервера (node_id)
+}
+
+// DefaultEnv возвращает окружение с именем хоста и узлом node.
+func DefaultEnv(node string) Env {
+	hostname, _ := os.Hostname()
+	return Env{Hostname: hostname, Node: node}
+}
+
+// Build проверяет описания и возвращает звенья в том же порядке.
+func Build(specs []Spec, env Env) ([]Func, error) {
+	funcs := make([]Func, 0, len(specs))
+	for i, spec := range specs {
+		f, err := build(spec, env)
+		if err != nil {
+			return nil, fmt.Errorf("transform %d (%s): %w", i+1, spec.Kind, err)
+		}
+		funcs = append(funcs, matching(spec, f))
+	}
+	return funcs, nil
+}
+
+func build(spec Spec, env Env) (Func, error) {
+	for _, pattern := range spec.Topics {
+		if err := domain.ValidateTopicPattern(pattern); err != nil {
+			return nil, fmt.Errorf("%w: %q", err, pattern)
+		}
+	}
+	switch spec.Kind {
+	case KindEnrich:
+		return enrich(spec.Metadata, env)
+	case KindRedact:
+		return redact(spec.Fields, spec.Replacement, spec.Remove)
+	case KindDrop:
+		return func(event domain.Event) (domain.Event, bool) { return event, false }, nil
+	case KindRoute:
+		if err := domain.ValidateTopic(spec.Topic); err != nil {
+			return nil, fmt.Errorf("%w: %q", err, spec.Topic)
+		}
+		return func(event domain.Event) (domain.Event, bool) {
+			event.Topic = spec.Topic
+			return event, true
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown kind %q", spec.Kind)
+	}
+}
+
+// matching применяет f только к событиям, отобранным Types и Topics.
+func matching(spec Spec, f Func) Func {
+	if len(spec.Types) == 0 && len(spec.Topics) == 0 {
+		return f
+	}
+	return func(event domain.Event) (domain.Event, bool) {
+		if !matches(spec, event) {
+			return event, true
+		}
+		return f(event)
+	}
+}
+
+func matches(spec Spec, event domain.Event) bool {
+	if len(spec.Types) > 0 && !contains(spec.Types, event.Type) {
+		return false
+	}
+	if len(spec.Topics) == 0 {
+		return true
+	}
+	for _, pattern := range spec.Topics {
+		if domain.MatchTopic(pattern, event.Topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// enrich дописывает метаданные, не затирая заданные издателем ключи.
+func enrich(metadata map[string]string, env Env) (Func, error) {
+	if len(metadata) == 0 {
+		return nil, fmt.Errorf("metadata is required")
+	}
+	if err := domain.ValidateMetadata(metadata); err != nil {
+		return nil, err
+	}
+	for key, value := range metadata {
+		if err := checkVars(value); err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+	}
+	return func(event domain.Event) (domain.Event, bool) {
+		out := make(map[string]string, len(event.Metadata)+len(metadata))
+		maps.Copy(out, event.Metadata)
+		for key, value := range metadata {
+			if _, ok := out[key]; !ok {
+				out[key] = os.Expand(value, func(name string) string { return lookup(name, event, env) })
+			}
+		}
+		// Лишние ключи или длинные значения отбрасываются, а не ломают рассылку.
+		if domain.ValidateMetadata(out) == nil {
+			event.Metadata = out
+		}
+		return event, true
+	}, nil
+}
+
+var varNames = []string{"hostname", "node", "namespace", "type", "topic", "source"}
+
+func checkVars(value string) error {
+	var err error
+	os.Expand(value, func(name string) string {
+		if err == nil && !contains(varNames, name) {
+			err = fmt.Errorf("unknown variable ${%s}", name)
+		}
+		return ""
+	})
+	return err
+}
+
+func lookup(name string, event domain.Event, env Env) string {
+	switch name {
+	case "hostname":
+		return env.Hostname
+	case "node":
+		return env.Node
+	case "namespace":
+		return event.NamespaceOrDefault()
+	case "type":
+		return event.Type
+	case "topic":
+		return event.Topic
+	case "source":
+		return event.Source
+	}
+	return ""
+}
+
+// redact маскирует или удаляет поля события.
+func redact(fields []string, replacement string, remove bool) (Func, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("fields are required")
+	}
+	if replacement == "" {
+		replacement = Redacted
+	}
+	var (
+		message  bool
+		metadata []string
+		data     [][]string
+	)
+	for _, field := range fields {
+		switch {
+		case field == "message":
+			message = true
+		case strings.HasPrefix(field, "metadata.") && len(field) > len("metadata."):
+			metadata = append(metadata, strings.TrimPrefix(field, "metadata."))
+		case strings.HasPrefix(field, "data.") && len(field) > len("data."):
+			data = append(data, strings.Split(strings.TrimPrefix(field, "data."), "."))
+		default:
+			return nil, fmt.Errorf("unsupported field %q", field)
+		}
+	}
+	rawReplacement, _ := json.Marshal(replacement)
+	return func(event domain.Event) (domain.Event, bool) {
+		if message {
+			if remove {
+				event.Message = ""
+			} else if event.Message != "" {
+				event.Message = replacement
+			}
+		}
+		if len(metadata) > 0 && len(event.Metadata) > 0 {
+			out := maps.Clone(event.Metadata)
+			for _, key := range metadata {
+				if _, ok := out[key]; !ok {
+					continue
+				}
+				if remove {
+					delete(out, key)
+				} else {
+					out[key] = replacement
+				}
+			}
+			event.Metadata = out
+		}
+		if len(data) > 0 && len(event.Data) > 0 {
+			// UseNumber сохраняет большие целые без потери точности.
+			dec := json.NewDecoder(bytes.NewReader(event.Data))
+			dec.UseNumber()
+			var doc any
+			if dec.Decode(&doc) == nil {
+				changed := false
+				for _, path := range data {
+					if redactPath(doc, path, rawReplacement, remove) {
+						changed = true
+					}
+				}
+				if changed {
+					if raw, err := json.Marshal(doc); err == nil {
+						event.Data = raw
+					}
+				}
+			}
+		}
+		return event, true
+	}, nil
+}
+
+// redactPath заменяет или удаляет значение по пути в разобранном JSON и
+// сообщает, нашлось ли оно.
+func redactPath(doc any, path []string, replacement json.RawMessage, remove bool) bool {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return false
+	}
+	value, ok := obj[path[0]]
+	if !ok {
+		return false
+	}
+	if len(path) > 1 {
+		return redactPath(value, path[1:], replacement, remove)
+	}
+	if remove {
+		delete(obj, path[0])
+	} else {
+		obj[path[0]] = replacement
+	}
+	return true
+}
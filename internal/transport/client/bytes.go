@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"net"
+
+	"github.com/wrongjunior/eventsync/internal/metrics"
+)
+
+// countingConn учитывает байты, прочитанные из сети, то есть после сжатия
+// permessage-deflate (и шифрования TLS), в отличие от размера сообщений.
+type countingConn struct {
+	net.Conn
+	wire *metrics.Counter
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.wire.Add(uint64(n))
+	return n, err
+}
+
+// netDialCounting возвращает функцию установки TCP-соединения, считающую
+// принятые байты в wire.
+func netDialCounting(wire *metrics.Counter) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &countingConn{Conn: conn, wire: wire}, nil
+	}
+}
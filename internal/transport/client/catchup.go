@@ -0,0 +1,157 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// catchUpTimeout ограничивает один запрос страницы событий.
+const catchUpTimeout = 30 * time.Second
+
+// catchUp загружает из истории сервера события после последнего
+// сохранённого номера и сохраняет их до подключения по WebSocket, которое
+// затем запрашивает только оставшийся хвост. Клиент без сохранённых
+// событий ничего не загружает, как и без CatchUp.
+func (ct *ClientTransport) catchUp(ctx context.Context, serverURL string) error {
+	if !ct.CatchUp || ct.Group != "" || ct.Subscription != "" || ct.AggregateOnly {
+		return nil
+	}
+	since := ct.ClientService.LastSeq()
+	if since == 0 {
+		return nil
+	}
+	target, err := catchUpURL(serverURL)
+	if err != nil {
+		return err
+	}
+	start, total := time.Now(), 0
+	for {
+		page, err := ct.fetchPage(ctx, target, since)
+		if err != nil {
+			return err
+		}
+		if err := ct.saveCaughtUp(page.Events); err != nil {
+			return err
+		}
+		total += len(page.Events)
+		if len(page.Events) > 0 {
+			since = page.Events[len(page.Events)-1].Seq
+		}
+		if !page.More || len(page.Events) == 0 {
+			break
+		}
+	}
+	if total > 0 {
+		ct.Logger.Info("Caught up over HTTP", "url", target, "events", total, "last_seq", since, "duration", time.Since(start))
+	}
+	return nil
+}
+
+// fetchPage запрашивает страницу событий с номером больше since.
+func (ct *ClientTransport) fetchPage(ctx context.Context, target string, since uint64) (domain.EventPage, error) {
+	var page domain.EventPage
+	q := url.Values{"since": {strconv.FormatUint(since, 10)}}
+	if ct.CatchUpPageSize > 0 {
+		q.Set("limit", strconv.Itoa(ct.CatchUpPageSize))
+	}
+	if ct.Namespace != "" {
+		q.Set("namespace", ct.Namespace)
+	}
+	if len(ct.Topics) > 0 {
+		q.Set("topics", strings.Join(ct.Topics, ","))
+	}
+	if len(ct.EventTypes) > 0 {
+		q.Set("types", strings.Join(ct.EventTypes, ","))
+	}
+	ctx, cancel := context.WithTimeout(ctx, catchUpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target+"?"+q.Encode(), nil)
+	if err != nil {
+		return page, err
+	}
+	if req.Header, err = ct.requestHeader(); err != nil {
+		return page, err
+	}
+	resp, err := ct.httpClient().Do(req)
+	if err != nil {
+		return page, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return page, fmt.Errorf("%w: server responded %s", domain.ErrUnauthorized, resp.Status)
+		}
+		return page, fmt.Errorf("server responded %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	return page, err
+}
+
+// saveCaughtUp сохраняет страницу событий и ждёт их записи. Дубликаты
+// ошибкой не считаются.
+func (ct *ClientTransport) saveCaughtUp(events []domain.Event) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for _, event := range events {
+		wg.Add(1)
+		ct.ClientService.ProcessEventAsync(event, func(err error) {
+			defer wg.Done()
+			if err != nil && !errors.Is(err, domain.ErrDuplicateEvent) {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("event %s: %w", event.ID, err)
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// httpClient возвращает HTTP-клиент с настройками TLS и прокси транспорта.
+func (ct *ClientTransport) httpClient() *http.Client {
+	ct.httpOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = ct.TLSConfig
+		if ct.ProxyURL != nil {
+			transport.Proxy = http.ProxyURL(ct.ProxyURL)
+		}
+		ct.http = &http.Client{Transport: transport}
+	})
+	return ct.http
+}
+
+// catchUpURL выводит адрес GET /events из адреса WebSocket сервера.
+func catchUpURL(serverURL string) (string, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	default:
+		return "", fmt.Errorf("unsupported server url scheme %q", u.Scheme)
+	}
+	u.Path, u.RawQuery = "/events", ""
+	return u.String(), nil
+}
@@ -1,24 +1,134 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/wrongjunior/eventsync/internal/bufpool"
 	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/metrics"
 	"github.com/wrongjunior/eventsync/internal/service"
 	"log/slog"
 )
 
 // ClientTransport реализует транспортный слой клиента: подключение, получение сообщений и переподключение.
 type ClientTransport struct {
-	ServerURL     string
-	Conn          *websocket.Conn
-	Logger        *slog.Logger
-	ClientService *service.ClientService
-	reconnecting  bool
+	// ServerURL — адрес основного сервера.
+	ServerURL string
+	// FailoverURLs — резервные адреса, перебираемые по порядку, если
+	// основной сервер недоступен.
+	FailoverURLs []string
+	// PrimaryRecheck — период проверки основного сервера при работе через
+	// резервный; 0 — DefaultPrimaryRecheck.
+	PrimaryRecheck time.Duration
+	Conn           *websocket.Conn
+	Logger         *slog.Logger
+	ClientService  *service.ClientService
+	// Topics — шаблоны топиков для подписки ("orders.*"); пустой список — все события.
+	Topics []string
+	// Namespace — пространство имён, к которому подключается клиент; пусто — по умолчанию.
+	Namespace string
+	// EventTypes — типы событий, которые сервер должен отправлять клиенту;
+	// пустой список — все типы.
+	EventTypes []string
+	// MinSeverity — порог важности событий, которые сервер должен отправлять
+	// клиенту; пусто — без порога.
+	MinSeverity string
+	// Aggregate запрашивает сводные события с числом полученных событий по
+	// типам за каждое окно такой длины; 0 — без сводок. AggregateOnly —
+	// только сводки, без самих событий.
+	Aggregate     time.Duration
+	AggregateOnly bool
+	// Group — группа потребителей: каждое событие получает только один из
+	// её участников. GroupStrategy — "round_robin" или "key_hash"; пусто —
+	// стратегия группы.
+	Group         string
+	GroupStrategy string
+	// Subscription — имя долговременной подписки: сервер хранит её смещение
+	// и присылает пропущенные события, даже если локальное хранилище клиента
+	// пусто. Несовместимо с Group.
+	Subscription string
+	// CatchUp перед каждым подключением забирает события, пропущенные с
+	// последнего сохранённого номера, из истории сервера по HTTP
+	// (GET /events?since=) постранично по CatchUpPageSize событий; 0 —
+	// размер страницы сервера. Так после долгого отключения повторная
+	// выдача не идёт через сокет. Не используется с Group и Subscription:
+	// там пропущенное присылает сервер.
+	CatchUp         bool
+	CatchUpPageSize int
+	// Snapshot запрашивает при каждом подключении уплотнённый снимок —
+	// последнее событие каждого типа и топика — перед новыми событиями.
+	Snapshot bool
+	// SchemaVersions — максимальные понятные клиенту версии схем по типам событий.
+	SchemaVersions map[string]int
+	// TLSConfig — настройки TLS для wss://; nil — настройки по умолчанию.
+	TLSConfig *tls.Config
+	// EnableCompression запрашивает у сервера сжатие permessage-deflate;
+	// сжатие используется, только если сервер его поддерживает.
+	EnableCompression bool
+	// ReadBufferSize и WriteBufferSize — размеры буферов соединения в байтах;
+	// 0 — 4 КиБ.
+	ReadBufferSize  int
+	WriteBufferSize int
+	// ReadLimit — максимальный размер кадра сервера в байтах; кадр больше
+	// разрывает соединение. 0 — без ограничения.
+	ReadLimit int64
+	// ProxyURL — прокси-сервер (http://, https:// или socks5://); nil —
+	// прокси из переменных окружения HTTP_PROXY, HTTPS_PROXY и NO_PROXY.
+	ProxyURL *url.URL
+	// APIKey передаётся в заголовке Authorization при каждом подключении.
+	APIKey string
+	// TokenSource, если задан, вызывается перед каждым подключением, и его
+	// токен передаётся в заголовке Authorization вместо APIKey.
+	TokenSource TokenSource
+	// Headers — дополнительные заголовки рукопожатия (например, X-API-Key).
+	Headers http.Header
+	// Outbox, если задан, хранит сообщения серверу, не отправленные из-за
+	// разрыва соединения, и досылает их по порядку после переподключения.
+	Outbox Outbox
+	// OutboxOwner отделяет очередь этого транспорта в общей БД очереди.
+	OutboxOwner string
+	// ClientID — имя экземпляра клиента в отчётах о состоянии.
+	ClientID string
+	// StatusInterval — период отправки серверу отчёта о состоянии; 0 — не отправлять.
+	StatusInterval time.Duration
+	// HeartbeatInterval — период прикладного ping с измерением задержки и
+	// расхождения часов; 0 — не отправлять. Работает с протоколом eventsync.v2.
+	HeartbeatInterval time.Duration
+	// HeartbeatTimeout — сколько ждать pong, прежде чем считать соединение
+	// зависшим и переподключиться; 0 — не проверять.
+	HeartbeatTimeout time.Duration
+	// OnPublishResult вызывается из цикла чтения с ответом сервера на Publish.
+	OnPublishResult func(domain.PublishResult)
+	// Metrics — метрики клиента; по умолчанию пустой набор без регистрации.
+	Metrics *metrics.ClientMetrics
+	// Reconnect — политика переподключения.
+	Reconnect ReconnectPolicy
+	// OnReconnectExhausted вызывается, когда попытки переподключения исчерпаны;
+	// после этого Listen завершается.
+	OnReconnectExhausted func(ReconnectExhausted)
+	connected            atomic.Bool
+	activeURL            string        // адрес сервера текущего соединения
+	protocol             string        // согласованная с сервером версия протокола
+	connDone             chan struct{} // закрывается при разрыве текущего соединения
+	outboxPending        bool          // в Outbox могут быть неотправленные сообщения; под writeMu
+	writeMu              sync.Mutex    // сериализует запись в соединение
+	lastPong             atomic.Int64  // время последнего pong, Unix-наносекунды
+	http                 *http.Client  // клиент запросов догоняющей загрузки
+	httpOnce             sync.Once
 }
 
 // NewClientTransport создаёт новый экземпляр транспорта клиента.
@@ -27,80 +137,380 @@ func NewClientTransport(serverURL string, cs *service.ClientService, logger *slo
 		ServerURL:     serverURL,
 		ClientService: cs,
 		Logger:        logger,
+		Metrics:       &metrics.ClientMetrics{},
+		Reconnect:     DefaultReconnectPolicy,
+		ReadLimit:     DefaultReadLimit,
 	}
 }
 
-// connect устанавливает WebSocket-соединение с сервером.
-func (ct *ClientTransport) connect() error {
-	u, err := url.Parse(ct.ServerURL)
+// DefaultReadLimit — ограничение кадра сервера по умолчанию: с запасом
+// вмещает пачку событий eventsync.v3 и событие с крупной нагрузкой.
+const DefaultReadLimit = 16 << 20
+
+// connect устанавливает WebSocket-соединение с первым доступным сервером,
+// начиная с основного.
+func (ct *ClientTransport) connect(ctx context.Context) error {
+	var lastErr error
+	for _, serverURL := range ct.servers() {
+		if lastErr = ct.connectTo(ctx, serverURL); lastErr == nil {
+			return nil
+		}
+		if len(ct.FailoverURLs) > 0 {
+			ct.Logger.Warn("Server unavailable", "url", serverURL, "error", lastErr)
+		}
+	}
+	return lastErr
+}
+
+// connectTo устанавливает WebSocket-соединение с указанным сервером.
+func (ct *ClientTransport) connectTo(ctx context.Context, serverURL string) error {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return err
+	}
+	if err := ct.catchUp(ctx, serverURL); err != nil {
+		ct.Logger.Warn("Catch-up over HTTP failed, relying on WebSocket replay", "url", serverURL, "error", err)
+	}
+	q := u.Query()
+	if len(ct.Topics) > 0 {
+		q.Set("topics", strings.Join(ct.Topics, ","))
+	}
+	if ct.Namespace != "" {
+		q.Set("namespace", ct.Namespace)
+	}
+	if len(ct.EventTypes) > 0 {
+		q.Set("types", strings.Join(ct.EventTypes, ","))
+	}
+	if ct.MinSeverity != "" {
+		q.Set("min_severity", ct.MinSeverity)
+	}
+	if ct.Aggregate > 0 {
+		q.Set("aggregate", ct.Aggregate.String())
+		if ct.AggregateOnly {
+			q.Set("aggregate_only", "true")
+		}
+	}
+	if ct.Group != "" {
+		q.Set("group", ct.Group)
+		if ct.GroupStrategy != "" {
+			q.Set("group_strategy", ct.GroupStrategy)
+		}
+	}
+	if ct.Subscription != "" {
+		q.Set("subscription", ct.Subscription)
+	}
+	if seq := ct.ClientService.LastSeq(); seq > 0 {
+		q.Set("since", strconv.FormatUint(seq, 10))
+	}
+	if ct.Snapshot {
+		q.Set("sync", "snapshot")
+	}
+	if len(ct.SchemaVersions) > 0 {
+		pairs := make([]string, 0, len(ct.SchemaVersions))
+		for eventType, v := range ct.SchemaVersions {
+			pairs = append(pairs, eventType+":"+strconv.Itoa(v))
+		}
+		sort.Strings(pairs)
+		q.Set("schema_versions", strings.Join(pairs, ","))
+	}
+	u.RawQuery = q.Encode()
+	header, err := ct.requestHeader()
 	if err != nil {
 		return err
 	}
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	conn, resp, err := ct.dialer().DialContext(ctx, u.String(), header)
 	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return fmt.Errorf("%w: server responded %s", domain.ErrUnauthorized, resp.Status)
+		}
 		return err
 	}
+	if ct.ReadLimit > 0 {
+		conn.SetReadLimit(ct.ReadLimit)
+	}
+	// Сервер, не выбравший подпротокол, понимает только кадры без конверта.
+	protocol := conn.Subprotocol()
+	if protocol == "" {
+		protocol = domain.ProtocolV1
+	}
+	// Conn меняется под writeMu: Publish может вызываться из других горутин.
+	ct.writeMu.Lock()
 	ct.Conn = conn
-	ct.Logger.Info("Connected to server", "url", ct.ServerURL)
+	ct.protocol = protocol
+	if ct.Outbox != nil && ct.outboxPending {
+		if err := ct.flushOutbox(conn); err != nil {
+			ct.Logger.Error("Outbox flush error", "error", err)
+		}
+	}
+	ct.writeMu.Unlock()
+	ct.activeURL = serverURL
+	ct.connDone = make(chan struct{})
+	ct.setConnected(true)
+	ct.Logger.Info("Connected to server", "url", serverURL, "protocol", protocol)
+	go ct.closeOnCancel(ctx, conn, ct.connDone)
+	if ct.StatusInterval > 0 {
+		go ct.reportStatus(conn, ct.connDone)
+	}
+	if ct.HeartbeatInterval > 0 && protocol != domain.ProtocolV1 {
+		go ct.heartbeat(conn, ct.connDone)
+	}
+	if serverURL != ct.ServerURL {
+		go ct.watchPrimary(conn, ct.connDone)
+	}
 	return nil
 }
 
+// requestHeader возвращает заголовки запроса к серверу с учётными данными.
+func (ct *ClientTransport) requestHeader() (http.Header, error) {
+	header := ct.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if ct.TokenSource != nil {
+		token, err := ct.TokenSource()
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	} else if ct.APIKey != "" {
+		header.Set("Authorization", "Bearer "+ct.APIKey)
+	}
+	return header, nil
+}
+
 // Listen запускает цикл получения сообщений с автоматическим переподключением.
 func (ct *ClientTransport) Listen(ctx context.Context) {
+	// Очередь могла остаться непустой после прошлого запуска.
+	ct.writeMu.Lock()
+	ct.outboxPending = ct.Outbox != nil
+	ct.writeMu.Unlock()
+
 	// Первоначальное соединение.
-	if err := ct.connect(); err != nil {
+	if err := ct.connect(ctx); err != nil {
 		ct.Logger.Error("Initial connection failed", "error", err)
-		go ct.reconnect(ctx)
+		if !ct.reconnect(ctx) {
+			return
+		}
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			ct.Logger.Info("Client transport shutting down")
+			ct.dropConn()
+			ct.setConnected(false)
 			return
 		default:
-			_, message, err := ct.Conn.ReadMessage()
+			buf := bufpool.Get()
+			err := readMessage(ct.Conn, buf)
+			ct.Metrics.MessageBytes.Add(uint64(buf.Len()))
 			if err != nil {
-				ct.Logger.Error("Read error", "error", err)
-				ct.reconnect(ctx)
+				bufpool.Put(buf)
+			}
+			if err != nil && ctx.Err() != nil {
+				// Чтение прервано завершением работы (см. closeOnCancel).
 				continue
 			}
-			var event domain.Event
-			if err := json.Unmarshal(message, &event); err != nil {
-				ct.Logger.Error("JSON unmarshal error", "error", err)
+			if err != nil {
+				ct.Logger.Error("Read error", "error", err)
+				ct.setConnected(false)
+				if !ct.reconnect(ctx) {
+					return
+				}
 				continue
 			}
-			ct.ClientService.ProcessEvent(event)
+			ct.handleMessage(buf.Bytes())
+			bufpool.Put(buf)
 		}
 	}
 }
 
-// reconnect пытается восстановить соединение с экспоненциальной задержкой.
-func (ct *ClientTransport) reconnect(ctx context.Context) {
-	if ct.reconnecting {
+// readMessage читает следующее сообщение соединения в buf. В отличие от
+// ReadMessage буфер переиспользуется между сообщениями.
+func readMessage(conn *websocket.Conn, buf *bytes.Buffer) error {
+	_, r, err := conn.NextReader()
+	if err != nil {
+		return err
+	}
+	_, err = buf.ReadFrom(r)
+	return err
+}
+
+// handleMessage разбирает кадр сервера. Разобранные значения не ссылаются
+// на message, поэтому буфер сообщения можно переиспользовать после возврата.
+func (ct *ClientTransport) handleMessage(message []byte) {
+	kind, payload, err := domain.DecodeFrame(message)
+	if err != nil {
+		ct.Logger.Error("JSON unmarshal error", "error", err)
 		return
 	}
-	ct.reconnecting = true
-	if ct.Conn != nil {
-		ct.Conn.Close()
+	switch kind {
+	case domain.FrameKindEvent:
+		ct.handleEvent(payload)
+	case domain.FrameKindBatch:
+		var events []json.RawMessage
+		if err := json.Unmarshal(payload, &events); err != nil {
+			ct.Logger.Error("JSON unmarshal error", "error", err)
+			return
+		}
+		for _, event := range events {
+			ct.handleEvent(event)
+		}
+	default:
+		ct.handleFrame(kind, payload)
 	}
-	backoff := time.Second
-	for {
+}
+
+// handleEvent разбирает событие из кадра и передаёт его на сохранение.
+func (ct *ClientTransport) handleEvent(payload []byte) {
+	var event domain.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		ct.Logger.Error("JSON unmarshal error", "error", err)
+		return
+	}
+	if event.ID == "" {
+		// Сервер присваивает ID каждому событию; кадр без него
+		// повреждён, и сохранять его как событие нельзя.
+		ct.Logger.Error("Event without ID ignored", "type", event.Type)
+		return
+	}
+	// Подтверждение уходит после записи события; при пакетной записи —
+	// после записи всей пачки, поэтому отправляется в то соединение,
+	// из которого событие получено.
+	conn := ct.Conn
+	ct.ClientService.ProcessEventAsync(event, func(err error) {
+		if err == nil || errors.Is(err, domain.ErrDuplicateEvent) {
+			ct.sendAck(conn, domain.NewAck(event))
+		}
+	})
+}
+
+// Connected сообщает, установлено ли соединение с сервером.
+func (ct *ClientTransport) Connected() bool {
+	return ct.connected.Load()
+}
+
+// setConnected обновляет состояние соединения для метрик.
+func (ct *ClientTransport) setConnected(connected bool) {
+	if ct.connected.Swap(connected) == connected {
+		return
+	}
+	if connected {
+		ct.Metrics.Connected.Add(1)
+	} else {
+		ct.Metrics.Connected.Add(-1)
+	}
+}
+
+// sendAck подтверждает серверу сохранение событий. Ошибка записи не
+// критична: соединение будет восстановлено циклом чтения, а при заданном
+// Outbox подтверждение будет дослано после переподключения.
+func (ct *ClientTransport) sendAck(conn *websocket.Conn, ack domain.Ack) {
+	ct.writeMu.Lock()
+	defer ct.writeMu.Unlock()
+	if err := ct.send(conn, domain.FrameKindAck, ack); err != nil {
+		ct.Logger.Error("Ack write error", "error", err)
+	}
+}
+
+// reconnect пытается восстановить соединение согласно ReconnectPolicy.
+// Возвращает false, если контекст отменён или попытки исчерпаны.
+func (ct *ClientTransport) reconnect(ctx context.Context) bool {
+	ct.dropConn()
+	var lastErr error
+	for attempt := 1; ; attempt++ {
 		select {
 		case <-ctx.Done():
 			ct.Logger.Info("Reconnection cancelled")
-			return
+			return false
 		default:
-			if err := ct.connect(); err == nil {
-				ct.reconnecting = false
-				ct.Logger.Info("Reconnected successfully")
-				return
-			}
-			ct.Logger.Error("Reconnection attempt failed", "error", "connection error")
-			time.Sleep(backoff)
-			if backoff < 30*time.Second {
-				backoff *= 2
+		}
+		if lastErr = ct.connect(ctx); lastErr == nil {
+			ct.Metrics.Reconnects.Inc()
+			ct.Logger.Info("Reconnected successfully", "attempts", attempt)
+			return true
+		}
+		if ct.Reconnect.exhausted(attempt) {
+			ct.Logger.Error("Reconnection attempts exhausted", "attempts", attempt, "error", lastErr)
+			if ct.OnReconnectExhausted != nil {
+				ct.OnReconnectExhausted(ReconnectExhausted{URL: ct.activeURL, Attempts: attempt, LastError: lastErr})
 			}
+			return false
 		}
+		delay := ct.Reconnect.backoff(attempt)
+		ct.Logger.Error("Reconnection attempt failed", "attempt", attempt, "retry_in", delay, "error", lastErr)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			ct.Logger.Info("Reconnection cancelled")
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// dialer возвращает WebSocket-dialer с настройками транспорта.
+func (ct *ClientTransport) dialer() *websocket.Dialer {
+	d := *websocket.DefaultDialer
+	d.TLSClientConfig = ct.TLSConfig
+	d.EnableCompression = ct.EnableCompression
+	d.ReadBufferSize = ct.ReadBufferSize
+	d.WriteBufferSize = ct.WriteBufferSize
+	d.Subprotocols = domain.SupportedProtocols
+	d.NetDialContext = netDialCounting(ct.Metrics.WireBytes)
+	if ct.ProxyURL != nil {
+		d.Proxy = http.ProxyURL(ct.ProxyURL)
+	}
+	return &d
+}
+
+// closeWait — сколько ждать ответного кадра закрытия от сервера.
+const closeWait = time.Second
+
+// closeOnCancel при отмене ctx отправляет серверу кадр закрытия, чтобы тот
+// сразу снял регистрацию клиента, и ограничивает ожидание ответного кадра:
+// цикл чтения получит его (или таймаут) и завершит Listen.
+func (ct *ClientTransport) closeOnCancel(ctx context.Context, conn *websocket.Conn, done <-chan struct{}) {
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "client shutdown")
+	ct.writeMu.Lock()
+	err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeWait))
+	ct.writeMu.Unlock()
+	if err != nil {
+		ct.Logger.Debug("Close frame write error", "error", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(closeWait))
+}
+
+// dropConn закрывает текущее соединение и останавливает связанные с ним горутины.
+func (ct *ClientTransport) dropConn() {
+	if ct.connDone != nil {
+		close(ct.connDone)
+		ct.connDone = nil
+	}
+	if ct.Conn != nil {
+		ct.Conn.Close()
+	}
+}
+
+// ParseProxyURL разбирает адрес прокси и проверяет, что его схема поддерживается.
+func ParseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
 	}
+	return u, nil
 }
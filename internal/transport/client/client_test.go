@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/wrongjunior/eventsync/internal/bufpool"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/repository"
+	"github.com/wrongjunior/eventsync/internal/service"
+)
+
+// BenchmarkReadEventFrame замеряет чтение кадра события ProtocolV2 в буфер
+// пула и его разбор, как в цикле чтения клиента.
+func BenchmarkReadEventFrame(b *testing.B) {
+	event := domain.Event{
+		ID: "0123456789abcdef0123456789abcdef", Seq: 42, Type: "info", Topic: "orders.eu.created",
+		Message: strings.Repeat("m", 200), Data: json.RawMessage(`{"order":123}`), Timestamp: time.Now(),
+	}
+	env, err := domain.NewEnvelope(domain.FrameKindEvent, event)
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg, err := json.Marshal(env)
+	if err != nil {
+		b.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		pm, _ := websocket.NewPreparedMessage(websocket.TextMessage, msg)
+		for {
+			if err := c.WritePreparedMessage(pm); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	for i := 0; i < b.N; i++ {
+		buf := bufpool.Get()
+		if err := readMessage(conn, buf); err != nil {
+			b.Fatal(err)
+		}
+		kind, payload, err := domain.DecodeFrame(buf.Bytes())
+		if err != nil || kind != domain.FrameKindEvent {
+			b.Fatalf("decode: %q %v", kind, err)
+		}
+		var ev domain.Event
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			b.Fatal(err)
+		}
+		bufpool.Put(buf)
+	}
+}
+
+// TestReconnectAfterRejectedEvent проверяет, что событие, отклонённое
+// обработчиком BeforeSave, не возвращает клиента к себе при
+// переподключении: он продолжает с последнего сохранённого события.
+func TestReconnectAfterRejectedEvent(t *testing.T) {
+	since := make(chan string, 1)
+	var connections atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if connections.Add(1) > 1 {
+			since <- r.URL.Query().Get("since")
+			return
+		}
+		for seq, id := range []string{"bad", "good"} {
+			env, err := domain.NewEnvelope(domain.FrameKindEvent, domain.Event{ID: id, Type: "t", Seq: uint64(seq + 1)})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := c.WriteJSON(env); err != nil {
+				return
+			}
+		}
+		// Подтверждение «good» означает, что оба события обработаны.
+		for {
+			_, msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if kind, _, _ := domain.DecodeFrame(msg); kind == domain.FrameKindAck {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cs := service.NewClientService(repository.NewMemoryRepository(0), logger)
+	cs.BeforeSave(service.AnyEventType, func(event domain.Event) error {
+		if event.ID == "bad" {
+			return errors.New("invalid payload")
+		}
+		return nil
+	})
+	ct := NewClientTransport("ws"+strings.TrimPrefix(srv.URL, "http"), cs, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ct.Listen(ctx)
+
+	select {
+	case got := <-since:
+		if got != "2" {
+			t.Fatalf("reconnected with since=%q, want 2", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not reconnect")
+	}
+}
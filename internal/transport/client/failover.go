@@ -0,0 +1,66 @@
+package client
+
+import (
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultPrimaryRecheck — период проверки основного сервера при работе через резервный.
+const DefaultPrimaryRecheck = 30 * time.Second
+
+// servers возвращает адреса в порядке предпочтения: основной, затем резервные.
+func (ct *ClientTransport) servers() []string {
+	return append([]string{ct.ServerURL}, ct.FailoverURLs...)
+}
+
+// watchPrimary, пока клиент подключён к резервному серверу, периодически
+// проверяет доступность основного. Когда основной сервер снова принимает
+// TCP-соединения, текущее соединение закрывается, и цикл переподключения,
+// перебирающий адреса с основного, возвращается на него. Проверка не
+// открывает WebSocket-сессию, чтобы сервер не начал рассылку пробному клиенту.
+func (ct *ClientTransport) watchPrimary(conn *websocket.Conn, done <-chan struct{}) {
+	addr, err := dialAddr(ct.ServerURL)
+	if err != nil {
+		return
+	}
+	interval := ct.PrimaryRecheck
+	if interval <= 0 {
+		interval = DefaultPrimaryRecheck
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		probe, err := net.DialTimeout("tcp", addr, 5*time.Second)
+		if err != nil {
+			continue
+		}
+		probe.Close()
+		ct.Logger.Info("Primary server recovered, switching back", "url", ct.ServerURL)
+		conn.Close()
+		return
+	}
+}
+
+// dialAddr возвращает host:port сервера из WebSocket-адреса.
+func dialAddr(serverURL string) (string, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Port() != "" {
+		return u.Host, nil
+	}
+	port := "80"
+	if u.Scheme == "wss" || u.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
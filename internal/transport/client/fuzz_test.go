@@ -0,0 +1,56 @@
+package client
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/service"
+	"github.com/wrongjunior/eventsync/internal/testutil"
+)
+
+// frameSeeds — кадры сервера всех версий протокола, включая пачки и
+// повреждённые кадры.
+var frameSeeds = []string{
+	// ProtocolV1: плоские кадры.
+	`{"id":"1","seq":1,"type":"info","message":"m","timestamp":"2024-01-01T00:00:00Z"}`,
+	`{"kind":"publish_result","id":"p","seq":3}`,
+	`{"kind":"error","error":{"code":"bad_request","message":"m"}}`,
+	// ProtocolV2: кадры в конверте.
+	`{"kind":"event","payload":{"id":"2","seq":2,"type":"info","message":"m","data":{"a":1}}}`,
+	`{"kind":"ping","payload":{"kind":"ping","sent_at":"2024-01-01T00:00:00Z"}}`,
+	`{"kind":"pong","payload":{"kind":"pong","sent_at":"2024-01-01T00:00:00Z","received_at":"2024-01-01T00:00:01Z"}}`,
+	`{"kind":"publish_result","payload":{"kind":"publish_result","id":"p","error":{"code":"forbidden","message":"m"}}}`,
+	// ProtocolV3: пачки.
+	`{"kind":"batch","payload":[{"id":"3","seq":3,"type":"info"},{"id":"4","seq":4,"type":"warning"}]}`,
+	`{"kind":"batch","payload":[{"seq":5},null,7]}`,
+	// Повреждённые кадры.
+	`null`,
+	`{"kind":"event","payload":null}`,
+	`{"kind":"batch","payload":{}}`,
+	`{"type":"info"}`,
+	`{`,
+}
+
+func FuzzHandleMessage(f *testing.F) {
+	for _, seed := range frameSeeds {
+		f.Add([]byte(seed), false)
+		f.Add([]byte(seed), true)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f.Fuzz(func(t *testing.T, message []byte, v1 bool) {
+		repo := testutil.NewRepository()
+		ct := NewClientTransport("ws://localhost/ws", service.NewClientService(repo, logger), logger)
+		ct.protocol = domain.ProtocolV2
+		if v1 {
+			ct.protocol = domain.ProtocolV1
+		}
+		ct.handleMessage(message)
+		for _, call := range repo.Calls() {
+			if call.Method == "Save" && call.Event.ID == "" {
+				t.Fatalf("event without ID saved from %q", message)
+			}
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// heartbeat периодически отправляет серверу прикладной ping и, если ответа
+// нет дольше HeartbeatTimeout, закрывает соединение, чтобы цикл чтения
+// переподключился. Так обнаруживается соединение, через которое не проходят
+// данные, хотя TCP и WebSocket-ping ещё не сообщили об ошибке.
+func (ct *ClientTransport) heartbeat(conn *websocket.Conn, done <-chan struct{}) {
+	ct.lastPong.Store(time.Now().UnixNano())
+	ticker := time.NewTicker(ct.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if ct.HeartbeatTimeout > 0 {
+			if silent := time.Since(time.Unix(0, ct.lastPong.Load())); silent > ct.HeartbeatTimeout {
+				ct.Logger.Warn("Heartbeat timeout, reconnecting", "silent_for", silent)
+				conn.Close()
+				return
+			}
+		}
+		if err := ct.writeFrame(conn, domain.FrameKindPing, domain.NewPingFrame(time.Now())); err != nil {
+			ct.Logger.Debug("Ping write error", "error", err)
+			return
+		}
+	}
+}
+
+// handleHeartbeat отвечает на ping сервера и учитывает измерение по pong.
+func (ct *ClientTransport) handleHeartbeat(kind string, payload []byte) {
+	var frame domain.PingFrame
+	if err := json.Unmarshal(payload, &frame); err != nil {
+		ct.Logger.Error("Malformed heartbeat frame", "kind", kind, "error", err)
+		return
+	}
+	now := time.Now()
+	if kind == domain.FrameKindPing {
+		if err := ct.writeFrame(ct.Conn, domain.FrameKindPong, frame.Pong(now)); err != nil {
+			ct.Logger.Debug("Pong write error", "error", err)
+		}
+		return
+	}
+	ct.lastPong.Store(now.UnixNano())
+	rtt, offset, ok := frame.Measure(now)
+	if !ok {
+		return
+	}
+	ct.Metrics.HeartbeatRTT.Observe(rtt.Seconds())
+	ct.Metrics.ClockOffset.Set(offset.Seconds())
+	ct.Logger.Debug("Heartbeat", "rtt", rtt, "clock_offset", offset)
+}
+
+// writeFrame отправляет служебный кадр в conn без откладывания в Outbox.
+func (ct *ClientTransport) writeFrame(conn *websocket.Conn, kind string, frame any) error {
+	ct.writeMu.Lock()
+	defer ct.writeMu.Unlock()
+	if conn == nil {
+		return errNotConnected
+	}
+	msg, err := ct.encode(kind, frame)
+	if err != nil {
+		return err
+	}
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return conn.WriteMessage(websocket.TextMessage, msg)
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/repository"
+)
+
+// Outbox хранит исходящие сообщения, которые не удалось отправить.
+type Outbox interface {
+	Enqueue(owner string, payload []byte) error
+	Pending(owner string, limit int) ([]repository.OutboxMessage, error)
+	Remove(id int64) error
+}
+
+// errNotConnected возвращается при отправке до установки соединения.
+var errNotConnected = errors.New("not connected")
+
+// outboxFlushBatch — сколько сообщений очереди читается за один запрос.
+const outboxFlushBatch = 100
+
+// send отправляет серверу кадр вида kind. Если транспорт настроен с Outbox, то
+// сообщение, которое не удалось отправить, откладывается в очередь, а перед
+// отправкой новых сообщений очередь досылается, чтобы сохранить порядок.
+// Вызывается под writeMu.
+func (ct *ClientTransport) send(conn *websocket.Conn, kind string, frame any) error {
+	payload, err := ct.encode(kind, frame)
+	if err != nil {
+		return err
+	}
+	if conn == nil {
+		return ct.deferMessage(payload, errNotConnected)
+	}
+	if ct.Outbox != nil && ct.outboxPending {
+		if err := ct.flushOutbox(conn); err != nil {
+			return ct.deferMessage(payload, err)
+		}
+	}
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+		return ct.deferMessage(payload, err)
+	}
+	return nil
+}
+
+// encode кодирует кадр для согласованной версии протокола: в конверте
+// либо, для ProtocolV1, без него. Вызывается под writeMu.
+func (ct *ClientTransport) encode(kind string, frame any) ([]byte, error) {
+	if ct.protocol == domain.ProtocolV1 {
+		return json.Marshal(frame)
+	}
+	env, err := domain.NewEnvelope(kind, frame)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
+
+// deferMessage откладывает сообщение в очередь; без очереди возвращает cause.
+func (ct *ClientTransport) deferMessage(payload []byte, cause error) error {
+	if ct.Outbox == nil {
+		return cause
+	}
+	if err := ct.Outbox.Enqueue(ct.OutboxOwner, payload); err != nil {
+		ct.Logger.Error("Outbox enqueue error", "error", err)
+		return cause
+	}
+	ct.outboxPending = true
+	ct.Logger.Debug("Message deferred to outbox", "cause", cause)
+	return nil
+}
+
+// flushOutbox досылает отложенные сообщения по порядку. Сообщение удаляется
+// из очереди только после успешной записи в соединение. Вызывается под writeMu.
+func (ct *ClientTransport) flushOutbox(conn *websocket.Conn) error {
+	sent := 0
+	for {
+		msgs, err := ct.Outbox.Pending(ct.OutboxOwner, outboxFlushBatch)
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteMessage(websocket.TextMessage, m.Payload); err != nil {
+				return err
+			}
+			if err := ct.Outbox.Remove(m.ID); err != nil {
+				return err
+			}
+			sent++
+		}
+		if len(msgs) < outboxFlushBatch {
+			break
+		}
+	}
+	ct.outboxPending = false
+	if sent > 0 {
+		ct.Logger.Info("Outbox flushed", "messages", sent)
+	}
+	return nil
+}
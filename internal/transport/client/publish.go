@@ -0,0 +1,92 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// Publish отправляет событие серверу через текущее соединение; сервер
+// проверяет его и рассылает остальным подписчикам. При причинном порядке
+// событие помечается векторными часами узла ClientID. Событию без ID
+// присваивается случайный ID, по которому ответ сервера сопоставляется с
+// публикацией (см. OnPublishResult). Без соединения событие откладывается в
+// Outbox, если он задан, иначе возвращается ошибка.
+func (ct *ClientTransport) Publish(event domain.Event) (string, error) {
+	if event.ID == "" {
+		event.ID = newEventID()
+	}
+	if ct.ClientID != "" {
+		event = ct.ClientService.StampCausality(ct.ClientID, event)
+		if event.Source == "" {
+			event.Source = domain.NewSource(domain.SourceClient, ct.ClientID)
+		}
+	}
+	ct.writeMu.Lock()
+	defer ct.writeMu.Unlock()
+	if err := ct.send(ct.Conn, domain.FrameKindPublish, domain.NewPublishFrame(event)); err != nil {
+		return "", err
+	}
+	return event.ID, nil
+}
+
+// Subscribe заменяет шаблоны подписки без переподключения. Новые шаблоны
+// используются и при следующих подключениях.
+func (ct *ClientTransport) Subscribe(topics []string) error {
+	ct.writeMu.Lock()
+	defer ct.writeMu.Unlock()
+	ct.Topics = topics
+	if ct.Conn == nil {
+		return nil
+	}
+	msg, err := ct.encode(domain.FrameKindSubscribe, domain.SubscribeFrame{Kind: domain.FrameKindSubscribe, Topics: topics})
+	if err != nil {
+		return err
+	}
+	ct.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return ct.Conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// handleFrame обрабатывает тело служебного кадра сервера.
+func (ct *ClientTransport) handleFrame(kind string, payload []byte) {
+	switch kind {
+	case domain.FrameKindPublishResult:
+		var result domain.PublishResult
+		if err := json.Unmarshal(payload, &result); err != nil {
+			ct.Logger.Error("Malformed publish result", "error", err)
+			return
+		}
+		if result.Error != nil {
+			ct.Logger.Warn("Publish rejected by server", "id", result.ID, "code", result.Error.Code,
+				"error", result.Error.Message)
+		} else {
+			ct.Logger.Debug("Event published", "id", result.ID, "seq", result.Seq)
+		}
+		if ct.OnPublishResult != nil {
+			ct.OnPublishResult(result)
+		}
+	case domain.FrameKindPing, domain.FrameKindPong:
+		ct.handleHeartbeat(kind, payload)
+	case domain.FrameKindError:
+		var frame domain.ErrorFrame
+		if err := json.Unmarshal(payload, &frame); err != nil {
+			ct.Logger.Error("Malformed error frame", "error", err)
+			return
+		}
+		ct.Logger.Warn("Frame rejected by server", "code", frame.Error.Code, "error", frame.Error.Message)
+	default:
+		ct.Logger.Warn("Unexpected server frame ignored", "kind", kind)
+	}
+}
+
+// newEventID генерирует случайный идентификатор публикуемого события.
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"math/rand"
+	"time"
+)
+
+// ReconnectPolicy задаёт экспоненциальную задержку между попытками переподключения.
+type ReconnectPolicy struct {
+	InitialBackoff time.Duration // задержка перед второй попыткой
+	MaxBackoff     time.Duration // верхняя граница задержки
+	// Jitter — доля случайного разброса задержки (0..1): при 0.2 задержка
+	// выбирается равномерно в диапазоне ±20%, чтобы клиенты не переподключались синхронно.
+	Jitter float64
+	// MaxRetries — максимальное число попыток; 0 — без ограничения.
+	MaxRetries int
+}
+
+// DefaultReconnectPolicy повторяет прежнее поведение: от 1 до 30 секунд, без ограничения попыток.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+}
+
+// ReconnectExhausted описывает исчерпание попыток переподключения.
+type ReconnectExhausted struct {
+	URL       string
+	Attempts  int
+	LastError error
+}
+
+// backoff возвращает задержку после attempt-й неудачной попытки (attempt >= 1).
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	initial, maxBackoff := p.InitialBackoff, p.MaxBackoff
+	if initial <= 0 {
+		initial = DefaultReconnectPolicy.InitialBackoff
+	}
+	if maxBackoff < initial {
+		maxBackoff = initial
+	}
+	d := initial
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxBackoff)
+	if p.Jitter > 0 {
+		spread := float64(d) * min(p.Jitter, 1)
+		d += time.Duration((rand.Float64()*2 - 1) * spread)
+	}
+	return d
+}
+
+// exhausted сообщает, исчерпан ли лимит попыток.
+func (p ReconnectPolicy) exhausted(attempts int) bool {
+	return p.MaxRetries > 0 && attempts >= p.MaxRetries
+}
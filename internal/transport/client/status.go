@@ -0,0 +1,29 @@
+package client
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// reportStatus периодически отправляет серверу состояние синхронизации,
+// пока соединение не разорвано. Отчёты не откладываются в Outbox: после
+// переподключения устаревший отчёт не нужен.
+func (ct *ClientTransport) reportStatus(conn *websocket.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(ct.StatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		status := ct.ClientService.Status()
+		status.ClientID = ct.ClientID
+		if err := ct.writeFrame(conn, domain.FrameKindStatus, status); err != nil {
+			ct.Logger.Debug("Status write error", "error", err)
+			return
+		}
+	}
+}
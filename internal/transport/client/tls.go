@@ -0,0 +1,56 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSOptions задаёт параметры TLS для подключения по wss://.
+type TLSOptions struct {
+	CAFile   string // PEM-файл доверенных сертификатов; пусто — системные
+	CertFile string // клиентский сертификат (PEM) для взаимной аутентификации
+	KeyFile  string // закрытый ключ клиентского сертификата (PEM)
+	// ServerName переопределяет имя сервера для SNI и проверки сертификата.
+	ServerName string
+	// InsecureSkipVerify отключает проверку сертификата сервера. Только для
+	// отладки: соединение становится уязвимым для перехвата.
+	InsecureSkipVerify bool
+}
+
+// NewTLSConfig строит *tls.Config по параметрам. Для нулевых параметров
+// возвращает nil, и используются настройки по умолчанию.
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if opts == (TLSOptions{}) {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("client certificate requires both cert_file and key_file")
+	}
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
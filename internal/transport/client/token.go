@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// TokenSource возвращает актуальный токен доступа. Вызывается перед каждым
+// подключением, поэтому токен может обновляться между переподключениями.
+type TokenSource func() (string, error)
+
+// FileTokenSource читает токен из файла при каждом вызове; пробельные
+// символы по краям отбрасываются.
+func FileTokenSource(path string) TokenSource {
+	return func() (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read token file: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", fmt.Errorf("token file %s is empty", path)
+		}
+		return token, nil
+	}
+}
+
+// tokenCommandTimeout ограничивает время работы команды получения токена.
+const tokenCommandTimeout = 30 * time.Second
+
+// CommandTokenSource запускает команду (argv, без оболочки) при каждом вызове
+// и берёт токен из её стандартного вывода.
+func CommandTokenSource(argv []string) TokenSource {
+	return func() (string, error) {
+		if len(argv) == 0 {
+			return "", errors.New("empty token command")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, argv[0], argv[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("run token command: %w", err)
+		}
+		token := strings.TrimSpace(string(out))
+		if token == "" {
+			return "", errors.New("token command printed an empty token")
+		}
+		return token, nil
+	}
+}
@@ -0,0 +1,218 @@
+// Package local реализует локальный HTTP API клиента: другие процессы на
+// той же машине читают синхронизированные события и состояние клиента, не
+// открывая файл БД напрямую.
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/repository"
+	"github.com/wrongjunior/eventsync/internal/service"
+	"github.com/wrongjunior/eventsync/internal/version"
+	"log/slog"
+)
+
+const (
+	// DefaultLimit — число событий в ответе GET /events по умолчанию.
+	DefaultLimit = 100
+	// MaxLimit — наибольшее число событий в ответе GET /events.
+	MaxLimit = 10000
+)
+
+// Instance — клиент, данные которого отдаёт API.
+type Instance struct {
+	ID      string // имя клиента, например "client-1"
+	Service *service.ClientService
+	// Connected сообщает, подключён ли клиент к серверу; nil — неизвестно.
+	Connected func() bool
+}
+
+// InstanceStatus — состояние синхронизации клиента в ответе GET /status.
+type InstanceStatus struct {
+	domain.ClientStatus
+	Connected bool `json:"connected"`
+}
+
+// API отдаёт события и состояние клиентов:
+//
+//	GET /events — события из хранилища, параметры как у команды query;
+//	GET /status — состояние синхронизации каждого клиента;
+//	GET /health — доступность хранилища и число подключённых клиентов;
+//	GET /version — сведения о сборке клиента.
+type API struct {
+	instances []Instance
+	logger    *slog.Logger
+	mux       *http.ServeMux
+}
+
+// New создаёт API для клиентов instances; события по умолчанию читаются из
+// хранилища первого клиента.
+func New(instances []Instance, logger *slog.Logger) *API {
+	a := &API{instances: instances, logger: logger, mux: http.NewServeMux()}
+	a.mux.HandleFunc("GET /events", a.events)
+	a.mux.HandleFunc("GET /status", a.status)
+	a.mux.HandleFunc("GET /health", a.health)
+	a.mux.HandleFunc("GET /version", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, version.Get())
+	})
+	return a
+}
+
+// ServeHTTP реализует http.Handler.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+// Serve принимает запросы на addr до ошибки сервера. Адрес вне loopback
+// допускается, но API не проверяет подлинность запросов.
+func (a *API) Serve(addr string) error {
+	if host, _, err := net.SplitHostPort(addr); err == nil && !isLoopback(host) {
+		a.logger.Warn("Local API is exposed beyond localhost and has no authentication", "addr", addr)
+	}
+	a.logger.Info("Serving local API", "addr", addr)
+	return http.ListenAndServe(addr, a)
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// events отдаёт события хранилища клиента "client" (по умолчанию первого)
+// по фильтрам "type", "since", "until" (RFC 3339 или длительность назад,
+// например "1h"), "contains", "source" и "limit".
+func (a *API) events(w http.ResponseWriter, r *http.Request) {
+	inst, ok := a.instance(r.URL.Query().Get("client"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown client")
+		return
+	}
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := filter.Limit
+	filter.Limit++
+	events, err := inst.Service.Query(filter)
+	if err != nil {
+		a.logger.Error("Local API query error", "error", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	page := domain.EventPage{Events: events, More: len(events) > limit}
+	if page.More {
+		page.Events = events[:limit]
+	}
+	if page.Events == nil {
+		page.Events = []domain.Event{}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseFilter разбирает параметры выборки событий.
+func parseFilter(r *http.Request) (repository.EventFilter, error) {
+	q := r.URL.Query()
+	filter := repository.EventFilter{Contains: q.Get("contains"), Source: q.Get("source"), Limit: DefaultLimit}
+	if v := q.Get("type"); v != "" {
+		filter.Types = strings.Split(v, ",")
+	}
+	var err error
+	if filter.Since, err = parseTime(q.Get("since")); err != nil {
+		return filter, errors.New("invalid since: want RFC 3339 time or duration")
+	}
+	if filter.Until, err = parseTime(q.Get("until")); err != nil {
+		return filter, errors.New("invalid until: want RFC 3339 time or duration")
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 {
+			return filter, errors.New("invalid limit")
+		}
+		filter.Limit = min(filter.Limit, MaxLimit)
+	}
+	return filter, nil
+}
+
+// parseTime принимает время RFC 3339 или длительность, отсчитываемую назад
+// от текущего момента.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// status отдаёт состояние синхронизации каждого клиента.
+func (a *API) status(w http.ResponseWriter, r *http.Request) {
+	statuses := make([]InstanceStatus, 0, len(a.instances))
+	for _, inst := range a.instances {
+		st := InstanceStatus{ClientStatus: inst.Service.Status()}
+		st.ClientID = inst.ID
+		if inst.Connected != nil {
+			st.Connected = inst.Connected()
+		}
+		statuses = append(statuses, st)
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// health проверяет чтение из хранилищ клиентов: 200, если все доступны,
+// иначе 503. Отключение от сервера не делает API нездоровым — данные
+// остаются доступны для чтения.
+func (a *API) health(w http.ResponseWriter, r *http.Request) {
+	connected := 0
+	checked := make(map[*service.ClientService]bool)
+	for _, inst := range a.instances {
+		if inst.Connected != nil && inst.Connected() {
+			connected++
+		}
+		if checked[inst.Service] {
+			continue
+		}
+		checked[inst.Service] = true
+		if _, err := inst.Service.Query(repository.EventFilter{Limit: 1}); err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "unavailable", "error": err.Error()})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "clients": len(a.instances), "connected": connected})
+}
+
+// instance возвращает клиента по имени; пустое имя — первый клиент.
+func (a *API) instance(id string) (Instance, bool) {
+	if len(a.instances) == 0 {
+		return Instance{}, false
+	}
+	if id == "" {
+		return a.instances[0], true
+	}
+	for _, inst := range a.instances {
+		if inst.ID == id {
+			return inst, true
+		}
+	}
+	return Instance{}, false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
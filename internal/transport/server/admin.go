@@ -0,0 +1,442 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wrongjunior/eventsync/internal/audit"
+	"github.com/wrongjunior/eventsync/internal/auth"
+	"github.com/wrongjunior/eventsync/internal/crdt"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/guard"
+	"github.com/wrongjunior/eventsync/internal/history"
+	"github.com/wrongjunior/eventsync/internal/quota"
+	"github.com/wrongjunior/eventsync/internal/schedule"
+	"github.com/wrongjunior/eventsync/internal/schema"
+	eservice "github.com/wrongjunior/eventsync/internal/service"
+	"log/slog"
+)
+
+// AdminHandler реализует административный API: управление ключами, квоты,
+// схемы событий, состояние подключённых клиентов и журнал подключений.
+type AdminHandler struct {
+	Events     *eservice.EventService
+	Keys       auth.KeyStore
+	Quotas     *quota.Manager
+	Schemas    *schema.Registry
+	Quarantine *schema.Quarantine
+	Schedule   *schedule.Queue
+	History    history.ServerEventStore
+	Audit      *audit.Log
+	Guard      *guard.Guard
+	Logger     *slog.Logger
+}
+
+type createKeyRequest struct {
+	Tenant string   `json:"tenant"`
+	Scopes []string `json:"scopes"`
+}
+
+type createKeyResponse struct {
+	auth.APIKey
+	Secret string `json:"secret"`
+}
+
+// Routes регистрирует маршруты административного API.
+func (h *AdminHandler) Routes(r chi.Router) {
+	if h.Keys != nil {
+		r.Get("/keys", h.listKeys)
+		r.Post("/keys", h.createKey)
+		r.Delete("/keys/{id}", h.revokeKey)
+	}
+	if h.Quotas != nil {
+		r.Get("/quotas", h.listQuotas)
+		r.Get("/quotas/{tenant}", h.getQuota)
+	}
+	if h.Schemas != nil {
+		r.Get("/schemas", h.listSchemas)
+		r.Get("/schemas/{type}", h.getSchema)
+		r.Get("/schemas/{type}/versions/{version}", h.getSchema)
+		r.Put("/schemas/{type}", h.putSchema)
+		r.Put("/schemas/{type}/versions/{version}", h.putSchema)
+	}
+	if h.Quarantine != nil {
+		r.Get("/quarantine", h.listQuarantine)
+	}
+	if h.Schedule != nil {
+		r.Get("/scheduled", h.listScheduled)
+		r.Delete("/scheduled/{id}", h.cancelScheduled)
+	}
+	if h.History != nil && h.Events != nil {
+		r.Post("/replay", h.replayEvents)
+	}
+	if h.Audit != nil {
+		r.Get("/audit", h.listAudit)
+	}
+	if h.Guard != nil {
+		r.Get("/bans", h.listBans)
+		r.Delete("/bans/{addr}", h.unban)
+	}
+	if h.Events != nil {
+		r.Get("/clients", h.listClients)
+		r.Get("/crdt", h.listCRDT)
+		r.Get("/crdt/{object}", h.getCRDT)
+		r.Get("/subscriptions", h.listSubscriptions)
+		r.Delete("/subscriptions/{name}", h.deleteSubscription)
+	}
+}
+
+// requestNamespace возвращает пространство имён запроса к состоянию CRDT,
+// отложенным событиям или долговременным подпискам: ключ тенанта видит только своё, глобальный
+// администратор выбирает параметром namespace. Если параметр расходится с
+// тенантом ключа, отвечает 403 и возвращает false.
+func requestNamespace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	namespace, err := resolveNamespace(r, principal)
+	if err != nil {
+		writeNamespaceError(w, err)
+		return "", false
+	}
+	return namespace, true
+}
+
+// listCRDT возвращает материализованное состояние всех объектов CRDT.
+func (h *AdminHandler) listCRDT(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := requestNamespace(w, r)
+	if !ok {
+		return
+	}
+	store := h.Events.CRDTStore(namespace)
+	if store == nil {
+		writeJSON(w, http.StatusOK, []crdt.State{})
+		return
+	}
+	writeJSON(w, http.StatusOK, store.States())
+}
+
+// getCRDT возвращает материализованное состояние одного объекта CRDT.
+func (h *AdminHandler) getCRDT(w http.ResponseWriter, r *http.Request) {
+	namespace, ok := requestNamespace(w, r)
+	if !ok {
+		return
+	}
+	store := h.Events.CRDTStore(namespace)
+	if store == nil {
+		writeError(w, http.StatusNotFound, "not_found", "crdt object not found")
+		return
+	}
+	state, ok := store.State(chi.URLParam(r, "object"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "crdt object not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// listAudit возвращает записи журнала подключений с фильтрами kind,
+// client_id, since (RFC 3339) и limit; ключ тенанта видит только записи
+// своего пространства имён.
+func (h *AdminHandler) listAudit(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	q := r.URL.Query()
+	filter := audit.Filter{Kind: audit.Kind(q.Get("kind"))}
+	// Без параметра глобальный администратор видит все пространства имён.
+	if principal.Tenant != "" || q.Get("namespace") != "" {
+		var ok bool
+		if filter.Namespace, ok = requestNamespace(w, r); !ok {
+			return
+		}
+	}
+	var err error
+	if v := q.Get("client_id"); v != "" {
+		if filter.ClientID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid client_id")
+			return
+		}
+	}
+	if v := q.Get("since"); v != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid since")
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 0 {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid limit")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, h.Audit.List(filter))
+}
+
+// listBans возвращает действующие блокировки адресов. Блокировки общие для
+// всех тенантов, поэтому доступны только глобальному администратору.
+func (h *AdminHandler) listBans(w http.ResponseWriter, r *http.Request) {
+	if principal, _ := auth.PrincipalFromContext(r.Context()); principal.Tenant != "" {
+		writeError(w, http.StatusForbidden, "forbidden", "bans are managed by the global administrator")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.Guard.Bans())
+}
+
+// unban снимает временную блокировку адреса.
+func (h *AdminHandler) unban(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	if principal.Tenant != "" {
+		writeError(w, http.StatusForbidden, "forbidden", "bans are managed by the global administrator")
+		return
+	}
+	addr := chi.URLParam(r, "addr")
+	if !h.Guard.Unban(addr) {
+		writeError(w, http.StatusNotFound, "not_found", "address is not banned")
+		return
+	}
+	h.Logger.Info("Address unbanned", "addr", addr, "by", principal.KeyID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listClients возвращает состояние синхронизации подключённых клиентов;
+// ключ тенанта видит только клиентов своего пространства имён.
+func (h *AdminHandler) listClients(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	writeJSON(w, http.StatusOK, h.Events.Clients(principal.Tenant))
+}
+
+func (h *AdminHandler) listSchemas(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.Schemas.Types())
+}
+
+// getSchema возвращает схему указанной версии или последнюю, если версия не задана.
+func (h *AdminHandler) getSchema(w http.ResponseWriter, r *http.Request) {
+	version := 0
+	if v := chi.URLParam(r, "version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid schema version")
+			return
+		}
+		version = n
+	}
+	raw, ok := h.Schemas.Raw(chi.URLParam(r, "type"), version)
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "schema not registered")
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(raw)
+}
+
+// putSchema регистрирует новую версию схемы типа. Схемы общие для всех тенантов, поэтому
+// изменять их может только администратор без привязки к тенанту. С номером
+// версии в пути повторная регистрация той же версии отклоняется с 409, а не
+// добавляет ещё одну.
+func (h *AdminHandler) putSchema(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	if principal.Tenant != "" {
+		writeError(w, http.StatusForbidden, "forbidden", "schemas are managed by the global administrator")
+		return
+	}
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	eventType := chi.URLParam(r, "type")
+	var version int
+	if v := chi.URLParam(r, "version"); v != "" {
+		if version, err = strconv.Atoi(v); err != nil || version < 1 {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid schema version")
+			return
+		}
+		err = h.Schemas.RegisterVersion(eventType, version, raw)
+	} else {
+		version, err = h.Schemas.Register(eventType, raw)
+	}
+	switch {
+	case errors.Is(err, schema.ErrVersionConflict):
+		writeError(w, http.StatusConflict, "version_conflict", err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, "invalid_schema", err.Error())
+		return
+	}
+	h.Logger.Info("Event schema registered", "type", eventType, "version", version, "by", principal.KeyID)
+	writeJSON(w, http.StatusCreated, map[string]any{"type": eventType, "version": version})
+}
+
+func (h *AdminHandler) listQuarantine(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	writeJSON(w, http.StatusOK, h.Quarantine.List(principal.Tenant))
+}
+
+func (h *AdminHandler) listScheduled(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	events, err := h.Schedule.List(principal.Tenant)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// cancelScheduled отменяет отложенное событие пространства имён ключа;
+// глобальный администратор выбирает его параметром namespace.
+func (h *AdminHandler) cancelScheduled(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+	namespace, ok := requestNamespace(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.Schedule.Cancel(namespace, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "not_found", "scheduled event not found")
+		return
+	}
+	h.Logger.Info("Scheduled event cancelled", "id", id, "by", principal.KeyID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	subs, err := h.Events.DurableSubscriptions(principal.Tenant)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, subs)
+}
+
+// deleteSubscription удаляет отключённую долговременную подписку
+// пространства имён ключа вместе с журналом её событий.
+func (h *AdminHandler) deleteSubscription(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	name := chi.URLParam(r, "name")
+	namespace, ok := requestNamespace(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.Events.DeleteDurable(namespace, name)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "not_found", "subscription not found")
+		return
+	}
+	h.Logger.Info("Durable subscription deleted", "name", name, "by", principal.KeyID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) listQuotas(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	if principal.Tenant != "" {
+		writeJSON(w, http.StatusOK, []quota.Usage{h.Quotas.Usage(principal.Tenant)})
+		return
+	}
+	writeJSON(w, http.StatusOK, h.Quotas.AllUsage())
+}
+
+func (h *AdminHandler) getQuota(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	tenant := chi.URLParam(r, "tenant")
+	if !principal.CanAccessTenant(tenant) {
+		writeError(w, http.StatusForbidden, "forbidden", "cannot view quotas of another tenant")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.Quotas.Usage(tenant))
+}
+
+func (h *AdminHandler) listKeys(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	keys, err := h.Keys.List(principal.Tenant)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	for i := range keys {
+		keys[i] = keys[i].Redacted()
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (h *AdminHandler) createKey(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	var req createKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	if req.Tenant == "" {
+		req.Tenant = principal.Tenant
+	}
+	if err := domain.ValidateNamespace(req.Tenant); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	if !principal.CanAccessTenant(req.Tenant) {
+		writeError(w, http.StatusForbidden, "forbidden", "cannot manage keys of another tenant")
+		return
+	}
+	scopes := make([]auth.Scope, 0, len(req.Scopes))
+	for _, s := range req.Scopes {
+		scope, err := auth.ParseScope(s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", err.Error()+": "+s)
+			return
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		writeError(w, http.StatusBadRequest, "bad_request", "at least one scope is required")
+		return
+	}
+	key, secret, err := h.Keys.Create(req.Tenant, scopes)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	h.Logger.Info("API key created", "key_id", key.ID, "tenant", key.Tenant, "by", principal.KeyID)
+	writeJSON(w, http.StatusCreated, createKeyResponse{APIKey: key.Redacted(), Secret: secret})
+}
+
+func (h *AdminHandler) revokeKey(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	id := chi.URLParam(r, "id")
+	keys, err := h.Keys.List(principal.Tenant)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	found := false
+	for _, k := range keys {
+		if k.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "not_found", auth.ErrKeyNotFound.Error())
+		return
+	}
+	if err := h.Keys.Revoke(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, "revoke_failed", err.Error())
+		return
+	}
+	h.Logger.Info("API key revoked", "key_id", id, "by", principal.KeyID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/wrongjunior/eventsync/internal/apidoc"
+	"github.com/wrongjunior/eventsync/internal/audit"
+	"github.com/wrongjunior/eventsync/internal/auth"
+	"github.com/wrongjunior/eventsync/internal/crdt"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/guard"
+	"github.com/wrongjunior/eventsync/internal/quota"
+	"github.com/wrongjunior/eventsync/internal/schema"
+	eservice "github.com/wrongjunior/eventsync/internal/service"
+	"github.com/wrongjunior/eventsync/internal/version"
+)
+
+// Параметры запросов, общие для нескольких маршрутов.
+var (
+	namespaceParam = apidoc.Param{Name: "namespace", In: "query", Description: "namespace; fixed by a tenant-bound key"}
+	topicsParam    = apidoc.Param{Name: "topics", In: "query", Description: "comma-separated topic patterns"}
+	typesParam     = apidoc.Param{Name: "types", In: "query", Description: "comma-separated event types"}
+	limitParam     = apidoc.Param{Name: "limit", In: "query", Type: "integer"}
+	formatParam    = apidoc.Param{Name: "format", In: "query", Description: `event format: "json" (default) or "cloudevents"`}
+	wsFormatParam  = apidoc.Param{Name: "format", In: "query", Description: `event format: "json" (default), "cloudevents", "protobuf" or "avro"`}
+	replayParams   = []apidoc.Param{
+		namespaceParam, topicsParam, typesParam, limitParam,
+		{Name: "from_seq", In: "query", Type: "integer", Description: "first sequence number, inclusive"},
+		{Name: "to_seq", In: "query", Type: "integer", Description: "last sequence number, inclusive"},
+		{Name: "from", In: "query", Description: "RFC 3339 start time, inclusive"},
+		{Name: "to", In: "query", Description: "RFC 3339 end time, exclusive"},
+		{Name: "speed", In: "query", Type: "number", Description: "playback speed; 0 — no pauses"},
+		{Name: "max_gap", In: "query", Description: "longest pause, Go duration"},
+	}
+)
+
+// operationDocs описывает маршруты HTTP API по ключу "<метод> <шаблон>".
+// В документ попадают только маршруты, действительно зарегистрированные
+// с текущей конфигурацией.
+func operationDocs(wsPath string) map[string]apidoc.Operation {
+	ok := func(body reflect.Type) []apidoc.Response {
+		return []apidoc.Response{{Status: http.StatusOK, Body: body}}
+	}
+	noContent := []apidoc.Response{{Status: http.StatusNoContent}}
+	schemaRegistered := []apidoc.Response{{Status: http.StatusCreated, Body: reflect.TypeOf(struct {
+		Type    string `json:"type"`
+		Version int    `json:"version"`
+	}{})}}
+	subscribe, publish, admin := string(auth.ScopeSubscribe), string(auth.ScopePublish), string(auth.ScopeAdmin)
+	ops := []apidoc.Operation{
+		{Method: "GET", Path: wsPath, Scope: subscribe,
+			Summary: "WebSocket subscription; frames are described by /schema?format=asyncapi",
+			Params:  wsParams(),
+			Responses: []apidoc.Response{{Status: http.StatusSwitchingProtocols,
+				Description: "Switching to WebSocket"}}},
+		{Method: "POST", Path: "/events", Scope: publish,
+			Summary:   "Publish an event; CloudEvents 1.0 are accepted in structured (application/cloudevents+json) and binary (ce-* headers) mode, protobuf as application/x-protobuf, Avro single-object encoding as avro/binary",
+			Body:      reflect.TypeOf(domain.Event{}),
+			Responses: []apidoc.Response{{Status: http.StatusAccepted, Body: reflect.TypeOf(domain.Event{})}}},
+		{Method: "GET", Path: "/events", Scope: subscribe, Summary: "Page of events after a sequence number",
+			Params: []apidoc.Param{
+				{Name: "since", In: "query", Type: "integer", Required: true, Description: "last sequence number already received"},
+				namespaceParam, topicsParam, typesParam, limitParam, formatParam,
+			},
+			Responses: ok(reflect.TypeOf(domain.EventPage{}))},
+		{Method: "GET", Path: "/replay", Scope: subscribe, Summary: "Stream history events as NDJSON at the recorded pace",
+			Params: append(replayParams, formatParam),
+			Responses: []apidoc.Response{{Status: http.StatusOK, Media: "application/x-ndjson",
+				Description: "One event per line"}}},
+		{Method: "GET", Path: "/version", Summary: "Build information", Responses: ok(reflect.TypeOf(version.Info{}))},
+		{Method: "GET", Path: "/schema", Summary: "This protocol description",
+			Params:    []apidoc.Param{{Name: "format", In: "query", Description: `"openapi" (default) or "asyncapi"`}},
+			Responses: ok(nil)},
+
+		{Method: "GET", Path: "/admin/keys", Scope: admin, Summary: "List API keys", Responses: ok(reflect.TypeOf([]auth.APIKey(nil)))},
+		{Method: "POST", Path: "/admin/keys", Scope: admin, Summary: "Create an API key",
+			Body:      reflect.TypeOf(createKeyRequest{}),
+			Responses: []apidoc.Response{{Status: http.StatusCreated, Body: reflect.TypeOf(createKeyResponse{})}}},
+		{Method: "DELETE", Path: "/admin/keys/{id}", Scope: admin, Summary: "Revoke an API key", Responses: noContent},
+		{Method: "GET", Path: "/admin/quotas", Scope: admin, Summary: "Quota usage of all tenants", Responses: ok(reflect.TypeOf([]quota.Usage(nil)))},
+		{Method: "GET", Path: "/admin/quotas/{tenant}", Scope: admin, Summary: "Quota usage of a tenant", Responses: ok(reflect.TypeOf(quota.Usage{}))},
+		{Method: "GET", Path: "/admin/schemas", Scope: admin, Summary: "Registered schema versions by event type",
+			Responses: ok(reflect.TypeOf(map[string][]int(nil)))},
+		{Method: "GET", Path: "/admin/schemas/{type}", Scope: admin, Summary: "Latest JSON Schema of an event type",
+			Responses: []apidoc.Response{{Status: http.StatusOK, Media: "application/schema+json"}}},
+		{Method: "GET", Path: "/admin/schemas/{type}/versions/{version}", Scope: admin, Summary: "JSON Schema of an event type version",
+			Responses: []apidoc.Response{{Status: http.StatusOK, Media: "application/schema+json"}}},
+		{Method: "PUT", Path: "/admin/schemas/{type}", Scope: admin, Summary: "Register a new JSON Schema version",
+			Body: reflect.TypeOf(json.RawMessage(nil)), BodyMedia: "application/schema+json", Responses: schemaRegistered},
+		{Method: "PUT", Path: "/admin/schemas/{type}/versions/{version}", Scope: admin, Summary: "Register a JSON Schema as the given next version",
+			Body: reflect.TypeOf(json.RawMessage(nil)), BodyMedia: "application/schema+json",
+			Responses: append(schemaRegistered[:1:1], apidoc.Response{Status: http.StatusConflict, Description: "Version already registered"})},
+		{Method: "GET", Path: "/admin/quarantine", Scope: admin, Summary: "Events rejected by schema validation",
+			Responses: ok(reflect.TypeOf([]schema.QuarantinedEvent(nil)))},
+		{Method: "GET", Path: "/admin/scheduled", Scope: admin, Summary: "Events waiting for delayed delivery",
+			Responses: ok(reflect.TypeOf([]domain.Event(nil)))},
+		{Method: "DELETE", Path: "/admin/scheduled/{id}", Scope: admin, Summary: "Cancel a delayed event",
+			Params: []apidoc.Param{namespaceParam}, Responses: noContent},
+		{Method: "POST", Path: "/admin/replay", Scope: admin, Summary: "Rebroadcast history events to subscribers",
+			Params: replayParams,
+			Responses: []apidoc.Response{{Status: http.StatusAccepted, Body: reflect.TypeOf(struct {
+				Namespace string `json:"namespace"`
+				Events    int    `json:"events"`
+			}{})}}},
+		{Method: "GET", Path: "/admin/audit", Scope: admin, Summary: "Connection audit log",
+			Params: []apidoc.Param{
+				namespaceParam, limitParam,
+				{Name: "kind", In: "query"},
+				{Name: "client_id", In: "query", Type: "integer"},
+				{Name: "since", In: "query", Description: "RFC 3339 time"},
+			},
+			Responses: ok(reflect.TypeOf([]audit.Entry(nil)))},
+		{Method: "GET", Path: "/admin/bans", Scope: admin, Summary: "Banned addresses", Responses: ok(reflect.TypeOf([]guard.Ban(nil)))},
+		{Method: "DELETE", Path: "/admin/bans/{addr}", Scope: admin, Summary: "Lift a ban", Responses: noContent},
+		{Method: "GET", Path: "/admin/clients", Scope: admin, Summary: "Connected clients", Responses: ok(reflect.TypeOf([]eservice.ClientInfo(nil)))},
+		{Method: "GET", Path: "/admin/crdt", Scope: admin, Summary: "CRDT objects",
+			Params: []apidoc.Param{namespaceParam}, Responses: ok(reflect.TypeOf([]crdt.State(nil)))},
+		{Method: "GET", Path: "/admin/crdt/{object}", Scope: admin, Summary: "CRDT object state",
+			Params: []apidoc.Param{namespaceParam}, Responses: ok(reflect.TypeOf(crdt.State{}))},
+		{Method: "GET", Path: "/admin/subscriptions", Scope: admin, Summary: "Durable subscriptions",
+			Responses: ok(reflect.TypeOf([]eservice.DurableSubscription(nil)))},
+		{Method: "DELETE", Path: "/admin/subscriptions/{name}", Scope: admin, Summary: "Delete a disconnected durable subscription",
+			Params: []apidoc.Param{namespaceParam}, Responses: noContent},
+	}
+	docs := make(map[string]apidoc.Operation, len(ops))
+	for _, op := range ops {
+		docs[op.Method+" "+op.Path] = op
+	}
+	return docs
+}
+
+// wsParams — параметры запроса подключения WebSocket.
+func wsParams() []apidoc.Param {
+	return []apidoc.Param{
+		namespaceParam, topicsParam, typesParam, wsFormatParam,
+		{Name: "since", In: "query", Type: "integer", Description: "resume after this sequence number"},
+		{Name: "sync", In: "query", Description: `"snapshot" — latest event of each type and topic first`},
+		{Name: "min_severity", In: "query", Description: "debug, info, warning, error or critical"},
+		{Name: "aggregate", In: "query", Description: "summary window, Go duration"},
+		{Name: "aggregate_only", In: "query", Type: "boolean"},
+		{Name: "group", In: "query", Description: "consumer group"},
+		{Name: "group_strategy", In: "query", Description: `"round_robin" or "key_hash"`},
+		{Name: "subscription", In: "query", Description: "durable subscription name"},
+		{Name: "schema_versions", In: "query", Description: `highest understood versions, e.g. "info:1,order:2"`},
+		{Name: "api_key", In: "query", Description: "API key for clients that cannot set headers"},
+	}
+}
+
+// wsChannel описывает кадры соединения WebSocket.
+func wsChannel(wsPath string) apidoc.Channel {
+	return apidoc.Channel{
+		Path: wsPath,
+		Description: "Protocol versions are negotiated with Sec-WebSocket-Protocol. " +
+			domain.ProtocolV1 + " frames are flat objects: a frame without kind is an event. " +
+			domain.ProtocolV2 + " and later wrap every frame in an Envelope {kind, payload}; " +
+			domain.ProtocolV3 + " adds batch frames. With format=cloudevents events are CloudEvents 1.0 " +
+			"in structured mode; publish frames accept either form. With format=protobuf events arrive as binary frames " +
+			"carrying an eventsync.v1.Frame message (proto/eventsync/v1/event.proto), with format=avro as binary " +
+			"frames holding an Avro single-object encoded array of events; other frames stay JSON.",
+		Scope:        string(auth.ScopeSubscribe),
+		Query:        wsParams(),
+		Subprotocols: domain.SupportedProtocols,
+		Receive: []apidoc.Message{
+			{Name: domain.FrameKindEvent, Summary: "Event", Payload: reflect.TypeOf(domain.Event{})},
+			{Name: domain.FrameKindBatch, Summary: "Several queued events (" + domain.ProtocolV3 + ")", Payload: reflect.TypeOf([]domain.Event(nil))},
+			{Name: domain.FrameKindPublishResult, Summary: "Result of a publish frame", Payload: reflect.TypeOf(domain.PublishResult{})},
+			{Name: domain.FrameKindPong, Summary: "Reply to a ping", Payload: reflect.TypeOf(domain.PingFrame{})},
+			{Name: domain.FrameKindError, Summary: "Frame could not be processed", Payload: reflect.TypeOf(domain.ErrorFrame{})},
+		},
+		Send: []apidoc.Message{
+			{Name: domain.FrameKindAck, Summary: "Acknowledge stored events", Payload: reflect.TypeOf(domain.Ack{})},
+			{Name: domain.FrameKindStatus, Summary: "Client synchronization status", Payload: reflect.TypeOf(domain.ClientStatus{})},
+			{Name: domain.FrameKindPublish, Summary: "Publish an event", Payload: reflect.TypeOf(domain.PublishFrame{})},
+			{Name: domain.FrameKindSubscribe, Summary: "Replace topic patterns", Payload: reflect.TypeOf(domain.SubscribeFrame{})},
+			{Name: domain.FrameKindResume, Summary: "Resume after a sequence number", Payload: reflect.TypeOf(domain.ResumeFrame{})},
+			{Name: domain.FrameKindPing, Summary: "Application heartbeat", Payload: reflect.TypeOf(domain.PingFrame{})},
+		},
+	}
+}
+
+// protocolDocs отдаёт описание протокола на GET /schema. Документы
+// строятся один раз по маршрутам готового роутера.
+type protocolDocs struct {
+	openAPI  []byte
+	asyncAPI []byte
+}
+
+// build собирает документы по маршрутам router. Маршруты без описания
+// попадают в документ только с методом и путём.
+func (d *protocolDocs) build(router chi.Routes, wsPath string) error {
+	info := apidoc.Info{
+		Title:       "eventsync",
+		Version:     version.Get().Version,
+		Description: "Event synchronization server",
+	}
+	docs := operationDocs(wsPath)
+	var ops []apidoc.Operation
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		op, ok := docs[method+" "+route]
+		if !ok {
+			op = apidoc.Operation{Method: method, Path: route, Responses: []apidoc.Response{{Status: http.StatusOK}}}
+		}
+		ops = append(ops, op)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if d.openAPI, err = json.Marshal(apidoc.OpenAPI(info, ops, reflect.TypeOf(errorBody{}), apidoc.NewSchemas())); err != nil {
+		return err
+	}
+	d.asyncAPI, err = json.Marshal(apidoc.AsyncAPI(info, wsChannel(wsPath), apidoc.NewSchemas()))
+	return err
+}
+
+// serve отдаёт документ OpenAPI, а с format=asyncapi — AsyncAPI.
+func (d *protocolDocs) serve(w http.ResponseWriter, r *http.Request) {
+	var doc []byte
+	switch r.URL.Query().Get("format") {
+	case "", "openapi":
+		doc = d.openAPI
+	case "asyncapi":
+		doc = d.asyncAPI
+	default:
+		writeError(w, http.StatusBadRequest, "bad_request", `format must be "openapi" or "asyncapi"`)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(doc)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/wrongjunior/eventsync/internal/audit"
+	"github.com/wrongjunior/eventsync/internal/auth"
+	"github.com/wrongjunior/eventsync/internal/guard"
+)
+
+// allScopes выдаётся bootstrap-ключу администратора.
+var allScopes = []auth.Scope{auth.ScopePublish, auth.ScopeSubscribe, auth.ScopeAdmin}
+
+// openScopes выдаётся подключениям при выключенной аутентификации:
+// административный API без неё закрыт.
+var openScopes = []auth.Scope{auth.ScopePublish, auth.ScopeSubscribe}
+
+// Authenticator проверяет API-ключи и JWT запросов и области доступа.
+// Если не задано ни хранилище ключей, ни AdminKey, ни JWT, аутентификация
+// выключена: открыты все маршруты, кроме требующих области admin.
+type Authenticator struct {
+	Keys     auth.KeyStore
+	AdminKey string // статический ключ администратора всех тенантов
+	// JWT проверяет токены, переданные вместо API-ключа; nil — JWT не принимаются.
+	JWT *auth.JWTVerifier
+	// OIDC проверяет токены операторов от провайдера OpenID Connect раньше
+	// остальных способов; nil — не принимаются.
+	OIDC *auth.OIDC
+	// Audit — журнал, в который пишутся отказы в доступе; nil — не ведётся.
+	Audit *audit.Log
+	// Guard учитывает неудачные попытки аутентификации по адресам; nil — не учитываются.
+	Guard *guard.Guard
+}
+
+// Enabled сообщает, включена ли аутентификация.
+func (a *Authenticator) Enabled() bool {
+	return a.Keys != nil || a.AdminKey != "" || a.JWT != nil || a.OIDC != nil
+}
+
+// authenticate определяет субъекта запроса по ключу, JWT или токену OIDC из заголовков или параметра api_key.
+func (a *Authenticator) authenticate(r *http.Request) (auth.Principal, error) {
+	if !a.Enabled() {
+		return auth.Principal{Scopes: openScopes}, nil
+	}
+	secret := extractAPIKey(r)
+	if secret == "" {
+		return auth.Principal{}, auth.ErrUnauthenticated
+	}
+	if a.AdminKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(a.AdminKey)) == 1 {
+		return auth.Principal{KeyID: "admin", Scopes: allScopes}, nil
+	}
+	if a.OIDC != nil {
+		principal, err := a.OIDC.Verify(r.Context(), secret)
+		// Не принятый провайдером токен может оказаться API-ключом или JWT сервиса.
+		if err == nil || !errors.Is(err, auth.ErrUnauthenticated) || (a.Keys == nil && a.JWT == nil) {
+			return principal, err
+		}
+	}
+	if a.JWT != nil && auth.LooksLikeJWT(secret) {
+		return a.JWT.Verify(secret)
+	}
+	if a.Keys == nil {
+		return auth.Principal{}, auth.ErrUnauthenticated
+	}
+	key, err := a.Keys.Authenticate(secret)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	return key.Principal(), nil
+}
+
+// Require возвращает middleware, пропускающее только запросы с указанной областью доступа.
+func (a *Authenticator) Require(scope auth.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := a.authenticate(r)
+			if err != nil {
+				status := http.StatusUnauthorized
+				if !errors.Is(err, auth.ErrUnauthenticated) {
+					status = http.StatusInternalServerError
+				} else {
+					a.Audit.Record(connectionEntry(r, audit.KindAuthFailure, "", "", err.Error()))
+					failAttempt(a.Guard, r, err.Error())
+				}
+				writeError(w, status, "unauthenticated", err.Error())
+				return
+			}
+			if !principal.Has(scope) {
+				a.Audit.Record(connectionEntry(r, audit.KindAuthFailure, principal.KeyID, principal.Tenant, "missing scope "+string(scope)))
+				writeError(w, http.StatusForbidden, "forbidden", auth.ErrForbidden.Error()+": "+string(scope))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// extractAPIKey ищет ключ в Authorization: Bearer, X-API-Key или параметре api_key
+// (браузерные WebSocket-клиенты не умеют передавать заголовки).
+func extractAPIKey(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		if token, ok := strings.CutPrefix(h, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("api_key")
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wrongjunior/eventsync/internal/auth"
+	"github.com/wrongjunior/eventsync/internal/cloudevents"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/history"
+)
+
+// cloudEventPage — страница GET /events с format=cloudevents.
+type cloudEventPage struct {
+	Events []cloudevents.Event `json:"events"`
+	More   bool                `json:"more"`
+}
+
+const (
+	// DefaultCatchUpLimit — размер страницы GET /events по умолчанию.
+	DefaultCatchUpLimit = 1000
+	// MaxCatchUpLimit — наибольший размер страницы GET /events.
+	MaxCatchUpLimit = 10000
+)
+
+// CatchUp отдаёт страницу событий истории с номером больше "since" для
+// догоняющего клиента: после долгого отключения он забирает пропущенное
+// по HTTP и только затем подключается по WebSocket. Параметры "topics",
+// "types" и "namespace" — как у подключения, "limit" — размер страницы,
+// "format=cloudevents" — события страницы в формате CloudEvents.
+func (h *Handler) CatchUp(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format, err := parseEventFormat(r)
+	if err == nil {
+		err = streamOnly(format)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	since, err := strconv.ParseUint(q.Get("since"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid since")
+		return
+	}
+	limit := DefaultCatchUpLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, "bad_request", "invalid limit")
+			return
+		}
+		limit = min(limit, MaxCatchUpLimit)
+	}
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	query := history.Query{FromSeq: since + 1, Limit: limit + 1}
+	if query.Namespace, err = resolveNamespace(r, principal); err != nil {
+		writeNamespaceError(w, err)
+		return
+	}
+	if query.Topics, err = parseTopics(r); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	if len(query.Topics) == 0 {
+		query.Topics = principal.Topics
+	}
+	if err := authorizeTopics(principal, query.Topics); err != nil {
+		writeError(w, http.StatusForbidden, "forbidden", err.Error())
+		return
+	}
+	if v := q.Get("types"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				query.Types = append(query.Types, t)
+			}
+		}
+	}
+	events, err := h.History.Range(query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	page := domain.EventPage{Events: events, More: len(events) > limit}
+	if page.More {
+		page.Events = events[:limit]
+	}
+	if page.Events == nil {
+		page.Events = []domain.Event{}
+	}
+	h.Logger.Debug("Catch-up page served", "namespace", query.Namespace, "since", since, "events", len(page.Events), "more", page.More)
+	if format == FormatCloudEvents {
+		converted, err := cloudEventsOf(page.Events)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, cloudEventPage{Events: converted, More: page.More})
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/wrongjunior/eventsync/internal/avro"
+	"github.com/wrongjunior/eventsync/internal/cloudevents"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/pb"
+)
+
+// EventFormat — формат событий в ответах и кадрах, значение параметра "format".
+type EventFormat string
+
+const (
+	FormatJSON        EventFormat = "json"        // собственный формат domain.Event
+	FormatCloudEvents EventFormat = "cloudevents" // CloudEvents 1.0, структурированный режим
+	// FormatProtobuf — сообщения eventsync.v1 (см. proto/eventsync/v1):
+	// события WebSocket отправляются двоичными кадрами pb.Frame.
+	FormatProtobuf EventFormat = "protobuf"
+	// FormatAvro — Avro single object encoding: каждый двоичный кадр — пачка
+	// событий с отпечатком схемы avro.BatchSchema.
+	FormatAvro EventFormat = "avro"
+)
+
+// binaryCodec — кодек формата, события которого отправляются по WebSocket
+// двоичными кадрами.
+type binaryCodec struct {
+	event  func(domain.Event) ([]byte, error)       // кодировка одного события
+	single func(dst, event []byte) []byte           // кадр с одним закодированным событием
+	batch  func(dst []byte, events [][]byte) []byte // кадр с пачкой закодированных событий
+}
+
+var binaryCodecs = map[EventFormat]binaryCodec{
+	FormatProtobuf: {
+		event:  pb.MarshalEvent,
+		single: pb.AppendEventFrame,
+		batch:  pb.AppendBatchFrame,
+	},
+	FormatAvro: {
+		event: func(e domain.Event) ([]byte, error) { return avro.AppendEvent(nil, e), nil },
+		// Кадр Avro всегда пачка: отпечаток схемы один на кадр.
+		single: func(dst, event []byte) []byte { return avro.AppendBatch(dst, [][]byte{event}) },
+		batch:  avro.AppendBatch,
+	},
+}
+
+// streamOnly проверяет, что формат допустим для ответа HTTP в JSON:
+// двоичные форматы поддерживаются только подпиской WebSocket.
+func streamOnly(format EventFormat) error {
+	if _, ok := binaryCodecs[format]; ok {
+		return fmt.Errorf("format %q is supported only for WebSocket", format)
+	}
+	return nil
+}
+
+// parseEventFormat разбирает параметр "format".
+func parseEventFormat(r *http.Request) (EventFormat, error) {
+	switch format := EventFormat(r.URL.Query().Get("format")); format {
+	case "":
+		return FormatJSON, nil
+	case FormatJSON, FormatCloudEvents, FormatProtobuf, FormatAvro:
+		return format, nil
+	default:
+		return "", errors.New(`invalid format: want "json", "cloudevents", "protobuf" or "avro"`)
+	}
+}
+
+// eventMode — представление события в запросе публикации.
+type eventMode int
+
+const (
+	modeNative     eventMode = iota // собственный формат domain.Event
+	modeStructured                  // CloudEvents, структурированный режим
+	modeBinary                      // CloudEvents, двоичный режим HTTP
+	modeProtobuf                    // сообщение pb.Event, Content-Type application/x-protobuf
+	modeAvro                        // Avro single object encoding, Content-Type avro/binary
+)
+
+// readEvent разбирает событие из тела запроса публикации: в собственном
+// формате, в структурированном режиме CloudEvents (Content-Type
+// application/cloudevents+json), в двоичном (заголовки ce-*), в protobuf
+// или в Avro.
+func readEvent(r *http.Request) (domain.Event, eventMode, error) {
+	switch mediaType(r.Header) {
+	case pb.MediaType:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return domain.Event{}, modeProtobuf, err
+		}
+		event, err := pb.UnmarshalEvent(body)
+		return event, modeProtobuf, err
+	case avro.MediaType:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return domain.Event{}, modeAvro, err
+		}
+		event, err := avro.UnmarshalEvent(body)
+		return event, modeAvro, err
+	}
+	switch {
+	case cloudevents.IsStructured(r.Header):
+		var ce cloudevents.Event
+		if err := json.NewDecoder(r.Body).Decode(&ce); err != nil {
+			return domain.Event{}, modeStructured, err
+		}
+		event, err := ce.Domain()
+		return event, modeStructured, err
+	case cloudevents.IsBinary(r.Header):
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return domain.Event{}, modeBinary, err
+		}
+		ce, err := cloudevents.ReadBinary(r.Header, body)
+		if err != nil {
+			return domain.Event{}, modeBinary, err
+		}
+		event, err := ce.Domain()
+		return event, modeBinary, err
+	default:
+		var event domain.Event
+		err := json.NewDecoder(r.Body).Decode(&event)
+		return event, modeNative, err
+	}
+}
+
+// writeEvent отвечает событием в представлении mode.
+func writeEvent(w http.ResponseWriter, status int, event domain.Event, mode eventMode) {
+	switch mode {
+	case modeNative:
+		writeJSON(w, status, event)
+		return
+	case modeProtobuf:
+		body, err := pb.MarshalEvent(event)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", pb.MediaType)
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	case modeAvro:
+		w.Header().Set("Content-Type", avro.MediaType)
+		w.WriteHeader(status)
+		w.Write(avro.MarshalEvent(event))
+		return
+	}
+	ce, err := cloudevents.FromDomain(event)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	if mode == modeBinary {
+		body := cloudevents.WriteBinary(w.Header(), ce)
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+	w.Header().Set("Content-Type", cloudevents.MediaType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(ce)
+}
+
+// mediaType возвращает тип тела запроса без параметров.
+func mediaType(h http.Header) string {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType
+}
+
+// decodeFrameEvent разбирает событие кадра публикации; событие с
+// атрибутом specversion принимается в структурированном режиме CloudEvents.
+func decodeFrameEvent(raw json.RawMessage) (domain.Event, error) {
+	var probe struct {
+		SpecVersion *json.RawMessage `json:"specversion"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return domain.Event{}, err
+	}
+	if probe.SpecVersion == nil {
+		var event domain.Event
+		err := json.Unmarshal(raw, &event)
+		return event, err
+	}
+	var ce cloudevents.Event
+	if err := json.Unmarshal(raw, &ce); err != nil {
+		return domain.Event{}, err
+	}
+	return ce.Domain()
+}
+
+// cloudEventsOf переводит события в CloudEvents для ответов с
+// format=cloudevents.
+func cloudEventsOf(events []domain.Event) ([]cloudevents.Event, error) {
+	result := make([]cloudevents.Event, 0, len(events))
+	for _, e := range events {
+		ce, err := cloudevents.FromDomain(e)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ce)
+	}
+	return result, nil
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/wrongjunior/eventsync/internal/auth"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	eservice "github.com/wrongjunior/eventsync/internal/service"
+)
+
+// frameSeeds — кадры клиента всех версий протокола, включая пачки и
+// повреждённые кадры.
+var frameSeeds = []string{
+	// ProtocolV1: плоские кадры.
+	`{"kind":"ack","id":"1","seq":1}`,
+	`{"kind":"status","client_id":"c","last_seq":1}`,
+	`{"kind":"publish","event":{"id":"p1","type":"info","message":"m"}}`,
+	`{"kind":"subscribe","topics":["orders.*"]}`,
+	`{"kind":"resume","since":10}`,
+	`{"kind":"ping","sent_at":"2024-01-01T00:00:00Z"}`,
+	// ProtocolV2: кадры в конверте.
+	`{"kind":"ack","payload":{"kind":"ack","id":"1","seq":1}}`,
+	`{"kind":"publish","payload":{"kind":"publish","event":{"id":"p2","type":"info","topic":"a.b"}}}`,
+	`{"kind":"publish","payload":{"kind":"publish","event":{"specversion":"1.0","id":"c1","source":"s","type":"info"}}}`,
+	`{"kind":"subscribe","payload":{"kind":"subscribe","topics":["#"]}}`,
+	`{"kind":"pong","payload":{"kind":"pong","sent_at":"2024-01-01T00:00:00Z","received_at":"2024-01-01T00:00:01Z"}}`,
+	// ProtocolV3: пачка, которую клиент отправлять не должен.
+	`{"kind":"batch","payload":[{"id":"1","seq":1,"type":"info"}]}`,
+	// Повреждённые кадры.
+	`null`,
+	`{"kind":"publish","payload":null}`,
+	`{"kind":"publish","event":null}`,
+	`{"kind":"subscribe","topics":["a..b"]}`,
+	`{"id":"1","type":"info"}`,
+	`{`,
+}
+
+func FuzzHandleMessage(f *testing.F) {
+	for _, seed := range frameSeeds {
+		f.Add([]byte(seed))
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	principal := auth.Principal{Scopes: []auth.Scope{auth.ScopePublish, auth.ScopeSubscribe}}
+	f.Fuzz(func(t *testing.T, message []byte) {
+		es := eservice.NewEventService(logger)
+		defer es.Shutdown()
+		h := NewHandler(es, logger)
+		notifier := newNotifier(nil, logger, 16)
+		client := &eservice.Client{Notifier: notifier, Namespace: domain.DefaultNamespace}
+		es.Register(client)
+		defer es.Unregister(client)
+		h.handleMessage(client, notifier, principal, message)
+	})
+}
+
+func FuzzHandleFrame(f *testing.F) {
+	kinds := []string{
+		domain.FrameKindAck, domain.FrameKindStatus, domain.FrameKindPublish,
+		domain.FrameKindSubscribe, domain.FrameKindResume, domain.FrameKindPing,
+		domain.FrameKindPong, domain.FrameKindBatch, "unknown",
+	}
+	for _, seed := range frameSeeds {
+		for _, kind := range kinds {
+			f.Add(kind, []byte(seed))
+		}
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	principal := auth.Principal{Scopes: []auth.Scope{auth.ScopePublish, auth.ScopeSubscribe}}
+	f.Fuzz(func(t *testing.T, kind string, payload []byte) {
+		es := eservice.NewEventService(logger)
+		defer es.Shutdown()
+		h := NewHandler(es, logger)
+		notifier := newNotifier(nil, logger, 16)
+		client := &eservice.Client{Notifier: notifier, Namespace: domain.DefaultNamespace}
+		es.Register(client)
+		defer es.Unregister(client)
+		_ = h.handleFrame(client, notifier, principal, kind, payload)
+	})
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/wrongjunior/eventsync/internal/guard"
+)
+
+// guardRequests возвращает middleware, отклоняющее запросы с заблокированных
+// адресов; подключения к wsPath дополнительно ограничиваются по частоте.
+func guardRequests(g *guard.Guard, wsPath string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr := g.ClientAddr(r)
+			check := g.Check
+			if r.URL.Path == wsPath {
+				check = g.Handshake
+			}
+			if err := check(addr); err != nil {
+				writeRejected(w, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeRejected отправляет 429 с Retry-After для временной блокировки или
+// превышения частоты и 403 для постоянно запрещённого адреса.
+func writeRejected(w http.ResponseWriter, err error) {
+	var rejected *guard.RejectedError
+	if !errors.As(err, &rejected) {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	code := "rate_limited"
+	if errors.Is(err, guard.ErrBanned) {
+		code = "banned"
+	}
+	if rejected.RetryAfter == 0 {
+		writeError(w, http.StatusForbidden, code, err.Error())
+		return
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rejected.RetryAfter.Seconds()))))
+	writeError(w, http.StatusTooManyRequests, code, err.Error())
+}
+
+// failAttempt учитывает неудачную попытку аутентификации или подключения
+// с адреса запроса; g может быть nil.
+func failAttempt(g *guard.Guard, r *http.Request, reason string) {
+	if g != nil {
+		g.Fail(g.ClientAddr(r), reason)
+	}
+}
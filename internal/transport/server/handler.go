@@ -2,41 +2,95 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
+	"github.com/wrongjunior/eventsync/internal/audit"
+	"github.com/wrongjunior/eventsync/internal/auth"
 	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/guard"
+	"github.com/wrongjunior/eventsync/internal/history"
+	"github.com/wrongjunior/eventsync/internal/metrics"
+	"github.com/wrongjunior/eventsync/internal/netpoll"
+	"github.com/wrongjunior/eventsync/internal/quota"
+	"github.com/wrongjunior/eventsync/internal/schedule"
+	"github.com/wrongjunior/eventsync/internal/schema"
 	eservice "github.com/wrongjunior/eventsync/internal/service"
 	"log/slog"
 )
 
-// WebSocketNotifier оборачивает websocket-соединение для реализации интерфейса Notifier.
-type WebSocketNotifier struct {
-	Conn   *websocket.Conn
-	Logger *slog.Logger
+// WebSocketLimits задаёт размеры буферов WebSocket-соединения и
+// ограничение входящего кадра; нулевые поля берутся из DefaultWebSocketLimits.
+type WebSocketLimits struct {
+	ReadBufferSize  int   // буфер чтения, байт
+	WriteBufferSize int   // буфер записи, байт
+	ReadLimit       int64 // максимальный размер кадра клиента, байт
 }
 
-// Notify отправляет событие через WebSocket.
-func (w *WebSocketNotifier) Notify(event domain.Event) {
-	w.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-	if err := w.Conn.WriteJSON(event); err != nil {
-		w.Logger.Error("Error writing JSON", "error", err)
+// DefaultWebSocketLimits — размеры буферов и ограничение кадра по умолчанию.
+var DefaultWebSocketLimits = WebSocketLimits{
+	ReadBufferSize:  4 << 10,
+	WriteBufferSize: 4 << 10,
+	ReadLimit:       1 << 20,
+}
+
+// withDefaults подставляет значения по умолчанию вместо нулевых полей.
+func (l WebSocketLimits) withDefaults() WebSocketLimits {
+	if l.ReadBufferSize <= 0 {
+		l.ReadBufferSize = DefaultWebSocketLimits.ReadBufferSize
+	}
+	if l.WriteBufferSize <= 0 {
+		l.WriteBufferSize = DefaultWebSocketLimits.WriteBufferSize
 	}
+	if l.ReadLimit <= 0 {
+		l.ReadLimit = DefaultWebSocketLimits.ReadLimit
+	}
+	return l
 }
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	// Разрешаем подключения с любых источников (для демонстрации)
-	CheckOrigin: func(r *http.Request) bool { return true },
+// upgrader возвращает Upgrader с размерами буферов из limits.
+func upgrader(limits WebSocketLimits) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  limits.ReadBufferSize,
+		WriteBufferSize: limits.WriteBufferSize,
+		// Сжатие permessage-deflate включается, только если его запросил клиент.
+		EnableCompression: true,
+		// Разрешаем подключения с любых источников (для демонстрации)
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
 }
 
 // Handler реализует HTTP-обработчик для WebSocket.
 type Handler struct {
 	EventService *eservice.EventService
 	Logger       *slog.Logger
+	// Quotas применяет квоты тенантов; nil — без ограничений.
+	Quotas *quota.Manager
+	// Metrics — метрики доставки событий клиентам; nil — не собираются.
+	Metrics *metrics.ServerMetrics
+	// Audit — журнал подключений; nil — не ведётся.
+	Audit *audit.Log
+	// Guard учитывает неудачные рукопожатия по адресам; nil — не учитываются.
+	Guard *guard.Guard
+	// Coalesce — ограничения пачек событий для клиентов ProtocolV3.
+	Coalesce CoalesceLimits
+	// WebSocket — размеры буферов соединения и ограничение кадра клиента.
+	WebSocket WebSocketLimits
+	// Poller, если задан, обслуживает соединения реактором netpoll вместо
+	// пары горутин на соединение; см. pollConn.
+	Poller *netpoll.Poller
+	// History — история разосланных событий для GET /replay; nil — маршрут
+	// не регистрируется.
+	History history.ServerEventStore
 }
 
 // NewHandler создаёт новый обработчик.
@@ -44,71 +98,698 @@ func NewHandler(es *eservice.EventService, logger *slog.Logger) *Handler {
 	return &Handler{
 		EventService: es,
 		Logger:       logger,
+		Coalesce:     DefaultCoalesceLimits,
+		WebSocket:    DefaultWebSocketLimits,
 	}
 }
 
 // ServeHTTP выполняет апгрейд соединения и регистрирует клиента.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	topics, err := parseTopics(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	versions, err := parseSchemaVersions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var resumeFrom uint64
+	if since := r.URL.Query().Get("since"); since != "" {
+		if resumeFrom, err = strconv.ParseUint(since, 10, 64); err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+	var snapshot bool
+	switch mode := r.URL.Query().Get("sync"); mode {
+	case "":
+	case "snapshot":
+		snapshot = true
+	default:
+		http.Error(w, "invalid sync mode", http.StatusBadRequest)
+		return
+	}
+	minSeverity, err := domain.ParseSeverity(r.URL.Query().Get("min_severity"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	aggregation, err := parseAggregation(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	group, err := parseGroup(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subscription, err := parseSubscription(r, group)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format, err := parseEventFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	namespace, err := resolveNamespace(r, principal)
+	if err != nil {
+		h.Audit.Record(connectionEntry(r, audit.KindAuthFailure, principal.KeyID, principal.Tenant, err.Error()))
+		writeNamespaceError(w, err)
+		return
+	}
+	if len(topics) == 0 {
+		// Субъект с ограниченными топиками без явной подписки получает
+		// все разрешённые ему топики.
+		topics = principal.Topics
+	}
+	if err := authorizeTopics(principal, topics); err != nil {
+		h.Audit.Record(connectionEntry(r, audit.KindAuthFailure, principal.KeyID, namespace, err.Error()))
+		writeError(w, http.StatusForbidden, "forbidden", err.Error())
+		return
+	}
+	var release func()
+	if h.Quotas != nil {
+		if release, err = h.Quotas.AcquireConnection(namespace); err != nil {
+			h.Audit.Record(connectionEntry(r, audit.KindRejected, principal.KeyID, namespace, err.Error()))
+			writeQuotaError(w, err)
+			return
+		}
+	}
+	if subscription != "" {
+		releaseSub, err := h.EventService.AcquireDurable(namespace, subscription)
+		if err != nil {
+			h.Audit.Record(connectionEntry(r, audit.KindRejected, principal.KeyID, namespace, err.Error()))
+			writeSubscriptionError(w, err)
+			if release != nil {
+				release()
+			}
+			return
+		}
+		// Подписка освобождается вместе с квотой при закрытии соединения.
+		releaseQuota := release
+		release = func() {
+			releaseSub()
+			if releaseQuota != nil {
+				releaseQuota()
+			}
+		}
+	}
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+	offered := websocket.Subprotocols(r)
+	protocol, ok := domain.NegotiateProtocol(offered)
+	if !ok {
+		h.Logger.Warn("Unsupported protocol versions", "offered", offered, "supported", domain.SupportedProtocols)
+		h.Audit.Record(connectionEntry(r, audit.KindRejected, principal.KeyID, namespace, "unsupported protocol"))
+		failAttempt(h.Guard, r, "unsupported protocol")
+		writeError(w, http.StatusUpgradeRequired, "unsupported_protocol",
+			"supported protocol versions: "+strings.Join(domain.SupportedProtocols, ", "))
+		return
+	}
+	var respHeader http.Header
+	if slices.Contains(offered, protocol) {
+		respHeader = http.Header{"Sec-WebSocket-Protocol": {protocol}}
+	}
+	newClient := func(notifier *WebSocketNotifier) *eservice.Client {
+		notifier.Envelope = protocol != domain.ProtocolV1
+		notifier.Format = format
+		if protocol == domain.ProtocolV3 {
+			notifier.Coalesce = h.Coalesce
+		}
+		notifier.Metrics = h.Metrics
+		client := &eservice.Client{Notifier: notifier, Topics: topics, Namespace: namespace, Versions: versions}
+		client.Protocol = protocol
+		client.ResumeFrom = resumeFrom
+		client.EventTypes = parseEventTypes(r)
+		client.MinSeverity = minSeverity
+		client.RemoteAddr = r.RemoteAddr
+		client.Snapshot = snapshot
+		client.Aggregate = aggregation
+		client.Group = group
+		client.Subscription = subscription
+		return client
+	}
+	if h.Poller != nil {
+		// Соединение переходит реактору; квота освобождается при его закрытии.
+		h.servePolled(w, r, respHeader, principal, newClient, release)
+		release = nil
+		return
+	}
+	conn, err := upgrader(h.WebSocket.withDefaults()).Upgrade(w, r, respHeader)
 	if err != nil {
 		h.Logger.Error("WebSocket upgrade error", "error", err)
+		failAttempt(h.Guard, r, err.Error())
 		return
 	}
-	notifier := &WebSocketNotifier{Conn: conn, Logger: h.Logger}
-	client := &eservice.Client{Notifier: notifier}
-	h.EventService.Register(client)
+	notifier := NewWebSocketNotifier(conn, h.Logger, defaultQueueSize)
+	client := newClient(notifier)
+	connected := h.register(r, client, principal)
 
 	// Создаём контекст для управления жизненным циклом соединения.
 	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
-	go h.writePump(conn, ctx)
-	h.readPump(conn)
+	go notifier.writePump(ctx)
+	readErr := h.readPump(conn, client, notifier, principal)
 	h.EventService.Unregister(client)
+	h.Audit.Record(disconnectEntry(connected, client, notifier, readErr))
+}
+
+// register регистрирует клиента в сервисе и записывает подключение в журнал.
+func (h *Handler) register(r *http.Request, client *eservice.Client, principal auth.Principal) audit.Entry {
+	h.EventService.Register(client)
+	connected := connectionEntry(r, audit.KindConnect, principal.KeyID, client.Namespace, "")
+	connected.ClientID = client.ID()
+	h.Audit.Record(connected)
+	return connected
+}
+
+// connectionEntry создаёт запись журнала подключений для запроса r.
+func connectionEntry(r *http.Request, kind audit.Kind, keyID, namespace, reason string) audit.Entry {
+	return audit.Entry{
+		Kind:       kind,
+		Namespace:  namespace,
+		KeyID:      keyID,
+		RemoteAddr: r.RemoteAddr,
+		Path:       r.URL.Path,
+		Reason:     reason,
+	}
+}
+
+// disconnectEntry создаёт запись об отключении клиента. Если отправка
+// остановилась из-за медленного клиента, отключение считается
+// принудительным; иначе причиной служит ошибка чтения.
+func disconnectEntry(connected audit.Entry, client *eservice.Client, notifier *WebSocketNotifier, readErr error) audit.Entry {
+	entry := connected
+	entry.Kind = audit.KindDisconnect
+	entry.Time = time.Now()
+	entry.DurationMillis = float64(entry.Time.Sub(client.ConnectedAt())) / float64(time.Millisecond)
+	entry.EventsDelivered = notifier.sent.Load()
+	cause := readErr
+	if writeErr := notifier.writeFailure(); writeErr != nil {
+		cause = writeErr
+		if errors.Is(writeErr, domain.ErrSlowClient) {
+			entry.Kind = audit.KindEvicted
+		}
+	}
+	if cause != nil {
+		entry.Reason = cause.Error()
+	}
+	return entry
+}
+
+// authorizeTopics проверяет, что шаблоны подписки не выходят за топики,
+// разрешённые субъекту.
+func authorizeTopics(principal auth.Principal, topics []string) error {
+	for _, pattern := range topics {
+		if !principal.CanSubscribe(pattern) {
+			return fmt.Errorf("%w: subscription to %q is not allowed", auth.ErrForbidden, pattern)
+		}
+	}
+	return nil
 }
 
-// readPump читает входящие сообщения и завершает соединение при ошибке.
-func (h *Handler) readPump(conn *websocket.Conn) {
+// parseTopics извлекает шаблоны подписки из параметра запроса "topics".
+// Параметр может повторяться и содержать несколько шаблонов через запятую.
+func parseTopics(r *http.Request) ([]string, error) {
+	var topics []string
+	for _, value := range r.URL.Query()["topics"] {
+		for _, pattern := range strings.Split(value, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			if err := domain.ValidateTopicPattern(pattern); err != nil {
+				return nil, fmt.Errorf("%w: %q", err, pattern)
+			}
+			topics = append(topics, pattern)
+		}
+	}
+	return topics, nil
+}
+
+// parseEventTypes разбирает параметр "types" — список нужных клиенту типов событий.
+func parseEventTypes(r *http.Request) map[string]struct{} {
+	value := r.URL.Query().Get("types")
+	if value == "" {
+		return nil
+	}
+	types := make(map[string]struct{})
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = struct{}{}
+		}
+	}
+	return types
+}
+
+// parseAggregation разбирает параметры "aggregate" (окно сводок, например
+// "1m") и "aggregate_only" (только сводки, без самих событий).
+func parseAggregation(r *http.Request) (*eservice.Aggregation, error) {
+	value := r.URL.Query().Get("aggregate")
+	if value == "" {
+		if r.URL.Query().Has("aggregate_only") {
+			return nil, errors.New("aggregate_only requires aggregate")
+		}
+		return nil, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < domain.MinAggregateInterval {
+		return nil, fmt.Errorf("invalid aggregate: want a duration of at least %s", domain.MinAggregateInterval)
+	}
+	aggregation := &eservice.Aggregation{Interval: interval}
+	if only := r.URL.Query().Get("aggregate_only"); only != "" {
+		if aggregation.Only, err = strconv.ParseBool(only); err != nil {
+			return nil, errors.New("invalid aggregate_only")
+		}
+	}
+	return aggregation, nil
+}
+
+// parseGroup разбирает параметры "group" (группа потребителей) и
+// "group_strategy" ("round_robin" или "key_hash").
+func parseGroup(r *http.Request) (*eservice.GroupMembership, error) {
+	name := r.URL.Query().Get("group")
+	strategy := r.URL.Query().Get("group_strategy")
+	if name == "" {
+		if strategy != "" {
+			return nil, errors.New("group_strategy requires group")
+		}
+		return nil, nil
+	}
+	if err := domain.ValidateGroup(name); err != nil {
+		return nil, fmt.Errorf("%w: %q", err, name)
+	}
+	if strategy != "" {
+		if _, err := eservice.ParseGroupStrategy(strategy); err != nil {
+			return nil, err
+		}
+	}
+	return &eservice.GroupMembership{Name: name, Strategy: strategy}, nil
+}
+
+// parseSubscription разбирает параметр "subscription" — имя долговременной
+// подписки. Долговременная подписка несовместима с группой потребителей.
+func parseSubscription(r *http.Request, group *eservice.GroupMembership) (string, error) {
+	name := r.URL.Query().Get("subscription")
+	if name == "" {
+		return "", nil
+	}
+	if err := domain.ValidateSubscription(name); err != nil {
+		return "", fmt.Errorf("%w: %q", err, name)
+	}
+	if group != nil {
+		return "", errors.New("subscription cannot be combined with group")
+	}
+	return name, nil
+}
+
+// writeSubscriptionError сопоставляет ошибку занятия долговременной
+// подписки с HTTP-ответом.
+func writeSubscriptionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, eservice.ErrSubscriptionInUse):
+		writeError(w, http.StatusConflict, "subscription_in_use", err.Error())
+	case errors.Is(err, eservice.ErrDurableDisabled):
+		writeError(w, http.StatusBadRequest, "durable_disabled", err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+	}
+}
+
+// parseSchemaVersions разбирает параметр "schema_versions" вида "info:1,order:2",
+// которым клиент сообщает максимальные понятные ему версии схем.
+func parseSchemaVersions(r *http.Request) (map[string]int, error) {
+	value := r.URL.Query().Get("schema_versions")
+	if value == "" {
+		return nil, nil
+	}
+	versions := make(map[string]int)
+	for _, pair := range strings.Split(value, ",") {
+		eventType, v, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		n, err := strconv.Atoi(v)
+		if !ok || eventType == "" || err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid schema version %q", pair)
+		}
+		versions[eventType] = n
+	}
+	return versions, nil
+}
+
+// resolveNamespace определяет пространство имён подключения. Ключ, привязанный
+// к тенанту, фиксирует пространство имён; параметр запроса "namespace" может
+// лишь совпадать с ним. Пространство имён фиксируется на время жизни соединения.
+func resolveNamespace(r *http.Request, principal auth.Principal) (string, error) {
+	namespace := r.URL.Query().Get("namespace")
+	if principal.Tenant != "" {
+		if namespace != "" && namespace != principal.Tenant {
+			return "", fmt.Errorf("%w: key is bound to namespace %q", auth.ErrForbidden, principal.Tenant)
+		}
+		return principal.Tenant, nil
+	}
+	if namespace == "" {
+		return domain.DefaultNamespace, nil
+	}
+	if err := domain.ValidateNamespace(namespace); err != nil {
+		return "", fmt.Errorf("%w: %q", err, namespace)
+	}
+	return namespace, nil
+}
+
+// Publish принимает событие через HTTP и рассылает его в пространстве имён
+// ключа. Событие CloudEvents принимается в структурированном и двоичном
+// режимах; ответ повторяет режим запроса.
+func (h *Handler) Publish(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	event, mode, err := readEvent(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	if principal.Tenant != "" {
+		if event.Namespace != "" && event.Namespace != principal.Tenant {
+			writeError(w, http.StatusForbidden, "forbidden", "key is bound to namespace "+principal.Tenant)
+			return
+		}
+		event.Namespace = principal.Tenant
+	}
+	if !principal.CanPublish(event.Topic) {
+		writeError(w, http.StatusForbidden, "forbidden", "publishing to topic "+strconv.Quote(event.Topic)+" is not allowed")
+		return
+	}
+	// Квота резервируется до публикации и возвращается, если событие
+	// отклонено, чтобы отклонённые события её не расходовали.
+	cancel := func() {}
+	if h.Quotas != nil {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_event", err.Error())
+			return
+		}
+		if cancel, err = h.Quotas.ReservePublish(event.NamespaceOrDefault(), int64(len(payload))); err != nil {
+			writeQuotaError(w, err)
+			return
+		}
+	}
+	if event.Source == "" {
+		event.Source = domain.NewSource(domain.SourceHTTP, principal.KeyID)
+	}
+	published, err := h.EventService.Publish(event)
+	if err != nil {
+		cancel()
+		writePublishError(w, err)
+		return
+	}
+	writeEvent(w, http.StatusAccepted, published, mode)
+}
+
+// readPump читает входящие кадры клиента в конверте или без него и
+// завершает соединение при ошибке, которую возвращает. На некорректный кадр
+// клиенту уходит кадр "error", соединение сохраняется.
+func (h *Handler) readPump(conn *websocket.Conn, client *eservice.Client, notifier *WebSocketNotifier, principal auth.Principal) error {
 	defer conn.Close()
-	conn.SetReadLimit(1024)
-	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	conn.SetReadLimit(h.WebSocket.withDefaults().ReadLimit)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
 	conn.SetPongHandler(func(string) error {
-		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
 	for {
-		if _, _, err := conn.ReadMessage(); err != nil {
-			h.Logger.Error("readPump error", "error", err)
-			break
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				h.Logger.Info("Client closed connection", "error", err)
+			} else if errors.Is(err, net.ErrClosed) {
+				h.Logger.Info("Connection closed by server")
+			} else {
+				h.Logger.Error("readPump error", "error", err)
+			}
+			return err
 		}
+		h.handleMessage(client, notifier, principal, message)
 	}
 }
 
-// writePump отправляет ping-сообщения для поддержания соединения.
-func (h *Handler) writePump(conn *websocket.Conn, ctx context.Context) {
-	ticker := time.NewTicker(54 * time.Second)
-	defer func() {
-		ticker.Stop()
-		conn.Close()
-	}()
-	for {
-		select {
-		case <-ticker.C:
-			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				h.Logger.Error("Ping error", "error", err)
-				return
+// handleMessage разбирает и обрабатывает кадр клиента в конверте или без него.
+func (h *Handler) handleMessage(client *eservice.Client, notifier *WebSocketNotifier, principal auth.Principal, message []byte) {
+	kind, payload, err := domain.DecodeFrame(message)
+	if err != nil {
+		h.rejectFrame(notifier, "", "bad_request", err.Error())
+		return
+	}
+	if err := h.handleFrame(client, notifier, principal, kind, payload); err != nil {
+		h.rejectFrame(notifier, kind, "bad_request", err.Error())
+	}
+}
+
+// handleFrame обрабатывает тело кадра клиента вида kind.
+func (h *Handler) handleFrame(client *eservice.Client, notifier *WebSocketNotifier, principal auth.Principal, kind string, payload []byte) error {
+	switch kind {
+	case domain.FrameKindAck:
+		var ack domain.Ack
+		if err := json.Unmarshal(payload, &ack); err != nil {
+			return err
+		}
+		h.EventService.Acknowledge(client, ack)
+	case domain.FrameKindStatus:
+		var status domain.ClientStatus
+		if err := json.Unmarshal(payload, &status); err != nil {
+			return err
+		}
+		h.EventService.ReportStatus(client, status)
+	case domain.FrameKindPublish:
+		notifier.Send(domain.FrameKindPublishResult, h.publishFrame(client, principal, payload))
+	case domain.FrameKindSubscribe:
+		var frame domain.SubscribeFrame
+		if err := json.Unmarshal(payload, &frame); err != nil {
+			return err
+		}
+		for _, pattern := range frame.Topics {
+			if err := domain.ValidateTopicPattern(pattern); err != nil {
+				return fmt.Errorf("%w: %q", err, pattern)
 			}
-		case <-ctx.Done():
-			return
 		}
+		topics := frame.Topics
+		if len(topics) == 0 {
+			topics = principal.Topics
+		}
+		if err := authorizeTopics(principal, topics); err != nil {
+			return err
+		}
+		h.EventService.Subscribe(client, topics)
+	case domain.FrameKindResume:
+		var frame domain.ResumeFrame
+		if err := json.Unmarshal(payload, &frame); err != nil {
+			return err
+		}
+		h.EventService.Resume(client, frame.Since)
+	case domain.FrameKindPing:
+		var ping domain.PingFrame
+		if err := json.Unmarshal(payload, &ping); err != nil {
+			return err
+		}
+		notifier.Send(domain.FrameKindPong, ping.Pong(time.Now()))
+	case domain.FrameKindPong:
+		var pong domain.PingFrame
+		if err := json.Unmarshal(payload, &pong); err != nil {
+			return err
+		}
+		if rtt, offset, ok := pong.Measure(time.Now()); ok {
+			client.RecordHeartbeat(rtt, offset)
+		}
+	default:
+		return fmt.Errorf("unknown frame kind %q", kind)
 	}
+	return nil
+}
+
+// rejectFrame сообщает клиенту, что его кадр не обработан.
+func (h *Handler) rejectFrame(notifier *WebSocketNotifier, kind, code, message string) {
+	h.Logger.Warn("Client frame rejected", "kind", kind, "error", message)
+	notifier.Send(domain.FrameKindError, domain.NewErrorFrame(code, message))
+}
+
+// publishFrame обрабатывает событие, опубликованное клиентом через WebSocket,
+// с теми же проверками, что и POST /events: право публикации, пространство
+// имён подключения, квоты и схемы. Событие не возвращается отправителю.
+func (h *Handler) publishFrame(client *eservice.Client, principal auth.Principal, message []byte) domain.PublishResult {
+	var frame struct {
+		Event json.RawMessage `json:"event"`
+	}
+	if err := json.Unmarshal(message, &frame); err != nil {
+		return publishFailure("", "bad_request", err.Error(), nil)
+	}
+	event, err := decodeFrameEvent(frame.Event)
+	if err != nil {
+		return publishFailure("", "bad_request", err.Error(), nil)
+	}
+	if !principal.Has(auth.ScopePublish) {
+		return publishFailure(event.ID, "forbidden", "key lacks publish scope", nil)
+	}
+	if event.Namespace != "" && event.Namespace != client.Namespace {
+		return publishFailure(event.ID, "forbidden", "connection is bound to namespace "+client.Namespace, nil)
+	}
+	event.Namespace = client.Namespace
+	if !principal.CanPublish(event.Topic) {
+		return publishFailure(event.ID, "forbidden", "publishing to topic "+strconv.Quote(event.Topic)+" is not allowed", nil)
+	}
+	cancel := func() {}
+	if h.Quotas != nil {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return publishFailure(event.ID, "invalid_event", err.Error(), nil)
+		}
+		if cancel, err = h.Quotas.ReservePublish(event.Namespace, int64(len(payload))); err != nil {
+			return publishFailure(event.ID, "quota_exceeded", err.Error(), nil)
+		}
+	}
+	published, err := h.EventService.PublishFrom(client, event)
+	if err != nil {
+		cancel()
+		_, code, details := publishErrorCode(err)
+		return publishFailure(event.ID, code, err.Error(), details)
+	}
+	return domain.PublishResult{Kind: domain.FrameKindPublishResult, ID: published.ID, Seq: published.Seq, DeliverAt: published.DeliverAt}
+}
+
+// publishFailure формирует ответ об отклонённой публикации.
+func publishFailure(id, code, message string, details any) domain.PublishResult {
+	return domain.PublishResult{
+		Kind:  domain.FrameKindPublishResult,
+		ID:    id,
+		Error: &domain.FrameError{Code: code, Message: message, Details: details},
+	}
+}
+
+// writePublishError сопоставляет ошибку публикации с HTTP-ответом.
+func writePublishError(w http.ResponseWriter, err error) {
+	status, code, details := publishErrorCode(err)
+	writeJSON(w, status, errorBody{Error: errorDetail{Code: code, Message: err.Error(), Details: details}})
+}
+
+// publishErrorCode возвращает HTTP-статус, код ошибки и подробности для
+// ошибки публикации; общий для HTTP API и кадров WebSocket.
+func publishErrorCode(err error) (int, string, any) {
+	var ve *schema.ValidationError
+	errors.As(err, &ve)
+	switch {
+	case errors.Is(err, eservice.ErrEventQuarantined):
+		return http.StatusUnprocessableEntity, "quarantined", ve
+	case errors.Is(err, eservice.ErrConflict):
+		return http.StatusConflict, "conflict", nil
+	case errors.Is(err, eservice.ErrDraining):
+		return http.StatusServiceUnavailable, "draining", nil
+	case ve != nil:
+		return http.StatusUnprocessableEntity, "schema_violation", ve
+	default:
+		return http.StatusBadRequest, "invalid_event", nil
+	}
+}
+
+// RouterConfig задаёт параметры маршрутизации и зависимости HTTP API.
+type RouterConfig struct {
+	WSPath string
+	// Keys — хранилище API-ключей; nil отключает административный API ключей.
+	Keys auth.KeyStore
+	// AdminKey — статический ключ администратора всех тенантов.
+	AdminKey string
+	// JWT проверяет токены доступа; nil — JWT не принимаются.
+	JWT *auth.JWTVerifier
+	// AdminOIDC проверяет токены операторов для /admin; nil — только ключи и JWT.
+	AdminOIDC *auth.OIDC
+	// Quotas — менеджер квот тенантов; nil — без ограничений.
+	Quotas *quota.Manager
+	// Schemas — реестр JSON Schema публикуемых событий; nil — без проверки.
+	Schemas *schema.Registry
+	// Quarantine — карантин отклонённых событий для административного API.
+	Quarantine *schema.Quarantine
+	// Schedule — очередь отложенных событий для /admin/scheduled.
+	Schedule *schedule.Queue
+	// History — история разосланных событий для GET /replay и
+	// POST /admin/replay; nil — повторная выдача недоступна.
+	History history.ServerEventStore
+	// Metrics — метрики доставки событий клиентам; nil — не собираются.
+	Metrics *metrics.ServerMetrics
+	// Audit — журнал подключений, доступный в /admin/audit; nil — не ведётся.
+	Audit *audit.Log
+	// Guard ограничивает частоту подключений и блокирует адреса после
+	// повторных неудач; nil — без ограничений.
+	Guard *guard.Guard
+	// Coalesce — ограничения пачек событий для клиентов ProtocolV3; nil —
+	// DefaultCoalesceLimits.
+	Coalesce *CoalesceLimits
+	// WebSocket — размеры буферов соединений и ограничение кадра клиента;
+	// nil — DefaultWebSocketLimits.
+	WebSocket *WebSocketLimits
+	// Poller — реактор для соединений без собственных горутин; nil —
+	// каждое соединение обслуживают свои горутины чтения и записи.
+	Poller *netpoll.Poller
 }
 
 // SetupRouter настраивает маршруты через chi и возвращает http.Handler.
-func SetupRouter(es *eservice.EventService, logger *slog.Logger, wsPath string) http.Handler {
+func SetupRouter(es *eservice.EventService, logger *slog.Logger, cfg RouterConfig) http.Handler {
 	r := chi.NewRouter()
+	if cfg.Guard != nil {
+		r.Use(guardRequests(cfg.Guard, cfg.WSPath))
+	}
 	handler := NewHandler(es, logger)
-	r.Get(wsPath, handler.ServeHTTP)
+	handler.Quotas = cfg.Quotas
+	handler.Metrics = cfg.Metrics
+	handler.Audit = cfg.Audit
+	handler.Guard = cfg.Guard
+	if cfg.Coalesce != nil {
+		handler.Coalesce = *cfg.Coalesce
+	}
+	if cfg.WebSocket != nil {
+		handler.WebSocket = *cfg.WebSocket
+	}
+	handler.Poller = cfg.Poller
+	handler.History = cfg.History
+	authn := &Authenticator{Keys: cfg.Keys, AdminKey: cfg.AdminKey, JWT: cfg.JWT, Audit: cfg.Audit, Guard: cfg.Guard}
+	r.With(authn.Require(auth.ScopeSubscribe)).Get(cfg.WSPath, handler.ServeHTTP)
+	r.With(authn.Require(auth.ScopePublish)).Post("/events", handler.Publish)
+	// Версия открыта без ключа, чтобы поддержка могла определить развёрнутую сборку.
+	r.Get("/version", serveVersion)
+	// Описание протокола строится по итоговому набору маршрутов в конце.
+	docs := &protocolDocs{}
+	r.Get("/schema", docs.serve)
+	if cfg.History != nil {
+		r.With(authn.Require(auth.ScopeSubscribe)).Get("/replay", handler.Replay)
+		r.With(authn.Require(auth.ScopeSubscribe)).Get("/events", handler.CatchUp)
+	}
+	// Состояние клиентов доступно всегда; остальные разделы — при заданных зависимостях.
+	admin := &AdminHandler{
+		Events:     es,
+		Keys:       cfg.Keys,
+		Quotas:     cfg.Quotas,
+		Schemas:    cfg.Schemas,
+		Quarantine: cfg.Quarantine,
+		Schedule:   cfg.Schedule,
+		History:    cfg.History,
+		Audit:      cfg.Audit,
+		Guard:      cfg.Guard,
+		Logger:     logger,
+	}
+	// Административный API дополнительно принимает токены операторов OIDC и
+	// закрыт, даже если клиентские маршруты работают без аутентификации:
+	// без ключей, JWT и OIDC область admin не выдаётся никому.
+	adminAuthn := *authn
+	adminAuthn.OIDC = cfg.AdminOIDC
+	if !adminAuthn.Enabled() {
+		logger.Warn("Admin API is disabled: no api keys, admin key, JWT or admin OIDC configured")
+	}
+	r.With(adminAuthn.Require(auth.ScopeAdmin)).Route("/admin", admin.Routes)
+	if err := docs.build(r, cfg.WSPath); err != nil {
+		logger.Error("Failed to build protocol description", "error", err)
+	}
 	return r
 }
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wrongjunior/eventsync/internal/auth"
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// TestRequestNamespace проверяет, что пространство имён берётся из тенанта
+// ключа, а параметр namespace, расходящийся с ним, отклоняется с 403.
+func TestRequestNamespace(t *testing.T) {
+	tenant := auth.Principal{KeyID: "k1", Tenant: "acme"}
+	global := auth.Principal{KeyID: "admin"}
+	for _, tc := range []struct {
+		name      string
+		principal auth.Principal
+		query     string
+		want      string
+		status    int
+	}{
+		{"tenant without parameter", tenant, "", "acme", http.StatusOK},
+		{"tenant with own namespace", tenant, "?namespace=acme", "acme", http.StatusOK},
+		{"tenant with other namespace", tenant, "?namespace=globex", "", http.StatusForbidden},
+		{"global default", global, "", domain.DefaultNamespace, http.StatusOK},
+		{"global chooses namespace", global, "?namespace=globex", "globex", http.StatusOK},
+		{"invalid namespace", global, "?namespace=bad%20name", "", http.StatusBadRequest},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/admin/crdt"+tc.query, nil)
+		r = r.WithContext(auth.WithPrincipal(r.Context(), tc.principal))
+		w := httptest.NewRecorder()
+		got, ok := requestNamespace(w, r)
+		if ok != (tc.status == http.StatusOK) || got != tc.want || w.Code != tc.status {
+			t.Errorf("%s: requestNamespace = %q, %v, status %d; want %q, status %d", tc.name, got, ok, w.Code, tc.want, tc.status)
+		}
+	}
+}
@@ -0,0 +1,601 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/wrongjunior/eventsync/internal/bufpool"
+	"github.com/wrongjunior/eventsync/internal/cloudevents"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/metrics"
+	eservice "github.com/wrongjunior/eventsync/internal/service"
+	"log/slog"
+)
+
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = 54 * time.Second
+)
+
+// WebSocketNotifier оборачивает websocket-соединение для реализации интерфейса Notifier.
+// События ставятся в приоритетную очередь и отправляются единственным писателем
+// соединения (writePump или, для соединений netpoll, горутиной pollConn.flush),
+// поэтому Notify не блокирует рассылку.
+type WebSocketNotifier struct {
+	Logger  *slog.Logger
+	conn    frameConn
+	queue   *sendQueue
+	control chan controlFrame // служебные кадры, отправляемые вне очереди событий
+	closing chan string       // причина закрытия соединения сервером, см. Disconnect
+	// wake вызывается после постановки кадра в очередь; nil — очередь
+	// разбирает writePump.
+	wake func()
+	// Envelope включает упаковку кадров в domain.Envelope; иначе события
+	// и служебные кадры отправляются без конверта.
+	Envelope bool
+	// Format — формат событий: собственный, CloudEvents 1.0 или один из
+	// двоичных (protobuf, Avro). Служебные кадры всегда в JSON.
+	Format EventFormat
+	// Metrics — метрики задержки доставки и глубины очереди; nil — не собираются.
+	Metrics *metrics.ServerMetrics
+	// Coalesce — ограничения пачек FrameKindBatch, которыми отправляется
+	// накопившаяся очередь; нулевое значение — события отправляются по одному.
+	Coalesce CoalesceLimits
+
+	sent    atomic.Uint64         // событий, записанных в соединение
+	failure atomic.Pointer[error] // ошибка записи, на которой остановился writePump
+}
+
+// CoalesceLimits ограничивает пачку событий в одном кадре.
+type CoalesceLimits struct {
+	MaxEvents int // событий в пачке; меньше 2 — пачки не отправляются
+	MaxBytes  int // суммарный размер событий пачки в байтах; 0 — без ограничения
+}
+
+// DefaultCoalesceLimits — ограничения пачек для клиентов ProtocolV3 по умолчанию.
+var DefaultCoalesceLimits = CoalesceLimits{MaxEvents: 100, MaxBytes: 256 << 10}
+
+// errQueueOverflow — причина отключения клиента, переполнившего очередь отправки.
+var errQueueOverflow = fmt.Errorf("%w: send queue overflow", domain.ErrSlowClient)
+
+// controlFrame — служебный кадр в очереди отправки.
+type controlFrame struct {
+	kind string
+	body any
+}
+
+// controlQueueSize — вместимость очереди служебных кадров одного клиента.
+const controlQueueSize = 64
+
+// NewWebSocketNotifier создаёт notifier с очередью отправки вместимостью queueSize.
+func NewWebSocketNotifier(conn *websocket.Conn, logger *slog.Logger, queueSize int) *WebSocketNotifier {
+	return newNotifier(gorillaConn{conn}, logger, queueSize)
+}
+
+func newNotifier(conn frameConn, logger *slog.Logger, queueSize int) *WebSocketNotifier {
+	return &WebSocketNotifier{
+		Logger:  logger,
+		conn:    conn,
+		queue:   newSendQueue(queueSize),
+		control: make(chan controlFrame, controlQueueSize),
+		closing: make(chan string, 1),
+		Format:  FormatJSON,
+	}
+}
+
+// frameConn — WebSocket-соединение, в которое WebSocketNotifier пишет кадры.
+// Реализации ограничивают запись сроком writeWait и оборачивают его
+// истечение в domain.ErrSlowClient.
+type frameConn interface {
+	// writeText отправляет текстовый кадр с телом data.
+	writeText(data []byte) error
+	// writeBinary отправляет двоичный кадр с телом data.
+	writeBinary(data []byte) error
+	// writeShared отправляет кадр события рассылки. Кадр готовится функцией
+	// encode один раз на формат кадра и хранится в encodings; кадр формата
+	// с binaryCodec двоичный, остальные — текстовые.
+	writeShared(encodings *eservice.Encodings, format frameFormat, encode func(*bytes.Buffer) error) error
+	// writePing отправляет ping протокола WebSocket.
+	writePing() error
+	// writeClose отправляет кадр закрытия 1001 (going away) с причиной.
+	writeClose(reason string) error
+	Close() error
+}
+
+// gorillaConn — frameConn поверх gorilla/websocket; писатель у соединения
+// один (writePump), поэтому запись не синхронизируется.
+type gorillaConn struct {
+	*websocket.Conn
+}
+
+// frameFormat — формат кадра события: с конвертом или без и формат события.
+type frameFormat struct {
+	envelope bool
+	events   EventFormat
+}
+
+// binary сообщает, отправляется ли кадр события двоичным кадром WebSocket.
+func (f frameFormat) binary() bool {
+	_, ok := binaryCodecs[f.events]
+	return ok
+}
+
+// preparedKey — ключ подготовленного кадра gorilla/websocket в Encodings.
+type preparedKey struct{ format frameFormat }
+
+func (c gorillaConn) writeText(data []byte) error {
+	c.SetWriteDeadline(time.Now().Add(writeWait))
+	return slowClient(c.WriteMessage(websocket.TextMessage, data))
+}
+
+func (c gorillaConn) writeBinary(data []byte) error {
+	c.SetWriteDeadline(time.Now().Add(writeWait))
+	return slowClient(c.WriteMessage(websocket.BinaryMessage, data))
+}
+
+func (c gorillaConn) writeShared(encodings *eservice.Encodings, format frameFormat, encode func(*bytes.Buffer) error) error {
+	prepared, err := encodings.Load(preparedKey{format}, func() (any, error) {
+		buf := bufpool.Get()
+		defer bufpool.Put(buf)
+		if err := encode(buf); err != nil {
+			return nil, err
+		}
+		messageType := websocket.TextMessage
+		if format.binary() {
+			messageType = websocket.BinaryMessage
+		}
+		// Подготовленный кадр хранит данные, поэтому буфер пула копируется.
+		return websocket.NewPreparedMessage(messageType, bytes.Clone(buf.Bytes()))
+	})
+	if err != nil {
+		return err
+	}
+	c.SetWriteDeadline(time.Now().Add(writeWait))
+	return slowClient(c.WritePreparedMessage(prepared.(*websocket.PreparedMessage)))
+}
+
+func (c gorillaConn) writePing() error {
+	c.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.WriteMessage(websocket.PingMessage, nil)
+}
+
+func (c gorillaConn) writeClose(reason string) error {
+	return c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, reason),
+		time.Now().Add(writeWait))
+}
+
+// Notify ставит событие в очередь отправки клиенту.
+func (w *WebSocketNotifier) Notify(event domain.Event) {
+	w.enqueue(event, nil)
+}
+
+// NotifyShared ставит событие в очередь отправки; кадр события готовится
+// один раз для всех соединений рассылки с тем же форматом кадров.
+func (w *WebSocketNotifier) NotifyShared(event domain.Event, encodings *eservice.Encodings) {
+	w.enqueue(event, encodings)
+}
+
+// enqueue ставит событие в очередь отправки. Клиент, переполнивший
+// очередь, отключается: пропуск в номерах нельзя восполнить, пока он
+// остаётся подключённым, а после переподключения он продолжит с последнего
+// сохранённого события.
+func (w *WebSocketNotifier) enqueue(event domain.Event, encodings *eservice.Encodings) {
+	accepted, overflow := w.queue.push(event, encodings)
+	if w.Metrics != nil {
+		w.Metrics.QueueDepth.Observe(float64(w.queue.len()))
+		if !accepted {
+			w.Metrics.EventsDropped.Inc()
+		}
+	}
+	if overflow {
+		w.Logger.Warn("Send queue full, disconnecting slow client", "id", event.ID, "queued", w.queue.len())
+		w.fail(errQueueOverflow)
+		w.Disconnect("send queue overflow")
+		return
+	}
+	if accepted && w.wake != nil {
+		w.wake()
+	}
+}
+
+// Send ставит служебный кадр вида kind в очередь отправки. Если клиент не
+// читает ответы и очередь переполнена, кадр отбрасывается.
+func (w *WebSocketNotifier) Send(kind string, frame any) {
+	select {
+	case w.control <- controlFrame{kind: kind, body: frame}:
+		if w.wake != nil {
+			w.wake()
+		}
+	default:
+		w.Logger.Warn("Control queue full, frame dropped", "kind", kind)
+	}
+}
+
+// encode дописывает в buf кадр, при включённом Envelope — в конверте.
+// Конверт пишется напрямую, без промежуточной сериализации тела.
+func (w *WebSocketNotifier) encode(buf *bytes.Buffer, kind string, frame any) error {
+	if !w.Envelope {
+		return appendJSON(buf, frame)
+	}
+	buf.WriteString(`{"kind":"` + kind + `","payload":`)
+	if err := appendJSON(buf, frame); err != nil {
+		return err
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// appendJSON дописывает в buf JSON-представление v.
+func appendJSON(buf *bytes.Buffer, v any) error {
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1) // перевод строки, добавленный Encode
+	return nil
+}
+
+// write отправляет кадр. Истечение срока записи означает, что клиент не
+// читает соединение, и сообщается ошибкой, обёрнутой в domain.ErrSlowClient.
+func (w *WebSocketNotifier) write(kind string, frame any) error {
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	if err := w.encode(buf, kind, frame); err != nil {
+		return err
+	}
+	return w.conn.writeText(buf.Bytes())
+}
+
+// writeEvent отправляет событие из очереди. Событие рассылки кодируется
+// в подготовленный кадр один раз на формат кадра и записывается в каждое
+// соединение без повторной сериализации.
+func (w *WebSocketNotifier) writeEvent(item queuedEvent) error {
+	if codec, ok := binaryCodecs[w.Format]; ok {
+		return w.writeBinaryEvent(codec, item)
+	}
+	if item.encodings == nil {
+		frame, err := w.eventFrame(item.event)
+		if err != nil {
+			return err
+		}
+		return w.write(domain.FrameKindEvent, frame)
+	}
+	format := frameFormat{envelope: w.Envelope, events: w.Format}
+	return w.conn.writeShared(item.encodings, format, func(buf *bytes.Buffer) error {
+		frame, err := w.eventFrame(item.event)
+		if err != nil {
+			return err
+		}
+		return w.encode(buf, domain.FrameKindEvent, frame)
+	})
+}
+
+// writeBinaryEvent отправляет событие двоичным кадром формата codec.
+// Конверт к нему не применяется: двоичный кадр сам указывает вид тела.
+func (w *WebSocketNotifier) writeBinaryEvent(codec binaryCodec, item queuedEvent) error {
+	// Кодировка события берётся до writeShared: Encodings.Load не допускает
+	// вложенных вызовов.
+	body, err := w.binaryEvent(codec, item)
+	if err != nil {
+		return err
+	}
+	if item.encodings == nil {
+		return w.conn.writeBinary(codec.single(nil, body))
+	}
+	format := frameFormat{events: w.Format}
+	return w.conn.writeShared(item.encodings, format, func(buf *bytes.Buffer) error {
+		buf.Write(codec.single(buf.AvailableBuffer(), body))
+		return nil
+	})
+}
+
+// eventFrame возвращает тело текстового кадра события в формате соединения.
+func (w *WebSocketNotifier) eventFrame(event domain.Event) (any, error) {
+	if w.Format != FormatCloudEvents {
+		return event, nil
+	}
+	return cloudevents.FromDomain(event)
+}
+
+// writeBacklog отправляет first и всё, что накопилось в очереди, пачками в
+// пределах Coalesce, сокращая число кадров и системных вызовов. Пачка из
+// одного события отправляется обычным кадром.
+func (w *WebSocketNotifier) writeBacklog(first queuedEvent) error {
+	if codec, ok := binaryCodecs[w.Format]; ok {
+		return w.writeBinaryBacklog(codec, first)
+	}
+	const header = `{"kind":"` + domain.FrameKindBatch + `","payload":[`
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	var batch []queuedEvent
+	flush := func() error {
+		var err error
+		if len(batch) == 1 {
+			err = w.writeEvent(batch[0])
+		} else {
+			buf.WriteString("]}")
+			err = w.conn.writeText(buf.Bytes())
+		}
+		if err != nil {
+			return err
+		}
+		w.sent.Add(uint64(len(batch)))
+		for _, item := range batch {
+			w.observeSent(item)
+		}
+		batch = batch[:0]
+		buf.Reset()
+		buf.WriteString(header)
+		return nil
+	}
+	buf.WriteString(header)
+	for item, ok := first, true; ok; item, ok = w.queue.pop() {
+		mark := buf.Len()
+		if len(batch) > 0 {
+			buf.WriteByte(',')
+		}
+		if err := w.appendEvent(buf, item); err != nil {
+			return err
+		}
+		full := len(batch) >= w.Coalesce.MaxEvents ||
+			(w.Coalesce.MaxBytes > 0 && buf.Len()-len(header) > w.Coalesce.MaxBytes)
+		if len(batch) > 0 && full {
+			// Событие не помещается: пачка уходит без него, и оно начинает следующую.
+			buf.Truncate(mark)
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := w.appendEvent(buf, item); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, item)
+	}
+	return flush()
+}
+
+// writeBinaryBacklog — writeBacklog для двоичных форматов: пачка уходит
+// одним кадром, собранным codec.batch.
+func (w *WebSocketNotifier) writeBinaryBacklog(codec binaryCodec, first queuedEvent) error {
+	var (
+		batch  []queuedEvent
+		bodies [][]byte
+		size   int
+	)
+	flush := func() error {
+		var err error
+		if len(batch) == 1 {
+			err = w.writeBinaryEvent(codec, batch[0])
+		} else {
+			err = w.conn.writeBinary(codec.batch(nil, bodies))
+		}
+		if err != nil {
+			return err
+		}
+		w.sent.Add(uint64(len(batch)))
+		for _, item := range batch {
+			w.observeSent(item)
+		}
+		batch, bodies, size = batch[:0], bodies[:0], 0
+		return nil
+	}
+	for item, ok := first, true; ok; item, ok = w.queue.pop() {
+		body, err := w.binaryEvent(codec, item)
+		if err != nil {
+			return err
+		}
+		full := len(batch) >= w.Coalesce.MaxEvents ||
+			(w.Coalesce.MaxBytes > 0 && size+len(body) > w.Coalesce.MaxBytes)
+		if len(batch) > 0 && full {
+			// Событие не помещается: пачка уходит без него, и оно начинает следующую.
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, item)
+		bodies = append(bodies, body)
+		size += len(body)
+	}
+	return flush()
+}
+
+// binaryEvent возвращает событие в двоичной кодировке формата
+// соединения; кодировка события рассылки общая для всех клиентов формата.
+func (w *WebSocketNotifier) binaryEvent(codec binaryCodec, item queuedEvent) ([]byte, error) {
+	if item.encodings == nil {
+		return codec.event(item.event)
+	}
+	body, err := item.encodings.Load(w.Format, func() (any, error) {
+		return codec.event(item.event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return body.([]byte), nil
+}
+
+// appendEvent дописывает в buf событие; кодировка события рассылки общая
+// для всех клиентов с тем же форматом событий.
+func (w *WebSocketNotifier) appendEvent(buf *bytes.Buffer, item queuedEvent) error {
+	if item.encodings == nil {
+		frame, err := w.eventFrame(item.event)
+		if err != nil {
+			return err
+		}
+		return appendJSON(buf, frame)
+	}
+	body, err := item.encodings.Load(w.Format, func() (any, error) {
+		frame, err := w.eventFrame(item.event)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(frame)
+	})
+	if err != nil {
+		return err
+	}
+	buf.Write(body.([]byte))
+	return nil
+}
+
+// slowClient оборачивает истечение срока записи в domain.ErrSlowClient.
+func slowClient(err error) error {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return fmt.Errorf("%w: %w", domain.ErrSlowClient, err)
+	}
+	return err
+}
+
+// writePump — единственный писатель соединения: отправляет события из очереди
+// в порядке приоритета, служебные кадры и ping-сообщения для поддержания соединения.
+func (w *WebSocketNotifier) writePump(ctx context.Context) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		w.conn.Close()
+	}()
+	for {
+		select {
+		case <-w.queue.ready:
+			if err := w.flushQueue(); err != nil {
+				w.Logger.Error("Error writing JSON", "error", err)
+				w.fail(err)
+				return
+			}
+		case frame := <-w.control:
+			if err := w.write(frame.kind, frame.body); err != nil {
+				w.Logger.Error("Error writing JSON", "error", err)
+				w.fail(err)
+				return
+			}
+		case <-ticker.C:
+			if err := w.ping(); err != nil {
+				w.Logger.Error("Ping error", "error", err)
+				w.fail(err)
+				return
+			}
+		case reason := <-w.closing:
+			w.close(reason)
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// flushQueue отправляет события очереди, пока она не опустеет.
+func (w *WebSocketNotifier) flushQueue() error {
+	for {
+		item, ok := w.queue.pop()
+		if !ok {
+			return nil
+		}
+		if w.Coalesce.MaxEvents > 1 && w.queue.len() > 0 {
+			// Клиент отстаёт: очередь уходит пачками.
+			if err := w.writeBacklog(item); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.writeEvent(item); err != nil {
+			return err
+		}
+		w.sent.Add(1)
+		w.observeSent(item)
+	}
+}
+
+// flushControl отправляет накопившиеся служебные кадры.
+func (w *WebSocketNotifier) flushControl() error {
+	for {
+		select {
+		case frame := <-w.control:
+			if err := w.write(frame.kind, frame.body); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// pending сообщает, есть ли неотправленные события, служебные кадры или
+// запрос закрытия.
+func (w *WebSocketNotifier) pending() bool {
+	return len(w.control) > 0 || w.queue.len() > 0 || len(w.closing) > 0
+}
+
+// Disconnect реализует service.Disconnecter: соединение закрывается кадром
+// 1001 после отправки уже поставленных в очередь кадров. Повторные вызовы
+// игнорируются.
+func (w *WebSocketNotifier) Disconnect(reason string) {
+	select {
+	case w.closing <- reason:
+	default:
+	}
+	if w.wake != nil {
+		w.wake()
+	}
+}
+
+// close дописывает очередь и отправляет кадр закрытия; само соединение
+// закрывает вызывающий.
+func (w *WebSocketNotifier) close(reason string) {
+	err := w.flushControl()
+	if err == nil {
+		err = w.flushQueue()
+	}
+	if err == nil {
+		err = w.conn.writeClose(reason)
+	}
+	if err != nil {
+		w.Logger.Warn("Error closing connection", "error", err)
+	}
+}
+
+// ping отправляет ping протокола, а клиентам с конвертом — и прикладной
+// ping с временем отправки, по ответу на который измеряются задержка и часы.
+func (w *WebSocketNotifier) ping() error {
+	if err := w.conn.writePing(); err != nil {
+		return err
+	}
+	if w.Envelope {
+		return w.write(domain.FrameKindPing, domain.NewPingFrame(time.Now()))
+	}
+	return nil
+}
+
+// fail запоминает ошибку записи, из-за которой соединение закрыто.
+func (w *WebSocketNotifier) fail(err error) {
+	w.failure.Store(&err)
+}
+
+// writeFailure возвращает ошибку записи, на которой остановилась отправка;
+// nil — соединение закрыто не из-за неё.
+func (w *WebSocketNotifier) writeFailure() error {
+	if err := w.failure.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// observeSent учитывает в метриках запись события в соединение.
+func (w *WebSocketNotifier) observeSent(item queuedEvent) {
+	if w.Metrics == nil {
+		return
+	}
+	now := time.Now()
+	w.Metrics.EventsSent.Inc()
+	w.Metrics.DeliveryLatency.Observe(now.Sub(item.queuedAt).Seconds())
+	if !item.event.Timestamp.IsZero() {
+		w.Metrics.EventAge.Observe(now.Sub(item.event.Timestamp).Seconds())
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// benchEvent — событие типичного размера с полезной нагрузкой.
+func benchEvent() domain.Event {
+	return domain.Event{
+		ID: "0123456789abcdef0123456789abcdef", Seq: 42, Type: "info", Topic: "orders.eu.created",
+		Message: strings.Repeat("m", 200), Data: json.RawMessage(`{"order":123,"items":[1,2,3],"note":"hello"}`),
+		Source: "http:svc", Timestamp: time.Now(),
+	}
+}
+
+// benchNotifier создаёт notifier ProtocolV2 поверх соединения с сервером,
+// который читает и отбрасывает кадры.
+func benchNotifier(b *testing.B) *WebSocketNotifier {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		for {
+			if _, _, err := c.NextReader(); err != nil {
+				return
+			}
+		}
+	}))
+	b.Cleanup(srv.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { conn.Close() })
+	n := NewWebSocketNotifier(conn, slog.New(slog.NewTextHandler(io.Discard, nil)), 16)
+	n.Envelope = true
+	return n
+}
+
+// BenchmarkWriteEvent замеряет кодирование и запись кадра одного события.
+func BenchmarkWriteEvent(b *testing.B) {
+	n := benchNotifier(b)
+	item := queuedEvent{event: benchEvent()}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := n.writeEvent(item); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkWriteBatch замеряет отправку пачки из 50 событий одним кадром.
+func BenchmarkWriteBatch(b *testing.B) {
+	n := benchNotifier(b)
+	n.Coalesce = CoalesceLimits{MaxEvents: 50}
+	e := benchEvent()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 49; j++ {
+			n.queue.push(e, nil)
+		}
+		if err := n.writeBacklog(queuedEvent{event: e}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
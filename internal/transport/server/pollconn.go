@@ -0,0 +1,369 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/gorilla/websocket"
+	"github.com/wrongjunior/eventsync/internal/audit"
+	"github.com/wrongjunior/eventsync/internal/auth"
+	"github.com/wrongjunior/eventsync/internal/bufpool"
+	"github.com/wrongjunior/eventsync/internal/netpoll"
+	eservice "github.com/wrongjunior/eventsync/internal/service"
+)
+
+// readChunk — сколько байт читать из сокета за один вызов.
+const readChunk = 4 << 10
+
+// pingFrame — готовый ping-кадр протокола WebSocket.
+var pingFrame = ws.MustCompileFrame(ws.NewPingFrame(nil))
+
+// pollConn — WebSocket-соединение, обслуживаемое реактором netpoll: у
+// простаивающего соединения нет собственных горутин. Кадры клиента читает
+// пул реактора, когда в сокете появились данные; отправку выполняет
+// горутина, которая запускается при появлении кадров в очереди и
+// завершается, отправив их; ping отправляет таймер.
+type pollConn struct {
+	h         *Handler
+	conn      net.Conn
+	in        []byte // начало сообщения клиента, остаток которого ещё не пришёл
+	src       bytes.Reader
+	reader    wsutil.Reader
+	control   wsutil.FrameHandlerFunc
+	readLimit int64
+	client    *eservice.Client
+	notifier  *WebSocketNotifier
+	principal auth.Principal
+	connected audit.Entry
+	release   func() // освобождает квоту подключений; может быть nil
+	keepalive *time.Timer
+
+	writeMu   sync.Mutex   // сериализует запись кадров в сокет
+	flushing  atomic.Bool  // запущена горутина отправки
+	lastRead  atomic.Int64 // время последнего кадра клиента, Unix-наносекунды
+	closed    atomic.Bool
+	closeOnce sync.Once
+}
+
+// compiledKey — ключ готового кадра рассылки (заголовок и тело) в Encodings.
+type compiledKey struct{ format frameFormat }
+
+// servePolled выполняет апгрейд через gobwas/ws и передаёт соединение
+// реактору h.Poller. Сжатие permessage-deflate в этом режиме не
+// согласуется, а размеры буферов WebSocket не применяются: кадры читаются
+// прямо из сокета, чтобы реактор видел все непрочитанные данные.
+func (h *Handler) servePolled(w http.ResponseWriter, r *http.Request, respHeader http.Header, principal auth.Principal, newClient func(*WebSocketNotifier) *eservice.Client, release func()) {
+	upgrader := ws.HTTPUpgrader{Header: respHeader, Timeout: writeWait}
+	conn, rw, _, err := upgrader.Upgrade(r, w)
+	if err == nil && rw.Reader.Buffered() > 0 {
+		// До ответа на рукопожатие клиент не должен отправлять кадры
+		// (RFC 6455, 4.1), а прочитанные в буфер данные реактор не увидит.
+		err = errors.New("client sent data before handshake completed")
+	}
+	if err != nil {
+		h.Logger.Error("WebSocket upgrade error", "error", err)
+		failAttempt(h.Guard, r, err.Error())
+		if conn != nil {
+			conn.Close()
+		}
+		if release != nil {
+			release()
+		}
+		return
+	}
+	c := &pollConn{
+		h:         h,
+		conn:      conn,
+		readLimit: h.WebSocket.withDefaults().ReadLimit,
+		principal: principal,
+		release:   release,
+	}
+	c.control = wsutil.ControlFrameHandler(c, ws.StateServerSide)
+	c.reader = wsutil.Reader{
+		Source:         &c.src,
+		State:          ws.StateServerSide,
+		MaxFrameSize:   c.readLimit,
+		OnIntermediate: c.control,
+	}
+	c.lastRead.Store(time.Now().UnixNano())
+	c.notifier = newNotifier(c, h.Logger, defaultQueueSize)
+	c.notifier.wake = c.wake
+	c.client = newClient(c.notifier)
+	c.keepalive = time.AfterFunc(pingPeriod, c.ping)
+	c.connected = h.register(r, c.client, principal)
+	if err := h.Poller.Add(conn, c.onReadable); err != nil {
+		h.Logger.Error("Netpoll registration error", "error", err)
+		c.close(err)
+	}
+}
+
+// onReadable читает из сокета поступившие данные и обрабатывает
+// полученные целиком сообщения клиента; вызывается пулом реактора. Остаток
+// сокет не ждёт: начало сообщения хранится в c.in до следующих данных,
+// поэтому медленный клиент не занимает обработчик реактора.
+func (c *pollConn) onReadable() {
+	for !c.closed.Load() {
+		if cap(c.in)-len(c.in) < readChunk {
+			c.in = append(c.in, make([]byte, readChunk)...)[:len(c.in)]
+		}
+		n, err := netpoll.Read(c.conn, c.in[len(c.in):cap(c.in)])
+		c.in = c.in[:len(c.in)+n]
+		if err == nil {
+			err = c.handleBuffered()
+		}
+		if err != nil {
+			var closed wsutil.ClosedError
+			if errors.As(err, &closed) || errors.Is(err, io.EOF) {
+				c.h.Logger.Info("Client closed connection", "error", err)
+			} else {
+				c.h.Logger.Error("Read error", "error", err)
+			}
+			c.close(err)
+			return
+		}
+		if n == 0 {
+			break
+		}
+	}
+	if len(c.in) == 0 {
+		// Простаивающее соединение не держит буфер чтения.
+		c.in = nil
+	}
+}
+
+// handleBuffered обрабатывает сообщения, полностью полученные в c.in, и
+// оставляет в нём начало следующего.
+func (c *pollConn) handleBuffered() error {
+	for {
+		n, err := messageLength(c.in, c.readLimit)
+		if err != nil || n == 0 {
+			return err
+		}
+		c.src.Reset(c.in[:n])
+		message, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		if message != nil {
+			c.h.handleMessage(c.client, c.notifier, c.principal, message.Bytes())
+			bufpool.Put(message)
+		}
+		c.in = c.in[:copy(c.in, c.in[n:])]
+	}
+}
+
+// messageLength возвращает длину первого сообщения в data вместе со всеми
+// его фрагментами и служебными кадрами между ними или 0, если сообщение
+// получено не целиком. Служебный кадр в начале data — отдельное сообщение.
+// Сообщение длиннее limit отклоняется по заголовкам, не дожидаясь тела.
+func messageLength(data []byte, limit int64) (int, error) {
+	r := bytes.NewReader(data)
+	state := ws.StateServerSide
+	var size int64
+	for first := true; ; first = false {
+		hdr, err := ws.ReadHeader(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil
+		}
+		if err == nil {
+			err = ws.CheckHeader(hdr, state)
+		}
+		if err != nil {
+			return 0, err
+		}
+		control := hdr.OpCode.IsControl()
+		if !control {
+			if size += hdr.Length; size > limit {
+				return 0, websocket.ErrReadLimit
+			}
+			state = state.Set(ws.StateFragmented)
+		}
+		if hdr.Length > int64(r.Len()) {
+			return 0, nil
+		}
+		r.Seek(hdr.Length, io.SeekCurrent)
+		if control && first || !control && hdr.Fin {
+			return len(data) - r.Len(), nil
+		}
+	}
+}
+
+// readMessage читает сообщение клиента из c.src в буфер пула. Служебные
+// кадры (ping, pong, close) обрабатываются на месте, и тогда возвращается nil.
+func (c *pollConn) readMessage() (*bytes.Buffer, error) {
+	hdr, err := c.reader.NextFrame()
+	if err != nil {
+		return nil, err
+	}
+	c.lastRead.Store(time.Now().UnixNano())
+	if hdr.OpCode.IsControl() {
+		return nil, c.control(hdr, &c.reader)
+	}
+	buf := bufpool.Get()
+	if _, err := buf.ReadFrom(io.LimitReader(&c.reader, c.readLimit+1)); err != nil {
+		bufpool.Put(buf)
+		return nil, err
+	}
+	if int64(buf.Len()) > c.readLimit {
+		bufpool.Put(buf)
+		return nil, websocket.ErrReadLimit
+	}
+	return buf, nil
+}
+
+// wake запускает горутину отправки, если она ещё не запущена.
+func (c *pollConn) wake() {
+	if !c.closed.Load() && c.flushing.CompareAndSwap(false, true) {
+		go c.flush()
+	}
+}
+
+// flush отправляет накопившиеся служебные кадры и события и завершается,
+// когда отправлять больше нечего.
+func (c *pollConn) flush() {
+	for {
+		err := c.notifier.flushControl()
+		if err == nil {
+			err = c.notifier.flushQueue()
+		}
+		if err != nil {
+			c.h.Logger.Error("Error writing JSON", "error", err)
+			c.notifier.fail(err)
+			c.close(err)
+			return
+		}
+		select {
+		case reason := <-c.notifier.closing:
+			c.notifier.close(reason)
+			c.close(errors.New("server going away: " + reason))
+			return
+		default:
+		}
+		c.flushing.Store(false)
+		// Кадр, поставленный после опустошения очереди, но до сброса флага,
+		// не запустил горутину, поэтому очередь проверяется ещё раз.
+		if !c.notifier.pending() || !c.flushing.CompareAndSwap(false, true) {
+			return
+		}
+	}
+}
+
+// ping отправляет ping и закрывает соединение, от которого дольше pongWait
+// не было ни одного кадра.
+func (c *pollConn) ping() {
+	if c.closed.Load() {
+		return
+	}
+	if idle := time.Since(time.Unix(0, c.lastRead.Load())); idle > pongWait {
+		err := fmt.Errorf("no frames from client for %s", idle.Round(time.Second))
+		c.h.Logger.Error("Read error", "error", err)
+		c.close(err)
+		return
+	}
+	if err := c.notifier.ping(); err != nil {
+		c.h.Logger.Error("Ping error", "error", err)
+		c.notifier.fail(err)
+		c.close(err)
+		return
+	}
+	c.keepalive.Reset(pingPeriod)
+}
+
+// close закрывает соединение и снимает регистрацию клиента; cause —
+// причина закрытия для журнала подключений.
+func (c *pollConn) close(cause error) {
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		c.keepalive.Stop()
+		if err := c.h.Poller.Remove(c.conn); err != nil {
+			c.h.Logger.Warn("Netpoll removal error", "error", err)
+		}
+		c.conn.Close()
+		c.h.EventService.Unregister(c.client)
+		c.h.Audit.Record(disconnectEntry(c.connected, c.client, c.notifier, cause))
+		if c.release != nil {
+			c.release()
+		}
+	})
+}
+
+// writeFrames записывает в сокет части кадра одним вызовом writev.
+func (c *pollConn) writeFrames(bufs net.Buffers) (int64, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	n, err := bufs.WriteTo(c.conn)
+	return n, slowClient(err)
+}
+
+// Write записывает готовые кадры; через него отвечают на служебные кадры клиента.
+func (c *pollConn) Write(p []byte) (int, error) {
+	n, err := c.writeFrames(net.Buffers{p})
+	return int(n), err
+}
+
+func (c *pollConn) writeText(data []byte) error {
+	return c.writeData(ws.OpText, data)
+}
+
+func (c *pollConn) writeBinary(data []byte) error {
+	return c.writeData(ws.OpBinary, data)
+}
+
+// writeData записывает кадр данных с кодом op без копирования тела.
+func (c *pollConn) writeData(op ws.OpCode, data []byte) error {
+	var hdr bytes.Buffer
+	if err := ws.WriteHeader(&hdr, ws.Header{Fin: true, OpCode: op, Length: int64(len(data))}); err != nil {
+		return err
+	}
+	_, err := c.writeFrames(net.Buffers{hdr.Bytes(), data})
+	return err
+}
+
+func (c *pollConn) writeShared(encodings *eservice.Encodings, format frameFormat, encode func(*bytes.Buffer) error) error {
+	frame, err := encodings.Load(compiledKey{format}, func() (any, error) {
+		buf := bufpool.Get()
+		defer bufpool.Put(buf)
+		if err := encode(buf); err != nil {
+			return nil, err
+		}
+		if format.binary() {
+			return ws.CompileFrame(ws.NewBinaryFrame(buf.Bytes()))
+		}
+		return ws.CompileFrame(ws.NewTextFrame(buf.Bytes()))
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.writeFrames(net.Buffers{frame.([]byte)})
+	return err
+}
+
+func (c *pollConn) writePing() error {
+	_, err := c.writeFrames(net.Buffers{pingFrame})
+	return err
+}
+
+func (c *pollConn) writeClose(reason string) error {
+	frame, err := ws.CompileFrame(ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusGoingAway, reason)))
+	if err != nil {
+		return err
+	}
+	_, err = c.writeFrames(net.Buffers{frame})
+	return err
+}
+
+// Close закрывает соединение.
+func (c *pollConn) Close() error {
+	c.close(nil)
+	return nil
+}
@@ -0,0 +1,187 @@
+//go:build linux
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gorilla/websocket"
+
+	"github.com/wrongjunior/eventsync/internal/netpoll"
+	eservice "github.com/wrongjunior/eventsync/internal/service"
+)
+
+// clientFrame кодирует кадр клиента (с маской).
+func clientFrame(t testing.TB, f ws.Frame) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := ws.WriteFrame(&buf, ws.MaskFrameInPlace(f)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMessageLength(t *testing.T) {
+	ping := clientFrame(t, ws.NewPingFrame([]byte("p")))
+	first := clientFrame(t, ws.NewFrame(ws.OpText, false, []byte("hel")))
+	last := clientFrame(t, ws.NewFrame(ws.OpContinuation, true, []byte("lo")))
+	fragmented := append(append(append([]byte{}, first...), ping...), last...)
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"empty", nil, 0},
+		{"partial header", ping[:1], 0},
+		{"partial payload", ping[:len(ping)-1], 0},
+		{"control", append(append([]byte{}, ping...), first...), len(ping)},
+		{"fragment without last", append(append([]byte{}, first...), ping...), 0},
+		{"fragmented with control", append(append([]byte{}, fragmented...), ping...), len(fragmented)},
+	} {
+		if got, err := messageLength(tc.data, 1<<10); err != nil || got != tc.want {
+			t.Errorf("%s: messageLength = %d, %v; want %d", tc.name, got, err, tc.want)
+		}
+	}
+
+	// Превышение ограничения видно по заголовку, до получения тела.
+	big := clientFrame(t, ws.NewTextFrame(make([]byte, 100)))
+	if _, err := messageLength(big[:8], 10); !errors.Is(err, websocket.ErrReadLimit) {
+		t.Errorf("oversized message: %v, want read limit", err)
+	}
+	unmasked := ws.MustCompileFrame(ws.NewTextFrame([]byte("x")))
+	if _, err := messageLength(unmasked, 10); err == nil {
+		t.Error("unmasked client frame accepted")
+	}
+}
+
+// TestPollConnPartialFrame проверяет, что клиент, приславший начало кадра,
+// не занимает единственный обработчик реактора: другие соединения
+// обслуживаются, а кадр обрабатывается, когда приходит его остаток.
+func TestPollConnPartialFrame(t *testing.T) {
+	poller, err := netpoll.New(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer poller.Close()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	es := eservice.NewEventService(logger)
+	defer es.Shutdown()
+	srv := httptest.NewServer(SetupRouter(es, logger, RouterConfig{WSPath: "/ws", Poller: poller}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	dial := func() net.Conn {
+		conn, _, _, err := ws.Dial(context.Background(), url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	// awaitPong читает кадры сервера, пока не придёт pong.
+	awaitPong := func(conn net.Conn) []byte {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			f, err := ws.ReadFrame(conn)
+			if err != nil {
+				t.Fatalf("no pong: %v", err)
+			}
+			if f.Header.OpCode == ws.OpPong {
+				return f.Payload
+			}
+		}
+	}
+
+	stalled, other := dial(), dial()
+	ping := clientFrame(t, ws.NewPingFrame([]byte("stalled")))
+	if _, err := stalled.Write(ping[:4]); err != nil {
+		t.Fatal(err)
+	}
+	// Дать реактору прочитать начало кадра.
+	time.Sleep(50 * time.Millisecond)
+	if _, err := other.Write(clientFrame(t, ws.NewPingFrame([]byte("other")))); err != nil {
+		t.Fatal(err)
+	}
+	if got := awaitPong(other); string(got) != "other" {
+		t.Fatalf("pong = %q", got)
+	}
+	if _, err := stalled.Write(ping[4:]); err != nil {
+		t.Fatal(err)
+	}
+	if got := awaitPong(stalled); string(got) != "stalled" {
+		t.Fatalf("pong = %q", got)
+	}
+}
+
+// BenchmarkIdleConnections замеряет память сервера на простаивающее
+// подключение: с горутинами чтения и записи на соединение и с реактором
+// netpoll. Учитываются куча и стеки горутин процесса, поэтому в замер
+// входит и клиентская сторона соединений (она одинакова в обоих режимах).
+func BenchmarkIdleConnections(b *testing.B) {
+	const conns = 2000
+	for _, mode := range []string{"goroutines", "netpoll"} {
+		b.Run(mode, func(b *testing.B) {
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			cfg := RouterConfig{WSPath: "/ws"}
+			if mode == "netpoll" {
+				poller, err := netpoll.New(0)
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer poller.Close()
+				cfg.Poller = poller
+			}
+			for i := 0; i < b.N; i++ {
+				goroutines := runtime.NumGoroutine()
+				es := eservice.NewEventService(logger)
+				srv := httptest.NewServer(SetupRouter(es, logger, cfg))
+				url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+				before := memInUse()
+				clients := make([]net.Conn, 0, conns)
+				for len(clients) < conns {
+					conn, _, _, err := ws.Dial(context.Background(), url)
+					if err != nil {
+						b.Fatal(err)
+					}
+					clients = append(clients, conn)
+				}
+				for len(es.Clients("")) < conns {
+					time.Sleep(10 * time.Millisecond)
+				}
+				b.ReportMetric(float64(memInUse()-before)/conns, "bytes/conn")
+				for _, conn := range clients {
+					conn.Close()
+				}
+				for len(es.Clients("")) > 0 {
+					time.Sleep(10 * time.Millisecond)
+				}
+				srv.Close()
+				es.Shutdown()
+				// Следующий замер начинается, когда горутины этого завершились.
+				for runtime.NumGoroutine() > goroutines {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+		})
+	}
+}
+
+// memInUse возвращает занятую кучу и стеки горутин после сборки мусора.
+func memInUse() int64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapInuse + m.StackInuse)
+}
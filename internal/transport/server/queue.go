@@ -0,0 +1,169 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	eservice "github.com/wrongjunior/eventsync/internal/service"
+)
+
+// defaultQueueSize — вместимость очереди отправки одного клиента.
+const defaultQueueSize = 1024
+
+type queuedEvent struct {
+	event     domain.Event
+	encodings *eservice.Encodings // кодировки события, общие для рассылки; nil — кодируется отдельно
+	priority  int
+	queuedAt  time.Time
+}
+
+// eventFIFO — события одного приоритета в порядке поступления.
+type eventFIFO struct {
+	items []queuedEvent
+	head  int
+}
+
+func (f *eventFIFO) push(item queuedEvent) {
+	f.items = append(f.items, item)
+}
+
+func (f *eventFIFO) pop() queuedEvent {
+	item := f.items[f.head]
+	f.items[f.head] = queuedEvent{}
+	f.head++
+	// Освободившееся начало переиспользуется, когда его больше половины.
+	if f.head == len(f.items) {
+		f.items, f.head = f.items[:0], 0
+	} else if f.head > len(f.items)/2 {
+		n := copy(f.items, f.items[f.head:])
+		clear(f.items[n:])
+		f.items, f.head = f.items[:n], 0
+	}
+	return item
+}
+
+func (f *eventFIFO) len() int { return len(f.items) - f.head }
+
+// sendQueue — ограниченная приоритетная очередь отправки клиенту: по
+// очереди FIFO на каждый приоритет. Когда клиент отстаёт, срочные события
+// обгоняют накопившийся хвост менее важных, но не события своего ключа
+// партиции: приоритет события с ключом в очереди не выше, чем у стоящих в
+// ней событий того же ключа. Поэтому приоритеты событий ключа не возрастают
+// в порядке поступления, и они извлекаются в этом порядке.
+//
+// Переполненная очередь событий не вытесняет и не отбрасывает молча: она
+// перестаёт принимать события, а соединение закрывается, чтобы клиент
+// переподключился и продолжил с последнего сохранённого номера без пропусков.
+type sendQueue struct {
+	mu         sync.Mutex
+	levels     map[int]*eventFIFO // очереди по приоритетам
+	order      []int              // приоритеты непустых очередей по убыванию
+	size       int
+	limit      int
+	overflowed bool                      // очередь переполнялась и больше не принимает события
+	partitions map[string]partitionState // ключи партиций событий в очереди
+	ready      chan struct{}             // сигнал писателю о появлении событий
+}
+
+// partitionState — события одного ключа партиции в очереди.
+type partitionState struct {
+	queued   int // число событий ключа в очереди
+	priority int // приоритет последнего поставленного, наименьший среди них
+}
+
+func newSendQueue(limit int) *sendQueue {
+	if limit <= 0 {
+		limit = defaultQueueSize
+	}
+	return &sendQueue{limit: limit, levels: make(map[int]*eventFIFO), ready: make(chan struct{}, 1)}
+}
+
+// push ставит событие в очередь и сообщает, принято ли оно. Переполнение
+// сообщается один раз — при первом непринятом событии; после него очередь
+// не принимает событий, чтобы клиент не получил события после пропуска.
+func (q *sendQueue) push(event domain.Event, encodings *eservice.Encodings) (accepted, overflow bool) {
+	item := queuedEvent{event: event, encodings: encodings, priority: event.EffectivePriority(), queuedAt: time.Now()}
+	q.mu.Lock()
+	if q.overflowed {
+		q.mu.Unlock()
+		return false, false
+	}
+	if q.size >= q.limit {
+		q.overflowed = true
+		q.mu.Unlock()
+		return false, true
+	}
+	if part, ok := q.partitions[event.PartitionKey]; ok && event.PartitionKey != "" {
+		item.priority = min(item.priority, part.priority)
+	}
+	level, ok := q.levels[item.priority]
+	if !ok {
+		level = &eventFIFO{}
+		q.levels[item.priority] = level
+	}
+	if level.len() == 0 {
+		// Приоритетов в очереди обычно несколько, поэтому вставка в
+		// упорядоченный срез дешевле кучи.
+		i := sort.Search(len(q.order), func(i int) bool { return q.order[i] < item.priority })
+		q.order = append(q.order, 0)
+		copy(q.order[i+1:], q.order[i:])
+		q.order[i] = item.priority
+	}
+	level.push(item)
+	q.size++
+	if key := event.PartitionKey; key != "" {
+		if q.partitions == nil {
+			q.partitions = make(map[string]partitionState)
+		}
+		q.partitions[key] = partitionState{queued: q.partitions[key].queued + 1, priority: item.priority}
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true, false
+}
+
+// pop извлекает самое приоритетное событие.
+func (q *sendQueue) pop() (queuedEvent, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.size == 0 {
+		return queuedEvent{}, false
+	}
+	level := q.levels[q.order[0]]
+	item := level.pop()
+	if level.len() == 0 {
+		delete(q.levels, q.order[0])
+		q.order = q.order[1:]
+	}
+	q.size--
+	q.release(item.event.PartitionKey)
+	return item, true
+}
+
+// release учитывает, что событие ключа key покинуло очередь. Приоритет ключа
+// не пересчитывается: оставшиеся события ключа не менее важны.
+func (q *sendQueue) release(key string) {
+	if key == "" {
+		return
+	}
+	part := q.partitions[key]
+	if part.queued <= 1 {
+		delete(q.partitions, key)
+		return
+	}
+	part.queued--
+	q.partitions[key] = part
+}
+
+// len возвращает текущую глубину очереди.
+func (q *sendQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+)
+
+// drain извлекает из очереди ID событий в порядке отправки.
+func drain(q *sendQueue) []string {
+	var ids []string
+	for item, ok := q.pop(); ok; item, ok = q.pop() {
+		ids = append(ids, item.event.ID)
+	}
+	return ids
+}
+
+func TestSendQueueOrder(t *testing.T) {
+	q := newSendQueue(16)
+	for _, e := range []domain.Event{
+		{ID: "low1", Priority: domain.PriorityLow},
+		{ID: "k1", Priority: domain.PriorityLow, PartitionKey: "k"},
+		{ID: "normal", Priority: domain.PriorityNormal},
+		{ID: "high", Priority: domain.PriorityHigh},
+		// Срочное событие ключа не обгоняет ждущее событие того же ключа.
+		{ID: "k2", Priority: domain.PriorityHigh, PartitionKey: "k"},
+		{ID: "low2", Priority: domain.PriorityLow},
+	} {
+		if ok, _ := q.push(e, nil); !ok {
+			t.Fatalf("push(%s) rejected", e.ID)
+		}
+	}
+	want := []string{"high", "normal", "low1", "k1", "k2", "low2"}
+	if got := drain(q); !equalStrings(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+	if q.len() != 0 || len(q.partitions) != 0 || len(q.levels) != 0 {
+		t.Fatalf("queue not empty after drain: len=%d partitions=%v levels=%v", q.len(), q.partitions, q.levels)
+	}
+}
+
+// TestSendQueueOverflow проверяет, что переполненная очередь ничего не
+// вытесняет и не принимает событий и после того, как освободится место.
+func TestSendQueueOverflow(t *testing.T) {
+	q := newSendQueue(2)
+	q.push(domain.Event{ID: "1", Priority: domain.PriorityLow}, nil)
+	q.push(domain.Event{ID: "2", Priority: domain.PriorityLow}, nil)
+	if ok, overflow := q.push(domain.Event{ID: "3", Priority: domain.PriorityHigh}, nil); ok || !overflow {
+		t.Fatalf("push to full queue = %v, %v; want rejected with overflow", ok, overflow)
+	}
+	q.pop()
+	if ok, overflow := q.push(domain.Event{ID: "4"}, nil); ok || overflow {
+		t.Fatalf("push after overflow = %v, %v; want rejected without repeated overflow", ok, overflow)
+	}
+	if got := drain(q); !equalStrings(got, []string{"2"}) {
+		t.Fatalf("remaining = %v, want [2]", got)
+	}
+}
+
+func TestNotifierDisconnectsOnOverflow(t *testing.T) {
+	n := newNotifier(nil, slog.New(slog.NewTextHandler(io.Discard, nil)), 1)
+	n.Notify(domain.Event{ID: "1"})
+	n.Notify(domain.Event{ID: "2"})
+	select {
+	case reason := <-n.closing:
+		if reason != "send queue overflow" {
+			t.Fatalf("close reason = %q", reason)
+		}
+	default:
+		t.Fatal("slow client was not disconnected")
+	}
+	if err := n.writeFailure(); !errors.Is(err, domain.ErrSlowClient) {
+		t.Fatalf("write failure = %v, want slow client", err)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wrongjunior/eventsync/internal/auth"
+	"github.com/wrongjunior/eventsync/internal/cloudevents"
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/history"
+)
+
+// replayRequest — диапазон и темп повторной выдачи из параметров запроса.
+type replayRequest struct {
+	query  history.Query
+	speed  float64
+	maxGap time.Duration
+}
+
+// parseReplay разбирает параметры повторной выдачи: "from_seq" и "to_seq"
+// (номера включительно), "from" и "to" (время RFC 3339, to не включительно),
+// "topics", "types", "limit", "speed" (2 — вдвое быстрее исходного темпа,
+// 0 — без пауз) и "max_gap" (наибольшая пауза).
+func parseReplay(r *http.Request) (replayRequest, error) {
+	q := r.URL.Query()
+	var req replayRequest
+	var err error
+	for name, dst := range map[string]*uint64{"from_seq": &req.query.FromSeq, "to_seq": &req.query.ToSeq} {
+		if v := q.Get(name); v != "" {
+			if *dst, err = strconv.ParseUint(v, 10, 64); err != nil {
+				return req, errors.New("invalid " + name)
+			}
+		}
+	}
+	for name, dst := range map[string]*time.Time{"from": &req.query.Since, "to": &req.query.Until} {
+		if v := q.Get(name); v != "" {
+			if *dst, err = time.Parse(time.RFC3339Nano, v); err != nil {
+				return req, errors.New("invalid " + name + ": want RFC 3339 time")
+			}
+		}
+	}
+	if req.query.FromSeq == 0 && req.query.ToSeq == 0 && req.query.Since.IsZero() && req.query.Until.IsZero() {
+		return req, errors.New("a range is required: from_seq, to_seq, from or to")
+	}
+	if req.query.Topics, err = parseTopics(r); err != nil {
+		return req, err
+	}
+	if v := q.Get("types"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				req.query.Types = append(req.query.Types, t)
+			}
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if req.query.Limit, err = strconv.Atoi(v); err != nil || req.query.Limit < 0 {
+			return req, errors.New("invalid limit")
+		}
+	}
+	if v := q.Get("speed"); v != "" {
+		if req.speed, err = strconv.ParseFloat(v, 64); err != nil || req.speed < 0 {
+			return req, errors.New("invalid speed")
+		}
+	}
+	if v := q.Get("max_gap"); v != "" {
+		if req.maxGap, err = time.ParseDuration(v); err != nil || req.maxGap < 0 {
+			return req, errors.New("invalid max_gap")
+		}
+	}
+	return req, nil
+}
+
+// Replay отдаёт запросившему события истории за диапазон потоком NDJSON с
+// заданным темпом; с "format=cloudevents" — в формате CloudEvents. Субъект
+// видит только своё пространство имён и разрешённые ему топики.
+func (h *Handler) Replay(w http.ResponseWriter, r *http.Request) {
+	req, err := parseReplay(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	format, err := parseEventFormat(r)
+	if err == nil {
+		err = streamOnly(format)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	if req.query.Namespace, err = resolveNamespace(r, principal); err != nil {
+		writeNamespaceError(w, err)
+		return
+	}
+	if len(req.query.Topics) == 0 {
+		req.query.Topics = principal.Topics
+	}
+	if err := authorizeTopics(principal, req.query.Topics); err != nil {
+		writeError(w, http.StatusForbidden, "forbidden", err.Error())
+		return
+	}
+	events, err := h.History.Range(req.query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Replay-Events", strconv.Itoa(len(events)))
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	sent, err := history.Pace(r.Context(), events, req.speed, req.maxGap, func(event domain.Event) error {
+		var line any = event
+		if format == FormatCloudEvents {
+			ce, err := cloudevents.FromDomain(event)
+			if err != nil {
+				return err
+			}
+			line = ce
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		h.Logger.Warn("Replay stream interrupted", "sent", sent, "total", len(events), "error", err)
+		return
+	}
+	h.Logger.Info("Replay streamed", "namespace", req.query.Namespace, "events", sent)
+}
+
+// replayEvents повторно рассылает события истории за диапазон всем
+// подписчикам пространства имён. Рассылка идёт в фоне с заданным темпом;
+// ответ сообщает число событий.
+func (h *AdminHandler) replayEvents(w http.ResponseWriter, r *http.Request) {
+	req, err := parseReplay(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	var ok bool
+	if req.query.Namespace, ok = requestNamespace(w, r); !ok {
+		return
+	}
+	events, err := h.History.Range(req.query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	go func() {
+		ctx := context.WithoutCancel(r.Context())
+		sent, _ := history.Pace(ctx, events, req.speed, req.maxGap, func(event domain.Event) error {
+			h.Events.Replay(event)
+			return nil
+		})
+		h.Logger.Info("Replay broadcast finished", "namespace", req.query.Namespace, "events", sent)
+	}()
+	h.Logger.Info("Replay broadcast started", "namespace", req.query.Namespace, "events", len(events), "by", principal.KeyID)
+	writeJSON(w, http.StatusAccepted, map[string]any{"namespace": req.query.Namespace, "events": len(events)})
+}
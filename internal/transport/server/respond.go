@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/wrongjunior/eventsync/internal/auth"
+	"github.com/wrongjunior/eventsync/internal/quota"
+	"github.com/wrongjunior/eventsync/internal/version"
+)
+
+// errorBody — структурированный ответ об ошибке HTTP API.
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// writeJSON сериализует v в тело ответа с указанным статусом.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// serveVersion отдаёт сведения о сборке сервера.
+func serveVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, version.Get())
+}
+
+// writeError отправляет структурированную ошибку с машинно-читаемым кодом.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorBody{Error: errorDetail{Code: code, Message: message}})
+}
+
+// writeNamespaceError отвечает на ошибку resolveNamespace: 403, если параметр
+// namespace расходится с тенантом ключа, и 400 для некорректного имени.
+func writeNamespaceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, auth.ErrForbidden) {
+		writeError(w, http.StatusForbidden, "forbidden", err.Error())
+		return
+	}
+	writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+}
+
+// writeQuotaError отправляет 429 с описанием превышенной квоты.
+func writeQuotaError(w http.ResponseWriter, err error) {
+	var qe *quota.ExceededError
+	if !errors.As(err, &qe) {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusTooManyRequests, errorBody{Error: errorDetail{
+		Code:    "quota_exceeded",
+		Message: qe.Error(),
+		Details: qe,
+	}})
+}
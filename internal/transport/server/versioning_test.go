@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/wrongjunior/eventsync/internal/domain"
+	"github.com/wrongjunior/eventsync/internal/schema"
+	eservice "github.com/wrongjunior/eventsync/internal/service"
+)
+
+const (
+	orderV1 = `{"type": "object", "properties": {"data": {"type": "object", "required": ["id"],
+		"properties": {"id": {"type": "number"}}, "additionalProperties": false}}}`
+	orderV2 = `{"type": "object", "properties": {"data": {"type": "object", "required": ["id"],
+		"properties": {"id": {"type": "number"}, "discount": {"type": "number"}}, "additionalProperties": false}}}`
+)
+
+// TestSchemaDowngradeForOldSubscriber проверяет весь путь версионирования:
+// версии схем регистрируются через административный API, событие
+// публикуется в последней версии, а подписчик, понимающий только версию 1,
+// получает его понижённым преобразованием версии 2.
+func TestSchemaDowngradeForOldSubscriber(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	es := eservice.NewEventService(logger)
+	defer es.Shutdown()
+	registry := schema.NewRegistry()
+	registry.RegisterDowngrade("order", 2, schema.DropFields("discount"))
+	es.SetVersioning(registry)
+	es.SetValidation(registry, nil)
+	srv := httptest.NewServer(SetupRouter(es, logger, RouterConfig{WSPath: "/ws", AdminKey: "secret", Schemas: registry}))
+	defer srv.Close()
+
+	request := func(method, path, body string) int {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-API-Key", "secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	// Версии регистрируются по порядку.
+	if got := request(http.MethodPut, "/admin/schemas/order/versions/2", orderV1); got != http.StatusBadRequest {
+		t.Fatalf("PUT v2 before v1 = %d, want 400", got)
+	}
+	if got := request(http.MethodPut, "/admin/schemas/order/versions/1", orderV1); got != http.StatusCreated {
+		t.Fatalf("PUT v1 = %d", got)
+	}
+	if got := request(http.MethodPut, "/admin/schemas/order/versions/2", orderV2); got != http.StatusCreated {
+		t.Fatalf("PUT v2 = %d", got)
+	}
+	if got := request(http.MethodPut, "/admin/schemas/order/versions/2", orderV2); got != http.StatusConflict {
+		t.Fatalf("repeated PUT v2 = %d, want 409", got)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?api_key=secret&schema_versions=order:1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Подписчик регистрируется после рукопожатия; публикация до этого
+	// не дошла бы до него.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(es.Clients("")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := request(http.MethodPost, "/events", `{"type": "order", "data": {"id": 7, "discount": 5}}`); got != http.StatusAccepted {
+		t.Fatalf("publish = %d", got)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("event not delivered: %v", err)
+		}
+		kind, payload, err := domain.DecodeFrame(msg)
+		if err != nil || kind != domain.FrameKindEvent {
+			continue
+		}
+		var event domain.Event
+		if err := json.Unmarshal(payload, &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Version != 1 || string(event.Data) != `{"id":7}` {
+			t.Fatalf("old subscriber got version %d data %s, want version 1 data {\"id\":7}", event.Version, event.Data)
+		}
+		return
+	}
+}
@@ -0,0 +1,170 @@
+// Package upgrade реализует перезапуск сервера без закрытия слушающих
+// сокетов: новый процесс получает их дескрипторы от старого, сообщает о
+// готовности, и только после этого старый процесс перестаёт принимать
+// подключения и постепенно отключает своих клиентов.
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"log/slog"
+
+	"github.com/wrongjunior/eventsync/internal/systemd"
+)
+
+// Переменные окружения, через которые старый процесс передаёт новому
+// имена сокетов и дескриптор канала готовности.
+const (
+	envFDs   = "EVENTSYNC_UPGRADE_FDS"
+	envReady = "EVENTSYNC_UPGRADE_READY"
+)
+
+// firstFD — номер первого переданного дескриптора (после stdin/stdout/stderr).
+const firstFD = 3
+
+// DefaultReadyTimeout — ожидание готовности нового процесса по умолчанию.
+const DefaultReadyTimeout = time.Minute
+
+// Inherited возвращает сокеты, переданные процессом, который запустил
+// текущий для замены себя, в порядке передачи. Переменные передачи
+// удаляются из окружения. Без передачи возвращает nil.
+func Inherited() ([]systemd.Listener, error) {
+	value, ok := os.LookupEnv(envFDs)
+	os.Unsetenv(envFDs)
+	if !ok {
+		return nil, nil
+	}
+	names := strings.Split(value, ":")
+	listeners := make([]systemd.Listener, 0, len(names))
+	for i, name := range names {
+		fd := firstFD + i
+		syscall.CloseOnExec(fd)
+		f := os.NewFile(uintptr(fd), "UPGRADE_FD_"+strconv.Itoa(fd))
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("inherited socket %q: %w", name, err)
+		}
+		listeners = append(listeners, systemd.Listener{Listener: ln, Name: name})
+	}
+	return listeners, nil
+}
+
+// Upgraded сообщает, запущен ли процесс на замену другому; проверяется до
+// Ready.
+func Upgraded() bool {
+	_, ok := os.LookupEnv(envReady)
+	return ok
+}
+
+// Ready сообщает старому процессу, что новый принимает подключения. Вне
+// перезапуска ничего не делает.
+func Ready() error {
+	value, ok := os.LookupEnv(envReady)
+	os.Unsetenv(envReady)
+	if !ok {
+		return nil
+	}
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %q", envReady, value)
+	}
+	f := os.NewFile(uintptr(fd), "upgrade-ready")
+	defer f.Close()
+	_, err = f.Write([]byte{1})
+	return err
+}
+
+// Spawn запускает новый экземпляр исполняемого файла с теми же аргументами
+// и передаёт ему сокеты listeners. Возвращается, когда новый процесс
+// сообщил о готовности через Ready; если он завершился раньше или не успел
+// за timeout, процесс останавливается и возвращается ошибка — старый
+// процесс при этом продолжает работу.
+func Spawn(listeners []systemd.Listener, timeout time.Duration, logger *slog.Logger) (*os.Process, error) {
+	if timeout <= 0 {
+		timeout = DefaultReadyTimeout
+	}
+	path, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(listeners))
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, ln := range listeners {
+		fl, ok := ln.Listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, fmt.Errorf("socket %q cannot be passed", ln.Name)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return nil, fmt.Errorf("socket %q: %w", ln.Name, err)
+		}
+		names = append(names, ln.Name)
+		files = append(files, f)
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer readyR.Close()
+	files = append(files, readyW)
+
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		envFDs+"="+strings.Join(names, ":"),
+		envReady+"="+strconv.Itoa(firstFD+len(files)-1),
+	)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	// Копия конца записи остаётся только у нового процесса: если он
+	// завершится, не сообщив о готовности, чтение вернёт EOF.
+	readyW.Close()
+	files = files[:len(files)-1]
+	logger.Info("Started new server process", "pid", cmd.Process.Pid, "path", path)
+
+	result := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		if _, err := readyR.Read(buf); err != nil {
+			result <- errors.New("new process exited before becoming ready")
+			return
+		}
+		result <- nil
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err = <-result:
+	case <-timer.C:
+		err = fmt.Errorf("new process not ready after %s", timeout)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	// Новый процесс переживает старый; ожидание лишь собирает его статус,
+	// если старый ещё работает.
+	go cmd.Wait()
+	return cmd.Process, nil
+}
@@ -0,0 +1,85 @@
+// Package version хранит сведения о сборке, задаваемые при компоновке:
+//
+//	go build -ldflags "-X github.com/wrongjunior/eventsync/internal/version.Version=v1.4.0 \
+//	  -X github.com/wrongjunior/eventsync/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/wrongjunior/eventsync/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Незаданные коммит и дата берутся из сведений о VCS, которые go build
+// встраивает при сборке из рабочей копии git.
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Задаются через -ldflags "-X".
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info — сведения о сборке в ответе GET /version и команды version.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // собрано из рабочей копии с незафиксированными изменениями
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get возвращает сведения о текущей сборке.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range build.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = s.Value
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+	return info
+}
+
+// String возвращает сведения одной строкой для команды version и журнала.
+func (i Info) String() string {
+	s := i.Version
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if i.Modified {
+			commit += "-dirty"
+		}
+		s += " (" + commit
+		if i.BuildDate != "" {
+			s += ", " + i.BuildDate
+		}
+		s += ")"
+	}
+	return fmt.Sprintf("%s %s %s", s, i.GoVersion, i.Platform)
+}